@@ -0,0 +1,64 @@
+// Comando ingestd monitora os diretórios em que os registradores de campo depositam arquivos (SFTP) e
+// os importa com os loaders do pacote ingest. A configuração é lida de -config ou INGESTD_CONFIG_FILE.
+package main
+
+import (
+	"api/internal/configs"
+	"api/internal/qc"
+	"api/internal/store"
+	"api/internal/watch"
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	// Carrega as variáveis de ambiente
+	configs.LoadEnv()
+
+	configFile := flag.String("config", configs.GetIngestdConfigFile(), "arquivo JSON com os diretórios monitorados")
+	once := flag.Bool("once", false, "varre os diretórios uma única vez e termina")
+	flag.Parse()
+	if *configFile == "" {
+		log.Fatalln("Missing configuration: use -config or INGESTD_CONFIG_FILE")
+	}
+	cfg, err := watch.LoadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Unable to load ingestd configuration: %v\n", err)
+	}
+
+	// Conecta ao banco de dados usando um pool de conexões
+	conn, err := store.NewDB(configs.GetDatabaseURL())
+	if err != nil {
+		log.Fatalf("Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close()
+
+	// Usa as mesmas regras de controle de qualidade da API
+	if file := configs.GetQCConfigFile(); file != "" {
+		qcConfig, err := qc.LoadConfig(file)
+		if err != nil {
+			log.Fatalf("Unable to load QC configuration: %v\n", err)
+		}
+		qc.Active = qcConfig
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	w := watch.New(conn, cfg)
+	if *once {
+		if err := w.RunOnce(ctx); err != nil {
+			log.Fatalf("ingestd: %v\n", err)
+		}
+		return
+	}
+	log.Printf("ingestd watching %d directories every %s", len(cfg.Directories), cfg.PollInterval)
+	if err := w.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatalf("ingestd: %v\n", err)
+	}
+}
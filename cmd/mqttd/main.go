@@ -0,0 +1,49 @@
+// Comando mqttd assina os tópicos MQTT em que as estações publicam leituras e as grava nas tabelas de
+// dados com o pacote telemetry. A configuração é lida de -config ou MQTT_CONFIG_FILE. Para testes, o
+// serviço mosquitto do docker-compose oferece um broker local sem autenticação:
+//
+//	mosquitto_pub -h localhost -t stations/<equipment_id>/tower -q 1 \
+//	  -m '{"timestamp": "2024-01-01T00:10:00Z", "wind_speed": 7.2, "wind_direction": 184}'
+package main
+
+import (
+	"api/internal/configs"
+	"api/internal/store"
+	"api/internal/telemetry"
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	// Carrega as variáveis de ambiente
+	configs.LoadEnv()
+
+	configFile := flag.String("config", configs.GetMQTTConfigFile(), "arquivo JSON com o broker e as rotas")
+	flag.Parse()
+	if *configFile == "" {
+		log.Fatalln("Missing configuration: use -config or MQTT_CONFIG_FILE")
+	}
+	cfg, err := telemetry.LoadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Unable to load mqttd configuration: %v\n", err)
+	}
+
+	// Conecta ao banco de dados usando um pool de conexões
+	conn, err := store.NewDB(configs.GetDatabaseURL())
+	if err != nil {
+		log.Fatalf("Unable to connect to database: %v\n", err)
+	}
+	defer conn.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("mqttd connecting to %s with %d routes", cfg.Broker, len(cfg.Routes))
+	if err := telemetry.Subscribe(ctx, cfg, telemetry.NewIngestor(conn, cfg)); err != nil {
+		log.Fatalf("mqttd: %v\n", err)
+	}
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"api/internal/alerts"
+	"api/internal/availability"
 	"api/internal/configs"
 	"api/internal/handlers"
 	"api/internal/middleware"
+	"api/internal/qc"
 	"api/internal/store"
+	"api/internal/stream"
+	"context"
 	"log"
 	"net/http"
 
@@ -23,6 +28,37 @@ func main() {
 	}
 	defer conn.Close()
 
+	// Substitui as regras padrão de controle de qualidade, se configurado
+	if file := configs.GetQCConfigFile(); file != "" {
+		cfg, err := qc.LoadConfig(file)
+		if err != nil {
+			log.Fatalf("Unable to load QC configuration: %v\n", err)
+		}
+		qc.Active = cfg
+	}
+
+	// Detecção periódica de falhas de registro dos equipamentos
+	scanInterval, err := configs.GetAvailabilityScanInterval()
+	if err != nil {
+		log.Fatalf("Invalid AVAILABILITY_SCAN_INTERVAL: %v\n", err)
+	}
+	if scanInterval > 0 {
+		go availability.Schedule(context.Background(), conn, scanInterval, 2*scanInterval+availability.DefaultOutage, availability.DefaultOutage)
+	}
+
+	// Avaliação periódica das regras de alerta
+	alertInterval, err := configs.GetAlertEvaluationInterval()
+	if err != nil {
+		log.Fatalf("Invalid ALERT_EVALUATION_INTERVAL: %v\n", err)
+	}
+	if alertInterval > 0 {
+		go alerts.Schedule(context.Background(), conn, alertInterval)
+	}
+
+	// Avisos de dados novos e alertas (LISTEN/NOTIFY) para os streams ao vivo desta réplica
+	hub := stream.NewHub(conn)
+	go hub.Run(context.Background())
+
 	// Configura o roteador
 	r := chi.NewRouter()
 
@@ -146,6 +182,7 @@ func main() {
 			// Rotas de leitura para nível Avançado e superiores
 			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/", handlers.GetAllCampaigns(conn))
 			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/{id}", handlers.GetCampaignByID(conn))
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/{id}/bundle", handlers.GetCampaignBundle(conn))
 
 			// Rotas de modificação que exigem CSRF e nível Admin
 			r.With(middleware.AuthorizationMiddleware("administrador_campanhas")).With(middleware.ValidateCSRFToken).Post("/", handlers.CreateCampaign(conn))
@@ -158,11 +195,36 @@ func main() {
 			// Rotas de leitura para nível Avançado e superiores
 			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/", handlers.GetAllEquipments(conn))
 			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/{id}", handlers.GetEquipmentByID(conn))
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/{id}/availability", handlers.GetEquipmentAvailability(conn))
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/{id}/responsibles", handlers.GetEquipmentResponsibles(conn))
 
 			// Rotas de modificação que exigem CSRF e nível Admin
 			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Post("/", handlers.CreateEquipment(conn))
 			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Put("/{id}", handlers.UpdateEquipment(conn))
 			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Delete("/{id}", handlers.DeleteEquipment(conn))
+			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Post("/{id}/availability/scan", handlers.ScanEquipmentAvailability(conn))
+			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Put("/{id}/responsibles", handlers.SetEquipmentResponsibles(conn))
+		})
+
+		// Rotas de regras de alerta e do histórico de disparos
+		r.Route("/alerts", func(r chi.Router) {
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/rules", handlers.GetAlertRules(conn))
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/firings", handlers.GetAlertFirings(conn))
+
+			// Rotas de modificação que exigem CSRF e nível Admin
+			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Post("/rules", handlers.CreateAlertRule(conn))
+			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Put("/rules/{id}", handlers.UpdateAlertRule(conn))
+			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Delete("/rules/{id}", handlers.DeleteAlertRule(conn))
+			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Post("/evaluate", handlers.EvaluateAlertRules(conn))
+		})
+
+		// Rotas dos jobs de importação de arquivos
+		r.Route("/ingestion/jobs", func(r chi.Router) {
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/", handlers.GetIngestionJobs(conn))
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/{id}", handlers.GetIngestionJob(conn))
+
+			// Remoção (desfaz a importação) exige CSRF e nível Admin
+			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Delete("/{id}", handlers.DeleteIngestionJob(conn))
 		})
 
 		// Rotas para Dados de Lidar Zephy
@@ -189,6 +251,55 @@ func main() {
 			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Delete("/{id}", handlers.DeleteLidarWindcobeData(conn))
 		})
 
+		// Rotas do LIDAR WindCube: listagem dos dados e importação de arquivos nativos (.sta/.rtd)
+		r.Route("/lidarwindcube", func(r chi.Router) {
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/", handlers.GetLIDARWindCubeDados(conn))
+			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Post("/upload", handlers.UploadLIDARWindCubeFile(conn))
+		})
+
+		// Rotas para importação de arquivos nativos do SODAR (.mnd/AQ500)
+		r.Route("/sodar", func(r chi.Router) {
+			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Post("/upload", handlers.UploadSODARFile(conn))
+		})
+
+		// Rotas para importação de arquivos binários PD0 do ADCP
+		r.Route("/adcp", func(r chi.Router) {
+			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Post("/upload", handlers.UploadADCPPD0File(conn))
+		})
+
+		// Rotas para séries temporais agregadas (TimescaleDB)
+		r.Route("/series", func(r chi.Router) {
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/{instrument}/aggregate", handlers.GetSeriesAggregate(conn))
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/{instrument}/export", handlers.ExportSeries(conn))
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/{instrument}/profile", handlers.GetWindProfile(conn))
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/qc", handlers.GetQCScheme)
+			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Post("/{instrument}/qc", handlers.RunSeriesQC(conn))
+		})
+
+		// Streams ao vivo (Server-Sent Events) das linhas e alertas de um equipamento
+		r.Route("/stream", func(r chi.Router) {
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/{instrument}", handlers.StreamInstrumentData(conn, hub))
+		})
+
+		// Rotas de análise de recurso (rosa dos ventos, Weibull, turbulência, geometria solar, irradiância, irradiação, MCP, intercomparação)
+		r.Route("/analytics", func(r chi.Router) {
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/windrose", handlers.GetWindRose(conn))
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/weibull", handlers.GetWeibull(conn))
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/turbulence", handlers.GetTurbulence(conn))
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/solar", handlers.GetSolarGeometry(conn))
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/irradiance", handlers.GetIrradiance(conn))
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/solar-energy", handlers.GetSolarEnergy(conn))
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/mcp", handlers.GetMCP(conn))
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/intercomparison", handlers.GetIntercomparison(conn))
+		})
+
+		// Rotas de séries de referência de longo prazo (reanálise, estações) usadas na correção MCP
+		r.Route("/reference-series", func(r chi.Router) {
+			r.With(middleware.AuthorizationMiddleware("colaborador")).Get("/", handlers.GetReferenceSeries(conn))
+			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Post("/upload", handlers.UploadReferenceSeries(conn))
+			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Delete("/{id}", handlers.DeleteReferenceSeries(conn))
+		})
+
 		// Rotas para Dados de Sodar
 		r.Route("/sodardata", func(r chi.Router) {
 			// Rotas de leitura para nível Avançado e superiores
@@ -269,6 +380,7 @@ func main() {
 
 			// Rotas de escrita para nível Admin e superiores
 			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Post("/", handlers.CreateEstacaoSolarimetricaDados(conn))
+			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Post("/upload", handlers.UploadEstacaoSolarimetricaTOA5(conn))
 			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Put("/{id}", handlers.UpdateEstacaoSolarimetricaDados(conn))
 			r.With(middleware.AuthorizationMiddleware("administrador_equipamentos")).With(middleware.ValidateCSRFToken).Delete("/{id}", handlers.DeleteEstacaoSolarimetricaDados(conn))
 		})
@@ -0,0 +1,246 @@
+// Package alerts avalia regras de alerta sobre as tabelas de dados dos equipamentos e notifica os
+// responsáveis (Notificacoes do tipo 'alerta'). Cada condição gera um único disparo aberto, que é
+// encerrado, com nova notificação, quando a condição deixa de valer.
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"api/internal/availability"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Tipos de regra
+const (
+	KindThreshold = "threshold" // Coluna comparada a um limiar em todos os registros da janela Duration
+	KindNoData    = "no_data"   // Nenhum registro na tabela há Duration
+)
+
+// Situação de um disparo
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// thresholdSlack amplia a janela lida nas regras de limiar para alcançar o registro anterior a ela
+const thresholdSlack = time.Hour
+
+// ErrInvalidRule indica uma regra com campos inválidos
+var ErrInvalidRule = errors.New("invalid alert rule")
+
+// levelColumns indica a coluna de nível das tabelas com um registro por altura ou célula
+var levelColumns = map[string]string{"sodardados": "height", "adcpdados": "cell"}
+
+// operators compara um valor ao limiar
+var operators = map[string]func(v, threshold float64) bool{
+	"<":  func(v, t float64) bool { return v < t },
+	"<=": func(v, t float64) bool { return v <= t },
+	">":  func(v, t float64) bool { return v > t },
+	">=": func(v, t float64) bool { return v >= t },
+}
+
+// Rule é uma regra de alerta
+type Rule struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	EquipmentID *string   `json:"equipment_id"` // Sem equipamento: todos os que têm dados na tabela
+	Table       string    `json:"table"`
+	Kind        string    `json:"kind"`
+	Column      *string   `json:"column"`
+	Operator    *string   `json:"operator"`
+	Threshold   *float64  `json:"threshold"`
+	Level       *float64  `json:"level"` // Altura ou célula; sem valor, basta um nível violar
+	Duration    int       `json:"duration_seconds"`
+	Enabled     bool      `json:"enabled"`
+	CreatedBy   *string   `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Validate normaliza os campos da regra e confere o equipamento, a tabela e a coluna
+func (r *Rule) Validate(ctx context.Context, db *pgxpool.Pool) error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Table = strings.ToLower(strings.TrimSpace(r.Table))
+	if r.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+	known := false
+	for _, t := range availability.Tables {
+		known = known || t == r.Table
+	}
+	if !known {
+		return fmt.Errorf("%w: table must be one of %s", ErrInvalidRule, strings.Join(availability.Tables, ", "))
+	}
+	if r.EquipmentID != nil {
+		id, err := uuid.Parse(*r.EquipmentID)
+		if err != nil {
+			return fmt.Errorf("%w: equipment_id must be a UUID", ErrInvalidRule)
+		}
+		*r.EquipmentID = id.String()
+		var exists bool
+		err = db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM equipments WHERE equipmentid = $1::uuid)", *r.EquipmentID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: equipment %s not found", ErrInvalidRule, *r.EquipmentID)
+		}
+	}
+	if r.Duration < 0 {
+		return fmt.Errorf("%w: duration_seconds must not be negative", ErrInvalidRule)
+	}
+
+	switch r.Kind {
+	case KindNoData:
+		if r.Duration == 0 {
+			return fmt.Errorf("%w: no_data rules need duration_seconds", ErrInvalidRule)
+		}
+		r.Column, r.Operator, r.Threshold, r.Level = nil, nil, nil, nil
+	case KindThreshold:
+		if r.Column == nil || r.Operator == nil || r.Threshold == nil {
+			return fmt.Errorf("%w: threshold rules need column, operator and threshold", ErrInvalidRule)
+		}
+		if _, ok := operators[*r.Operator]; !ok {
+			return fmt.Errorf("%w: operator must be <, <=, > or >=", ErrInvalidRule)
+		}
+		column := strings.ToLower(strings.TrimSpace(*r.Column))
+		r.Column = &column
+		if r.Level != nil && levelColumns[r.Table] == "" {
+			return fmt.Errorf("%w: %s has no levels", ErrInvalidRule, r.Table)
+		}
+		var exists bool
+		err := db.QueryRow(ctx, `
+			SELECT EXISTS(SELECT 1 FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2
+			  AND data_type IN ('double precision', 'real', 'integer', 'smallint', 'bigint', 'numeric'))`,
+			r.Table, column).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: %s has no numeric column %s", ErrInvalidRule, r.Table, column)
+		}
+	default:
+		return fmt.Errorf("%w: kind must be threshold or no_data", ErrInvalidRule)
+	}
+	return nil
+}
+
+// ruleSelect lista as colunas lidas por scanRule
+const ruleSelect = `
+	SELECT alertruleid::text, name, equipmentid::text, datatable, kind, columnname, operator, threshold,
+	       level, duration, enabled, createdby::text, createdat
+	FROM AlertRules`
+
+// scanRule lê uma regra de uma linha de ruleSelect
+func scanRule(row pgx.Row) (*Rule, error) {
+	var r Rule
+	err := row.Scan(&r.ID, &r.Name, &r.EquipmentID, &r.Table, &r.Kind, &r.Column, &r.Operator, &r.Threshold,
+		&r.Level, &r.Duration, &r.Enabled, &r.CreatedBy, &r.CreatedAt)
+	return &r, err
+}
+
+// queryRules lê as regras que satisfazem a condição (com os argumentos args)
+func queryRules(ctx context.Context, db *pgxpool.Pool, where string, args ...interface{}) ([]*Rule, error) {
+	rows, err := db.Query(ctx, ruleSelect+" "+where+" ORDER BY createdat", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rules := []*Rule{}
+	for rows.Next() {
+		r, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// ListRules retorna todas as regras
+func ListRules(ctx context.Context, db *pgxpool.Pool) ([]*Rule, error) {
+	return queryRules(ctx, db, "")
+}
+
+// GetRule retorna uma regra; pgx.ErrNoRows quando ela não existe
+func GetRule(ctx context.Context, db *pgxpool.Pool, id string) (*Rule, error) {
+	return scanRule(db.QueryRow(ctx, ruleSelect+" WHERE alertruleid = $1::uuid", id))
+}
+
+// condition é o estado da condição de uma regra para um equipamento
+type condition struct {
+	active bool
+	start  time.Time // Primeiro registro violando (threshold) ou último registro recebido (no_data)
+	value  *float64  // Último valor avaliado (threshold)
+}
+
+// check avalia a condição da regra para o equipamento. Retorna false quando não há dados para
+// avaliá-la, caso em que o estado anterior é mantido.
+func (r *Rule) check(ctx context.Context, db *pgxpool.Pool, equipmentID string, now time.Time) (condition, bool, error) {
+	// Os nomes de tabela e coluna foram validados contra availability.Tables e information_schema
+	if r.Kind == KindNoData {
+		var last *time.Time
+		err := db.QueryRow(ctx, fmt.Sprintf("SELECT max(timestamp) FROM %s WHERE equipmentid = $1::uuid", r.Table),
+			equipmentID).Scan(&last)
+		if err != nil || last == nil {
+			return condition{}, false, err
+		}
+		return condition{active: now.Sub(*last) >= time.Duration(r.Duration)*time.Second, start: *last}, true, nil
+	}
+
+	column, operator := *r.Column, *r.Operator
+	aggregate := "max"
+	if strings.HasPrefix(operator, "<") {
+		aggregate = "min"
+	}
+	duration := time.Duration(r.Duration) * time.Second
+	args := []interface{}{equipmentID, duration + thresholdSlack}
+	levelFilter := ""
+	if level := levelColumns[r.Table]; level != "" && r.Level != nil {
+		args = append(args, *r.Level)
+		levelFilter = fmt.Sprintf(" AND %s = $3", level)
+	}
+	rows, err := db.Query(ctx, fmt.Sprintf(`
+		SELECT timestamp, %[1]s(%[2]s)::float8 FROM %[3]s
+		WHERE equipmentid = $1::uuid AND %[2]s IS NOT NULL%[4]s
+		  AND timestamp >= (SELECT max(timestamp) FROM %[3]s WHERE equipmentid = $1::uuid AND %[2]s IS NOT NULL%[4]s) - $2::interval
+		GROUP BY timestamp ORDER BY timestamp DESC`, aggregate, column, r.Table, levelFilter), args...)
+	if err != nil {
+		return condition{}, false, err
+	}
+	defer rows.Close()
+
+	compare := operators[operator]
+	var c condition
+	var last time.Time
+	first := true
+	for rows.Next() {
+		var ts time.Time
+		var v float64
+		if err := rows.Scan(&ts, &v); err != nil {
+			return condition{}, false, err
+		}
+		if first {
+			last, c.value, first = ts, &v, false
+		}
+		if !compare(v, *r.Threshold) {
+			break
+		}
+		c.start = ts
+	}
+	if err := rows.Err(); err != nil {
+		return condition{}, false, err
+	}
+	if first {
+		return condition{}, false, nil
+	}
+	c.active = !c.start.IsZero() && last.Sub(c.start) >= duration
+	return c, true, nil
+}
@@ -0,0 +1,287 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"api/internal/stream"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Transition é uma mudança de situação de um disparo: aberto (firing) ou encerrado (resolved)
+type Transition struct {
+	RuleID      string `json:"rule_id"`
+	EquipmentID string `json:"equipment_id"`
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	Notified    int    `json:"notified"` // Usuários notificados
+}
+
+// Firing é um registro do histórico de disparos
+type Firing struct {
+	ID          string     `json:"id"`
+	RuleID      string     `json:"rule_id"`
+	RuleName    string     `json:"rule_name"`
+	EquipmentID string     `json:"equipment_id"`
+	Status      string     `json:"status"`
+	StartedAt   time.Time  `json:"started_at"`
+	FiredAt     time.Time  `json:"fired_at"`
+	ResolvedAt  *time.Time `json:"resolved_at"`
+	Value       *float64   `json:"value"`
+	Message     string     `json:"message"`
+	Revision    int64      `json:"revision"` // Cresce a cada mudança de situação
+}
+
+// message descreve a condição ativa de uma regra
+func (r *Rule) message(equipment string, c condition) string {
+	if r.Kind == KindNoData {
+		return fmt.Sprintf("%s: sem dados em %s desde %s", equipment, r.Table, c.start.UTC().Format(time.RFC3339))
+	}
+	target := *r.Column
+	if r.Level != nil {
+		target += " (nível " + strconv.FormatFloat(*r.Level, 'f', -1, 64) + ")"
+	}
+	return fmt.Sprintf("%s: %s %s %s desde %s (último valor %s)", equipment, target, *r.Operator,
+		strconv.FormatFloat(*r.Threshold, 'f', -1, 64), c.start.UTC().Format(time.RFC3339),
+		strconv.FormatFloat(*c.value, 'f', -1, 64))
+}
+
+// notify cria uma notificação do tipo 'alerta' para cada responsável pelo equipamento ou, sem
+// responsáveis cadastrados, para o autor da regra. Retorna a quantidade de usuários notificados.
+func (r *Rule) notify(ctx context.Context, tx pgx.Tx, equipmentID, title, message string) (int, error) {
+	rows, err := tx.Query(ctx, "SELECT id_usuario::text FROM EquipmentResponsibles WHERE equipmentid = $1::uuid", equipmentID)
+	if err != nil {
+		return 0, err
+	}
+	users, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, err
+	}
+	if len(users) == 0 && r.CreatedBy != nil {
+		users = []string{*r.CreatedBy}
+	}
+	for _, user := range users {
+		var id string
+		err := tx.QueryRow(ctx, `
+			INSERT INTO Notificacoes (titulo, mensagem, tipo, id_usuario, enviado_para_todos)
+			VALUES ($1, $2, 'alerta', $3, false) RETURNING id_notificacao`, title, message, user).Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO NotificacoesUsuarios (id_notificacao, id_usuario, lida, oculta)
+			VALUES ($1, $2, false, false)`, id, user)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(users), nil
+}
+
+// Evaluate avalia a regra para o equipamento: abre um disparo e notifica quando a condição passa a
+// valer sem disparo aberto, e encerra o disparo aberto, notificando a normalização, quando ela deixa
+// de valer. Retorna nil quando nada muda.
+func (r *Rule) Evaluate(ctx context.Context, db *pgxpool.Pool, equipmentID string, now time.Time) (*Transition, error) {
+	c, ok, err := r.check(ctx, db, equipmentID, now)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var equipment string
+	if err := tx.QueryRow(ctx, "SELECT equipmentname FROM equipments WHERE equipmentid = $1::uuid", equipmentID).Scan(&equipment); err != nil {
+		return nil, err
+	}
+	var openID string
+	err = tx.QueryRow(ctx, `
+		SELECT alertfiringid::text FROM AlertFirings
+		WHERE alertruleid = $1::uuid AND equipmentid = $2::uuid AND status = 'firing'
+		FOR UPDATE`, r.ID, equipmentID).Scan(&openID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	t := &Transition{RuleID: r.ID, EquipmentID: equipmentID}
+	switch {
+	case c.active && openID == "":
+		t.Status, t.Message = StatusFiring, r.message(equipment, c)
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO AlertFirings (alertruleid, equipmentid, status, startedat, firedat, value, message)
+			VALUES ($1, $2, 'firing', $3, $4, $5, $6)
+			ON CONFLICT (alertruleid, equipmentid) WHERE status = 'firing' DO NOTHING`,
+			r.ID, equipmentID, c.start, now, c.value, t.Message)
+		if err != nil {
+			return nil, err
+		}
+		if tag.RowsAffected() == 0 {
+			// Outra avaliação concorrente abriu o disparo
+			return nil, nil
+		}
+		if t.Notified, err = r.notify(ctx, tx, equipmentID, "Alerta: "+r.Name, t.Message); err != nil {
+			return nil, err
+		}
+	case !c.active && openID != "":
+		t.Status = StatusResolved
+		t.Message = fmt.Sprintf("%s: condição normalizada em %s", equipment, now.UTC().Format(time.RFC3339))
+		_, err := tx.Exec(ctx, `
+			UPDATE AlertFirings SET status = 'resolved', resolvedat = $2, value = COALESCE($3, value),
+			       revision = nextval('alertfirings_revision_seq')
+			WHERE alertfiringid = $1::uuid`, openID, now, c.value)
+		if err != nil {
+			return nil, err
+		}
+		if t.Notified, err = r.notify(ctx, tx, equipmentID, "Normalizado: "+r.Name, t.Message); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+
+	if err := stream.Notify(ctx, tx, stream.AlertsTable, equipmentID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// targets retorna os equipamentos avaliados pela regra: o da regra ou os que têm dados na tabela
+func (r *Rule) targets(ctx context.Context, db *pgxpool.Pool) ([]string, error) {
+	if r.EquipmentID != nil {
+		return []string{*r.EquipmentID}, nil
+	}
+	rows, err := db.Query(ctx, fmt.Sprintf(`
+		SELECT e.equipmentid::text FROM equipments e
+		WHERE EXISTS (SELECT 1 FROM %s d WHERE d.equipmentid = e.equipmentid)`, r.Table))
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// EvaluateEquipment avalia as regras ativas de uma tabela que se aplicam ao equipamento; usado após
+// cada importação
+func EvaluateEquipment(ctx context.Context, db *pgxpool.Pool, table, equipmentID string) ([]*Transition, error) {
+	rules, err := queryRules(ctx, db, `
+		WHERE enabled AND datatable = $1 AND (equipmentid IS NULL OR equipmentid = $2::uuid)`,
+		table, equipmentID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	var transitions []*Transition
+	for _, r := range rules {
+		t, err := r.Evaluate(ctx, db, equipmentID, now)
+		if err != nil {
+			return transitions, fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+		if t != nil {
+			transitions = append(transitions, t)
+		}
+	}
+	return transitions, nil
+}
+
+// EvaluateAll avalia todas as regras ativas em todos os equipamentos a que se aplicam. Erros de uma
+// regra são registrados no log e não interrompem as demais.
+func EvaluateAll(ctx context.Context, db *pgxpool.Pool) ([]*Transition, error) {
+	rules, err := queryRules(ctx, db, "WHERE enabled")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	transitions := []*Transition{}
+	for _, r := range rules {
+		equipments, err := r.targets(ctx, db)
+		if err != nil {
+			log.Printf("alerts: failed to list equipments of rule %s: %v", r.ID, err)
+			continue
+		}
+		for _, id := range equipments {
+			t, err := r.Evaluate(ctx, db, id, now)
+			if err != nil {
+				log.Printf("alerts: rule %s on equipment %s failed: %v", r.ID, id, err)
+				continue
+			}
+			if t != nil {
+				transitions = append(transitions, t)
+			}
+		}
+	}
+	return transitions, nil
+}
+
+// Schedule avalia todas as regras a cada every até ctx ser cancelado
+func Schedule(ctx context.Context, db *pgxpool.Pool, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := EvaluateAll(ctx, db); err != nil {
+			log.Println("alerts: evaluation failed:", err)
+		}
+	}
+}
+
+// ListFirings retorna o histórico de disparos, do mais recente ao mais antigo, filtrado pela regra,
+// pelo equipamento e pela situação quando informados
+func ListFirings(ctx context.Context, db *pgxpool.Pool, ruleID, equipmentID, status string, limit int) ([]*Firing, error) {
+	return queryFirings(ctx, db, `
+		WHERE ($1::uuid IS NULL OR f.alertruleid = $1) AND ($2::uuid IS NULL OR f.equipmentid = $2)
+		  AND ($3 = '' OR f.status = $3)
+		ORDER BY f.firedat DESC LIMIT $4`, optionalID(ruleID), optionalID(equipmentID), status, limit)
+}
+
+// optionalID converte um identificador vazio em NULL
+func optionalID(id string) interface{} {
+	if id == "" {
+		return nil
+	}
+	return id
+}
+
+// FiringsSince retorna, em ordem de revisão, os disparos do equipamento que mudaram depois da revisão
+// after até a revisão until, inclusive
+func FiringsSince(ctx context.Context, db *pgxpool.Pool, equipmentID string, after, until int64, limit int) ([]*Firing, error) {
+	return queryFirings(ctx, db, `
+		WHERE f.equipmentid = $1::uuid AND f.revision > $2 AND f.revision <= $3
+		ORDER BY f.revision LIMIT $4`, equipmentID, after, until, limit)
+}
+
+// queryFirings lê os disparos, com o nome da regra, que atendem à cláusula informada
+func queryFirings(ctx context.Context, db *pgxpool.Pool, where string, args ...interface{}) ([]*Firing, error) {
+	rows, err := db.Query(ctx, `
+		SELECT f.alertfiringid::text, f.alertruleid::text, r.name, f.equipmentid::text, f.status, f.startedat,
+		       f.firedat, f.resolvedat, f.value, f.message, f.revision
+		FROM AlertFirings f JOIN AlertRules r ON r.alertruleid = f.alertruleid `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	firings := []*Firing{}
+	for rows.Next() {
+		var f Firing
+		if err := rows.Scan(&f.ID, &f.RuleID, &f.RuleName, &f.EquipmentID, &f.Status, &f.StartedAt,
+			&f.FiredAt, &f.ResolvedAt, &f.Value, &f.Message, &f.Revision); err != nil {
+			return nil, err
+		}
+		firings = append(firings, &f)
+	}
+	return firings, rows.Err()
+}
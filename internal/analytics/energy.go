@@ -0,0 +1,259 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// EnergySample é a energia (Wh/m²) do intervalo de integração que termina em Time; NaN quando falta
+type EnergySample struct {
+	Time   time.Time
+	Energy float64
+}
+
+// Gap é uma sequência de intervalos sem dado e sem preenchimento
+type Gap struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Slots int       `json:"slots"`
+}
+
+// EnergyPeriod é a irradiação integrada de um dia ou mês
+type EnergyPeriod struct {
+	Start        time.Time `json:"start"`
+	Total        float64   `json:"total_kwh_m2"`                // Irradiação dos intervalos medidos e preenchidos (kWh/m²)
+	Estimated    *float64  `json:"estimated_kwh_m2"`            // Total extrapolado para o período completo, quando há falhas
+	Expected     int       `json:"expected"`                    // Intervalos esperados no período
+	Measured     int       `json:"measured"`                    // Intervalos com dado
+	Filled       int       `json:"filled"`                      // Intervalos preenchidos por interpolação
+	Completeness float64   `json:"completeness"`                // (medidos + preenchidos) / esperados (%)
+	Gaps         []Gap     `json:"gaps,omitempty"`              // Falhas longas, não preenchidas
+	Days         int       `json:"days,omitempty"`              // Meses: dias com dado
+	MeanDaily    *float64  `json:"mean_daily_kwh_m2,omitempty"` // Meses: média diária (kWh/m²)
+	daily        []float64 // Meses: totais dos dias completos, para o ano típico
+}
+
+// energySlot é um intervalo da grade regular de integração
+type energySlot struct {
+	start  time.Time
+	value  float64
+	filled bool
+}
+
+// IntegrateDaily distribui as amostras (ordenadas) na grade de passo step, interpola linearmente
+// falhas de até maxFill intervalos entre dois valores medidos e soma a irradiação por dia civil em loc
+func IntegrateDaily(samples []EnergySample, step time.Duration, loc *time.Location, maxFill int) []EnergyPeriod {
+	if len(samples) == 0 || step <= 0 {
+		return nil
+	}
+	first := samples[0].Time.Add(-step).In(loc)
+	origin := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc)
+	last := samples[len(samples)-1].Time.Add(-step).In(loc)
+	end := time.Date(last.Year(), last.Month(), last.Day()+1, 0, 0, 0, 0, loc)
+
+	slots := make([]energySlot, int(end.Sub(origin)/step))
+	for i := range slots {
+		slots[i] = energySlot{start: origin.Add(time.Duration(i) * step), value: math.NaN()}
+	}
+	for _, s := range samples {
+		// O timestamp marca o fim do intervalo
+		i := int(math.Round(float64(s.Time.Sub(origin))/float64(step))) - 1
+		if i >= 0 && i < len(slots) && !math.IsNaN(s.Energy) {
+			slots[i].value = s.Energy
+		}
+	}
+	fillGaps(slots, maxFill)
+
+	var days []EnergyPeriod
+	var gap *Gap
+	for _, slot := range slots {
+		local := slot.start.In(loc)
+		dayStart := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		if len(days) == 0 || !days[len(days)-1].Start.Equal(dayStart) {
+			gap = nil
+			days = append(days, EnergyPeriod{Start: dayStart})
+		}
+		day := &days[len(days)-1]
+		day.Expected++
+		if math.IsNaN(slot.value) {
+			if gap == nil {
+				day.Gaps = append(day.Gaps, Gap{Start: slot.start})
+				gap = &day.Gaps[len(day.Gaps)-1]
+			}
+			gap.End = slot.start.Add(step)
+			gap.Slots++
+			continue
+		}
+		gap = nil
+		if slot.filled {
+			day.Filled++
+		} else {
+			day.Measured++
+		}
+		day.Total += slot.value / 1000
+	}
+	for i := range days {
+		days[i].complete()
+	}
+	return days
+}
+
+// fillGaps interpola linearmente as sequências de até maxFill intervalos vazios entre dois valores
+func fillGaps(slots []energySlot, maxFill int) {
+	previous := -1
+	for i, slot := range slots {
+		if math.IsNaN(slot.value) {
+			continue
+		}
+		if missing := i - previous - 1; previous >= 0 && missing > 0 && missing <= maxFill {
+			a, b := slots[previous].value, slot.value
+			for j := previous + 1; j < i; j++ {
+				f := float64(j-previous) / float64(i-previous)
+				slots[j].value, slots[j].filled = a+(b-a)*f, true
+			}
+		}
+		previous = i
+	}
+}
+
+// complete calcula a completude e a estimativa extrapolada do período
+func (p *EnergyPeriod) complete() {
+	if p.Expected == 0 {
+		return
+	}
+	available := p.Measured + p.Filled
+	p.Completeness = 100 * float64(available) / float64(p.Expected)
+	if available > 0 && available < p.Expected {
+		estimated := p.Total * float64(p.Expected) / float64(available)
+		p.Estimated = &estimated
+	}
+}
+
+// Monthly agrega os dias por mês civil. Dias com completude abaixo de minCompleteness (%) entram no
+// total, mas não na média diária nem na distribuição usada pelo ano típico.
+func Monthly(days []EnergyPeriod, minCompleteness float64) []EnergyPeriod {
+	var months []EnergyPeriod
+	var gridDays []int
+	for _, day := range days {
+		monthStart := time.Date(day.Start.Year(), day.Start.Month(), 1, 0, 0, 0, 0, day.Start.Location())
+		if len(months) == 0 || !months[len(months)-1].Start.Equal(monthStart) {
+			months = append(months, EnergyPeriod{Start: monthStart})
+			gridDays = append(gridDays, 0)
+		}
+		month := &months[len(months)-1]
+		gridDays[len(gridDays)-1]++
+		month.Total += day.Total
+		month.Expected += day.Expected
+		month.Measured += day.Measured
+		month.Filled += day.Filled
+		month.Gaps = append(month.Gaps, day.Gaps...)
+		if day.Measured+day.Filled > 0 {
+			month.Days++
+		}
+		if day.Completeness >= minCompleteness {
+			month.daily = append(month.daily, day.Total)
+		}
+	}
+	for i := range months {
+		m := &months[i]
+		// Meses parciais no início ou no fim da série: os intervalos esperados cobrem o mês inteiro
+		next := time.Date(m.Start.Year(), m.Start.Month()+1, 1, 0, 0, 0, 0, m.Start.Location())
+		monthDays := int(math.Round(next.Sub(m.Start).Hours() / 24))
+		if gridDays[i] < monthDays {
+			m.Expected = int(math.Round(float64(m.Expected) * float64(monthDays) / float64(gridDays[i])))
+		}
+		m.complete()
+		if len(m.daily) > 0 {
+			var sum float64
+			for _, v := range m.daily {
+				sum += v
+			}
+			mean := sum / float64(len(m.daily))
+			m.MeanDaily = &mean
+		}
+	}
+	return months
+}
+
+// TypicalMonth é o mês escolhido para o ano típico de um mês do calendário
+type TypicalMonth struct {
+	Month      int             `json:"month"`
+	Year       *int            `json:"year"`         // Ano escolhido; nil sem candidatos suficientes
+	Statistic  *float64        `json:"fs_statistic"` // Estatística de Finkelstein-Schafer do ano escolhido
+	Total      *float64        `json:"total_kwh_m2"`
+	Candidates map[int]float64 `json:"candidates"` // Estatística FS por ano candidato
+}
+
+// TypicalYear é o resumo do ano meteorológico típico
+type TypicalYear struct {
+	Complete bool           `json:"complete"`               // Todos os meses do calendário foram escolhidos
+	Total    *float64       `json:"total_kwh_m2,omitempty"` // Soma dos meses escolhidos, quando completo
+	Months   []TypicalMonth `json:"months"`
+}
+
+// SelectTypicalYear escolhe, para cada mês do calendário com ao menos minYears meses candidatos
+// (completude >= minCompleteness), o ano cuja distribuição de irradiação diária mais se aproxima da
+// distribuição de longo prazo pela estatística de Finkelstein-Schafer (método de Sandia, só com a
+// irradiação global)
+func SelectTypicalYear(months []EnergyPeriod, minCompleteness float64, minYears int) TypicalYear {
+	candidates := map[time.Month][]EnergyPeriod{}
+	for _, m := range months {
+		if m.Completeness >= minCompleteness && len(m.daily) > 0 {
+			candidates[m.Start.Month()] = append(candidates[m.Start.Month()], m)
+		}
+	}
+
+	tmy := TypicalYear{Complete: true}
+	var total float64
+	for month := time.January; month <= time.December; month++ {
+		typical := TypicalMonth{Month: int(month), Candidates: map[int]float64{}}
+		if len(candidates[month]) < minYears {
+			tmy.Complete = false
+			tmy.Months = append(tmy.Months, typical)
+			continue
+		}
+		var longTerm []float64
+		for _, m := range candidates[month] {
+			longTerm = append(longTerm, m.daily...)
+		}
+		sort.Float64s(longTerm)
+
+		for _, m := range candidates[month] {
+			fs := finkelsteinSchafer(m.daily, longTerm)
+			typical.Candidates[m.Start.Year()] = fs
+			if typical.Statistic == nil || fs < *typical.Statistic {
+				year, statistic := m.Start.Year(), fs
+				// O total do mês típico é o extrapolado quando o candidato tem falhas
+				monthTotal := m.Total
+				if m.Estimated != nil {
+					monthTotal = *m.Estimated
+				}
+				typical.Year, typical.Statistic, typical.Total = &year, &statistic, &monthTotal
+			}
+		}
+		total += *typical.Total
+		tmy.Months = append(tmy.Months, typical)
+	}
+	if tmy.Complete {
+		tmy.Total = &total
+	}
+	return tmy
+}
+
+// finkelsteinSchafer retorna a média das diferenças absolutas entre a distribuição acumulada empírica
+// da amostra e a de longo prazo (ordenada), avaliadas nos valores da amostra
+func finkelsteinSchafer(sample, longTerm []float64) float64 {
+	sorted := append([]float64(nil), sample...)
+	sort.Float64s(sorted)
+	var sum float64
+	for _, v := range sorted {
+		sum += math.Abs(empiricalCDF(sorted, v) - empiricalCDF(longTerm, v))
+	}
+	return sum / float64(len(sorted))
+}
+
+// empiricalCDF retorna a fração dos valores ordenados menores ou iguais a v
+func empiricalCDF(sorted []float64, v float64) float64 {
+	return float64(sort.Search(len(sorted), func(i int) bool { return sorted[i] > v })) / float64(len(sorted))
+}
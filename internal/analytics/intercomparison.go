@@ -0,0 +1,105 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+)
+
+// Regression é a reta ajustada entre o instrumento em teste (y) e a referência (x)
+type Regression struct {
+	Slope       float64 `json:"slope"`
+	Offset      float64 `json:"offset"`
+	R2          float64 `json:"r2"`
+	SlopeOrigin float64 `json:"slope_origin"` // Inclinação da reta forçada pela origem
+}
+
+// DensityBin é uma célula não vazia do diagrama de dispersão
+type DensityBin struct {
+	Reference float64 `json:"reference"` // Centro da classe da referência
+	Test      float64 `json:"test"`      // Centro da classe do instrumento em teste
+	Count     int     `json:"count"`
+}
+
+// Comparison compara uma grandeza medida por dois instrumentos nos mesmos instantes
+type Comparison struct {
+	Regression *Regression  `json:"regression"`
+	Errors     *ErrorStats  `json:"errors"` // Teste menos referência: MBE é o viés
+	Density    []DensityBin `json:"density"`
+}
+
+// Compare ajusta a reta, os erros e o diagrama de densidade (classes de largura binWidth) entre os pares
+// de referência e teste. Com circular, as grandezas são direções (°): a diferença é reduzida a
+// [-180, 180) e o teste é desenrolado em torno da referência antes do ajuste. Retorna nil sem pares.
+func Compare(reference, test []float64, binWidth float64, circular bool) *Comparison {
+	var x, y []float64
+	var errs ErrorAccumulator
+	cells := map[[2]int]int{}
+	for i := range reference {
+		ref, t := reference[i], test[i]
+		if math.IsNaN(ref) || math.IsNaN(t) {
+			continue
+		}
+		unwrapped := t
+		if circular {
+			ref = math.Mod(math.Mod(ref, 360)+360, 360)
+			unwrapped = ref + AngleDifference(t, ref)
+			t = math.Mod(math.Mod(t, 360)+360, 360)
+		}
+		x, y = append(x, ref), append(y, unwrapped)
+		errs.Add(unwrapped, ref)
+		cells[[2]int{int(math.Floor(ref / binWidth)), int(math.Floor(t / binWidth))}]++
+	}
+	if len(x) == 0 {
+		return nil
+	}
+
+	c := &Comparison{Errors: errs.Stats(), Density: make([]DensityBin, 0, len(cells))}
+	if a, b, r2, ok := linearFit(x, y); ok {
+		c.Regression = &Regression{Slope: b, Offset: a, R2: r2}
+		var sxy, sxx float64
+		for i := range x {
+			sxy += x[i] * y[i]
+			sxx += x[i] * x[i]
+		}
+		if sxx > 0 {
+			c.Regression.SlopeOrigin = sxy / sxx
+		}
+	}
+	for key, count := range cells {
+		c.Density = append(c.Density, DensityBin{
+			Reference: (float64(key[0]) + 0.5) * binWidth,
+			Test:      (float64(key[1]) + 0.5) * binWidth,
+			Count:     count,
+		})
+	}
+	sort.Slice(c.Density, func(i, j int) bool {
+		if c.Density[i].Reference != c.Density[j].Reference {
+			return c.Density[i].Reference < c.Density[j].Reference
+		}
+		return c.Density[i].Test < c.Density[j].Test
+	})
+	return c
+}
+
+// AngleDifference retorna a - b (°) reduzida ao intervalo [-180, 180)
+func AngleDifference(a, b float64) float64 {
+	d := math.Mod(a-b+180, 360)
+	if d < 0 {
+		d += 360
+	}
+	return d - 180
+}
+
+// MeanDirection retorna a média vetorial de direções (°) em [0, 360), ou NaN quando os vetores se anulam
+func MeanDirection(directions []float64) float64 {
+	var sx, sy float64
+	for _, d := range directions {
+		rad := d * math.Pi / 180
+		sx += math.Sin(rad)
+		sy += math.Cos(rad)
+	}
+	if math.Hypot(sx, sy) < 1e-9*float64(len(directions)) || len(directions) == 0 {
+		return math.NaN()
+	}
+	return math.Mod(math.Atan2(sx, sy)*180/math.Pi+360, 360)
+}
@@ -0,0 +1,278 @@
+package analytics
+
+import (
+	"errors"
+	"math"
+)
+
+// Métodos de correlação de longo prazo (measure-correlate-predict)
+const (
+	MCPLinear        = "lls"            // Regressão linear por mínimos quadrados
+	MCPVarianceRatio = "variance_ratio" // Reta que preserva a média e o desvio padrão do alvo
+	MCPMatrix        = "matrix"         // Distribuição do alvo por setor e classe de velocidade da referência
+)
+
+// MCPMethods lista os métodos na ordem do relatório
+var MCPMethods = []string{MCPLinear, MCPVarianceRatio, MCPMatrix}
+
+// ErrInsufficientPairs indica pares concorrentes insuficientes para ajustar o modelo
+var ErrInsufficientPairs = errors.New("insufficient concurrent data")
+
+// minMCPPairs é a quantidade mínima de pares concorrentes para ajustar um modelo
+const minMCPPairs = 10
+
+// WindSample é uma amostra de velocidade (m/s) e direção (°)
+type WindSample struct {
+	Speed     float64
+	Direction float64
+}
+
+// MCPPair é uma amostra concorrente da referência e do alvo
+type MCPPair struct {
+	Reference WindSample
+	Target    float64
+}
+
+// MCPOptions configura o método matricial
+type MCPOptions struct {
+	Sectors  int     // Setores de direção da referência
+	BinWidth float64 // Largura das classes de velocidade da referência (m/s)
+}
+
+// MCPModel prevê a velocidade do alvo a partir da série da referência
+type MCPModel interface {
+	Predict(reference []WindSample) []float64
+}
+
+// LinearMCP prevê o alvo por Offset + Slope·referência
+type LinearMCP struct {
+	Slope  float64 `json:"slope"`
+	Offset float64 `json:"offset"`
+	R2     float64 `json:"r2"`
+}
+
+// Predict aplica a reta; velocidades negativas viram zero
+func (m LinearMCP) Predict(reference []WindSample) []float64 {
+	out := make([]float64, len(reference))
+	for i, s := range reference {
+		out[i] = math.Max(0, m.Offset+m.Slope*s.Speed)
+	}
+	return out
+}
+
+// MatrixMCP guarda as velocidades concorrentes do alvo por setor e classe de velocidade da referência.
+// Cada amostra de longo prazo recebe, em rodízio, os valores concorrentes da sua célula, preservando a
+// distribuição do alvo; células sem dado usam a reta do setor ou, sem ela, a geral.
+type MatrixMCP struct {
+	options  MCPOptions
+	cells    map[[2]int][]float64
+	sectors  map[int]LinearMCP
+	fallback LinearMCP
+}
+
+// Cells retorna a quantidade de células com dados concorrentes
+func (m *MatrixMCP) Cells() int {
+	return len(m.cells)
+}
+
+// cell retorna o setor e a classe de velocidade de uma amostra da referência; sem direção, todas as
+// amostras caem no setor 0
+func (m *MatrixMCP) cell(s WindSample) [2]int {
+	sector := 0
+	if !math.IsNaN(s.Direction) {
+		sector = directionSector(s.Direction, m.options.Sectors)
+	}
+	return [2]int{sector, int(s.Speed / m.options.BinWidth)}
+}
+
+// Predict prevê a série do alvo
+func (m *MatrixMCP) Predict(reference []WindSample) []float64 {
+	out := make([]float64, len(reference))
+	next := map[[2]int]int{}
+	for i, s := range reference {
+		key := m.cell(s)
+		if values := m.cells[key]; len(values) > 0 {
+			out[i] = values[next[key]%len(values)]
+			next[key]++
+			continue
+		}
+		line, ok := m.sectors[key[0]]
+		if !ok {
+			line = m.fallback
+		}
+		out[i] = line.Predict([]WindSample{s})[0]
+	}
+	return out
+}
+
+// FitMCP ajusta o método aos pares concorrentes
+func FitMCP(method string, pairs []MCPPair, options MCPOptions) (MCPModel, error) {
+	if len(pairs) < minMCPPairs {
+		return nil, ErrInsufficientPairs
+	}
+	switch method {
+	case MCPLinear:
+		return fitLinearMCP(pairs)
+	case MCPVarianceRatio:
+		return fitVarianceRatioMCP(pairs)
+	case MCPMatrix:
+		return fitMatrixMCP(pairs, options)
+	}
+	return nil, errors.New("unknown MCP method: " + method)
+}
+
+// mcpColumns separa as velocidades da referência e do alvo
+func mcpColumns(pairs []MCPPair) (x, y []float64) {
+	x, y = make([]float64, len(pairs)), make([]float64, len(pairs))
+	for i, p := range pairs {
+		x[i], y[i] = p.Reference.Speed, p.Target
+	}
+	return x, y
+}
+
+func fitLinearMCP(pairs []MCPPair) (MCPModel, error) {
+	x, y := mcpColumns(pairs)
+	a, b, r2, ok := linearFit(x, y)
+	if !ok {
+		return nil, ErrInsufficientPairs
+	}
+	return LinearMCP{Slope: b, Offset: a, R2: r2}, nil
+}
+
+func fitVarianceRatioMCP(pairs []MCPPair) (MCPModel, error) {
+	x, y := mcpColumns(pairs)
+	dx, dy := Summarize(x), Summarize(y)
+	if dx == nil || dy == nil || dx.StdDev == 0 {
+		return nil, ErrInsufficientPairs
+	}
+	_, _, r2, _ := linearFit(x, y)
+	slope := dy.StdDev / dx.StdDev
+	return LinearMCP{Slope: slope, Offset: dy.Mean - slope*dx.Mean, R2: r2}, nil
+}
+
+func fitMatrixMCP(pairs []MCPPair, options MCPOptions) (MCPModel, error) {
+	if options.Sectors <= 0 || options.BinWidth <= 0 {
+		return nil, errors.New("invalid matrix MCP options")
+	}
+	fallback, err := fitLinearMCP(pairs)
+	if err != nil {
+		return nil, err
+	}
+	m := &MatrixMCP{options: options, cells: map[[2]int][]float64{}, sectors: map[int]LinearMCP{}, fallback: fallback.(LinearMCP)}
+	bySector := map[int][]MCPPair{}
+	for _, p := range pairs {
+		key := m.cell(p.Reference)
+		m.cells[key] = append(m.cells[key], p.Target)
+		bySector[key[0]] = append(bySector[key[0]], p)
+	}
+	for sector, sectorPairs := range bySector {
+		if len(sectorPairs) < minMCPPairs {
+			continue
+		}
+		if line, err := fitLinearMCP(sectorPairs); err == nil {
+			m.sectors[sector] = line.(LinearMCP)
+		}
+	}
+	return m, nil
+}
+
+// MCPValidation é o erro da validação cruzada em blocos contíguos
+type MCPValidation struct {
+	Folds     int         `json:"folds"`
+	Samples   *ErrorStats `json:"samples"`        // Erro das amostras previstas nos blocos retirados
+	MeanError *float64    `json:"mean_error_pct"` // Raiz do erro quadrático médio da média de cada bloco (%)
+	MaxError  *float64    `json:"max_error_pct"`  // Maior erro absoluto da média de um bloco (%)
+}
+
+// CrossValidate ajusta o método em todos os blocos menos um e prevê o bloco retirado. Os blocos são
+// contíguos no tempo para que a autocorrelação não vaze entre ajuste e validação.
+func CrossValidate(method string, pairs []MCPPair, options MCPOptions, folds int) *MCPValidation {
+	if folds < 2 || len(pairs) < folds*minMCPPairs {
+		return nil
+	}
+	v := &MCPValidation{Folds: folds}
+	var samples ErrorAccumulator
+	var sq, max float64
+	var evaluated int
+	for k := 0; k < folds; k++ {
+		from, to := k*len(pairs)/folds, (k+1)*len(pairs)/folds
+		train := append(append([]MCPPair(nil), pairs[:from]...), pairs[to:]...)
+		model, err := FitMCP(method, train, options)
+		if err != nil {
+			continue
+		}
+		held := pairs[from:to]
+		reference := make([]WindSample, len(held))
+		for i, p := range held {
+			reference[i] = p.Reference
+		}
+		var predicted, measured float64
+		for i, y := range model.Predict(reference) {
+			samples.Add(y, held[i].Target)
+			predicted += y
+			measured += held[i].Target
+		}
+		if measured <= 0 {
+			continue
+		}
+		e := 100 * (predicted - measured) / measured
+		sq += e * e
+		max = math.Max(max, math.Abs(e))
+		evaluated++
+	}
+	v.Samples = samples.Stats()
+	if evaluated > 0 {
+		rms := math.Sqrt(sq / float64(evaluated))
+		v.MeanError, v.MaxError = &rms, &max
+	}
+	return v
+}
+
+// MCPResult é a previsão de longo prazo de um método
+type MCPResult struct {
+	Method       string         `json:"method"`
+	Line         *LinearMCP     `json:"line,omitempty"`  // Métodos lineares: reta ajustada
+	Cells        int            `json:"cells,omitempty"` // Método matricial: células com dados concorrentes
+	LongTermMean float64        `json:"long_term_mean"`  // Velocidade média prevista no período de longo prazo (m/s)
+	Weibull      *Weibull       `json:"weibull"`         // Ajuste por máxima verossimilhança da série prevista
+	Validation   *MCPValidation `json:"validation"`
+}
+
+// RunMCP ajusta o método aos pares concorrentes, prevê o alvo no período de longo prazo da referência
+// e valida o método em folds blocos
+func RunMCP(method string, pairs []MCPPair, longTerm []WindSample, options MCPOptions, folds int) (*MCPResult, error) {
+	model, err := FitMCP(method, pairs, options)
+	if err != nil {
+		return nil, err
+	}
+	if len(longTerm) == 0 {
+		return nil, errors.New("empty long-term reference")
+	}
+	result := &MCPResult{Method: method, Validation: CrossValidate(method, pairs, options, folds)}
+	switch m := model.(type) {
+	case LinearMCP:
+		result.Line = &m
+	case *MatrixMCP:
+		result.Cells = m.Cells()
+	}
+	predicted := model.Predict(longTerm)
+	var sum float64
+	for _, v := range predicted {
+		sum += v
+	}
+	result.LongTermMean = sum / float64(len(predicted))
+	if w, ok := FitWeibullMLE(predicted); ok {
+		result.Weibull = &w
+	}
+	return result, nil
+}
+
+// directionSector retorna o setor de uma direção (°) com sectors setores, o setor 0 centrado no norte
+func directionSector(direction float64, sectors int) int {
+	width := 360 / float64(sectors)
+	d := math.Mod(direction+width/2, 360)
+	if d < 0 {
+		d += 360
+	}
+	return int(d/width) % sectors
+}
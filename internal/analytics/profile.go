@@ -0,0 +1,106 @@
+// Package analytics reúne os cálculos de avaliação de recurso eólico e solar feitos sobre as séries
+// dos instrumentos. As funções são puras; a leitura dos dados fica nos handlers.
+package analytics
+
+import "math"
+
+// VonKarman é a constante de von Kármán usada na lei logarítmica
+const VonKarman = 0.4
+
+// ProfileFit é o ajuste de um perfil vertical de velocidade do vento
+type ProfileFit struct {
+	Levels  int     // Alturas usadas no ajuste
+	Alpha   float64 // Expoente de cisalhamento da lei de potência
+	AlphaR2 float64 // R² do ajuste de ln(U) contra ln(z)
+	PowerOK bool    // Lei de potência ajustada (exige velocidades positivas)
+	powerA  float64 // ln(U) = powerA + Alpha·ln(z)
+
+	Z0    float64 // Comprimento de rugosidade da lei logarítmica (m)
+	UStar float64 // Velocidade de fricção (m/s)
+	LogR2 float64 // R² do ajuste de U contra ln(z)
+	LogOK bool    // Lei logarítmica ajustada (exige velocidade crescente com a altura)
+	logA  float64 // U = logA·ln(z) + logB
+	logB  float64
+}
+
+// linearFit ajusta y = a + b·x por mínimos quadrados e retorna a, b e R²
+func linearFit(x, y []float64) (a, b, r2 float64, ok bool) {
+	n := float64(len(x))
+	if len(x) < 2 {
+		return 0, 0, 0, false
+	}
+	var sx, sy, sxx, sxy, syy float64
+	for i := range x {
+		sx += x[i]
+		sy += y[i]
+		sxx += x[i] * x[i]
+		sxy += x[i] * y[i]
+		syy += y[i] * y[i]
+	}
+	varX := sxx - sx*sx/n
+	if varX <= 0 {
+		return 0, 0, 0, false
+	}
+	b = (sxy - sx*sy/n) / varX
+	a = (sy - b*sx) / n
+	varY := syy - sy*sy/n
+	if varY <= 0 {
+		// Perfil constante: a reta explica tudo
+		return a, b, 1, true
+	}
+	r2 = (sxy - sx*sy/n) * (sxy - sx*sy/n) / (varX * varY)
+	return a, b, r2, true
+}
+
+// FitProfile ajusta as leis de potência e logarítmica às velocidades (m/s) medidas nas alturas (m).
+// Alturas não positivas e velocidades NaN são ignoradas; exige ao menos minLevels alturas.
+func FitProfile(heights, speeds []float64, minLevels int) (ProfileFit, bool) {
+	if minLevels < 2 {
+		minLevels = 2
+	}
+	var lnZ, u, lnZPos, lnU []float64
+	for i, z := range heights {
+		if z <= 0 || math.IsNaN(speeds[i]) {
+			continue
+		}
+		lnZ = append(lnZ, math.Log(z))
+		u = append(u, speeds[i])
+		if speeds[i] > 0 {
+			lnZPos = append(lnZPos, math.Log(z))
+			lnU = append(lnU, math.Log(speeds[i]))
+		}
+	}
+
+	fit := ProfileFit{Levels: len(lnZ)}
+	if len(lnZ) < minLevels {
+		return fit, false
+	}
+
+	if len(lnU) >= minLevels {
+		if a, b, r2, ok := linearFit(lnZPos, lnU); ok {
+			fit.PowerOK, fit.powerA, fit.Alpha, fit.AlphaR2 = true, a, b, r2
+		}
+	}
+	if b, a, r2, ok := linearFit(lnZ, u); ok && a > 0 {
+		fit.LogOK, fit.logA, fit.logB, fit.LogR2 = true, a, b, r2
+		fit.Z0 = math.Exp(-b / a)
+		fit.UStar = VonKarman * a
+	}
+	return fit, fit.PowerOK || fit.LogOK
+}
+
+// PowerSpeed extrapola a velocidade para a altura z pela lei de potência ajustada
+func (f ProfileFit) PowerSpeed(z float64) float64 {
+	if !f.PowerOK || z <= 0 {
+		return math.NaN()
+	}
+	return math.Exp(f.powerA + f.Alpha*math.Log(z))
+}
+
+// LogSpeed extrapola a velocidade para a altura z pela lei logarítmica ajustada
+func (f ProfileFit) LogSpeed(z float64) float64 {
+	if !f.LogOK || z <= 0 {
+		return math.NaN()
+	}
+	return f.logA*math.Log(z) + f.logB
+}
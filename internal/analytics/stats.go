@@ -0,0 +1,108 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+)
+
+// Distribution resume a distribuição de uma grandeza
+type Distribution struct {
+	Count  int     `json:"count"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	Min    float64 `json:"min"`
+	P10    float64 `json:"p10"`
+	Median float64 `json:"median"`
+	P90    float64 `json:"p90"`
+	Max    float64 `json:"max"`
+}
+
+// Summarize calcula a distribuição dos valores (NaN são ignorados). Retorna nil sem valores válidos.
+func Summarize(values []float64) *Distribution {
+	sorted := make([]float64, 0, len(values))
+	for _, v := range values {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			sorted = append(sorted, v)
+		}
+	}
+	if len(sorted) == 0 {
+		return nil
+	}
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	mean := sum / float64(len(sorted))
+	var sq float64
+	for _, v := range sorted {
+		sq += (v - mean) * (v - mean)
+	}
+	d := &Distribution{
+		Count: len(sorted), Mean: mean, Min: sorted[0], Max: sorted[len(sorted)-1],
+		P10: Quantile(sorted, 0.1), Median: Quantile(sorted, 0.5), P90: Quantile(sorted, 0.9),
+	}
+	if len(sorted) > 1 {
+		d.StdDev = math.Sqrt(sq / float64(len(sorted)-1))
+	}
+	return d
+}
+
+// ErrorStats compara uma série modelada (ou de teste) com a medida (ou de referência)
+type ErrorStats struct {
+	Count        int     `json:"count"`
+	MeanMeasured float64 `json:"mean_measured"`
+	MBE          float64 `json:"mbe"`                // Erro médio (modelado − medido)
+	MAE          float64 `json:"mae"`                // Erro absoluto médio
+	RMSE         float64 `json:"rmse"`               // Raiz do erro quadrático médio
+	RelMBE       float64 `json:"rel_mbe,omitempty"`  // MBE / média medida (%)
+	RelRMSE      float64 `json:"rel_rmse,omitempty"` // RMSE / média medida (%)
+}
+
+// ErrorAccumulator acumula pares modelado/medido para ErrorStats
+type ErrorAccumulator struct {
+	n                        int
+	measured, sum, abs, sqrs float64
+}
+
+// Add acumula um par; pares com NaN são ignorados
+func (a *ErrorAccumulator) Add(modeled, measured float64) {
+	if math.IsNaN(modeled) || math.IsNaN(measured) || math.IsInf(modeled, 0) || math.IsInf(measured, 0) {
+		return
+	}
+	d := modeled - measured
+	a.n++
+	a.measured += measured
+	a.sum += d
+	a.abs += math.Abs(d)
+	a.sqrs += d * d
+}
+
+// Stats retorna as estatísticas acumuladas ou nil sem pares
+func (a *ErrorAccumulator) Stats() *ErrorStats {
+	if a.n == 0 {
+		return nil
+	}
+	n := float64(a.n)
+	s := &ErrorStats{Count: a.n, MeanMeasured: a.measured / n, MBE: a.sum / n, MAE: a.abs / n, RMSE: math.Sqrt(a.sqrs / n)}
+	if s.MeanMeasured != 0 {
+		s.RelMBE = 100 * s.MBE / s.MeanMeasured
+		s.RelRMSE = 100 * s.RMSE / s.MeanMeasured
+	}
+	return s
+}
+
+// Quantile retorna o quantil q (0..1) de valores já ordenados, com interpolação linear
+func Quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	if lo == hi {
+		return sorted[lo]
+	}
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
@@ -0,0 +1,161 @@
+package analytics
+
+import "math"
+
+// TurbulenceClass é uma categoria de turbulência da IEC 61400-1 (4ª edição)
+type TurbulenceClass struct {
+	Name string  `json:"name"`
+	IRef float64 `json:"i_ref"` // Intensidade de turbulência de referência a 15 m/s
+}
+
+// IECTurbulenceClasses lista as categorias da mais turbulenta (A+) para a menos turbulenta (C)
+var IECTurbulenceClasses = []TurbulenceClass{
+	{"A+", 0.18},
+	{"A", 0.16},
+	{"B", 0.14},
+	{"C", 0.12},
+}
+
+// ExceedsClasses é a classificação de um local mais turbulento que a categoria A+
+const ExceedsClasses = "S"
+
+// NTMIntensity retorna a intensidade de turbulência representativa do modelo de turbulência normal
+// (NTM) da categoria na velocidade v: Iref·(0,75 + 5,6/v)
+func (c TurbulenceClass) NTMIntensity(v float64) float64 {
+	return c.IRef * (0.75 + 5.6/v)
+}
+
+// RepresentativeTI retorna a intensidade de turbulência representativa (quantil de 90%) pela
+// aproximação normal: média + 1,28·desvio padrão
+func RepresentativeTI(mean, stddev float64) float64 {
+	return mean + 1.28*stddev
+}
+
+// TIBin resume a intensidade de turbulência de uma classe de velocidade
+type TIBin struct {
+	Speed            float64  `json:"speed"` // Centro da classe (m/s)
+	From             float64  `json:"from"`
+	To               float64  `json:"to"`
+	Count            int      `json:"count"`
+	MeanSpeed        float64  `json:"mean_speed"`
+	MeanTI           float64  `json:"mean_ti"`
+	StdTI            float64  `json:"std_ti"`
+	RepresentativeTI float64  `json:"representative_ti"`
+	Class            string   `json:"class"`                 // Menor categoria cuja curva NTM cobre a classe
+	Evaluated        bool     `json:"evaluated"`             // Classe usada na classificação do local
+	Limit            *float64 `json:"class_limit,omitempty"` // Curva NTM da categoria do local nesta velocidade
+}
+
+// TurbulenceTable acumula pares velocidade/intensidade de turbulência em classes de velocidade de
+// largura Width centradas em múltiplos de Width
+type TurbulenceTable struct {
+	Width float64
+	bins  map[int]*tiAccumulator
+}
+
+type tiAccumulator struct {
+	n                 int
+	speed, sum, sumSq float64
+}
+
+// NewTurbulenceTable cria uma tabela com classes de largura width (m/s)
+func NewTurbulenceTable(width float64) *TurbulenceTable {
+	return &TurbulenceTable{Width: width, bins: map[int]*tiAccumulator{}}
+}
+
+// Add acumula uma amostra; velocidades não positivas e valores NaN são ignorados
+func (t *TurbulenceTable) Add(speed, ti float64) {
+	if !(speed > 0) || math.IsNaN(ti) || math.IsInf(ti, 0) || ti < 0 {
+		return
+	}
+	i := int(math.Floor(speed/t.Width + 0.5))
+	acc := t.bins[i]
+	if acc == nil {
+		acc = &tiAccumulator{}
+		t.bins[i] = acc
+	}
+	acc.n++
+	acc.speed += speed
+	acc.sum += ti
+	acc.sumSq += ti * ti
+}
+
+// classFor retorna a menor categoria cuja curva NTM cobre a intensidade representativa na velocidade v
+func classFor(representative, v float64) string {
+	for i := len(IECTurbulenceClasses) - 1; i >= 0; i-- {
+		if representative <= IECTurbulenceClasses[i].NTMIntensity(v) {
+			return IECTurbulenceClasses[i].Name
+		}
+	}
+	return ExceedsClasses
+}
+
+// Bins retorna as classes de velocidade em ordem crescente
+func (t *TurbulenceTable) Bins() []TIBin {
+	var out []TIBin
+	for i := 0; len(out) < len(t.bins); i++ {
+		acc := t.bins[i]
+		if acc == nil {
+			continue
+		}
+		center := float64(i) * t.Width
+		n := float64(acc.n)
+		mean := acc.sum / n
+		var std float64
+		if acc.n > 1 {
+			std = math.Sqrt(math.Max(0, (acc.sumSq-n*mean*mean)/(n-1)))
+		}
+		bin := TIBin{
+			Speed: center, From: math.Max(0, center-t.Width/2), To: center + t.Width/2,
+			Count: acc.n, MeanSpeed: acc.speed / n, MeanTI: mean, StdTI: std,
+			RepresentativeTI: RepresentativeTI(mean, std),
+		}
+		if center > 0 {
+			bin.Class = classFor(bin.RepresentativeTI, center)
+		}
+		out = append(out, bin)
+	}
+	return out
+}
+
+// ClassifyTurbulence classifica o local pela menor categoria cuja curva NTM cobre a intensidade
+// representativa em todas as classes com centro a partir de minSpeed e ao menos minCount amostras.
+// Marca as classes avaliadas e o limite da categoria escolhida. Retorna "" sem classes avaliadas e
+// ExceedsClasses quando nem a categoria A+ cobre os dados.
+func ClassifyTurbulence(bins []TIBin, minSpeed float64, minCount int) string {
+	worst := -1
+	for i := range bins {
+		b := &bins[i]
+		b.Evaluated = b.Speed > 0 && b.Speed >= minSpeed && b.Count >= minCount
+		if !b.Evaluated {
+			continue
+		}
+		rank := len(IECTurbulenceClasses)
+		for j, c := range IECTurbulenceClasses {
+			if c.Name == b.Class {
+				rank = len(IECTurbulenceClasses) - 1 - j
+			}
+		}
+		if rank > worst {
+			worst = rank
+		}
+	}
+	if worst < 0 {
+		return ""
+	}
+	if worst >= len(IECTurbulenceClasses) {
+		return ExceedsClasses
+	}
+
+	class := IECTurbulenceClasses[len(IECTurbulenceClasses)-1-worst]
+	for i := range bins {
+		if bins[i].Speed > 0 {
+			bins[i].Limit = floatPtr(class.NTMIntensity(bins[i].Speed))
+		}
+	}
+	return class.Name
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,142 @@
+package analytics
+
+import "math"
+
+// Weibull são os parâmetros de forma (K) e escala (C, m/s) de uma distribuição de Weibull
+type Weibull struct {
+	K float64 `json:"k"`
+	C float64 `json:"c"`
+}
+
+// CDF retorna a probabilidade de a velocidade ser menor que x
+func (w Weibull) CDF(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	return 1 - math.Exp(-math.Pow(x/w.C, w.K))
+}
+
+// Mean retorna a velocidade média da distribuição
+func (w Weibull) Mean() float64 {
+	return w.C * math.Gamma(1+1/w.K)
+}
+
+// positive retorna os valores positivos e finitos; zeros não entram nos ajustes de Weibull
+func positive(values []float64) []float64 {
+	out := make([]float64, 0, len(values))
+	for _, v := range values {
+		if v > 0 && !math.IsInf(v, 0) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// FitWeibullMoments estima os parâmetros pela média e pelo desvio padrão (aproximação de Justus)
+func FitWeibullMoments(speeds []float64) (Weibull, bool) {
+	x := positive(speeds)
+	d := Summarize(x)
+	if d == nil || d.Count < 2 || d.StdDev == 0 {
+		return Weibull{}, false
+	}
+	k := math.Pow(d.StdDev/d.Mean, -1.086)
+	return Weibull{K: k, C: d.Mean / math.Gamma(1+1/k)}, true
+}
+
+// FitWeibullMLE estima os parâmetros por máxima verossimilhança (Newton-Raphson em k)
+func FitWeibullMLE(speeds []float64) (Weibull, bool) {
+	x := positive(speeds)
+	if len(x) < 2 {
+		return Weibull{}, false
+	}
+	n := float64(len(x))
+	var meanLn float64
+	for _, v := range x {
+		meanLn += math.Log(v)
+	}
+	meanLn /= n
+
+	// g(k) = Σxᵏ·ln x / Σxᵏ − 1/k − mean(ln x) = 0
+	k := 2.0
+	if start, ok := FitWeibullMoments(x); ok {
+		k = start.K
+	}
+	for i := 0; i < 100; i++ {
+		var s0, s1, s2 float64
+		for _, v := range x {
+			xk := math.Pow(v, k)
+			ln := math.Log(v)
+			s0 += xk
+			s1 += xk * ln
+			s2 += xk * ln * ln
+		}
+		g := s1/s0 - 1/k - meanLn
+		dg := (s2*s0-s1*s1)/(s0*s0) + 1/(k*k)
+		step := g / dg
+		next := k - step
+		if next <= 0 {
+			next = k / 2
+		}
+		converged := math.Abs(next-k) < 1e-9*k
+		k = next
+		if converged {
+			break
+		}
+	}
+	if math.IsNaN(k) || k <= 0 {
+		return Weibull{}, false
+	}
+
+	var sk float64
+	for _, v := range x {
+		sk += math.Pow(v, k)
+	}
+	return Weibull{K: k, C: math.Pow(sk/n, 1/k)}, true
+}
+
+// FitWeibullWAsP estima os parâmetros pelo método do WAsP: a distribuição ajustada reproduz a
+// média dos cubos das velocidades (densidade de potência) e a frequência de velocidades acima da
+// média observada. Calmarias (zeros) entram nas frequências observadas.
+func FitWeibullWAsP(speeds []float64) (Weibull, bool) {
+	var n, sum, sum3 float64
+	for _, v := range speeds {
+		if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		n++
+		sum += v
+		sum3 += v * v * v
+	}
+	if n < 2 || sum3 == 0 {
+		return Weibull{}, false
+	}
+	mean, mean3 := sum/n, sum3/n
+	var above float64
+	for _, v := range speeds {
+		if v > mean {
+			above++
+		}
+	}
+	exceed := above / n
+	if exceed <= 0 || exceed >= 1 {
+		return Weibull{}, false
+	}
+
+	scale := func(k float64) float64 { return math.Cbrt(mean3 / math.Gamma(1+3/k)) }
+	// f(k) = exp(−(mean/c(k))ᵏ) − P(U > mean), resolvida por bisseção
+	f := func(k float64) float64 { return math.Exp(-math.Pow(mean/scale(k), k)) - exceed }
+	lo, hi := 0.5, 12.0
+	if f(lo)*f(hi) > 0 {
+		return Weibull{}, false
+	}
+	for i := 0; i < 200 && hi-lo > 1e-10; i++ {
+		mid := (lo + hi) / 2
+		if f(lo)*f(mid) <= 0 {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	k := (lo + hi) / 2
+	return Weibull{K: k, C: scale(k)}, true
+}
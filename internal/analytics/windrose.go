@@ -0,0 +1,49 @@
+package analytics
+
+import "math"
+
+// WindRose acumula a frequência conjunta de setor de direção e classe de velocidade
+type WindRose struct {
+	Sectors int       // Quantidade de setores; o setor 0 é centrado no norte
+	Edges   []float64 // Limites inferiores das classes de velocidade; a última classe é aberta
+	Counts  [][]int   // Contagem por setor e classe
+	Speeds  []float64 // Soma das velocidades por setor, para a média
+	Calm    int       // Velocidades abaixo do primeiro limite
+	Total   int       // Pares válidos, incluindo calmarias
+}
+
+// NewWindRose cria uma rosa dos ventos com sectors setores e as classes de velocidade em edges (crescentes)
+func NewWindRose(sectors int, edges []float64) *WindRose {
+	counts := make([][]int, sectors)
+	for i := range counts {
+		counts[i] = make([]int, len(edges))
+	}
+	return &WindRose{Sectors: sectors, Edges: edges, Counts: counts, Speeds: make([]float64, sectors)}
+}
+
+// Sector retorna o setor de uma direção (°)
+func (w *WindRose) Sector(direction float64) int {
+	return directionSector(direction, w.Sectors)
+}
+
+// Add acumula um par velocidade/direção; valores NaN são ignorados
+func (w *WindRose) Add(speed, direction float64) {
+	if math.IsNaN(speed) || math.IsNaN(direction) || speed < 0 {
+		return
+	}
+	w.Total++
+	if len(w.Edges) == 0 || speed < w.Edges[0] {
+		w.Calm++
+		return
+	}
+	bin := len(w.Edges) - 1
+	for i := 1; i < len(w.Edges); i++ {
+		if speed < w.Edges[i] {
+			bin = i - 1
+			break
+		}
+	}
+	s := w.Sector(direction)
+	w.Counts[s][bin]++
+	w.Speeds[s] += speed
+}
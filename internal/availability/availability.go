@@ -0,0 +1,272 @@
+// Package availability detecta falhas de registro nas tabelas de dados dos equipamentos, grava-as em
+// DataGaps e calcula a disponibilidade diária. É executado após cada importação e periodicamente.
+package availability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Tables lista as hypertables de dados varridas; todas têm as colunas equipmentid e timestamp
+var Tables = []string{"estacaosolarimetricadados", "lidarwindcubedados", "sodardados", "adcpdados"}
+
+const (
+	// DefaultOutage é a duração a partir da qual uma falha é considerada uma interrupção
+	DefaultOutage = time.Hour
+	// gapFactor: espaçamentos acima de gapFactor intervalos contam como falha, tolerando atrasos do registrador
+	gapFactor = 1.5
+	// inferenceSamples é a quantidade de registros recentes usada para estimar o intervalo
+	inferenceSamples = 5000
+)
+
+// ErrUnknownTable indica uma tabela fora de Tables
+var ErrUnknownTable = errors.New("availability: tabela sem varredura")
+
+// ErrUnknownEquipment indica um equipamento inexistente ou um equipment_id que não é um UUID
+var ErrUnknownEquipment = errors.New("availability: equipamento inexistente")
+
+// Gap é uma falha de registro
+type Gap struct {
+	ID      string    `json:"id,omitempty"`
+	Table   string    `json:"table"`
+	Start   time.Time `json:"start"` // Último registro antes da falha
+	End     time.Time `json:"end"`   // Primeiro registro depois da falha ou, em aberto, o momento da varredura
+	Missing int       `json:"missing"`
+	Outage  bool      `json:"outage"`
+	Open    bool      `json:"open"`
+}
+
+// TableScan resume a varredura de uma tabela
+type TableScan struct {
+	Table      string  `json:"table"`
+	Interval   float64 `json:"interval_seconds"`
+	Configured bool    `json:"interval_configured"` // Intervalo de Equipments.LoggingInterval; senão, estimado
+	Gaps       int     `json:"gaps"`
+	Outages    int     `json:"outages"`
+	Open       bool    `json:"open"`
+}
+
+// equipment guarda os dados do equipamento usados na varredura
+type equipment struct {
+	id        pgtype.UUID
+	interval  *int
+	operating bool
+}
+
+// loadEquipment lê o intervalo configurado e se o equipamento está em operação
+func loadEquipment(ctx context.Context, db *pgxpool.Pool, equipmentID string) (*equipment, error) {
+	e := &equipment{}
+	if err := e.id.Scan(equipmentID); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEquipment, equipmentID)
+	}
+	err := db.QueryRow(ctx, `
+		SELECT logginginterval, COALESCE(operatingstatus IN ('Em Operação', 'Em Uso'), false)
+		FROM equipments WHERE equipmentid = $1`, e.id).Scan(&e.interval, &e.operating)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEquipment, equipmentID)
+	}
+	return e, err
+}
+
+// validTable confirma que a tabela é varrida; o nome é interpolado nas consultas
+func validTable(table string) error {
+	for _, t := range Tables {
+		if t == table {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownTable, table)
+}
+
+// expectedInterval retorna o intervalo esperado do equipamento na tabela: o configurado ou a mediana do
+// espaçamento dos registros mais recentes. Zero quando não há registros suficientes.
+func (e *equipment) expectedInterval(ctx context.Context, db *pgxpool.Pool, table string) (time.Duration, bool, error) {
+	if e.interval != nil && *e.interval > 0 {
+		return time.Duration(*e.interval) * time.Second, true, nil
+	}
+	var seconds *float64
+	err := db.QueryRow(ctx, fmt.Sprintf(`
+		SELECT percentile_cont(0.5) WITHIN GROUP (ORDER BY d) FROM (
+			SELECT extract(epoch FROM ts - lag(ts) OVER (ORDER BY ts)) AS d
+			FROM (SELECT DISTINCT timestamp AS ts FROM %s WHERE equipmentid = $1 ORDER BY ts DESC LIMIT %d) s
+		) g WHERE d > 0`, table, inferenceSamples), e.id).Scan(&seconds)
+	if err != nil || seconds == nil {
+		return 0, false, err
+	}
+	return time.Duration(*seconds * float64(time.Second)), false, nil
+}
+
+// Interval retorna o intervalo de registro esperado de um equipamento em uma tabela e se ele foi
+// configurado (true) ou estimado pelos dados (false)
+func Interval(ctx context.Context, db *pgxpool.Pool, equipmentID, table string) (time.Duration, bool, error) {
+	if err := validTable(table); err != nil {
+		return 0, false, err
+	}
+	e, err := loadEquipment(ctx, db, equipmentID)
+	if err != nil {
+		return 0, false, err
+	}
+	return e.expectedInterval(ctx, db, table)
+}
+
+// ScanTable detecta as falhas de um equipamento em uma tabela a partir de since (nil: todo o histórico)
+// e substitui as gravadas nesse trecho. Falhas a partir de outage são interrupções; se o equipamento
+// está em operação e o último registro é mais antigo que outage, grava uma falha aberta até agora.
+func ScanTable(ctx context.Context, db *pgxpool.Pool, equipmentID, table string, since *time.Time, outage time.Duration) (*TableScan, error) {
+	if err := validTable(table); err != nil {
+		return nil, err
+	}
+	e, err := loadEquipment(ctx, db, equipmentID)
+	if err != nil {
+		return nil, err
+	}
+	return e.scan(ctx, db, table, since, outage, time.Now().UTC())
+}
+
+func (e *equipment) scan(ctx context.Context, db *pgxpool.Pool, table string, since *time.Time, outage time.Duration, now time.Time) (*TableScan, error) {
+	result := &TableScan{Table: table}
+	interval, configured, err := e.expectedInterval(ctx, db, table)
+	if err != nil || interval <= 0 {
+		return result, err
+	}
+	result.Interval, result.Configured = interval.Seconds(), configured
+
+	// A varredura começa no último registro antes de since, para que a falha que o atravessa seja vista
+	var anchor, last *time.Time
+	err = db.QueryRow(ctx, fmt.Sprintf(`
+		SELECT (SELECT max(timestamp) FROM %[1]s WHERE equipmentid = $1 AND timestamp < $2),
+		       (SELECT max(timestamp) FROM %[1]s WHERE equipmentid = $1)`, table), e.id, since).Scan(&anchor, &last)
+	if err != nil {
+		return nil, err
+	}
+	if last == nil {
+		return result, nil
+	}
+	from := since
+	if anchor != nil {
+		from = anchor
+	}
+
+	threshold := time.Duration(float64(interval) * gapFactor)
+	rows, err := db.Query(ctx, fmt.Sprintf(`
+		SELECT prev, ts FROM (
+			SELECT ts, lag(ts) OVER (ORDER BY ts) AS prev
+			FROM (SELECT DISTINCT timestamp AS ts FROM %s
+			      WHERE equipmentid = $1 AND ($2::timestamptz IS NULL OR timestamp >= $2)) s
+		) g WHERE ts - prev > $3 ORDER BY ts`, table), e.id, from, threshold)
+	if err != nil {
+		return nil, err
+	}
+	var gaps []Gap
+	for rows.Next() {
+		var g Gap
+		if err := rows.Scan(&g.Start, &g.End); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		gaps = append(gaps, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if e.operating && now.Sub(*last) > outage {
+		gaps = append(gaps, Gap{Start: *last, End: now, Open: true})
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, `
+		DELETE FROM datagaps WHERE equipmentid = $1 AND datatable = $2
+		  AND (isopen OR $3::timestamptz IS NULL OR gapstart >= $3)`, e.id, table, from)
+	if err != nil {
+		return nil, err
+	}
+	for i := range gaps {
+		g := &gaps[i]
+		g.Table = table
+		if g.Open {
+			// A falha aberta ainda não tem o registro que a encerra
+			g.Missing = int(g.End.Sub(g.Start) / interval)
+		} else {
+			g.Missing = int(math.Round(float64(g.End.Sub(g.Start))/float64(interval))) - 1
+		}
+		g.Outage = g.End.Sub(g.Start) >= outage
+		_, err = tx.Exec(ctx, `
+			INSERT INTO datagaps (equipmentid, datatable, gapstart, gapend, missingsamples, isoutage, isopen)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`, e.id, table, g.Start, g.End, g.Missing, g.Outage, g.Open)
+		if err != nil {
+			return nil, err
+		}
+		result.Gaps++
+		if g.Outage {
+			result.Outages++
+		}
+		result.Open = result.Open || g.Open
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Scan varre todas as tabelas com dados do equipamento a partir de since (nil: todo o histórico)
+func Scan(ctx context.Context, db *pgxpool.Pool, equipmentID string, since *time.Time, outage time.Duration) ([]*TableScan, error) {
+	e, err := loadEquipment(ctx, db, equipmentID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	scans := []*TableScan{}
+	for _, table := range Tables {
+		scan, err := e.scan(ctx, db, table, since, outage, now)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", table, err)
+		}
+		if scan.Interval > 0 {
+			scans = append(scans, scan)
+		}
+	}
+	return scans, nil
+}
+
+// Schedule varre todos os equipamentos a cada every, revendo as últimas lookback horas, até ctx ser
+// cancelado. Erros são registrados no log e não interrompem o agendamento.
+func Schedule(ctx context.Context, db *pgxpool.Pool, every, lookback, outage time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		rows, err := db.Query(ctx, "SELECT equipmentid::text FROM equipments")
+		if err != nil {
+			log.Println("availability: failed to list equipments:", err)
+			continue
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			log.Println("availability: failed to list equipments:", err)
+			continue
+		}
+		since := time.Now().Add(-lookback)
+		for _, id := range ids {
+			if _, err := Scan(ctx, db, id, &since, outage); err != nil {
+				log.Printf("availability: scan of equipment %s failed: %v", id, err)
+			}
+		}
+	}
+}
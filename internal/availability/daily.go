@@ -0,0 +1,119 @@
+package availability
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Day é a disponibilidade de um dia (UTC)
+type Day struct {
+	Date         string  `json:"date"`
+	Received     int     `json:"received"` // Registros distintos recebidos
+	Expected     int     `json:"expected"` // Registros esperados pelo intervalo no trecho do dia dentro do período
+	Availability float64 `json:"availability_pct"`
+}
+
+// Coverage é a disponibilidade de um equipamento em uma tabela no período
+type Coverage struct {
+	Table        string     `json:"table"`
+	Interval     float64    `json:"interval_seconds"`
+	Configured   bool       `json:"interval_configured"`
+	First        *time.Time `json:"first"` // Primeiro e último registros da tabela no período
+	Last         *time.Time `json:"last"`
+	Received     int        `json:"received"`
+	Expected     int        `json:"expected"`
+	Availability float64    `json:"availability_pct"`
+	Days         []Day      `json:"days"`
+}
+
+// availabilityPct limita a 100% a razão entre recebidos e esperados
+func availabilityPct(received, expected int) float64 {
+	if expected <= 0 {
+		return 0
+	}
+	if received >= expected {
+		return 100
+	}
+	return 100 * float64(received) / float64(expected)
+}
+
+// Daily calcula a disponibilidade diária de um equipamento em uma tabela entre start e end; sem start
+// ou end, o período vai do primeiro registro ao fim do intervalo do último. Retorna nil quando a
+// tabela não tem registros do equipamento ou o período não pode ser delimitado.
+func Daily(ctx context.Context, db *pgxpool.Pool, equipmentID, table string, start, end *time.Time) (*Coverage, error) {
+	if err := validTable(table); err != nil {
+		return nil, err
+	}
+	e, err := loadEquipment(ctx, db, equipmentID)
+	if err != nil {
+		return nil, err
+	}
+	interval, configured, err := e.expectedInterval(ctx, db, table)
+	if err != nil || interval <= 0 {
+		return nil, err
+	}
+	c := &Coverage{Table: table, Interval: interval.Seconds(), Configured: configured, Days: []Day{}}
+
+	var hasData bool
+	err = db.QueryRow(ctx, fmt.Sprintf(`
+		SELECT min(timestamp), max(timestamp), EXISTS(SELECT 1 FROM %[1]s WHERE equipmentid = $1) FROM %[1]s
+		WHERE equipmentid = $1 AND ($2::timestamptz IS NULL OR timestamp >= $2) AND ($3::timestamptz IS NULL OR timestamp < $3)`,
+		table), e.id, start, end).Scan(&c.First, &c.Last, &hasData)
+	if err != nil || !hasData {
+		return nil, err
+	}
+	// Sem registros no período, só é possível medi-lo com start e end explícitos
+	if c.First == nil && (start == nil || end == nil) {
+		return nil, nil
+	}
+	from, to := start, end
+	if from == nil {
+		from = c.First
+	}
+	if to == nil {
+		t := c.Last.Add(interval)
+		to = &t
+	}
+
+	rows, err := db.Query(ctx, fmt.Sprintf(`
+		SELECT (timestamp AT TIME ZONE 'UTC')::date, count(DISTINCT timestamp) FROM %s
+		WHERE equipmentid = $1 AND timestamp >= $2 AND timestamp < $3
+		GROUP BY 1`, table), e.id, *from, *to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	received := map[string]int{}
+	for rows.Next() {
+		var day time.Time
+		var count int
+		if err := rows.Scan(&day, &count); err != nil {
+			return nil, err
+		}
+		received[day.Format("2006-01-02")] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for day := from.UTC().Truncate(24 * time.Hour); day.Before(*to); day = day.Add(24 * time.Hour) {
+		dayStart, dayEnd := day, day.Add(24*time.Hour)
+		if dayStart.Before(*from) {
+			dayStart = *from
+		}
+		if dayEnd.After(*to) {
+			dayEnd = *to
+		}
+		d := Day{Date: day.Format("2006-01-02"), Expected: int(dayEnd.Sub(dayStart) / interval)}
+		d.Received = received[d.Date]
+		d.Availability = availabilityPct(d.Received, d.Expected)
+		c.Days = append(c.Days, d)
+		c.Received += d.Received
+		c.Expected += d.Expected
+	}
+	c.Availability = availabilityPct(c.Received, c.Expected)
+	return c, nil
+}
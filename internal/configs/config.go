@@ -3,6 +3,7 @@ package configs
 import (
 	"log"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -20,3 +21,38 @@ func GetDatabaseURL() string {
 
 // JwtSecret é o segredo usado para assinar tokens JWT
 var JwtSecret = []byte(os.Getenv("JWT_SECRET"))
+
+// GetQCConfigFile retorna o caminho do arquivo JSON com as regras de controle de qualidade (opcional)
+func GetQCConfigFile() string {
+	return os.Getenv("QC_CONFIG_FILE")
+}
+
+// GetAvailabilityScanInterval retorna o intervalo da detecção periódica de falhas de registro
+// (AVAILABILITY_SCAN_INTERVAL, padrão 1h; "0" desativa)
+func GetAvailabilityScanInterval() (time.Duration, error) {
+	v := os.Getenv("AVAILABILITY_SCAN_INTERVAL")
+	if v == "" {
+		return time.Hour, nil
+	}
+	return time.ParseDuration(v)
+}
+
+// GetAlertEvaluationInterval retorna o intervalo da avaliação periódica das regras de alerta
+// (ALERT_EVALUATION_INTERVAL, padrão 5m; "0" desativa)
+func GetAlertEvaluationInterval() (time.Duration, error) {
+	v := os.Getenv("ALERT_EVALUATION_INTERVAL")
+	if v == "" {
+		return 5 * time.Minute, nil
+	}
+	return time.ParseDuration(v)
+}
+
+// GetIngestdConfigFile retorna o caminho do arquivo JSON com os diretórios monitorados pelo cmd/ingestd
+func GetIngestdConfigFile() string {
+	return os.Getenv("INGESTD_CONFIG_FILE")
+}
+
+// GetMQTTConfigFile retorna o caminho do arquivo JSON com o broker e as rotas do cmd/mqttd
+func GetMQTTConfigFile() string {
+	return os.Getenv("MQTT_CONFIG_FILE")
+}
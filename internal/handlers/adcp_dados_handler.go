@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"api/internal/ingest"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UploadADCPPD0File importa um arquivo binário PD0 do ADCP para ADCPDados. A resposta inclui o
+// diagnóstico da decodificação (falhas de checksum e ensembles descartados).
+func UploadADCPPD0File(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		form, err := parseUploadForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer form.File.Close()
+
+		runUploadJob(w, r, db, form, ingest.ParserPD0, func(target ingest.Target) (*ingest.Summary, error) {
+			return ingest.LoadPD0(r.Context(), db, form.File, form.FileName, target)
+		})
+	}
+}
@@ -23,37 +23,7 @@ func ADCPDataRouter(db *pgxpool.Pool) http.Handler {
 }
 
 func GetAllADCPData(db *pgxpool.Pool) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		rows, err := db.Query(context.Background(), "SELECT id, equipmentid, timestamp, watercurrentspeed, watercurrentdirection, watertemperature, salinity, depth FROM adcpdata")
-		if err != nil {
-			http.Error(w, "Failed to query ADCP data", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []models.ADCPData
-		for rows.Next() {
-			var datum models.ADCPData
-			err := rows.Scan(
-				&datum.ID,
-				&datum.EquipmentID,
-				&datum.Timestamp,
-				&datum.WaterCurrentSpeed,
-				&datum.WaterCurrentDirection,
-				&datum.WaterTemperature,
-				&datum.Salinity,
-				&datum.Depth,
-			)
-			if err != nil {
-				http.Error(w, "Failed to scan ADCP data", http.StatusInternalServerError)
-				return
-			}
-			data = append(data, datum)
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
-	}
+	return listInstrumentData(db, adcpDataDataset)
 }
 
 func GetADCPDataByID(db *pgxpool.Pool) http.HandlerFunc {
@@ -102,6 +72,7 @@ func CreateADCPData(db *pgxpool.Pool) http.HandlerFunc {
 			http.Error(w, "Failed to insert ADCP data", http.StatusInternalServerError)
 			return
 		}
+		evaluateInsertedRow(r.Context(), db, "adcpdata", datum.EquipmentID, datum.Timestamp)
 
 		w.WriteHeader(http.StatusCreated)
 	}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"api/internal/alerts"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Limites da listagem do histórico de disparos
+const (
+	defaultFiringsLimit = 200
+	maxFiringsLimit     = 5000
+)
+
+// decodeAlertRule lê e valida a regra do corpo da requisição
+func decodeAlertRule(w http.ResponseWriter, r *http.Request, db *pgxpool.Pool) (*alerts.Rule, bool) {
+	rule := &alerts.Rule{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(rule); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return nil, false
+	}
+	if err := rule.Validate(r.Context(), db); err != nil {
+		if errors.Is(err, alerts.ErrInvalidRule) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, "Failed to validate alert rule", http.StatusInternalServerError)
+			log.Println("Failed to validate alert rule:", err)
+		}
+		return nil, false
+	}
+	return rule, true
+}
+
+// GetAlertRules lista as regras de alerta
+func GetAlertRules(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rules, err := alerts.ListRules(r.Context(), db)
+		if err != nil {
+			http.Error(w, "Failed to query alert rules", http.StatusInternalServerError)
+			log.Println("Failed to query alert rules:", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rules)
+	}
+}
+
+// CreateAlertRule cria uma regra de alerta; o usuário autenticado fica como autor e recebe os alertas
+// dos equipamentos sem responsáveis cadastrados
+func CreateAlertRule(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := decodeAlertRule(w, r, db)
+		if !ok {
+			return
+		}
+		if _, userID, err := getUserRole(r); err == nil && userID != "" {
+			rule.CreatedBy = &userID
+		}
+
+		err := db.QueryRow(r.Context(), `
+			INSERT INTO AlertRules (name, equipmentid, datatable, kind, columnname, operator, threshold, level,
+			                        duration, enabled, createdby)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			RETURNING alertruleid::text, createdat`,
+			rule.Name, rule.EquipmentID, rule.Table, rule.Kind, rule.Column, rule.Operator, rule.Threshold, rule.Level,
+			rule.Duration, rule.Enabled, rule.CreatedBy).Scan(&rule.ID, &rule.CreatedAt)
+		if err != nil {
+			http.Error(w, "Failed to create alert rule", http.StatusInternalServerError)
+			log.Println("Failed to create alert rule:", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(rule)
+	}
+}
+
+// UpdateAlertRule substitui os campos de uma regra de alerta. Um disparo aberto continua aberto até a
+// próxima avaliação com a regra nova.
+func UpdateAlertRule(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseUUIDParam("alert rule id", chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rule, ok := decodeAlertRule(w, r, db)
+		if !ok {
+			return
+		}
+		tag, err := db.Exec(r.Context(), `
+			UPDATE AlertRules SET name = $1, equipmentid = $2, datatable = $3, kind = $4, columnname = $5,
+			       operator = $6, threshold = $7, level = $8, duration = $9, enabled = $10
+			WHERE alertruleid = $11::uuid`,
+			rule.Name, rule.EquipmentID, rule.Table, rule.Kind, rule.Column, rule.Operator, rule.Threshold, rule.Level,
+			rule.Duration, rule.Enabled, id)
+		if err != nil {
+			http.Error(w, "Failed to update alert rule", http.StatusInternalServerError)
+			log.Println("Failed to update alert rule:", err)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			http.Error(w, "Alert rule not found", http.StatusNotFound)
+			return
+		}
+		updated, err := alerts.GetRule(r.Context(), db, id)
+		if err != nil {
+			http.Error(w, "Failed to load alert rule", http.StatusInternalServerError)
+			log.Println("Failed to load alert rule:", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updated)
+	}
+}
+
+// DeleteAlertRule remove uma regra de alerta e o seu histórico de disparos
+func DeleteAlertRule(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseUUIDParam("alert rule id", chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tag, err := db.Exec(r.Context(), "DELETE FROM AlertRules WHERE alertruleid = $1::uuid", id)
+		if err != nil {
+			http.Error(w, "Failed to delete alert rule", http.StatusInternalServerError)
+			log.Println("Failed to delete alert rule:", err)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			http.Error(w, "Alert rule not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		log.Println("Alert rule successfully deleted:", id)
+	}
+}
+
+// GetAlertFirings retorna o histórico de disparos, filtrado por rule_id, equipment_id e status
+// (firing ou resolved), limitado por limit
+func GetAlertFirings(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		status := q.Get("status")
+		if status != "" && status != alerts.StatusFiring && status != alerts.StatusResolved {
+			http.Error(w, "Invalid status: use firing or resolved", http.StatusBadRequest)
+			return
+		}
+		limit := defaultFiringsLimit
+		if v := q.Get("limit"); v != "" {
+			var err error
+			if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxFiringsLimit {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+		ruleID, err := parseUUIDParam("rule_id", q.Get("rule_id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		equipmentID, err := parseUUIDParam("equipment_id", q.Get("equipment_id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		firings, err := alerts.ListFirings(r.Context(), db, ruleID, equipmentID, status, limit)
+		if err != nil {
+			http.Error(w, "Failed to query alert firings", http.StatusInternalServerError)
+			log.Println("Failed to query alert firings:", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(firings)
+	}
+}
+
+// EvaluateAlertRules avalia imediatamente todas as regras ativas e retorna os disparos abertos e
+// encerrados
+func EvaluateAlertRules(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		transitions, err := alerts.EvaluateAll(r.Context(), db)
+		if err != nil {
+			http.Error(w, "Failed to evaluate alert rules", http.StatusInternalServerError)
+			log.Println("Failed to evaluate alert rules:", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(transitions)
+	}
+}
+
+// equipmentResponsibles é o corpo de /api/equipments/{id}/responsibles
+type equipmentResponsibles struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+// GetEquipmentResponsibles lista os usuários que recebem os alertas do equipamento
+func GetEquipmentResponsibles(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseUUIDParam("equipment id", chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rows, err := db.Query(r.Context(), `
+			SELECT id_usuario::text FROM EquipmentResponsibles WHERE equipmentid = $1::uuid ORDER BY id_usuario`, id)
+		if err != nil {
+			http.Error(w, "Failed to query equipment responsibles", http.StatusInternalServerError)
+			log.Println("Failed to query equipment responsibles:", err)
+			return
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			http.Error(w, "Failed to query equipment responsibles", http.StatusInternalServerError)
+			log.Println("Failed to query equipment responsibles:", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(equipmentResponsibles{UserIDs: ids})
+	}
+}
+
+// SetEquipmentResponsibles substitui os usuários que recebem os alertas do equipamento
+func SetEquipmentResponsibles(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseUUIDParam("equipment id", chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var body equipmentResponsibles
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+		userIDs := make([]string, 0, len(body.UserIDs))
+		for _, userID := range body.UserIDs {
+			userID, err := parseUUIDParam("user id", userID)
+			if err != nil || userID == "" {
+				http.Error(w, "Invalid user id", http.StatusBadRequest)
+				return
+			}
+			userIDs = append(userIDs, userID)
+		}
+
+		tx, err := db.Begin(r.Context())
+		if err != nil {
+			http.Error(w, "Failed to begin transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		var exists bool
+		if err := tx.QueryRow(r.Context(), "SELECT EXISTS(SELECT 1 FROM equipments WHERE equipmentid = $1::uuid)", id).Scan(&exists); err != nil {
+			http.Error(w, "Failed to query equipment", http.StatusInternalServerError)
+			log.Println("Failed to query equipment:", err)
+			return
+		}
+		if !exists {
+			http.Error(w, "Equipment not found", http.StatusNotFound)
+			return
+		}
+		if _, err := tx.Exec(r.Context(), "DELETE FROM EquipmentResponsibles WHERE equipmentid = $1::uuid", id); err != nil {
+			http.Error(w, "Failed to clear equipment responsibles", http.StatusInternalServerError)
+			log.Println("Failed to clear equipment responsibles:", err)
+			return
+		}
+		seen := map[string]bool{}
+		for _, userID := range userIDs {
+			if seen[userID] {
+				continue
+			}
+			seen[userID] = true
+			tag, err := tx.Exec(r.Context(), `
+				INSERT INTO EquipmentResponsibles (equipmentid, id_usuario)
+				SELECT e.equipmentid, u.id_usuario FROM equipments e, Usuarios u
+				WHERE e.equipmentid = $1::uuid AND u.id_usuario = $2::uuid
+				ON CONFLICT DO NOTHING`, id, userID)
+			if err != nil {
+				http.Error(w, "Failed to set equipment responsibles", http.StatusInternalServerError)
+				log.Println("Failed to set equipment responsibles:", err)
+				return
+			}
+			if tag.RowsAffected() == 0 {
+				http.Error(w, "User not found: "+userID, http.StatusBadRequest)
+				return
+			}
+		}
+		if err := tx.Commit(r.Context()); err != nil {
+			http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
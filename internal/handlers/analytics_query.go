@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"api/internal/models"
+)
+
+// windColumns indica as colunas de velocidade e direção do vento de um dataset. No LIDAR WindCube
+// são os campos por altura (models.LIDARWindCubeColumn).
+type windColumns struct {
+	Speed     string
+	Direction string
+}
+
+// datasetWind associa os datasets que medem vento às suas colunas de velocidade e direção
+var datasetWind = map[*instrumentDataset]windColumns{
+	estacaoSolarimetricaDataset:     {"ws_ms_avg", "winddir"},
+	towerMicrometeorologicalDataset: {"windspeed", "winddirection"},
+	sodarDataDataset:                {"windspeed", "winddirection"},
+	lidarZephyDataset:               {"windspeed", "winddirection"},
+	lidarWindcobeDataset:            {"windspeed", "winddirection"},
+	sodarDadosDataset:               {"windspeed", "winddirection"},
+	lidarWindCubeDadosDataset:       {"windspeed", "winddirection"},
+}
+
+// turbulenceColumns indica as colunas usadas no cálculo da intensidade de turbulência: desvio padrão
+// da velocidade (Dispersion) e, quando existir, a intensidade já calculada pelo instrumento (Intensity)
+type turbulenceColumns struct {
+	Speed      string
+	Dispersion string
+	Intensity  string
+}
+
+// datasetTurbulence associa os datasets com medição de turbulência às suas colunas
+var datasetTurbulence = map[*instrumentDataset]turbulenceColumns{
+	sodarDadosDataset:         {"windspeed", "sigmaspeed", "turbulenceintensity"},
+	lidarWindCubeDadosDataset: {"windspeed", "windspeeddispersion", ""},
+}
+
+// analyticsDataset lê o parâmetro "instrument" das rotas /api/analytics
+func analyticsDataset(r *http.Request) (*instrumentDataset, error) {
+	instrument := r.URL.Query().Get("instrument")
+	if instrument == "" {
+		return nil, errors.New("instrument is required")
+	}
+	ds, ok := instrumentDatasets[instrument]
+	if !ok {
+		return nil, fmt.Errorf("Unknown instrument: %s", instrument)
+	}
+	return ds, nil
+}
+
+// parseAnalyticsFilter lê os filtros (exige equipment_id ou campaign_id), as opções de leitura e as
+// alturas de uma análise
+func parseAnalyticsFilter(r *http.Request, ds *instrumentDataset) (dataFilter, readOptions, map[float64]bool, error) {
+	filter, err := parseDataFilter(r)
+	if err != nil {
+		return filter, readOptions{}, nil, err
+	}
+	if filter.EquipmentID == "" && filter.CampaignID == "" {
+		return filter, readOptions{}, nil, errors.New("equipment_id or campaign_id is required")
+	}
+	opts, err := parseReadOptions(r, ds)
+	if err != nil {
+		return filter, opts, nil, err
+	}
+	heights, err := parseHeights(r)
+	return filter, opts, heights, err
+}
+
+// parseHeights lê o parâmetro "heights" (alturas separadas por vírgula). Retorna nil quando ausente.
+func parseHeights(r *http.Request) (map[float64]bool, error) {
+	v := r.URL.Query().Get("heights")
+	if v == "" {
+		return nil, nil
+	}
+	heights := map[float64]bool{}
+	for _, s := range strings.Split(v, ",") {
+		h, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, errors.New("Invalid heights")
+		}
+		heights[h] = true
+	}
+	return heights, nil
+}
+
+// longSeriesQuery monta a consulta das colunas fields em formato longo (ts, height, v1, v2, ...), uma
+// linha por timestamp e altura. No LIDAR WindCube fields são os campos por altura; nas tabelas com
+// LevelColumn a altura vem dessa coluna; nas demais height é NULL. Os parâmetros dos filtros são
+// numerados a partir de len(args)+1.
+func longSeriesQuery(ds *instrumentDataset, opts readOptions, filter dataFilter, args []interface{}, fields ...string) (string, []interface{}) {
+	var values, aliases []string
+	for i := range fields {
+		aliases = append(aliases, fmt.Sprintf("v%d", i+1))
+	}
+
+	var inner string
+	switch {
+	case ds == lidarWindCubeDadosDataset:
+		var levels []string
+		for _, height := range models.LIDARWindCubeHeights {
+			row := []string{strconv.Itoa(height)}
+			for _, field := range fields {
+				row = append(row, opts.value(models.LIDARWindCubeColumn(field, height)))
+			}
+			levels = append(levels, "("+strings.Join(row, ", ")+")")
+		}
+		for _, alias := range aliases {
+			values = append(values, "p."+alias)
+		}
+		inner = fmt.Sprintf("SELECT timestamp AS ts, p.height::float8 AS height, %s FROM %s CROSS JOIN LATERAL (VALUES %s) AS p(height, %s)",
+			strings.Join(values, ", "), opts.source(ds), strings.Join(levels, ", "), strings.Join(aliases, ", "))
+	default:
+		height := "NULL::float8"
+		if ds.LevelColumn != "" {
+			height = ds.LevelColumn + "::float8"
+		}
+		for i, field := range fields {
+			values = append(values, opts.value(field)+" AS "+aliases[i])
+		}
+		inner = fmt.Sprintf("SELECT timestamp AS ts, %s AS height, %s FROM %s", height, strings.Join(values, ", "), opts.source(ds))
+	}
+
+	where, args := filter.whereClause(ds, args)
+	return inner + where, args
+}
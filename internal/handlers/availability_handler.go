@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"api/internal/availability"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maintenanceWindow é a distância máxima entre uma manutenção e uma interrupção para associá-las
+const maintenanceWindow = 24 * time.Hour
+
+// availabilityOutage é uma falha da linha do tempo com as manutenções próximas
+type availabilityOutage struct {
+	availability.Gap
+	Duration    float64  `json:"duration_seconds"`
+	Maintenance []string `json:"maintenance_ids"` // Manutenções entre um dia antes do início e um dia depois do fim
+}
+
+// availabilityMaintenance é uma entrada de MaintenanceHistory no período
+type availabilityMaintenance struct {
+	ID          string    `json:"maintenance_id"`
+	Date        time.Time `json:"maintenance_date"`
+	PerformedBy *string   `json:"performed_by"`
+	Description *string   `json:"description"`
+}
+
+// availabilityResponse é a resposta de /api/equipments/{id}/availability
+type availabilityResponse struct {
+	EquipmentID string                    `json:"equipment_id"`
+	Tables      []*availability.Coverage  `json:"tables"`
+	Outages     []*availabilityOutage     `json:"outages"`
+	Maintenance []availabilityMaintenance `json:"maintenance"`
+}
+
+// writeAvailabilityError responde aos erros do pacote availability
+func writeAvailabilityError(w http.ResponseWriter, err error) {
+	if errors.Is(err, availability.ErrUnknownEquipment) {
+		http.Error(w, "Equipment not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "Failed to compute availability", http.StatusInternalServerError)
+	log.Println("Failed to compute availability:", err)
+}
+
+// GetEquipmentAvailability retorna a disponibilidade diária de cada tabela de dados do equipamento e a
+// linha do tempo das interrupções gravadas pela detecção de falhas (gaps=true inclui as falhas curtas),
+// com as manutenções de MaintenanceHistory próximas de cada uma. Aceita start e end.
+func GetEquipmentAvailability(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseUUIDParam("equipment id", chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q := r.URL.Query()
+		start, err := parseTimeParam("start", q.Get("start"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		end, err := parseTimeParam("end", q.Get("end"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		includeGaps := q.Get("gaps") == "true"
+
+		response := availabilityResponse{EquipmentID: id, Tables: []*availability.Coverage{},
+			Outages: []*availabilityOutage{}, Maintenance: []availabilityMaintenance{}}
+		for _, table := range availability.Tables {
+			coverage, err := availability.Daily(r.Context(), db, id, table, start, end)
+			if err != nil {
+				writeAvailabilityError(w, err)
+				return
+			}
+			if coverage != nil {
+				response.Tables = append(response.Tables, coverage)
+			}
+		}
+
+		rows, err := db.Query(r.Context(), `
+			SELECT maintenanceid::text, maintenancedate, performedby, description FROM MaintenanceHistory
+			WHERE equipmentid = $1::uuid
+			  AND ($2::timestamptz IS NULL OR maintenancedate >= ($2::timestamptz - interval '1 day')::date)
+			  AND ($3::timestamptz IS NULL OR maintenancedate <= ($3::timestamptz + interval '1 day')::date)
+			ORDER BY maintenancedate`, id, start, end)
+		if err != nil {
+			http.Error(w, "Failed to query maintenance history", http.StatusInternalServerError)
+			log.Println("Failed to query maintenance history:", err)
+			return
+		}
+		for rows.Next() {
+			var m availabilityMaintenance
+			if err := rows.Scan(&m.ID, &m.Date, &m.PerformedBy, &m.Description); err != nil {
+				rows.Close()
+				http.Error(w, "Failed to scan maintenance history", http.StatusInternalServerError)
+				log.Println("Failed to scan maintenance history:", err)
+				return
+			}
+			response.Maintenance = append(response.Maintenance, m)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Error iterating over maintenance history rows", http.StatusInternalServerError)
+			log.Println("Error iterating over maintenance history rows:", err)
+			return
+		}
+
+		rows, err = db.Query(r.Context(), `
+			SELECT gapid::text, datatable, gapstart, gapend, missingsamples, isoutage, isopen FROM DataGaps
+			WHERE equipmentid = $1::uuid AND ($2::timestamptz IS NULL OR gapend > $2)
+			  AND ($3::timestamptz IS NULL OR gapstart < $3) AND (isoutage OR $4)
+			ORDER BY gapstart`, id, start, end, includeGaps)
+		if err != nil {
+			http.Error(w, "Failed to query data gaps", http.StatusInternalServerError)
+			log.Println("Failed to query data gaps:", err)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			o := &availabilityOutage{Maintenance: []string{}}
+			if err := rows.Scan(&o.ID, &o.Table, &o.Start, &o.End, &o.Missing, &o.Outage, &o.Open); err != nil {
+				http.Error(w, "Failed to scan data gaps", http.StatusInternalServerError)
+				log.Println("Failed to scan data gaps:", err)
+				return
+			}
+			o.Duration = o.End.Sub(o.Start).Seconds()
+			from := o.Start.UTC().Truncate(24 * time.Hour).Add(-maintenanceWindow)
+			to := o.End.UTC().Truncate(24 * time.Hour).Add(maintenanceWindow)
+			for _, m := range response.Maintenance {
+				if !m.Date.Before(from) && !m.Date.After(to) {
+					o.Maintenance = append(o.Maintenance, m.ID)
+				}
+			}
+			response.Outages = append(response.Outages, o)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Error iterating over data gap rows", http.StatusInternalServerError)
+			log.Println("Error iterating over data gap rows:", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// ScanEquipmentAvailability refaz a detecção de falhas do equipamento em todas as tabelas, a partir de
+// since (padrão: todo o histórico), com interrupções a partir de outage (padrão 1h)
+func ScanEquipmentAvailability(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseUUIDParam("equipment id", chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q := r.URL.Query()
+		since, err := parseTimeParam("since", q.Get("since"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		outage := availability.DefaultOutage
+		if v := q.Get("outage"); v != "" {
+			if outage, err = time.ParseDuration(v); err != nil || outage <= 0 {
+				http.Error(w, "Invalid outage: use a positive duration such as 30m or 2h", http.StatusBadRequest)
+				return
+			}
+		}
+
+		scans, err := availability.Scan(r.Context(), db, id, since, outage)
+		if err != nil {
+			writeAvailabilityError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(scans)
+	}
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"api/internal/parquet"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// bundleInstruments lista os datasets (hipertabelas com campaignid) incluídos no pacote da campanha
+var bundleInstruments = []string{"estacao-solarimetrica", "lidarwindcube", "sodar", "adcp"}
+
+// rowQuerier é satisfeita por *pgxpool.Pool e por pgx.Tx
+type rowQuerier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+// parquetColumnType converte o tipo PostgreSQL de uma coluna no tipo Parquet correspondente
+func parquetColumnType(oid uint32) parquet.Type {
+	switch oid {
+	case pgtype.Int2OID, pgtype.Int4OID:
+		return parquet.Int32
+	case pgtype.Int8OID:
+		return parquet.Int64
+	case pgtype.TimestamptzOID, pgtype.TimestampOID:
+		return parquet.Timestamp
+	case pgtype.TextOID, pgtype.VarcharOID:
+		return parquet.String
+	case pgtype.BoolOID:
+		return parquet.Boolean
+	}
+	return parquet.Double
+}
+
+// writeParquetSeries grava em Parquet as linhas do dataset que atendem ao filtro, em fluxo, com as
+// opções de leitura de opts. Retorna a quantidade de linhas gravadas.
+func writeParquetSeries(ctx context.Context, db rowQuerier, w io.Writer, ds *instrumentDataset, filter dataFilter, columns []dataColumn, opts readOptions) (int64, error) {
+	selectList := []string{"timestamp", "equipmentid::text"}
+	names := []string{"timestamp", "equipment_id"}
+	if ds.HasCampaign {
+		selectList = append(selectList, "campaignid::text")
+		names = append(names, "campaign_id")
+	}
+	for _, c := range columns {
+		selectList = append(selectList, opts.selectValue(c.Name))
+		names = append(names, c.JSON)
+	}
+	if opts.Include {
+		for _, c := range columns {
+			selectList = append(selectList, flagExpression(c.Name))
+			names = append(names, c.JSON+"_qc")
+		}
+	}
+
+	where, args := filter.whereClause(ds, nil)
+	rows, err := db.Query(ctx, fmt.Sprintf("SELECT %s FROM %s%s ORDER BY timestamp, %s",
+		strings.Join(selectList, ", "), opts.source(ds), where, ds.IDColumn), args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	schema := make([]parquet.Column, len(names))
+	for i, field := range rows.FieldDescriptions() {
+		schema[i] = parquet.Column{Name: names[i], Type: parquetColumnType(field.DataTypeOID), Optional: i > 0}
+	}
+	pw, err := parquet.NewWriter(w, schema, 0)
+	if err != nil {
+		return 0, err
+	}
+	pw.SetMetadata("source_table", ds.Table)
+
+	var count int64
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return count, err
+		}
+		if err := pw.WriteRow(values); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+	return count, pw.Close()
+}
+
+// startedWriter só grava os cabeçalhos HTTP no primeiro Write, permitindo responder com erro
+// enquanto nada foi enviado
+type startedWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	fileName    string
+	started     bool
+}
+
+func (s *startedWriter) Write(b []byte) (int, error) {
+	if !s.started {
+		s.started = true
+		s.w.Header().Set("Content-Type", s.contentType)
+		s.w.Header().Set("Content-Disposition", `attachment; filename="`+s.fileName+`"`)
+	}
+	return s.w.Write(b)
+}
+
+// bundleCoverage é a cobertura temporal de um equipamento em um dataset
+type bundleCoverage struct {
+	EquipmentID string     `json:"equipment_id"`
+	Rows        int64      `json:"rows"`
+	Start       *time.Time `json:"start"`
+	End         *time.Time `json:"end"`
+}
+
+// bundleInstrument descreve um arquivo Parquet do pacote
+type bundleInstrument struct {
+	Instrument string            `json:"instrument"`
+	Table      string            `json:"table"`
+	File       string            `json:"file,omitempty"` // Ausente quando o dataset não tem dados na campanha
+	Rows       int64             `json:"rows"`
+	Start      *time.Time        `json:"start"`
+	End        *time.Time        `json:"end"`
+	Coverage   []bundleCoverage  `json:"coverage"`
+	Headers    []json.RawMessage `json:"headers"`
+}
+
+// bundleManifest é o manifest.json do pacote da campanha
+type bundleManifest struct {
+	Campaign    json.RawMessage    `json:"campaign"`
+	GeneratedAt time.Time          `json:"generated_at"`
+	Equipment   []json.RawMessage  `json:"equipment"`
+	Instruments []bundleInstrument `json:"instruments"`
+}
+
+// queryJSONRows executa uma consulta que devolve uma coluna JSON por linha
+func queryJSONRows(ctx context.Context, db rowQuerier, sql string, args ...interface{}) ([]json.RawMessage, error) {
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []json.RawMessage{}
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		result = append(result, json.RawMessage(raw))
+	}
+	return result, rows.Err()
+}
+
+// buildBundleManifest reúne os metadados da campanha, dos equipamentos, dos cabeçalhos e a cobertura
+// temporal de cada dataset
+func buildBundleManifest(ctx context.Context, tx pgx.Tx, campaignID string) (*bundleManifest, error) {
+	campaign, err := queryJSONRows(ctx, tx, `
+		SELECT json_build_object('campaign_id', campaignid, 'name', campaignname, 'start_date', startdate,
+			'end_date', enddate, 'team_name', teamname, 'status', status, 'location', ST_AsText(location),
+			'description', description)::text
+		FROM campaigns WHERE campaignid = $1::uuid`, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	if len(campaign) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	manifest := &bundleManifest{Campaign: campaign[0], GeneratedAt: time.Now().UTC()}
+	equipmentIDs := map[string]bool{}
+	filter := dataFilter{CampaignID: campaignID}
+
+	for _, instrument := range bundleInstruments {
+		ds := instrumentDatasets[instrument]
+		entry := bundleInstrument{Instrument: instrument, Table: ds.Table, Coverage: []bundleCoverage{}}
+
+		where, args := filter.whereClause(ds, nil)
+		rows, err := tx.Query(ctx, fmt.Sprintf(`
+			SELECT equipmentid::text, count(*), min(timestamp), max(timestamp)
+			FROM %s%s GROUP BY equipmentid ORDER BY equipmentid`, ds.Table, where), args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var c bundleCoverage
+			if err := rows.Scan(&c.EquipmentID, &c.Rows, &c.Start, &c.End); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			entry.Coverage = append(entry.Coverage, c)
+			entry.Rows += c.Rows
+			if entry.Start == nil || c.Start != nil && c.Start.Before(*entry.Start) {
+				entry.Start = c.Start
+			}
+			if entry.End == nil || c.End != nil && c.End.After(*entry.End) {
+				entry.End = c.End
+			}
+			equipmentIDs[c.EquipmentID] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		if entry.Rows > 0 {
+			entry.File = instrument + ".parquet"
+		}
+
+		entry.Headers = []json.RawMessage{}
+		if table, ok := exportHeaderTables[ds.Table]; ok {
+			entry.Headers, err = queryJSONRows(ctx, tx, fmt.Sprintf(
+				"SELECT row_to_json(h)::text FROM %s h WHERE campaignid = $1::uuid ORDER BY uploaddate", table), campaignID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		manifest.Instruments = append(manifest.Instruments, entry)
+	}
+
+	ids := make([]string, 0, len(equipmentIDs))
+	for id := range equipmentIDs {
+		ids = append(ids, id)
+	}
+	manifest.Equipment, err = queryJSONRows(ctx, tx, `
+		SELECT json_build_object('equipment_id', equipmentid, 'name', equipmentname, 'type', equipmenttype,
+			'serial_number', serialnumber, 'model', model, 'manufacturer', manufacturer)::text
+		FROM equipments
+		WHERE equipmentid = ANY($1::uuid[])
+		   OR equipmentid IN (SELECT equipmentid FROM CampaignEquipment WHERE campaignid = $2::uuid)
+		ORDER BY equipmentname`, ids, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// GetCampaignBundle gera um zip com um arquivo Parquet por instrumento da campanha e um manifest.json
+// com os equipamentos, os cabeçalhos importados e a cobertura temporal. Os dados e o manifesto vêm
+// do mesmo snapshot e o zip é enviado em fluxo.
+func GetCampaignBundle(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		campaignID, err := parseUUIDParam("campaign id", chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+
+		tx, err := db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+		if err != nil {
+			http.Error(w, "Failed to build campaign bundle", http.StatusInternalServerError)
+			log.Println("Failed to build campaign bundle:", err)
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		manifest, err := buildBundleManifest(ctx, tx, campaignID)
+		if err == pgx.ErrNoRows {
+			http.Error(w, "Campaign not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to build campaign bundle", http.StatusInternalServerError)
+			log.Println("Failed to build campaign bundle:", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="campaign_`+campaignID+`.zip"`)
+		zw := zip.NewWriter(w)
+
+		entry, err := zw.Create("manifest.json")
+		if err == nil {
+			encoder := json.NewEncoder(entry)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(manifest)
+		}
+		if err != nil {
+			log.Println("Failed to write campaign bundle:", err)
+			return
+		}
+
+		filter := dataFilter{CampaignID: campaignID}
+		for _, instrument := range manifest.Instruments {
+			if instrument.File == "" {
+				continue
+			}
+			ds := instrumentDatasets[instrument.Instrument]
+			entry, err := zw.Create(instrument.File)
+			if err != nil {
+				log.Println("Failed to write campaign bundle:", err)
+				return
+			}
+			if _, err := writeParquetSeries(ctx, tx, entry, ds, filter, ds.Columns, readOptions{Derived: true}); err != nil {
+				log.Println("Failed to write campaign bundle:", err)
+				return
+			}
+		}
+
+		if err := zw.Close(); err != nil {
+			log.Println("Failed to write campaign bundle:", err)
+		}
+	}
+}
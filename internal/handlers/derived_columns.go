@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"api/internal/models"
+)
+
+// Grandezas derivadas do LIDAR WindCube: gerais e por altura (colunas <grandeza>_<altura>m)
+var (
+	lidarDerivedFields       = []string{"airdensity"}
+	lidarDerivedHeightFields = []string{"powerdensity"}
+)
+
+// stationSearchWindow é a maior diferença de tempo entre uma linha do LIDAR e a leitura da estação
+// solarimétrica usada no lugar dos sensores meteorológicos do LIDAR
+const stationSearchWindow = "30 minutes"
+
+// hasDerived indica se alguma das colunas é derivada
+func (ds *instrumentDataset) hasDerived(columns []dataColumn) bool {
+	for _, c := range columns {
+		if ds.Derived[heightSuffix.ReplaceAllString(c.Name, "")] {
+			return true
+		}
+	}
+	return false
+}
+
+// derivedSubquery acrescenta a from as colunas derivadas do dataset, calculadas a partir dos valores
+// já mascarados pelas opções de leitura:
+//   - airdensity: densidade do ar úmido (kg/m³) pela função moist_air_density do banco. No LIDAR
+//     WindCube usa Pressure/ExtTemp/RelHumidity do próprio equipamento e, na falta deles, a leitura
+//     mais próxima no tempo (até stationSearchWindow) da estação solarimétrica mais próxima do LIDAR;
+//   - powerdensity_<altura>m: densidade de potência do vento (W/m²), ½·ρ·mean(U³). Como mean(U³) é
+//     maior que Ū³, o cubo da média é corrigido pela dispersão σ do intervalo: mean(U³) ≈ Ū³ + 3·Ū·σ²
+//     (a assimetria da distribuição é desprezada). Sem dispersão registrada, σ = 0.
+func (o readOptions) derivedSubquery(ds *instrumentDataset, from string) string {
+	switch ds {
+	case estacaoSolarimetricaDataset:
+		return fmt.Sprintf("(SELECT d.*, moist_air_density(%s, %s, %s) AS airdensity FROM %s d)",
+			o.value("bp_mbar_avg"), o.value("airtc_avg"), o.value("rh"), from)
+	case lidarWindCubeDadosDataset:
+		selectList := []string{"d.*", "x.airdensity"}
+		for _, height := range models.LIDARWindCubeHeights {
+			selectList = append(selectList, fmt.Sprintf("0.5 * x.airdensity * (power(%[1]s, 3) + 3 * %[1]s * power(COALESCE(%[2]s, 0), 2)) AS %[3]s",
+				o.value(models.LIDARWindCubeColumn("WindSpeed", height)), o.value(models.LIDARWindCubeColumn("WindSpeedDispersion", height)),
+				models.LIDARWindCubeColumn("PowerDensity", height)))
+		}
+		// COALESCE só executa a busca na estação quando faltam dados meteorológicos no LIDAR
+		station := fmt.Sprintf(`SELECT moist_air_density(s.bp_mbar_avg, s.airtc_avg, s.rh)
+			FROM estacaosolarimetricadados s
+			JOIN equipments se ON se.equipmentid = s.equipmentid
+			JOIN equipments le ON le.equipmentid = d.equipmentid
+			WHERE s.timestamp BETWEEN d.timestamp - interval '%[1]s' AND d.timestamp + interval '%[1]s'
+				AND s.bp_mbar_avg IS NOT NULL AND s.airtc_avg IS NOT NULL
+			ORDER BY ST_Distance(se.location, le.location), abs(extract(epoch FROM s.timestamp - d.timestamp))
+			LIMIT 1`, stationSearchWindow)
+		return fmt.Sprintf(`(SELECT %s FROM %s d
+		CROSS JOIN LATERAL (SELECT COALESCE(moist_air_density(%s, %s, %s), (%s)) AS airdensity) x)`,
+			strings.Join(selectList, ", "), from,
+			o.value("pressure"), o.value("exttemp"), o.value("relhumidity"), station)
+	}
+	return from
+}
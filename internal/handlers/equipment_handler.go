@@ -32,6 +32,10 @@ func CreateEquipment(db *pgxpool.Pool) http.HandlerFunc {
 			http.Error(w, "Invalid input", http.StatusBadRequest)
 			return
 		}
+		if equipment.LoggingInterval != nil && *equipment.LoggingInterval <= 0 {
+			http.Error(w, "logging_interval must be a positive number of seconds", http.StatusBadRequest)
+			return
+		}
 
 		tx, err := db.Begin(context.Background())
 		if err != nil {
@@ -47,15 +51,16 @@ func CreateEquipment(db *pgxpool.Pool) http.HandlerFunc {
 			`INSERT INTO equipments 
 				(equipmentname, description, equipmenttype, serialnumber, model, manufacturer, frequency, calibrationdate, 
 				lastmaintenancedate, maintainedby, manufacturingdate, acquisitiondate, datatypes, notes, 
-				warrantyexpirationdate, operatingstatus, location, equipment_image) 
+				warrantyexpirationdate, operatingstatus, location, equipment_image, logginginterval) 
 			VALUES 
-				($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, ST_GeogFromText($17), $18)
+				($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, ST_GeogFromText($17), $18, $19)
 			RETURNING equipmentid`,
 			equipment.EquipmentName, equipment.Description, equipment.Type, equipment.SerialNumber, equipment.Model,
 			equipment.Manufacturer, equipment.Frequency, equipment.CalibrationDate, equipment.LastMaintenanceDate,
 			equipment.MaintainedBy, equipment.ManufacturingDate, equipment.AcquisitionDate, equipment.DataTypes,
 			equipment.Notes, equipment.WarrantyExpirationDate, equipment.OperatingStatus, equipment.Location, // WKT
 			equipment.EquipmentImage, // Novo campo EquipmentImage
+			equipment.LoggingInterval,
 		).Scan(&equipmentID)
 		if err != nil {
 			http.Error(w, "Failed to insert equipment", http.StatusInternalServerError)
@@ -168,7 +173,7 @@ func GetEquipmentByID(db *pgxpool.Pool) http.HandlerFunc {
 				equipmentid, equipmentname, description, equipmenttype, serialnumber, model, 
 				manufacturer, frequency, calibrationdate, lastmaintenancedate, maintainedby,
 				manufacturingdate, acquisitiondate, datatypes, notes, 
-				warrantyexpirationdate, operatingstatus, ST_AsText(location), equipment_image 
+				warrantyexpirationdate, operatingstatus, ST_AsText(location), equipment_image, logginginterval 
 			FROM equipments WHERE equipmentid=$1`, id).Scan(
 			&equipment.ID, &equipment.EquipmentName, &equipment.Description, &equipment.Type,
 			&equipment.SerialNumber, &equipment.Model, &equipment.Manufacturer, &equipment.Frequency,
@@ -176,6 +181,7 @@ func GetEquipmentByID(db *pgxpool.Pool) http.HandlerFunc {
 			&equipment.ManufacturingDate, &equipment.AcquisitionDate, &equipment.DataTypes, &equipment.Notes,
 			&equipment.WarrantyExpirationDate, &equipment.OperatingStatus, &equipment.Location,
 			&equipment.EquipmentImage, // Inclui o campo de imagem
+			&equipment.LoggingInterval,
 		)
 		if err != nil {
 			http.Error(w, "Equipment not found", http.StatusNotFound)
@@ -225,6 +231,10 @@ func UpdateEquipment(db *pgxpool.Pool) http.HandlerFunc {
 			http.Error(w, "Invalid input", http.StatusBadRequest)
 			return
 		}
+		if equipment.LoggingInterval != nil && *equipment.LoggingInterval <= 0 {
+			http.Error(w, "logging_interval must be a positive number of seconds", http.StatusBadRequest)
+			return
+		}
 
 		tx, err := db.Begin(context.Background())
 		if err != nil {
@@ -239,13 +249,15 @@ func UpdateEquipment(db *pgxpool.Pool) http.HandlerFunc {
 				equipmentname=$1, description=$2, equipmenttype=$3, serialnumber=$4, model=$5, manufacturer=$6, 
 				frequency=$7, calibrationdate=$8, lastmaintenancedate=$9, maintainedby=$10, 
 				manufacturingdate=$11, acquisitiondate=$12, datatypes=$13, notes=$14, 
-				warrantyexpirationdate=$15, operatingstatus=$16, location=ST_GeogFromText($17), equipment_image=$18 
-			WHERE equipmentid=$19`,
+				warrantyexpirationdate=$15, operatingstatus=$16, location=ST_GeogFromText($17), equipment_image=$18, 
+				logginginterval=$19 
+			WHERE equipmentid=$20`,
 			equipment.EquipmentName, equipment.Description, equipment.Type, equipment.SerialNumber, equipment.Model,
 			equipment.Manufacturer, equipment.Frequency, equipment.CalibrationDate, equipment.LastMaintenanceDate,
 			equipment.MaintainedBy, equipment.ManufacturingDate, equipment.AcquisitionDate, equipment.DataTypes,
 			equipment.Notes, equipment.WarrantyExpirationDate, equipment.OperatingStatus, equipment.Location, // WKT
 			equipment.EquipmentImage, // Novo campo EquipmentImage
+			equipment.LoggingInterval,
 			id,
 		)
 		if err != nil {
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"api/internal/ingest"
 	"api/internal/models"
 	"context"
 	"encoding/json"
@@ -22,33 +23,9 @@ func EstacaoSolarimetricaDadosRouter(db *pgxpool.Pool) http.Handler {
 	return r
 }
 
-// GetAllEstacaoSolarimetricaDados retorna todos os dados da Estação Solarimétrica
+// GetAllEstacaoSolarimetricaDados retorna os dados da Estação Solarimétrica, com filtros de período, equipamento e campanha e paginação
 func GetAllEstacaoSolarimetricaDados(db *pgxpool.Pool) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		rows, err := db.Query(context.Background(), `
-			SELECT id, equipmentid, campaignid, timestamp, battv, ptemp_c, winddir, ws_ms_avg, ws_ms_max, ws_ms_min, airtc_avg, airtc_max, airtc_min, rh_max, rh_min, rh, rain_mm_tot, bp_mbar_avg, bp_mbar_max, bp_mbar_min, slrw_cmp10_horizontal_avg, slrw_cmp10_horizontal_max, slrw_cmp10_horizontal_min, slrkj_cmp10_horizontal_tot 
-			FROM EstacaoSolarimetricaDados
-		`)
-		if err != nil {
-			http.Error(w, "Failed to query Estacao Solarimétrica data", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []models.EstacaoSolarimetricaDados
-		for rows.Next() {
-			var datum models.EstacaoSolarimetricaDados
-			err := rows.Scan(&datum.EstacaoSolarimetricaDadosID, &datum.EquipmentID, &datum.CampaignID, &datum.Timestamp, &datum.BattV, &datum.PTemp_C, &datum.WindDir, &datum.WS_ms_Avg, &datum.WS_ms_Max, &datum.WS_ms_Min, &datum.AirTC_Avg, &datum.AirTC_Max, &datum.AirTC_Min, &datum.RH_Max, &datum.RH_Min, &datum.RH, &datum.Rain_mm_Tot, &datum.BP_mbar_Avg, &datum.BP_mbar_Max, &datum.BP_mbar_Min, &datum.SlrW_CMP10_Horizontal_Avg, &datum.SlrW_CMP10_Horizontal_Max, &datum.SlrW_CMP10_Horizontal_Min, &datum.SlrkJ_CMP10_Horizontal_Tot)
-			if err != nil {
-				http.Error(w, "Failed to scan Estacao Solarimétrica data", http.StatusInternalServerError)
-				return
-			}
-			data = append(data, datum)
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
-	}
+	return listInstrumentData(db, estacaoSolarimetricaDataset)
 }
 
 // GetEstacaoSolarimetricaDadosByID retorna os dados da Estação Solarimétrica por ID
@@ -147,3 +124,32 @@ func DeleteEstacaoSolarimetricaDados(db *pgxpool.Pool) http.HandlerFunc {
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// UploadEstacaoSolarimetricaTOA5 importa um arquivo TOA5 (.dat) do registrador Campbell da estação solarimétrica.
+// Campos opcionais do formulário: "aliases" (JSON {"campo": "coluna"}) e "timezone" (ex: "UTC-03:00").
+func UploadEstacaoSolarimetricaTOA5(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		form, err := parseUploadForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer form.File.Close()
+
+		var opts ingest.TOA5Options
+		if aliases := r.FormValue("aliases"); aliases != "" {
+			if err := json.Unmarshal([]byte(aliases), &opts.Aliases); err != nil {
+				http.Error(w, "Invalid aliases", http.StatusBadRequest)
+				return
+			}
+		}
+		if opts.Location, err = formLocation(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		runUploadJob(w, r, db, form, ingest.ParserTOA5, func(target ingest.Target) (*ingest.Summary, error) {
+			return ingest.LoadTOA5(r.Context(), db, form.File, form.FileName, target, opts)
+		})
+	}
+}
@@ -0,0 +1,789 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"api/internal/models"
+	"api/internal/netcdf"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// columnMeta descreve as unidades e os nomes CF de uma coluna de dados
+type columnMeta struct {
+	Units        string
+	StandardName string
+	LongName     string
+}
+
+// columnMetadata associa as colunas das tabelas de dados aos metadados usados nas exportações
+var columnMetadata = map[string]columnMeta{
+	// LIDAR WindCube (sem o sufixo de altura)
+	"windspeed":           {"m s-1", "wind_speed", "Horizontal wind speed"},
+	"windspeeddispersion": {"m s-1", "", "Horizontal wind speed standard deviation"},
+	"windspeedmin":        {"m s-1", "", "Minimum horizontal wind speed"},
+	"windspeedmax":        {"m s-1", "", "Maximum horizontal wind speed"},
+	"winddirection":       {"degree", "wind_from_direction", "Wind direction"},
+	"zwind":               {"m s-1", "upward_air_velocity", "Vertical wind speed"},
+	"zwinddispersion":     {"m s-1", "", "Vertical wind speed standard deviation"},
+	"cnr":                 {"dB", "", "Carrier-to-noise ratio"},
+	"cnrmin":              {"dB", "", "Minimum carrier-to-noise ratio"},
+	"doppspectbroad":      {"m s-1", "", "Doppler spectrum broadening"},
+	"dataavailability":    {"%", "", "Data availability"},
+	"inttemp":             {"degC", "", "Internal temperature"},
+	"exttemp":             {"degC", "air_temperature", "External temperature"},
+	"pressure":            {"hPa", "air_pressure", "Pressure"},
+	"relhumidity":         {"%", "relative_humidity", "Relative humidity"},
+	"wipercount":          {"1", "", "Wiper count"},
+	"vbatt":               {"V", "", "Battery voltage"},
+
+	// SODAR
+	"height":              {"m", "height", "Height above ground"},
+	"u_geo":               {"m s-1", "eastward_wind", "Eastward wind"},
+	"v_geo":               {"m s-1", "northward_wind", "Northward wind"},
+	"u":                   {"m s-1", "", "Wind component U (antenna frame)"},
+	"v":                   {"m s-1", "", "Wind component V (antenna frame)"},
+	"w":                   {"m s-1", "upward_air_velocity", "Vertical wind speed"},
+	"sigmau":              {"m s-1", "", "Standard deviation of U"},
+	"sigmau_radial":       {"m s-1", "", "Radial standard deviation of U"},
+	"sigmav":              {"m s-1", "", "Standard deviation of V"},
+	"sigmav_radial":       {"m s-1", "", "Radial standard deviation of V"},
+	"sigmaw":              {"m s-1", "", "Standard deviation of W"},
+	"windshear":           {"s-1", "", "Wind shear"},
+	"windsheardirection":  {"degree", "", "Wind shear direction"},
+	"sigmaspeed":          {"m s-1", "", "Wind speed standard deviation"},
+	"sigmalateral":        {"m s-1", "", "Lateral wind standard deviation"},
+	"sigmaphi":            {"degree", "", "Elevation angle standard deviation"},
+	"sigmatheta":          {"degree", "", "Wind direction standard deviation"},
+	"turbulenceintensity": {"1", "", "Turbulence intensity"},
+	"pgz":                 {"1", "", "Pasquill-Gifford stability class"},
+	"tke":                 {"m2 s-2", "", "Turbulent kinetic energy"},
+	"edr":                 {"m2 s-3", "", "Eddy dissipation rate"},
+	"backscatterraw":      {"1", "", "Raw backscatter"},
+	"backscatter":         {"1", "", "Backscatter"},
+	"backscatterid":       {"1", "", "Backscatter layer identifier"},
+	"ct2":                 {"", "", "Temperature structure parameter (K2 m-2/3)"},
+
+	// ADCP (PD0)
+	"ensemblenumber":   {"1", "", "Ensemble number"},
+	"cell":             {"1", "", "Depth cell number"},
+	"distance":         {"m", "", "Distance from transducer to cell center"},
+	"depth":            {"m", "depth", "Depth of cell center"},
+	"currentspeed":     {"m s-1", "sea_water_speed", "Current speed"},
+	"currentdirection": {"degree", "sea_water_velocity_to_direction", "Current direction"},
+	"heading":          {"degree", "platform_orientation", "Heading"},
+	"pitch":            {"degree", "platform_pitch", "Pitch"},
+	"roll":             {"degree", "platform_roll", "Roll"},
+	"watertemperature": {"degC", "sea_water_temperature", "Water temperature"},
+	"salinity":         {"1e-3", "sea_water_salinity", "Salinity"},
+	"transducerdepth":  {"m", "", "Transducer depth"},
+	"speedofsound":     {"m s-1", "speed_of_sound_in_sea_water", "Speed of sound"},
+
+	// Estação solarimétrica
+	"battv":        {"V", "", "Battery voltage"},
+	"ptemp_c":      {"degC", "", "Logger panel temperature"},
+	"winddir":      {"degree", "wind_from_direction", "Wind direction"},
+	"rh":           {"%", "relative_humidity", "Relative humidity"},
+	"rain_mm_tot":  {"mm", "thickness_of_rainfall_amount", "Total precipitation"},
+	"solarazimuth": {"degree", "solar_azimuth_angle", "Solar azimuth"},
+	"sunelevation": {"degree", "solar_elevation_angle", "Sun elevation"},
+	"hourangle":    {"degree", "", "Hour angle"},
+	"declination":  {"degree", "", "Solar declination"},
+	"airmass":      {"1", "", "Air mass"},
+
+	// Tabelas legadas
+	"watercurrentspeed":     {"m s-1", "sea_water_speed", "Water current speed"},
+	"watercurrentdirection": {"degree", "sea_water_velocity_to_direction", "Water current direction"},
+	"temperature":           {"degC", "air_temperature", "Temperature"},
+	"humidity":              {"%", "relative_humidity", "Relative humidity"},
+	"solarradiation":        {"W m-2", "surface_downwelling_shortwave_flux_in_air", "Solar radiation"},
+	"barometricpressure":    {"hPa", "air_pressure", "Barometric pressure"},
+
+	// Variáveis derivadas
+	"airdensity":   {"kg m-3", "air_density", "Moist air density"},
+	"powerdensity": {"W m-2", "", "Wind power density"},
+}
+
+// columnPrefixMetadata cobre as famílias de colunas com sufixos (_avg, _max, número do feixe, ...)
+var columnPrefixMetadata = []struct {
+	Prefix string
+	Meta   columnMeta
+}{
+	{"ws_ms_", columnMeta{"m s-1", "wind_speed", "Wind speed"}},
+	{"airtc_", columnMeta{"degC", "air_temperature", "Air temperature"}},
+	{"rh_", columnMeta{"%", "relative_humidity", "Relative humidity"}},
+	{"bp_mbar_", columnMeta{"hPa", "air_pressure", "Barometric pressure"}},
+	{"slrw_cmp10_horizontal", columnMeta{"W m-2", "surface_downwelling_shortwave_flux_in_air", "Global horizontal irradiance"}},
+	{"slrw_", columnMeta{"W m-2", "", "Irradiance"}},
+	{"slrkj_", columnMeta{"kJ m-2", "", "Irradiation"}},
+	{"velocity", columnMeta{"m s-1", "", "Velocity"}},
+	{"correlation", columnMeta{"1", "", "Correlation magnitude"}},
+	{"echointensity", columnMeta{"1", "", "Echo intensity"}},
+	{"percentgood", columnMeta{"%", "", "Percent good"}},
+}
+
+// heightSuffix identifica o sufixo de altura das colunas de LIDARWindCubeDados (ex: _40m)
+var heightSuffix = regexp.MustCompile(`_\d+m$`)
+
+// describeColumn retorna os metadados de uma coluna, ignorando o sufixo de altura
+func describeColumn(name string) columnMeta {
+	base := heightSuffix.ReplaceAllString(name, "")
+	if meta, ok := columnMetadata[base]; ok {
+		return meta
+	}
+	for _, p := range columnPrefixMetadata {
+		if strings.HasPrefix(base, p.Prefix) {
+			meta := p.Meta
+			meta.LongName += " (" + base + ")"
+			return meta
+		}
+	}
+	return columnMeta{LongName: base}
+}
+
+// exportHeaderTables associa cada tabela de dados à tabela de cabeçalhos dos arquivos importados
+var exportHeaderTables = map[string]string{
+	"estacaosolarimetricadados": "estacaosolarimetricaheaders",
+	"lidarwindcubedados":        "lidarwindcubeheaders",
+	"sodardados":                "sodarheaders",
+	"adcpdados":                 "adcpheaders",
+}
+
+// headerVariableAttributes define quais colunas dos cabeçalhos viram atributos de quais variáveis
+var headerVariableAttributes = map[string][]struct{ Column, Variable, Attribute string }{
+	"lidarwindcubeheaders": {
+		{"installationoffsetagl", "height", "installation_offset_agl"},
+		{"altitudesagl", "height", "configured_altitudes_agl"},
+		{"directionoffset", "winddirection", "direction_offset"},
+		{"declination", "winddirection", "magnetic_declination"},
+		{"cnrthreshold", "cnr", "cnr_threshold"},
+		{"cnrthreshold", "windspeed", "cnr_threshold"},
+	},
+	"sodarheaders": {
+		{"heightaboveground", "height", "antenna_height_above_ground"},
+		{"heightabovesealevel", "height", "antenna_height_above_sea_level"},
+		{"antennaazimuthangle", "winddirection", "antenna_azimuth_angle"},
+	},
+	"adcpheaders": {
+		{"cellsize", "cell", "cell_size"},
+		{"bin1distance", "cell", "bin1_distance"},
+		{"blank", "cell", "blank_distance"},
+		{"beamsfacingup", "cell", "beams_facing_up"},
+		{"coordinatesystem", "velocity1", "coordinate_system"},
+		{"coordinatesystem", "velocity2", "coordinate_system"},
+		{"coordinatesystem", "velocity3", "coordinate_system"},
+		{"coordinatesystem", "velocity4", "coordinate_system"},
+	},
+}
+
+// exportLayout descreve como as colunas de um dataset se distribuem nas dimensões do NetCDF
+type exportLayout struct {
+	LevelName string       // Dimensão vertical (height ou cell); vazia em séries simples
+	Levels    []float64    // Valores da dimensão vertical
+	Profile   []dataColumn // Variáveis (time, nível)
+	Scalar    []dataColumn // Variáveis (time)
+	wide      bool         // Uma coluna por nível (LIDARWindCubeDados) em vez de uma linha por nível
+}
+
+// exportColumns retorna as colunas pedidas em "columns" ou todas as do dataset
+func exportColumns(r *http.Request, ds *instrumentDataset) ([]dataColumn, error) {
+	list := r.URL.Query().Get("columns")
+	if list == "" {
+		return ds.Columns, nil
+	}
+	var columns []dataColumn
+	for _, name := range strings.Split(list, ",") {
+		c, ok := ds.column(name)
+		if !ok {
+			return nil, fmt.Errorf("Unknown column: %s", strings.TrimSpace(name))
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// ExportSeries exporta a série de um instrumento em CSV (format=csv, padrão), Parquet (format=parquet)
+// ou NetCDF CF-1.8 (format=netcdf), com os filtros start, end, equipment_id e campaign_id e a seleção em "columns".
+// qc_max mascara os valores reprovados no QC; qc_flags=true (CSV e Parquet) acrescenta uma coluna
+// <coluna>_qc com o código de qualidade de cada valor. No LIDAR WindCube, min_availability e min_cnr
+// mascaram os valores de vento de cada altura (sem contagem, já que o arquivo é enviado em fluxo).
+// Os dados são lidos e enviados em fluxo, sem carregar o resultado inteiro em memória.
+func ExportSeries(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		instrument := chi.URLParam(r, "instrument")
+		ds, ok := instrumentDatasets[instrument]
+		if !ok {
+			http.Error(w, "Unknown instrument", http.StatusNotFound)
+			return
+		}
+
+		filter, err := parseDataFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts, err := parseReadOptions(r, ds)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch format := r.URL.Query().Get("format"); format {
+		case "", "csv":
+			columns, err := exportColumns(r, ds)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			opts.Derived = ds.hasDerived(columns)
+			exportCSV(w, r, db, instrument, ds, filter, columns, opts)
+		case "parquet":
+			columns, err := exportColumns(r, ds)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			opts.Derived = ds.hasDerived(columns)
+			sw := &startedWriter{w: w, contentType: "application/vnd.apache.parquet", fileName: exportFileName(instrument, filter, "parquet")}
+			if _, err := writeParquetSeries(r.Context(), db, sw, ds, filter, columns, opts); err != nil {
+				if !sw.started {
+					http.Error(w, "Failed to export "+ds.Name, http.StatusInternalServerError)
+				}
+				log.Println("Failed to export", ds.Name+":", err)
+			}
+		case "netcdf", "nc":
+			if filter.EquipmentID == "" {
+				http.Error(w, "equipment_id is required for NetCDF export", http.StatusBadRequest)
+				return
+			}
+			if opts.Include {
+				http.Error(w, "qc_flags is not supported for NetCDF export; use qc_max", http.StatusBadRequest)
+				return
+			}
+			exportNetCDF(w, r, db, instrument, ds, filter, opts)
+		default:
+			http.Error(w, "Invalid format: use csv, parquet or netcdf", http.StatusBadRequest)
+		}
+	}
+}
+
+// exportFileName monta o nome do arquivo entregue no Content-Disposition
+func exportFileName(instrument string, filter dataFilter, extension string) string {
+	name := instrument
+	if filter.Start != nil {
+		name += "_" + filter.Start.UTC().Format("20060102")
+	}
+	if filter.End != nil {
+		name += "_" + filter.End.UTC().Format("20060102")
+	}
+	return name + "." + extension
+}
+
+// exportCSV envia as linhas em CSV: cabeçalho com os nomes, uma linha de unidades e os dados
+func exportCSV(w http.ResponseWriter, r *http.Request, db *pgxpool.Pool, instrument string, ds *instrumentDataset, filter dataFilter, columns []dataColumn, opts readOptions) {
+	selectList := []string{"timestamp", "equipmentid::text"}
+	names := []string{"timestamp", "equipment_id"}
+	units := []string{"UTC", ""}
+	if ds.HasCampaign {
+		selectList = append(selectList, "campaignid::text")
+		names = append(names, "campaign_id")
+		units = append(units, "")
+	}
+	for _, c := range columns {
+		selectList = append(selectList, opts.selectValue(c.Name))
+		names = append(names, c.JSON)
+		units = append(units, describeColumn(c.Name).Units)
+	}
+	if opts.Include {
+		for _, c := range columns {
+			selectList = append(selectList, flagExpression(c.Name))
+			names = append(names, c.JSON+"_qc")
+			units = append(units, "")
+		}
+	}
+
+	where, args := filter.whereClause(ds, nil)
+	sql := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY timestamp, %s",
+		strings.Join(selectList, ", "), opts.source(ds), where, ds.IDColumn)
+	rows, err := db.Query(r.Context(), sql, args...)
+	if err != nil {
+		http.Error(w, "Failed to query "+ds.Name, http.StatusInternalServerError)
+		log.Println("Failed to export", ds.Name+":", err)
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+exportFileName(instrument, filter, "csv")+`"`)
+	cw := csv.NewWriter(w)
+	cw.Write(names)
+	cw.Write(units)
+
+	record := make([]string, len(names))
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			log.Println("Failed to export", ds.Name+":", err)
+			return
+		}
+		for i, v := range values {
+			record[i] = formatCSVValue(v)
+		}
+		if err := cw.Write(record); err != nil {
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Println("Failed to export", ds.Name+":", err)
+	}
+	cw.Flush()
+}
+
+// formatCSVValue formata um valor devolvido pelo pgx para o CSV
+func formatCSVValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return x.UTC().Format(time.RFC3339)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32)
+	case string:
+		return x
+	}
+	return fmt.Sprint(v)
+}
+
+// exportNetCDF envia a série de um equipamento como NetCDF CF-1.8. A contagem de registros, os
+// níveis e os dados são lidos no mesmo snapshot (REPEATABLE READ) para que o cabeçalho seja exato.
+func exportNetCDF(w http.ResponseWriter, r *http.Request, db *pgxpool.Pool, instrument string, ds *instrumentDataset, filter dataFilter, opts readOptions) {
+	ctx := r.Context()
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		http.Error(w, "Failed to export "+ds.Name, http.StatusInternalServerError)
+		log.Println("Failed to export", ds.Name+":", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	layout, err := buildExportLayout(ctx, tx, r, ds, filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.Derived = ds.hasDerived(layout.Scalar) || ds.hasDerived(layout.Profile)
+
+	where, args := filter.whereClause(ds, nil)
+	var numRecs int
+	err = tx.QueryRow(ctx, fmt.Sprintf("SELECT count(DISTINCT timestamp) FROM %s%s", ds.Table, where), args...).Scan(&numRecs)
+	if err != nil {
+		http.Error(w, "Failed to export "+ds.Name, http.StatusInternalServerError)
+		log.Println("Failed to export", ds.Name+":", err)
+		return
+	}
+
+	header, err := loadExportHeader(ctx, tx, ds, filter)
+	if err != nil {
+		http.Error(w, "Failed to export "+ds.Name, http.StatusInternalServerError)
+		log.Println("Failed to export", ds.Name+":", err)
+		return
+	}
+
+	file := buildNetCDFFile(instrument, ds, filter, layout, header, numRecs)
+
+	// Dados: uma linha por timestamp (mais recente em caso de duplicata) ou uma linha por nível
+	var sql string
+	if layout.LevelName != "" && !layout.wide {
+		selectList := []string{"timestamp", ds.LevelColumn}
+		for _, c := range layout.Profile {
+			selectList = append(selectList, opts.selectValue(c.Name))
+		}
+		sql = fmt.Sprintf("SELECT %s FROM %s%s ORDER BY timestamp, %s, %s",
+			strings.Join(selectList, ", "), opts.source(ds), where, ds.LevelColumn, ds.IDColumn)
+	} else {
+		selectList := []string{"timestamp"}
+		for _, c := range layout.Scalar {
+			selectList = append(selectList, opts.selectValue(c.Name))
+		}
+		for _, c := range layout.Profile {
+			for _, height := range layout.Levels {
+				selectList = append(selectList, opts.selectValue(models.LIDARWindCubeColumn(c.Name, int(height))))
+			}
+		}
+		sql = fmt.Sprintf("SELECT DISTINCT ON (timestamp) %s FROM %s%s ORDER BY timestamp, %s DESC",
+			strings.Join(selectList, ", "), opts.source(ds), where, ds.IDColumn)
+	}
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		http.Error(w, "Failed to export "+ds.Name, http.StatusInternalServerError)
+		log.Println("Failed to export", ds.Name+":", err)
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "application/x-netcdf")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+exportFileName(instrument, filter, "nc")+`"`)
+	nw, err := netcdf.NewWriter(w, file)
+	if err != nil {
+		log.Println("Failed to export", ds.Name+":", err)
+		return
+	}
+	if layout.LevelName != "" {
+		if err := nw.Write(layout.Levels); err != nil {
+			log.Println("Failed to export", ds.Name+":", err)
+			return
+		}
+	}
+
+	if err := streamNetCDFRecords(rows, nw, layout); err != nil {
+		log.Println("Failed to export", ds.Name+":", err)
+		return
+	}
+	if err := nw.Close(); err != nil {
+		log.Println("Failed to export", ds.Name+":", err)
+	}
+}
+
+// buildExportLayout distribui as colunas pedidas entre variáveis de perfil e de série simples
+func buildExportLayout(ctx context.Context, tx pgx.Tx, r *http.Request, ds *instrumentDataset, filter dataFilter) (*exportLayout, error) {
+	layout := &exportLayout{}
+
+	if ds.Table == "lidarwindcubedados" {
+		// Colunas pedidas pelo nome da grandeza (windspeed) ou pela coluna de uma altura (windspeed_40m)
+		wanted := map[string]bool{}
+		for _, name := range strings.Split(r.URL.Query().Get("columns"), ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				wanted[heightSuffix.ReplaceAllString(name, "")] = true
+			}
+		}
+		layout.LevelName, layout.wide = "height", true
+		for _, height := range models.LIDARWindCubeHeights {
+			layout.Levels = append(layout.Levels, float64(height))
+		}
+		for _, field := range models.LIDARWindCubeBaseFields {
+			if name := strings.ToLower(field); len(wanted) == 0 || wanted[name] {
+				layout.Scalar = append(layout.Scalar, dataColumn{name, name})
+			}
+		}
+		for _, name := range lidarDerivedFields {
+			if len(wanted) == 0 || wanted[name] {
+				layout.Scalar = append(layout.Scalar, dataColumn{name, name})
+			}
+		}
+		for _, field := range models.LIDARWindCubeHeightFields {
+			if name := strings.ToLower(field); len(wanted) == 0 || wanted[name] {
+				layout.Profile = append(layout.Profile, dataColumn{name, name})
+			}
+		}
+		for _, name := range lidarDerivedHeightFields {
+			if len(wanted) == 0 || wanted[name] {
+				layout.Profile = append(layout.Profile, dataColumn{name, name})
+			}
+		}
+		if len(layout.Scalar)+len(layout.Profile) == 0 {
+			return nil, fmt.Errorf("No valid columns selected")
+		}
+		return layout, nil
+	}
+
+	columns, err := exportColumns(r, ds)
+	if err != nil {
+		return nil, err
+	}
+	if ds.LevelColumn == "" {
+		layout.Scalar = columns
+		return layout, nil
+	}
+
+	layout.LevelName = ds.LevelColumn
+	for _, c := range columns {
+		if c.Name != ds.LevelColumn {
+			layout.Profile = append(layout.Profile, c)
+		}
+	}
+	where, args := filter.whereClause(ds, nil)
+	if where == "" {
+		where = " WHERE "
+	} else {
+		where += " AND "
+	}
+	rows, err := tx.Query(ctx, fmt.Sprintf("SELECT DISTINCT %[1]s::float8 FROM %[2]s%[3]s%[1]s IS NOT NULL ORDER BY 1",
+		ds.LevelColumn, ds.Table, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var level float64
+		if err := rows.Scan(&level); err != nil {
+			return nil, err
+		}
+		layout.Levels = append(layout.Levels, level)
+	}
+	return layout, rows.Err()
+}
+
+// loadExportHeader lê o cabeçalho mais recente do equipamento (e da campanha, quando informada)
+func loadExportHeader(ctx context.Context, tx pgx.Tx, ds *instrumentDataset, filter dataFilter) (map[string]interface{}, error) {
+	table, ok := exportHeaderTables[ds.Table]
+	if !ok {
+		return nil, nil
+	}
+	sql := fmt.Sprintf("SELECT row_to_json(h)::text FROM %s h WHERE equipmentid = $1::uuid", table)
+	args := []interface{}{filter.EquipmentID}
+	if filter.CampaignID != "" {
+		sql += " AND campaignid = $2::uuid"
+		args = append(args, filter.CampaignID)
+	}
+	var raw string
+	err := tx.QueryRow(ctx, sql+" ORDER BY uploaddate DESC LIMIT 1", args...).Scan(&raw)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	header := map[string]interface{}{"_table": table}
+	return header, json.Unmarshal([]byte(raw), &header)
+}
+
+// headerAttributeValue converte um valor do cabeçalho para atributo NetCDF (nil quando vazio)
+func headerAttributeValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case string:
+		if x == "" {
+			return nil
+		}
+		return x
+	case float64:
+		return x
+	case bool:
+		return strconv.FormatBool(x)
+	}
+	return fmt.Sprint(v)
+}
+
+// buildNetCDFFile monta as dimensões, as variáveis e os atributos CF do arquivo exportado
+func buildNetCDFFile(instrument string, ds *instrumentDataset, filter dataFilter, layout *exportLayout, header map[string]interface{}, numRecs int) *netcdf.File {
+	file := &netcdf.File{
+		NumRecs:    numRecs,
+		Dimensions: []netcdf.Dimension{{Name: "time", Length: 0}},
+		Attributes: []netcdf.Attribute{
+			{Name: "Conventions", Value: "CF-1.8"},
+			{Name: "title", Value: ds.Name + " exported from " + instrument},
+			{Name: "source", Value: ds.Table},
+			{Name: "equipment_id", Value: filter.EquipmentID},
+			{Name: "history", Value: time.Now().UTC().Format(time.RFC3339) + " exported by the API"},
+		},
+	}
+	if filter.CampaignID != "" {
+		file.Attributes = append(file.Attributes, netcdf.Attribute{Name: "campaign_id", Value: filter.CampaignID})
+	}
+
+	variableAttributes := map[string][]netcdf.Attribute{}
+	if header != nil {
+		table, _ := header["_table"].(string)
+		keys := make([]string, 0, len(header))
+		for key := range header {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if strings.HasPrefix(key, "_") || strings.HasSuffix(key, "id") {
+				continue
+			}
+			if value := headerAttributeValue(header[key]); value != nil {
+				file.Attributes = append(file.Attributes, netcdf.Attribute{Name: "instrument_" + key, Value: value})
+			}
+		}
+		for _, a := range headerVariableAttributes[table] {
+			if value := headerAttributeValue(header[a.Column]); value != nil {
+				variableAttributes[a.Variable] = append(variableAttributes[a.Variable], netcdf.Attribute{Name: a.Attribute, Value: value})
+			}
+		}
+	}
+
+	file.Variables = append(file.Variables, netcdf.Variable{
+		Name: "time", Type: netcdf.Double, Dimensions: []string{"time"},
+		Attributes: []netcdf.Attribute{
+			{Name: "standard_name", Value: "time"},
+			{Name: "long_name", Value: "Time"},
+			{Name: "units", Value: "seconds since 1970-01-01 00:00:00 UTC"},
+			{Name: "calendar", Value: "standard"},
+			{Name: "axis", Value: "T"},
+		},
+	})
+
+	if layout.LevelName != "" {
+		file.Dimensions = append(file.Dimensions, netcdf.Dimension{Name: layout.LevelName, Length: len(layout.Levels)})
+		attributes := []netcdf.Attribute{{Name: "long_name", Value: describeColumn(layout.LevelName).LongName}}
+		if layout.LevelName == "height" {
+			attributes = append(attributes,
+				netcdf.Attribute{Name: "standard_name", Value: "height"},
+				netcdf.Attribute{Name: "units", Value: "m"},
+				netcdf.Attribute{Name: "positive", Value: "up"},
+				netcdf.Attribute{Name: "axis", Value: "Z"},
+			)
+		} else {
+			attributes = append(attributes, netcdf.Attribute{Name: "units", Value: "1"})
+		}
+		attributes = append(attributes, variableAttributes[layout.LevelName]...)
+		// A variável de coordenada vertical é fixa e deve preceder as de registro
+		file.Variables = append([]netcdf.Variable{{
+			Name: layout.LevelName, Type: netcdf.Double, Dimensions: []string{layout.LevelName}, Attributes: attributes,
+		}}, file.Variables...)
+	}
+
+	addVariable := func(c dataColumn, dimensions []string) {
+		meta := describeColumn(c.Name)
+		attributes := []netcdf.Attribute{{Name: "long_name", Value: meta.LongName}}
+		if meta.StandardName != "" {
+			attributes = append(attributes, netcdf.Attribute{Name: "standard_name", Value: meta.StandardName})
+		}
+		if meta.Units != "" {
+			attributes = append(attributes, netcdf.Attribute{Name: "units", Value: meta.Units})
+		}
+		attributes = append(attributes, netcdf.Attribute{Name: "_FillValue", Value: netcdf.FillFloat})
+		attributes = append(attributes, variableAttributes[c.Name]...)
+		file.Variables = append(file.Variables, netcdf.Variable{
+			Name: c.JSON, Type: netcdf.Float, Dimensions: dimensions, Attributes: attributes,
+		})
+	}
+	for _, c := range layout.Scalar {
+		addVariable(c, []string{"time"})
+	}
+	for _, c := range layout.Profile {
+		addVariable(c, []string{"time", layout.LevelName})
+	}
+	return file
+}
+
+// streamNetCDFRecords grava um registro por timestamp na ordem das variáveis de registro
+// (time, variáveis simples, variáveis de perfil)
+func streamNetCDFRecords(rows pgx.Rows, nw *netcdf.Writer, layout *exportLayout) error {
+	levels := len(layout.Levels)
+	levelIndex := make(map[float64]int, levels)
+	for i, level := range layout.Levels {
+		levelIndex[level] = i
+	}
+
+	timeValue := make([]float64, 1)
+	scalar := make([][]float64, len(layout.Scalar))
+	for i := range scalar {
+		scalar[i] = make([]float64, 1)
+	}
+	profile := make([][]float64, len(layout.Profile))
+	for i := range profile {
+		profile[i] = make([]float64, levels)
+	}
+
+	flush := func() error {
+		if err := nw.Write(timeValue); err != nil {
+			return err
+		}
+		for _, values := range scalar {
+			if err := nw.Write(values); err != nil {
+				return err
+			}
+		}
+		for _, values := range profile {
+			if err := nw.Write(values); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	reset := func() {
+		for _, values := range profile {
+			for i := range values {
+				values[i] = math.NaN()
+			}
+		}
+	}
+
+	long := layout.LevelName != "" && !layout.wide
+	var current time.Time
+	pending := false
+	reset()
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return err
+		}
+		ts := values[0].(time.Time)
+
+		if !long {
+			timeValue[0] = float64(ts.UnixNano()) / 1e9
+			for i := range scalar {
+				scalar[i][0] = exportFloat(values[1+i])
+			}
+			offset := 1 + len(scalar)
+			for i := range profile {
+				for j := 0; j < levels; j++ {
+					profile[i][j] = exportFloat(values[offset+i*levels+j])
+				}
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if pending && !ts.Equal(current) {
+			if err := flush(); err != nil {
+				return err
+			}
+			reset()
+		}
+		current, pending = ts, true
+		timeValue[0] = float64(ts.UnixNano()) / 1e9
+		idx, ok := levelIndex[exportFloat(values[1])]
+		if !ok {
+			continue
+		}
+		for i := range profile {
+			profile[i][idx] = exportFloat(values[2+i])
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if pending {
+		return flush()
+	}
+	return nil
+}
+
+// exportFloat converte um valor numérico do pgx para float64 (NaN quando nulo)
+func exportFloat(v interface{}) float64 {
+	switch x := v.(type) {
+	case float64:
+		return x
+	case float32:
+		return float64(x)
+	case int16:
+		return float64(x)
+	case int32:
+		return float64(x)
+	case int64:
+		return float64(x)
+	}
+	return math.NaN()
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"api/internal/ingest"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Limites da listagem de jobs de importação
+const (
+	defaultJobsLimit = 100
+	maxJobsLimit     = 5000
+)
+
+// writeJobError responde aos erros dos jobs de importação
+func writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ingest.ErrJobNotFound):
+		http.Error(w, "Ingestion job not found", http.StatusNotFound)
+	case errors.Is(err, ingest.ErrJobRunning):
+		http.Error(w, "Ingestion job is still running", http.StatusConflict)
+	default:
+		http.Error(w, "Failed to process ingestion job", http.StatusInternalServerError)
+		log.Println("Failed to process ingestion job:", err)
+	}
+}
+
+// GetIngestionJobs lista os jobs de importação, filtrados por status, equipment_id e parser e
+// limitados por limit
+func GetIngestionJobs(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		equipmentID, err := parseUUIDParam("equipment_id", q.Get("equipment_id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter := ingest.JobFilter{
+			Status:      q.Get("status"),
+			EquipmentID: equipmentID,
+			Parser:      strings.ToUpper(q.Get("parser")),
+			Limit:       defaultJobsLimit,
+		}
+		switch filter.Status {
+		case "", ingest.JobQueued, ingest.JobRunning, ingest.JobSucceeded, ingest.JobFailed:
+		default:
+			http.Error(w, "Invalid status: use queued, running, succeeded or failed", http.StatusBadRequest)
+			return
+		}
+		if v := q.Get("limit"); v != "" {
+			if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 1 || filter.Limit > maxJobsLimit {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		jobs, err := ingest.ListJobs(r.Context(), db, filter)
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jobs)
+	}
+}
+
+// GetIngestionJob retorna um job de importação
+func GetIngestionJob(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseUUIDParam("job id", chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		job, err := ingest.GetJob(r.Context(), db, id)
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
+	}
+}
+
+// DeleteIngestionJob remove um job de importação e desfaz a importação: as linhas que ele gravou, as
+// marcações de controle de qualidade delas e o cabeçalho do arquivo
+func DeleteIngestionJob(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseUUIDParam("job id", chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rollback, err := ingest.DeleteJob(r.Context(), db, id)
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+		log.Printf("Ingestion job %s deleted: %d rows removed from %s", id, rollback.RowsDeleted, rollback.Table)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rollback)
+	}
+}
@@ -0,0 +1,529 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Limites de paginação das listagens de dados de instrumentos
+const (
+	defaultPageSize = 500
+	maxPageSize     = 5000
+)
+
+// dataColumn associa uma coluna da tabela ao nome do campo no JSON
+type dataColumn struct {
+	Name string // Coluna no PostgreSQL
+	JSON string // Campo no JSON de resposta e no parâmetro "columns"
+}
+
+// instrumentDataset descreve uma tabela de dados de instrumento para as consultas compartilhadas
+type instrumentDataset struct {
+	Name        string          // Nome usado nas mensagens de erro
+	Table       string          // Tabela no PostgreSQL
+	IDColumn    string          // Chave usada para desempate na paginação
+	IDJSON      string          // Campo do ID no JSON
+	HasCampaign bool            // A tabela possui a coluna campaignid
+	LevelColumn string          // Coluna de altura/célula em tabelas com uma linha por nível (vazia quando não há)
+	Columns     []dataColumn    // Colunas de medição disponíveis, incluindo as derivadas
+	Derived     map[string]bool // Colunas derivadas (sem o sufixo de altura), calculadas na leitura
+}
+
+// column retorna a coluna cujo nome no JSON ou no banco corresponde a name
+func (ds *instrumentDataset) column(name string) (dataColumn, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, c := range ds.Columns {
+		if c.JSON == name || c.Name == name {
+			return c, true
+		}
+	}
+	return dataColumn{}, false
+}
+
+var sodarDataDataset = &instrumentDataset{
+	Name: "sodar data", Table: "sodardata", IDColumn: "id", IDJSON: "id",
+	Columns: []dataColumn{
+		{"windspeed", "wind_speed"}, {"winddirection", "wind_direction"},
+		{"temperature", "temperature"}, {"humidity", "humidity"},
+	},
+}
+
+var adcpDataDataset = &instrumentDataset{
+	Name: "ADCP data", Table: "adcpdata", IDColumn: "id", IDJSON: "id",
+	Columns: []dataColumn{
+		{"watercurrentspeed", "water_current_speed"}, {"watercurrentdirection", "water_current_direction"},
+		{"watertemperature", "water_temperature"}, {"salinity", "salinity"}, {"depth", "depth"},
+	},
+}
+
+var towerMicrometeorologicalDataset = &instrumentDataset{
+	Name: "tower micrometeorological data", Table: "towermicrometeorologicaldata", IDColumn: "id", IDJSON: "id",
+	Columns: []dataColumn{
+		{"windspeed", "wind_speed"}, {"winddirection", "wind_direction"},
+		{"temperature", "temperature"}, {"humidity", "humidity"},
+		{"solarradiation", "solar_radiation"}, {"barometricpressure", "barometric_pressure"},
+	},
+}
+
+var lidarZephyDataset = &instrumentDataset{
+	Name: "lidar zephy data", Table: "lidarzephydata", IDColumn: "id", IDJSON: "id",
+	Columns: []dataColumn{
+		{"windspeed", "wind_speed"}, {"winddirection", "wind_direction"}, {"temperature", "temperature"},
+	},
+}
+
+var lidarWindcobeDataset = &instrumentDataset{
+	Name: "lidar windcobe data", Table: "lidarwindcobedata", IDColumn: "id", IDJSON: "id",
+	Columns: []dataColumn{
+		{"windspeed", "wind_speed"}, {"winddirection", "wind_direction"}, {"pressure", "pressure"},
+	},
+}
+
+var estacaoSolarimetricaDataset = &instrumentDataset{
+	Name: "Estacao Solarimétrica data", Table: "estacaosolarimetricadados",
+	IDColumn: "estacaosolarimetricadadosid", IDJSON: "estacao_solarimetrica_dados_id", HasCampaign: true,
+	Columns: []dataColumn{
+		{"battv", "batt_v"}, {"ptemp_c", "ptemp_c"}, {"winddir", "wind_dir"},
+		{"ws_ms_avg", "ws_ms_avg"}, {"ws_ms_max", "ws_ms_max"}, {"ws_ms_min", "ws_ms_min"},
+		{"airtc_avg", "airtc_avg"}, {"airtc_max", "airtc_max"}, {"airtc_min", "airtc_min"},
+		{"rh_max", "rh_max"}, {"rh_min", "rh_min"}, {"rh", "rh"}, {"rain_mm_tot", "rain_mm_tot"},
+		{"bp_mbar_avg", "bp_mbar_avg"}, {"bp_mbar_max", "bp_mbar_max"}, {"bp_mbar_min", "bp_mbar_min"},
+		{"slrw_cmp10_horizontal_avg", "slrw_cmp10_horizontal_avg"}, {"slrw_cmp10_horizontal_max", "slrw_cmp10_horizontal_max"},
+		{"slrw_cmp10_horizontal_min", "slrw_cmp10_horizontal_min"}, {"slrkj_cmp10_horizontal_tot", "slrk_cmp10_horizontal_tot"},
+		{"slrw_cmp10_inclinado_avg", "slrw_cmp10_inclinado_avg"}, {"slrw_cmp10_inclinado_max", "slrw_cmp10_inclinado_max"},
+		{"slrw_cmp10_inclinado_min", "slrw_cmp10_inclinado_min"}, {"slrkj_cmp10_inclinado_tot", "slrk_cmp10_inclinado_tot"},
+		{"slrw_chp1_avg", "slrw_chp1_avg"}, {"slrw_chp1_max", "slrw_chp1_max"},
+		{"slrw_chp1_min", "slrw_chp1_min"}, {"slrkj_chp1_tot", "slrk_chp1_tot"},
+		{"solarazimuth", "solar_azimuth"}, {"sunelevation", "sun_elevation"}, {"hourangle", "hour_angle"},
+		{"declination", "declination"}, {"airmass", "air_mass"}, {"airdensity", "air_density"},
+	},
+	Derived: map[string]bool{"airdensity": true},
+}
+
+var lidarWindCubeDadosDataset = &instrumentDataset{
+	Name: "LIDAR WindCube data", Table: "lidarwindcubedados",
+	IDColumn: "lidarwindcubedadosid", IDJSON: "lidar_windcube_dados_id", HasCampaign: true,
+	Columns: lidarWindCubeDataColumns(),
+	Derived: map[string]bool{"airdensity": true, "powerdensity": true},
+}
+
+var sodarDadosDataset = &instrumentDataset{
+	Name: "SODAR data", Table: "sodardados",
+	IDColumn: "sodardadosid", IDJSON: "sodar_dados_id", HasCampaign: true, LevelColumn: "height",
+	Columns: modelDataColumns(models.SODARColumns),
+}
+
+var adcpDadosDataset = &instrumentDataset{
+	Name: "ADCP PD0 data", Table: "adcpdados",
+	IDColumn: "adcpdadosid", IDJSON: "adcp_dados_id", HasCampaign: true, LevelColumn: "cell",
+	Columns: modelDataColumns(models.ADCPColumns),
+}
+
+// instrumentDatasets indexa os datasets pelo nome usado nas rotas /api/series/{instrument}
+var instrumentDatasets = map[string]*instrumentDataset{
+	"sodardata":                    sodarDataDataset,
+	"adcpdata":                     adcpDataDataset,
+	"towermicrometeorologicaldata": towerMicrometeorologicalDataset,
+	"lidarzephydata":               lidarZephyDataset,
+	"lidarwindcobedata":            lidarWindcobeDataset,
+	"estacao-solarimetrica":        estacaoSolarimetricaDataset,
+	"lidarwindcube":                lidarWindCubeDadosDataset,
+	"sodar":                        sodarDadosDataset,
+	"adcp":                         adcpDadosDataset,
+}
+
+// modelDataColumns cria as colunas de um dataset cujos campos JSON têm o mesmo nome das colunas
+func modelDataColumns(names []string) []dataColumn {
+	columns := make([]dataColumn, len(names))
+	for i, name := range names {
+		columns[i] = dataColumn{name, name}
+	}
+	return columns
+}
+
+// lidarWindCubeDataColumns lista as colunas gerais e as colunas por altura de LIDARWindCubeDados,
+// seguidas das derivadas
+func lidarWindCubeDataColumns() []dataColumn {
+	var names []string
+	for _, field := range models.LIDARWindCubeBaseFields {
+		names = append(names, strings.ToLower(field))
+	}
+	for _, height := range models.LIDARWindCubeHeights {
+		for _, field := range models.LIDARWindCubeHeightFields {
+			names = append(names, models.LIDARWindCubeColumn(field, height))
+		}
+	}
+	names = append(names, lidarDerivedFields...)
+	for _, height := range models.LIDARWindCubeHeights {
+		for _, field := range lidarDerivedHeightFields {
+			names = append(names, models.LIDARWindCubeColumn(field, height))
+		}
+	}
+	return modelDataColumns(names)
+}
+
+// dataFilter reúne os filtros comuns às consultas de dados de instrumentos
+type dataFilter struct {
+	Start       *time.Time
+	End         *time.Time
+	EquipmentID string
+	CampaignID  string
+}
+
+// dataQuery é um dataFilter acrescido da seleção de colunas e da paginação
+type dataQuery struct {
+	dataFilter
+	Columns []dataColumn
+	Limit   int
+	After   *pageCursor
+	Options readOptions
+}
+
+// readOptions reúne as opções aplicadas na leitura das séries: as flags de QC, em
+// LIDARWindCubeDados os limites de disponibilidade e CNR e o cálculo das colunas derivadas
+type readOptions struct {
+	qcOptions
+	LIDAR   *lidarFilter
+	Derived bool // Acrescenta as colunas derivadas; definido por quem escolhe as colunas (hasDerived)
+}
+
+// parseReadOptions lê as opções de QC e, quando o dataset é o do LIDAR WindCube, os limites do LIDAR
+func parseReadOptions(r *http.Request, ds *instrumentDataset) (readOptions, error) {
+	var opts readOptions
+	var err error
+	if opts.qcOptions, err = parseQCOptions(r); err != nil {
+		return opts, err
+	}
+	if ds == lidarWindCubeDadosDataset {
+		if opts.LIDAR, err = parseLIDARFilter(r); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// source retorna a expressão FROM do dataset. Com as opções ativas, a tabela é substituída por uma
+// subconsulta com o mesmo nome, acrescida de qc_flags e das colunas derivadas e com os valores do
+// LIDAR mascarados, então as demais cláusulas não mudam.
+func (o readOptions) source(ds *instrumentDataset) string {
+	from := ds.Table
+	if o.LIDAR != nil {
+		from = o.LIDAR.subquery()
+	}
+	if o.active() {
+		from = fmt.Sprintf(`(SELECT d.*, q.flags AS qc_flags FROM %s d
+		LEFT JOIN qcflags q ON q.datatable = '%s' AND q.rowid = d.%s AND q.timestamp = d.timestamp)`,
+			from, ds.Table, ds.IDColumn)
+	}
+	if o.Derived {
+		from = o.derivedSubquery(ds, from)
+	}
+	if from == ds.Table {
+		return from
+	}
+	return from + " AS " + ds.Table
+}
+
+// pageCursor é a posição (timestamp, id) do último registro entregue
+type pageCursor struct {
+	Timestamp time.Time
+	ID        int64
+}
+
+// encode serializa o cursor em base64 URL-safe
+func (c pageCursor) encode() string {
+	raw := c.Timestamp.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor interpreta o valor do parâmetro "cursor"
+func decodeCursor(s string) (*pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("Invalid cursor")
+	}
+	ts, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+	return &pageCursor{Timestamp: ts, ID: id}, nil
+}
+
+// parseTimeParam aceita RFC 3339 ou apenas a data (YYYY-MM-DD, em UTC)
+func parseTimeParam(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("Invalid %s: use RFC 3339 or YYYY-MM-DD", name)
+}
+
+// parseUUIDParam valida um identificador UUID e o devolve na forma canônica (vazio quando ausente)
+func parseUUIDParam(name, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("Invalid %s", name)
+	}
+	return id.String(), nil
+}
+
+// parseDataFilter lê os parâmetros start, end, equipment_id e campaign_id
+func parseDataFilter(r *http.Request) (dataFilter, error) {
+	q := r.URL.Query()
+	var f dataFilter
+	var err error
+	if f.Start, err = parseTimeParam("start", q.Get("start")); err != nil {
+		return f, err
+	}
+	if f.End, err = parseTimeParam("end", q.Get("end")); err != nil {
+		return f, err
+	}
+	if f.Start != nil && f.End != nil && !f.Start.Before(*f.End) {
+		return f, errors.New("start must be before end")
+	}
+	if f.EquipmentID, err = parseUUIDParam("equipment_id", q.Get("equipment_id")); err != nil {
+		return f, err
+	}
+	if f.CampaignID, err = parseUUIDParam("campaign_id", q.Get("campaign_id")); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// parseDataQuery lê os filtros, as colunas ("columns", separadas por vírgula), o tamanho da página
+// ("limit", até maxPageSize), o cursor ("cursor") e as opções de leitura de uma listagem de dados
+func parseDataQuery(r *http.Request, ds *instrumentDataset) (*dataQuery, error) {
+	filter, err := parseDataFilter(r)
+	if err != nil {
+		return nil, err
+	}
+	query := &dataQuery{dataFilter: filter, Limit: defaultPageSize}
+	if query.Options, err = parseReadOptions(r, ds); err != nil {
+		return nil, err
+	}
+
+	q := r.URL.Query()
+	if columns := q.Get("columns"); columns != "" {
+		for _, name := range strings.Split(columns, ",") {
+			c, ok := ds.column(name)
+			if !ok {
+				return nil, fmt.Errorf("Unknown column: %s", strings.TrimSpace(name))
+			}
+			query.Columns = append(query.Columns, c)
+		}
+	} else {
+		query.Columns = ds.Columns
+	}
+	query.Options.Derived = ds.hasDerived(query.Columns)
+
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return nil, errors.New("Invalid limit")
+		}
+		if n > maxPageSize {
+			n = maxPageSize
+		}
+		query.Limit = n
+	}
+
+	if cursor := q.Get("cursor"); cursor != "" {
+		if query.After, err = decodeCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+	return query, nil
+}
+
+// whereClause monta a cláusula WHERE dos filtros, numerando os parâmetros a partir de len(args)+1
+func (f dataFilter) whereClause(ds *instrumentDataset, args []interface{}) (string, []interface{}) {
+	var conditions []string
+	add := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if f.Start != nil {
+		add("timestamp >= $%d", *f.Start)
+	}
+	if f.End != nil {
+		add("timestamp < $%d", *f.End)
+	}
+	if f.EquipmentID != "" {
+		add("equipmentid = $%d::uuid", f.EquipmentID)
+	}
+	if f.CampaignID != "" {
+		if ds.HasCampaign {
+			add("campaignid = $%d::uuid", f.CampaignID)
+		} else {
+			// Tabelas sem campaignid: equipamentos associados à campanha em CampaignEquipment
+			add("equipmentid IN (SELECT equipmentid FROM CampaignEquipment WHERE campaignid = $%d::uuid)", f.CampaignID)
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// dataPage é a resposta paginada das listagens de dados de instrumentos
+type dataPage struct {
+	Data   []map[string]interface{} `json:"data"`
+	Next   string                   `json:"next,omitempty"`   // Cursor da próxima página; vazio na última
+	Masked map[string]int64         `json:"masked,omitempty"` // LIDAR: registros da página com o vento mascarado, por altura
+}
+
+// listInstrumentData cria o handler de listagem filtrada e paginada (keyset em timestamp e id) de um dataset
+func listInstrumentData(db *pgxpool.Pool, ds *instrumentDataset) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseDataQuery(r, ds)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		selectList := []string{ds.IDColumn, "equipmentid::text", "timestamp"}
+		if ds.HasCampaign {
+			selectList = append(selectList, "campaignid::text")
+		}
+		for _, c := range query.Columns {
+			selectList = append(selectList, query.Options.selectValue(c.Name))
+		}
+		if query.Options.Include {
+			selectList = append(selectList, "qc_flags")
+		}
+		var heights []int
+		if query.Options.LIDAR != nil {
+			heights = maskedHeights(query.Columns)
+			for _, height := range heights {
+				selectList = append(selectList, maskedColumn(height))
+			}
+		}
+
+		where, args := query.whereClause(ds, nil)
+		if query.After != nil {
+			args = append(args, query.After.Timestamp, query.After.ID)
+			keyset := fmt.Sprintf("(timestamp, %s) > ($%d, $%d)", ds.IDColumn, len(args)-1, len(args))
+			if where == "" {
+				where = " WHERE " + keyset
+			} else {
+				where += " AND " + keyset
+			}
+		}
+		args = append(args, query.Limit+1)
+
+		sql := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY timestamp, %s LIMIT $%d",
+			strings.Join(selectList, ", "), query.Options.source(ds), where, ds.IDColumn, len(args))
+		rows, err := db.Query(r.Context(), sql, args...)
+		if err != nil {
+			http.Error(w, "Failed to query "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to query", ds.Name+":", err)
+			return
+		}
+		defer rows.Close()
+
+		page := dataPage{Data: []map[string]interface{}{}}
+		if len(heights) > 0 {
+			page.Masked = make(map[string]int64, len(heights))
+			for _, height := range heights {
+				page.Masked[strconv.Itoa(height)+"m"] = 0
+			}
+		}
+		var last pageCursor
+		for rows.Next() {
+			values, err := rows.Values()
+			if err != nil {
+				http.Error(w, "Failed to scan "+ds.Name, http.StatusInternalServerError)
+				return
+			}
+			if len(page.Data) == query.Limit {
+				page.Next = last.encode()
+				break
+			}
+
+			id, _ := toInt64(values[0])
+			last = pageCursor{Timestamp: values[2].(time.Time), ID: id}
+			datum := map[string]interface{}{
+				ds.IDJSON:      values[0],
+				"equipment_id": values[1],
+				"timestamp":    values[2],
+			}
+			offset := 3
+			if ds.HasCampaign {
+				datum["campaign_id"] = values[3]
+				offset = 4
+			}
+			for i, c := range query.Columns {
+				datum[c.JSON] = values[offset+i]
+			}
+			offset += len(query.Columns)
+			if query.Options.Include {
+				datum["qc_flags"] = namedFlags(values[offset])
+				offset++
+			}
+			for i, height := range heights {
+				if masked, _ := values[offset+i].(bool); !masked {
+					continue
+				}
+				page.Masked[strconv.Itoa(height)+"m"]++
+				if query.Options.LIDAR.Drop {
+					for field := range lidarWindFields {
+						delete(datum, models.LIDARWindCubeColumn(field, height))
+					}
+					for _, field := range lidarDerivedHeightFields {
+						delete(datum, models.LIDARWindCubeColumn(field, height))
+					}
+				}
+			}
+			page.Data = append(page.Data, datum)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Failed to scan "+ds.Name, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+	}
+}
+
+// toInt64 converte os tipos inteiros devolvidos pelo pgx
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"api/internal/analytics"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Padrões da intercomparação
+const (
+	defaultIntercomparisonInterval = 10 * time.Minute
+	defaultHeightTolerance         = 5.0  // Maior distância vertical entre alturas pareadas (m)
+	defaultSpeedBin                = 0.5  // Classes de velocidade do diagrama de densidade (m/s)
+	defaultDirectionBin            = 10.0 // Classes de direção do diagrama de densidade (°)
+	defaultDirectionMinSpeed       = 2.0  // Velocidade mínima da referência para comparar direções (m/s)
+)
+
+// intercomparisonSide descreve um dos instrumentos comparados
+type intercomparisonSide struct {
+	Instrument  string    `json:"instrument"`
+	EquipmentID string    `json:"equipment_id,omitempty"`
+	Heights     []float64 `json:"heights"`
+	Intervals   int       `json:"intervals"` // Intervalos médios com dados, somadas as alturas
+}
+
+// intercomparisonLevel compara um par de alturas
+type intercomparisonLevel struct {
+	ReferenceHeight float64               `json:"reference_height"`
+	TestHeight      float64               `json:"test_height"`
+	Pairs           int                   `json:"pairs"`
+	Speed           *analytics.Comparison `json:"speed"`
+	Direction       *analytics.Comparison `json:"direction"`
+}
+
+// intercomparisonResponse é a resposta de /api/analytics/intercomparison
+type intercomparisonResponse struct {
+	Interval  string                  `json:"interval"`
+	Reference intercomparisonSide     `json:"reference"`
+	Test      intercomparisonSide     `json:"test"`
+	Levels    []*intercomparisonLevel `json:"levels"`
+}
+
+// windAverage é a média de um intervalo: velocidade escalar e direção vetorial (NaN sem direção)
+type windAverage struct {
+	Speed     float64
+	Direction float64
+}
+
+// readIntervalWind lê o vento de um instrumento e calcula as médias por altura em intervalos de
+// interval, rotulados pelo início. Datasets de um único nível usam fixedHeight.
+func readIntervalWind(ctx context.Context, db *pgxpool.Pool, ds *instrumentDataset, opts readOptions, filter dataFilter, fixedHeight float64, interval time.Duration) (map[float64]map[time.Time]windAverage, error) {
+	columns := datasetWind[ds]
+	inner, args := longSeriesQuery(ds, opts, filter, nil, columns.Speed, columns.Direction)
+	rows, err := db.Query(ctx, fmt.Sprintf("SELECT ts, height, v1, v2 FROM (%s) s WHERE v1 IS NOT NULL", inner), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type accumulator struct {
+		speeds, directions []float64
+	}
+	buckets := map[float64]map[time.Time]*accumulator{}
+	for rows.Next() {
+		var ts time.Time
+		var height, direction *float64
+		var speed float64
+		if err := rows.Scan(&ts, &height, &speed, &direction); err != nil {
+			return nil, err
+		}
+		h := fixedHeight
+		if height != nil {
+			h = *height
+		}
+		if buckets[h] == nil {
+			buckets[h] = map[time.Time]*accumulator{}
+		}
+		key := ts.UTC().Truncate(interval)
+		acc := buckets[h][key]
+		if acc == nil {
+			acc = &accumulator{}
+			buckets[h][key] = acc
+		}
+		acc.speeds = append(acc.speeds, speed)
+		if direction != nil {
+			acc.directions = append(acc.directions, *direction)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := map[float64]map[time.Time]windAverage{}
+	for h, byTime := range buckets {
+		result[h] = map[time.Time]windAverage{}
+		for ts, acc := range byTime {
+			var sum float64
+			for _, v := range acc.speeds {
+				sum += v
+			}
+			avg := windAverage{Speed: sum / float64(len(acc.speeds)), Direction: math.NaN()}
+			if len(acc.directions) > 0 {
+				avg.Direction = analytics.MeanDirection(acc.directions)
+			}
+			result[h][ts] = avg
+		}
+	}
+	return result, nil
+}
+
+// parseIntercomparisonSide lê o instrumento, o equipamento e a altura fixa de um lado da comparação
+// (prefix "reference" ou "test")
+func parseIntercomparisonSide(r *http.Request, prefix string, base dataFilter) (*instrumentDataset, dataFilter, float64, error) {
+	q := r.URL.Query()
+	instrument := q.Get(prefix)
+	if instrument == "" {
+		return nil, base, 0, fmt.Errorf("%s is required", prefix)
+	}
+	ds, ok := instrumentDatasets[instrument]
+	if !ok {
+		return nil, base, 0, fmt.Errorf("Unknown instrument: %s", instrument)
+	}
+	if _, ok := datasetWind[ds]; !ok {
+		return nil, base, 0, fmt.Errorf("No wind data in %s", ds.Name)
+	}
+	filter := base
+	var err error
+	if filter.EquipmentID, err = parseUUIDParam(prefix+"_equipment_id", q.Get(prefix+"_equipment_id")); err != nil {
+		return nil, base, 0, err
+	}
+	if filter.EquipmentID == "" && filter.CampaignID == "" {
+		return nil, base, 0, fmt.Errorf("%s_equipment_id or campaign_id is required", prefix)
+	}
+	var height float64
+	if v := q.Get(prefix + "_height"); v != "" {
+		if height, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, base, 0, fmt.Errorf("Invalid %s_height", prefix)
+		}
+	} else if ds != lidarWindCubeDadosDataset && ds.LevelColumn == "" {
+		return nil, base, 0, fmt.Errorf("%s_height is required for %s", prefix, ds.Name)
+	}
+	return ds, filter, height, nil
+}
+
+// sortedHeights retorna as alturas de uma série, em ordem crescente
+func sortedHeights(series map[float64]map[time.Time]windAverage) []float64 {
+	heights := make([]float64, 0, len(series))
+	for h := range series {
+		heights = append(heights, h)
+	}
+	sort.Float64s(heights)
+	return heights
+}
+
+// GetIntercomparison compara o vento medido por dois instrumentos co-localizados: reference e test
+// (chaves de instrumento), com reference_equipment_id e test_equipment_id ou campaign_id, e
+// reference_height/test_height nos instrumentos de um único nível. As séries são promediadas em
+// intervalos comuns (interval, padrão 10m) e cada altura da referência (heights filtra) é pareada com
+// a altura mais próxima do teste dentro de height_tolerance. Retorna, por par de alturas, a reta,
+// os erros e o diagrama de densidade (speed_bin, direction_bin) da velocidade e da direção; direções
+// só são comparadas com a referência acima de direction_min_speed. Aceita start, end e qc_max.
+func GetIntercomparison(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		base, err := parseDataFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		base.EquipmentID = ""
+		refDS, refFilter, refHeight, err := parseIntercomparisonSide(r, "reference", base)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		testDS, testFilter, testHeight, err := parseIntercomparisonSide(r, "test", base)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if refDS == testDS && refFilter.EquipmentID == testFilter.EquipmentID {
+			http.Error(w, "reference and test must be different instruments or equipment", http.StatusBadRequest)
+			return
+		}
+		refOpts, err := parseReadOptions(r, refDS)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		testOpts, err := parseReadOptions(r, testDS)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		heights, err := parseHeights(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		interval := defaultIntercomparisonInterval
+		if v := q.Get("interval"); v != "" {
+			if interval, err = time.ParseDuration(v); err != nil || interval < time.Minute || interval > 24*time.Hour {
+				http.Error(w, "Invalid interval: use a duration from 1m to 24h", http.StatusBadRequest)
+				return
+			}
+		}
+		tolerance, err := parseBoundedFloat(r, "height_tolerance", defaultHeightTolerance, 0, 100)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		speedBin, err := parseBoundedFloat(r, "speed_bin", defaultSpeedBin, 0.1, 5)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		directionBin, err := parseBoundedFloat(r, "direction_bin", defaultDirectionBin, 1, 90)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		minSpeed, err := parseBoundedFloat(r, "direction_min_speed", defaultDirectionMinSpeed, 0, 20)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		reference, err := readIntervalWind(r.Context(), db, refDS, refOpts, refFilter, refHeight, interval)
+		if err != nil {
+			http.Error(w, "Failed to analyze "+refDS.Name, http.StatusInternalServerError)
+			log.Println("Failed to analyze", refDS.Name+":", err)
+			return
+		}
+		test, err := readIntervalWind(r.Context(), db, testDS, testOpts, testFilter, testHeight, interval)
+		if err != nil {
+			http.Error(w, "Failed to analyze "+testDS.Name, http.StatusInternalServerError)
+			log.Println("Failed to analyze", testDS.Name+":", err)
+			return
+		}
+
+		response := intercomparisonResponse{
+			Interval:  interval.String(),
+			Reference: intercomparisonSide{Instrument: q.Get("reference"), EquipmentID: refFilter.EquipmentID, Heights: sortedHeights(reference)},
+			Test:      intercomparisonSide{Instrument: q.Get("test"), EquipmentID: testFilter.EquipmentID, Heights: sortedHeights(test)},
+			Levels:    []*intercomparisonLevel{},
+		}
+		for _, byTime := range reference {
+			response.Reference.Intervals += len(byTime)
+		}
+		for _, byTime := range test {
+			response.Test.Intervals += len(byTime)
+		}
+
+		for _, rh := range response.Reference.Heights {
+			if heights != nil && !heights[rh] {
+				continue
+			}
+			th, best := 0.0, math.Inf(1)
+			for _, h := range response.Test.Heights {
+				if d := math.Abs(h - rh); d < best {
+					th, best = h, d
+				}
+			}
+			if best > tolerance {
+				continue
+			}
+
+			times := make([]time.Time, 0, len(reference[rh]))
+			for ts := range reference[rh] {
+				if _, ok := test[th][ts]; ok {
+					times = append(times, ts)
+				}
+			}
+			sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+			var refSpeeds, testSpeeds, refDirections, testDirections []float64
+			for _, ts := range times {
+				a, b := reference[rh][ts], test[th][ts]
+				refSpeeds, testSpeeds = append(refSpeeds, a.Speed), append(testSpeeds, b.Speed)
+				if a.Speed >= minSpeed {
+					refDirections, testDirections = append(refDirections, a.Direction), append(testDirections, b.Direction)
+				}
+			}
+			response.Levels = append(response.Levels, &intercomparisonLevel{
+				ReferenceHeight: rh,
+				TestHeight:      th,
+				Pairs:           len(times),
+				Speed:           analytics.Compare(refSpeeds, testSpeeds, speedBin, false),
+				Direction:       analytics.Compare(refDirections, testDirections, directionBin, true),
+			})
+		}
+		if len(response.Levels) == 0 && (len(reference) == 0 || len(test) == 0) {
+			http.Error(w, "No wind data for one of the instruments in the period", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api/internal/analytics"
+	"api/internal/solar"
+	"api/internal/store"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// defaultAlbedo é o albedo do solo usado na parcela refletida da transposição
+const defaultAlbedo = 0.2
+
+// irradiancePoint é a separação e a transposição de uma linha diurna da estação solarimétrica
+type irradiancePoint struct {
+	Timestamp     time.Time           `json:"timestamp"`
+	Zenith        float64             `json:"zenith"`
+	GHI           float64             `json:"slrw_cmp10_horizontal_avg"`
+	MeasuredDNI   *float64            `json:"slrw_chp1_avg"`
+	Inclined      *float64            `json:"slrw_cmp10_inclinado_avg"`
+	DNI           float64             `json:"dni"` // DNI medida ou do modelo de decomposição
+	DHI           float64             `json:"dhi"`
+	DiffuseSource string              `json:"diffuse_source"` // closure ou erbs
+	Incidence     *float64            `json:"incidence"`      // Ângulo de incidência no plano (°)
+	POA           solar.PlaneOfArray  `json:"poa"`            // Irradiância no plano pedido, pelo modelo pedido
+	Sensor        map[string]*float64 `json:"sensor_modeled"` // Irradiância modelada no plano do sensor inclinado, por modelo
+}
+
+// irradianceResponse é a resposta de /api/analytics/irradiance
+type irradianceResponse struct {
+	EquipmentID   string                           `json:"equipment_id"`
+	Latitude      float64                          `json:"latitude"`
+	Longitude     float64                          `json:"longitude"`
+	Model         string                           `json:"model"`
+	Albedo        float64                          `json:"albedo"`
+	Surface       solar.Surface                    `json:"surface"`
+	SensorSurface solar.Surface                    `json:"sensor_surface"`
+	Count         int                              `json:"count"`   // Linhas diurnas com GHI
+	Sources       map[string]int                   `json:"sources"` // Linhas por origem da difusa
+	Errors        map[string]*analytics.ErrorStats `json:"errors"`  // Modelado − medido no sensor inclinado, por modelo
+	Truncated     bool                             `json:"truncated,omitempty"`
+	Series        []irradiancePoint                `json:"series,omitempty"`
+}
+
+// parseBoundedFloat lê um número opcional da query dentro de [min, max]
+func parseBoundedFloat(r *http.Request, name string, fallback, min, max float64) (float64, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return fallback, nil
+	}
+	value, err := strconv.ParseFloat(v, 64)
+	if err != nil || value < min || value > max {
+		return 0, fmt.Errorf("Invalid %s: use a value between %g and %g", name, min, max)
+	}
+	return value, nil
+}
+
+// GetIrradiance separa a irradiância da estação solarimétrica em direta e difusa, pelo fechamento
+// DHI = GHI − DNI·cos(z) com SlrW_CHP1_Avg ou pelo modelo de Erbs quando a DNI falta, e a transpõe
+// para o plano tilt/azimuth (padrão: inclinação igual à latitude, voltado para o equador) pelo modelo
+// perez (padrão) ou haydavies. Compara os dois modelos com SlrW_CMP10_Inclinado_Avg no plano
+// sensor_tilt/sensor_azimuth (padrão: o plano pedido). Exige equipment_id; aceita start, end, albedo,
+// altitude, series=false e qc_max.
+func GetIrradiance(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ds := estacaoSolarimetricaDataset
+		filter, err := parseDataFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if filter.EquipmentID == "" {
+			http.Error(w, "equipment_id is required", http.StatusBadRequest)
+			return
+		}
+		opts, err := parseReadOptions(r, ds)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		q := r.URL.Query()
+		response := irradianceResponse{
+			EquipmentID: filter.EquipmentID, Model: solar.PerezModel, Albedo: defaultAlbedo,
+			Sources: map[string]int{}, Errors: map[string]*analytics.ErrorStats{},
+		}
+		switch model := strings.ToLower(q.Get("model")); model {
+		case "", solar.PerezModel:
+		case solar.HayDaviesModel:
+			response.Model = model
+		default:
+			http.Error(w, "Invalid model: use perez or haydavies", http.StatusBadRequest)
+			return
+		}
+		if response.Albedo, err = parseBoundedFloat(r, "albedo", defaultAlbedo, 0, 1); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var altitude float64
+		if altitude, err = parseBoundedFloat(r, "altitude", 0, -500, 9000); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		includeSeries := q.Get("series") != "false"
+
+		lat, lon, found, err := store.EquipmentLocation(r.Context(), db, filter.EquipmentID)
+		if err != nil {
+			http.Error(w, "Failed to load equipment location", http.StatusInternalServerError)
+			log.Println("Failed to load equipment location:", err)
+			return
+		}
+		if !found {
+			http.Error(w, "Equipment not found or without location", http.StatusNotFound)
+			return
+		}
+		response.Latitude, response.Longitude = lat, lon
+		site := solar.Site{Latitude: lat, Longitude: lon, Altitude: altitude}
+
+		equator := 180.0
+		if lat < 0 {
+			equator = 0
+		}
+		if response.Surface.Tilt, err = parseBoundedFloat(r, "tilt", math.Abs(lat), 0, 90); err == nil {
+			if response.Surface.Azimuth, err = parseBoundedFloat(r, "azimuth", equator, 0, 360); err == nil {
+				if response.SensorSurface.Tilt, err = parseBoundedFloat(r, "sensor_tilt", response.Surface.Tilt, 0, 90); err == nil {
+					response.SensorSurface.Azimuth, err = parseBoundedFloat(r, "sensor_azimuth", response.Surface.Azimuth, 0, 360)
+				}
+			}
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		where, args := filter.whereClause(ds, nil)
+		rows, err := db.Query(r.Context(), fmt.Sprintf("SELECT timestamp, %s, %s, %s FROM %s%s ORDER BY timestamp",
+			opts.value("slrw_cmp10_horizontal_avg"), opts.value("slrw_chp1_avg"), opts.value("slrw_cmp10_inclinado_avg"),
+			opts.source(ds), where), args...)
+		if err != nil {
+			http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to analyze", ds.Name+":", err)
+			return
+		}
+		defer rows.Close()
+
+		models := []string{solar.PerezModel, solar.HayDaviesModel}
+		accumulators := map[string]*analytics.ErrorAccumulator{}
+		for _, model := range models {
+			accumulators[model] = &analytics.ErrorAccumulator{}
+		}
+		for rows.Next() {
+			var (
+				ts  time.Time
+				ghi *float64
+				p   irradiancePoint
+			)
+			if err := rows.Scan(&ts, &ghi, &p.MeasuredDNI, &p.Inclined); err != nil {
+				http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+				log.Println("Failed to analyze", ds.Name+":", err)
+				return
+			}
+			position := solar.Compute(ts, site)
+			if ghi == nil || position.Elevation <= 0 {
+				continue
+			}
+			response.Count++
+
+			var c solar.Components
+			if p.MeasuredDNI != nil && *p.MeasuredDNI >= 0 {
+				c = solar.Closure(*ghi, *p.MeasuredDNI, position)
+			} else {
+				c = solar.Erbs(*ghi, position)
+			}
+			response.Sources[c.Source]++
+
+			p.Timestamp, p.Zenith, p.GHI = ts, position.Zenith, *ghi
+			p.DNI, p.DHI, p.DiffuseSource = c.DNI, c.DHI, c.Source
+			p.Incidence = floatPointer(math.Acos(math.Max(-1, math.Min(1, response.Surface.CosIncidence(position)))) * 180 / math.Pi)
+			p.POA = solar.Transpose(response.Model, response.Surface, position, c, response.Albedo)
+			p.Sensor = map[string]*float64{}
+			for _, model := range models {
+				modeled := solar.Transpose(model, response.SensorSurface, position, c, response.Albedo).Global
+				p.Sensor[model] = floatPointer(modeled)
+				// Perto do horizonte a resposta cosseno dos piranômetros domina o erro
+				if p.Inclined != nil && position.Elevation > solarIndexMinElevation {
+					accumulators[model].Add(modeled, *p.Inclined)
+				}
+			}
+
+			if includeSeries {
+				if len(response.Series) < maxSolarPoints {
+					response.Series = append(response.Series, p)
+				} else {
+					response.Truncated = true
+				}
+			}
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to analyze", ds.Name+":", err)
+			return
+		}
+		for _, model := range models {
+			response.Errors[model] = accumulators[model].Stats()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -22,30 +22,9 @@ func LidarWindcobeDataRouter(db *pgxpool.Pool) http.Handler {
 	return r
 }
 
-// GetAllLidarWindcobeData retorna todos os dados de Lidar Windcobe
+// GetAllLidarWindcobeData retorna os dados de Lidar Windcobe, com filtros de período, equipamento e campanha e paginação
 func GetAllLidarWindcobeData(db *pgxpool.Pool) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		rows, err := db.Query(context.Background(), "SELECT id, equipmentid, timestamp, windspeed, winddirection, pressure FROM lidarwindcobedata")
-		if err != nil {
-			http.Error(w, "Failed to query lidar windcobe data", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []models.LidarWindcobeData
-		for rows.Next() {
-			var datum models.LidarWindcobeData
-			err := rows.Scan(&datum.ID, &datum.EquipmentID, &datum.Timestamp, &datum.WindSpeed, &datum.WindDirection, &datum.Pressure)
-			if err != nil {
-				http.Error(w, "Failed to scan lidar windcobe data", http.StatusInternalServerError)
-				return
-			}
-			data = append(data, datum)
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
-	}
+	return listInstrumentData(db, lidarWindcobeDataset)
 }
 
 // GetLidarWindcobeDataByID retorna os dados de Lidar Windcobe por ID
@@ -89,6 +68,7 @@ func CreateLidarWindcobeData(db *pgxpool.Pool) http.HandlerFunc {
 			http.Error(w, "Failed to insert lidar windcobe data", http.StatusInternalServerError)
 			return
 		}
+		evaluateInsertedRow(r.Context(), db, "lidarwindcobedata", datum.EquipmentID, datum.Timestamp)
 
 		w.WriteHeader(http.StatusCreated)
 	}
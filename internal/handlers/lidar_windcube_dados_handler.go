@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"api/internal/ingest"
+	"api/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UploadLIDARWindCubeFile importa um arquivo nativo .sta ou .rtd do WindCube para LIDARWindCubeDados
+func UploadLIDARWindCubeFile(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		form, err := parseUploadForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer form.File.Close()
+
+		runUploadJob(w, r, db, form, ingest.ParserWindCube, func(target ingest.Target) (*ingest.Summary, error) {
+			return ingest.LoadWindCube(r.Context(), db, form.File, form.FileName, target)
+		})
+	}
+}
+
+// GetLIDARWindCubeDados retorna os dados do LIDAR WindCube, com filtros de período, equipamento e campanha,
+// paginação e os limites de leitura min_availability e min_cnr
+func GetLIDARWindCubeDados(db *pgxpool.Pool) http.HandlerFunc {
+	return listInstrumentData(db, lidarWindCubeDadosDataset)
+}
+
+// lidarWindFields lista as grandezas por altura mascaradas pelos limites de disponibilidade e CNR
+var lidarWindFields = map[string]bool{
+	"windspeed": true, "windspeeddispersion": true, "windspeedmin": true, "windspeedmax": true,
+	"winddirection": true, "zwind": true, "zwinddispersion": true,
+}
+
+// lidarFilter guarda os limites aplicados na leitura de LIDARWindCubeDados. Em cada altura, os
+// valores de vento são mascarados quando DataAvailability ou CNR ficam abaixo dos limites.
+type lidarFilter struct {
+	MinAvailability *float64 // min_availability (%); sem padrão
+	MinCNR          *float64 // min_cnr (dB); quando ausente vale o CNRThreshold do cabeçalho do arquivo
+	HeaderCNR       bool     // Usa o CNRThreshold do cabeçalho (desligado com min_cnr=none)
+	Drop            bool     // lidar_mask=drop: a listagem omite os campos mascarados em vez de devolvê-los nulos
+}
+
+// parseLIDARFilter lê min_availability, min_cnr (número, "header" ou "none") e lidar_mask (null ou drop).
+// Retorna nil quando nenhum limite se aplica.
+func parseLIDARFilter(r *http.Request) (*lidarFilter, error) {
+	q := r.URL.Query()
+	f := &lidarFilter{HeaderCNR: true}
+
+	if v := q.Get("min_availability"); v != "" {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || n < 0 || n > 100 {
+			return nil, errors.New("Invalid min_availability: use a percentage between 0 and 100")
+		}
+		f.MinAvailability = &n
+	}
+	switch v := strings.ToLower(q.Get("min_cnr")); v {
+	case "", "header":
+	case "none":
+		f.HeaderCNR = false
+	default:
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, errors.New("Invalid min_cnr: use a value in dB, header or none")
+		}
+		f.MinCNR, f.HeaderCNR = &n, false
+	}
+	switch v := strings.ToLower(q.Get("lidar_mask")); v {
+	case "", "null":
+	case "drop":
+		f.Drop = true
+	default:
+		return nil, errors.New("Invalid lidar_mask: use null or drop")
+	}
+
+	if f.MinAvailability == nil && f.MinCNR == nil && !f.HeaderCNR {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// maskedColumn é o nome da coluna que indica se a altura foi mascarada
+func maskedColumn(height int) string {
+	return "masked_" + strconv.Itoa(height) + "m"
+}
+
+// failExpression monta a condição de mascaramento de uma altura. Valores nulos de disponibilidade
+// ou CNR não mascaram.
+func (f *lidarFilter) failExpression(height int) string {
+	var conditions []string
+	if f.MinAvailability != nil {
+		conditions = append(conditions, fmt.Sprintf("COALESCE(d.%s < %s, false)",
+			models.LIDARWindCubeColumn("DataAvailability", height), strconv.FormatFloat(*f.MinAvailability, 'f', -1, 64)))
+	}
+	if f.MinCNR != nil {
+		conditions = append(conditions, fmt.Sprintf("COALESCE(d.%s < %s, false)",
+			models.LIDARWindCubeColumn("CNR", height), strconv.FormatFloat(*f.MinCNR, 'f', -1, 64)))
+	} else if f.HeaderCNR {
+		conditions = append(conditions, fmt.Sprintf("COALESCE(d.%s < h.cnrthreshold, false)",
+			models.LIDARWindCubeColumn("CNR", height)))
+	}
+	if len(conditions) == 0 {
+		return "false"
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")"
+}
+
+// subquery monta a leitura de LIDARWindCubeDados com os valores de vento mascarados e uma coluna
+// masked_<altura>m por altura
+func (f *lidarFilter) subquery() string {
+	selectList := []string{"d.lidarwindcubedadosid", "d.equipmentid", "d.campaignid", "d.windcubeheaderid", "d.timestamp"}
+	for _, field := range models.LIDARWindCubeBaseFields {
+		selectList = append(selectList, "d."+strings.ToLower(field))
+	}
+	var masks []string
+	for _, height := range models.LIDARWindCubeHeights {
+		masks = append(masks, f.failExpression(height)+" AS "+maskedColumn(height))
+		selectList = append(selectList, "m."+maskedColumn(height))
+		for _, field := range models.LIDARWindCubeHeightFields {
+			column := models.LIDARWindCubeColumn(field, height)
+			if lidarWindFields[strings.ToLower(field)] {
+				selectList = append(selectList, fmt.Sprintf("CASE WHEN m.%s THEN NULL ELSE d.%s END AS %s", maskedColumn(height), column, column))
+			} else {
+				selectList = append(selectList, "d."+column)
+			}
+		}
+	}
+
+	join := ""
+	if f.MinCNR == nil && f.HeaderCNR {
+		join = " LEFT JOIN lidarwindcubeheaders h ON h.windcubeheaderid = d.windcubeheaderid"
+	}
+	return fmt.Sprintf("(SELECT %s FROM lidarwindcubedados d%s CROSS JOIN LATERAL (SELECT %s) m)",
+		strings.Join(selectList, ", "), join, strings.Join(masks, ", "))
+}
+
+// maskedHeights retorna as alturas com alguma coluna de vento (medida ou derivada) entre as selecionadas
+func maskedHeights(columns []dataColumn) []int {
+	selected := map[string]bool{}
+	for _, c := range columns {
+		selected[c.Name] = true
+	}
+	fields := append([]string{}, lidarDerivedHeightFields...)
+	for field := range lidarWindFields {
+		fields = append(fields, field)
+	}
+	var heights []int
+	for _, height := range models.LIDARWindCubeHeights {
+		for _, field := range fields {
+			if selected[models.LIDARWindCubeColumn(field, height)] {
+				heights = append(heights, height)
+				break
+			}
+		}
+	}
+	return heights
+}
@@ -22,30 +22,9 @@ func LidarZephyDataRouter(db *pgxpool.Pool) http.Handler {
 	return r
 }
 
-// GetAllLidarZephyData retorna todos os dados de Lidar Zephy
+// GetAllLidarZephyData retorna os dados de Lidar Zephy, com filtros de período, equipamento e campanha e paginação
 func GetAllLidarZephyData(db *pgxpool.Pool) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		rows, err := db.Query(context.Background(), "SELECT id, equipmentid, timestamp, windspeed, winddirection, temperature FROM lidarzephydata")
-		if err != nil {
-			http.Error(w, "Failed to query lidar zephy data", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []models.LidarZephyData
-		for rows.Next() {
-			var datum models.LidarZephyData
-			err := rows.Scan(&datum.ID, &datum.EquipmentID, &datum.Timestamp, &datum.WindSpeed, &datum.WindDirection, &datum.Temperature)
-			if err != nil {
-				http.Error(w, "Failed to scan lidar zephy data", http.StatusInternalServerError)
-				return
-			}
-			data = append(data, datum)
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
-	}
+	return listInstrumentData(db, lidarZephyDataset)
 }
 
 // GetLidarZephyDataByID retorna os dados de Lidar Zephy por ID
@@ -89,6 +68,7 @@ func CreateLidarZephyData(db *pgxpool.Pool) http.HandlerFunc {
 			http.Error(w, "Failed to insert lidar zephy data", http.StatusInternalServerError)
 			return
 		}
+		evaluateInsertedRow(r.Context(), db, "lidarzephydata", datum.EquipmentID, datum.Timestamp)
 
 		w.WriteHeader(http.StatusCreated)
 	}
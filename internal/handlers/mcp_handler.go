@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"api/internal/analytics"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Padrões da correlação de longo prazo
+const (
+	defaultMCPSectors = 12
+	defaultMCPBin     = 1.0 // Largura das classes de velocidade do método matricial (m/s)
+	defaultMCPFolds   = 10
+)
+
+// timedSpeed é uma velocidade (m/s) com o timestamp e a direção (°, NaN quando ausente)
+type timedSpeed struct {
+	Time time.Time
+	analytics.WindSample
+}
+
+// mcpConcurrent resume o período concorrente entre o alvo e a referência
+type mcpConcurrent struct {
+	Pairs         int        `json:"pairs"`
+	Start         *time.Time `json:"start"`
+	End           *time.Time `json:"end"`
+	ReferenceMean float64    `json:"reference_mean"`
+	TargetMean    float64    `json:"target_mean"`
+}
+
+// mcpLongTerm resume o período de longo prazo da referência
+type mcpLongTerm struct {
+	Samples       int       `json:"samples"`
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	ReferenceMean float64   `json:"reference_mean"`
+	Index         float64   `json:"index"` // Média de longo prazo / média concorrente da referência
+}
+
+// mcpResponse é a resposta de /api/analytics/mcp
+type mcpResponse struct {
+	Instrument  string                 `json:"instrument"`
+	EquipmentID string                 `json:"equipment_id"`
+	Height      *float64               `json:"height,omitempty"`
+	ReferenceID string                 `json:"reference_id"`
+	Reference   string                 `json:"reference"`
+	Step        string                 `json:"step"` // Intervalo da referência, janela de média do alvo
+	Concurrent  mcpConcurrent          `json:"concurrent"`
+	LongTerm    mcpLongTerm            `json:"long_term"`
+	Models      []*analytics.MCPResult `json:"models"`
+	Errors      map[string]string      `json:"errors,omitempty"` // Métodos que não puderam ser ajustados
+}
+
+// readReferenceSeries lê as amostras de uma série de referência no período, ordenadas
+func readReferenceSeries(r *http.Request, db *pgxpool.Pool, id string, start, end *time.Time) ([]timedSpeed, error) {
+	rows, err := db.Query(r.Context(), `
+		SELECT timestamp, windspeed, winddirection FROM ReferenceSeriesData
+		WHERE referenceseriesid = $1::uuid AND windspeed IS NOT NULL
+		  AND ($2::timestamptz IS NULL OR timestamp >= $2) AND ($3::timestamptz IS NULL OR timestamp < $3)
+		ORDER BY timestamp`, id, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var samples []timedSpeed
+	for rows.Next() {
+		var s timedSpeed
+		var direction *float64
+		if err := rows.Scan(&s.Time, &s.Speed, &direction); err != nil {
+			return nil, err
+		}
+		s.Direction = math.NaN()
+		if direction != nil {
+			s.Direction = *direction
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+// alignMCP calcula a média do alvo na janela de um intervalo da referência centrada em cada timestamp.
+// Janelas com menos da metade da contagem mediana de amostras são descartadas. Retorna os pares e os
+// timestamps da referência correspondentes.
+func alignMCP(reference []timedSpeed, target []timedSpeed, step time.Duration) ([]analytics.MCPPair, []time.Time) {
+	type window struct {
+		time      time.Time
+		reference analytics.WindSample
+		sum       float64
+		count     int
+	}
+	var windows []window
+	j := 0
+	for _, ref := range reference {
+		from, to := ref.Time.Add(-step/2), ref.Time.Add(step/2)
+		for j < len(target) && target[j].Time.Before(from) {
+			j++
+		}
+		w := window{time: ref.Time, reference: ref.WindSample}
+		for k := j; k < len(target) && target[k].Time.Before(to); k++ {
+			w.sum += target[k].Speed
+			w.count++
+		}
+		if w.count > 0 {
+			windows = append(windows, w)
+		}
+	}
+	if len(windows) == 0 {
+		return nil, nil
+	}
+	counts := make([]int, len(windows))
+	for i, w := range windows {
+		counts[i] = w.count
+	}
+	sort.Ints(counts)
+	minCount := (counts[len(counts)/2] + 1) / 2
+
+	var pairs []analytics.MCPPair
+	var times []time.Time
+	for _, w := range windows {
+		if w.count >= minCount {
+			pairs = append(pairs, analytics.MCPPair{Reference: w.reference, Target: w.sum / float64(w.count)})
+			times = append(times, w.time)
+		}
+	}
+	return pairs, times
+}
+
+// medianInterval retorna a mediana dos intervalos entre amostras consecutivas
+func medianInterval(samples []timedSpeed) time.Duration {
+	var diffs []time.Duration
+	for i := 1; i < len(samples); i++ {
+		if d := samples[i].Time.Sub(samples[i-1].Time); d > 0 {
+			diffs = append(diffs, d)
+		}
+	}
+	if len(diffs) == 0 {
+		return time.Hour
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i] < diffs[j] })
+	return diffs[len(diffs)/2]
+}
+
+// GetMCP corrige para o longo prazo a velocidade medida por um instrumento (instrument, equipment_id e,
+// nos instrumentos com várias alturas, height) com uma série de referência importada (reference_id).
+// O alvo é promediado na janela de cada intervalo da referência; os métodos lls, variance_ratio e
+// matrix (sectors, matrix_bin) são ajustados no período concorrente (start, end) e aplicados ao
+// período de longo prazo (long_term_start, long_term_end; padrão: toda a referência), com validação
+// cruzada em folds blocos contíguos. Aceita qc_max.
+func GetMCP(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ds, err := analyticsDataset(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		columns, ok := datasetWind[ds]
+		if !ok {
+			http.Error(w, "Instrument without wind speed", http.StatusBadRequest)
+			return
+		}
+		filter, opts, _, err := parseAnalyticsFilter(r, ds)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if filter.EquipmentID == "" {
+			http.Error(w, "equipment_id is required", http.StatusBadRequest)
+			return
+		}
+
+		q := r.URL.Query()
+		response := mcpResponse{Instrument: q.Get("instrument"), EquipmentID: filter.EquipmentID}
+		if response.ReferenceID, err = parseUUIDParam("reference_id", q.Get("reference_id")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if response.ReferenceID == "" {
+			http.Error(w, "reference_id is required", http.StatusBadRequest)
+			return
+		}
+		if v := q.Get("height"); v != "" {
+			height, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				http.Error(w, "Invalid height", http.StatusBadRequest)
+				return
+			}
+			response.Height = &height
+		} else if ds == lidarWindCubeDadosDataset || ds.LevelColumn != "" {
+			http.Error(w, "height is required for "+ds.Name, http.StatusBadRequest)
+			return
+		}
+		options := analytics.MCPOptions{Sectors: defaultMCPSectors, BinWidth: defaultMCPBin}
+		folds := defaultMCPFolds
+		if v := q.Get("sectors"); v != "" {
+			if options.Sectors, err = strconv.Atoi(v); err != nil || options.Sectors < 1 || options.Sectors > 36 {
+				http.Error(w, "Invalid sectors: use 1 to 36", http.StatusBadRequest)
+				return
+			}
+		}
+		if options.BinWidth, err = parseBoundedFloat(r, "matrix_bin", defaultMCPBin, 0.1, 10); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if v := q.Get("folds"); v != "" {
+			if folds, err = strconv.Atoi(v); err != nil || folds < 2 || folds > 50 {
+				http.Error(w, "Invalid folds: use 2 to 50", http.StatusBadRequest)
+				return
+			}
+		}
+		longStart, err := parseTimeParam("long_term_start", q.Get("long_term_start"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		longEnd, err := parseTimeParam("long_term_end", q.Get("long_term_end"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = db.QueryRow(r.Context(), "SELECT name FROM ReferenceSeries WHERE referenceseriesid = $1::uuid",
+			response.ReferenceID).Scan(&response.Reference)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, errReferenceNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to load reference series", http.StatusInternalServerError)
+			log.Println("Failed to load reference series:", err)
+			return
+		}
+		longTerm, err := readReferenceSeries(r, db, response.ReferenceID, longStart, longEnd)
+		if err != nil {
+			http.Error(w, "Failed to load reference series", http.StatusInternalServerError)
+			log.Println("Failed to load reference series:", err)
+			return
+		}
+		if len(longTerm) == 0 {
+			http.Error(w, errReferenceNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		// O período concorrente usa toda a referência, não apenas a janela de longo prazo
+		reference, err := readReferenceSeries(r, db, response.ReferenceID, filter.Start, filter.End)
+		if err != nil {
+			http.Error(w, "Failed to load reference series", http.StatusInternalServerError)
+			log.Println("Failed to load reference series:", err)
+			return
+		}
+
+		inner, args := longSeriesQuery(ds, opts, filter, nil, columns.Speed)
+		sql := fmt.Sprintf("SELECT ts, v1 FROM (%s) s WHERE v1 IS NOT NULL", inner)
+		if response.Height != nil {
+			args = append(args, *response.Height)
+			sql += fmt.Sprintf(" AND height = $%d", len(args))
+		}
+		rows, err := db.Query(r.Context(), sql+" ORDER BY ts", args...)
+		if err != nil {
+			http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to analyze", ds.Name+":", err)
+			return
+		}
+		var target []timedSpeed
+		for rows.Next() {
+			var s timedSpeed
+			if err := rows.Scan(&s.Time, &s.Speed); err != nil {
+				rows.Close()
+				http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+				log.Println("Failed to analyze", ds.Name+":", err)
+				return
+			}
+			target = append(target, s)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to analyze", ds.Name+":", err)
+			return
+		}
+
+		step := medianInterval(longTerm)
+		response.Step = step.String()
+		pairs, times := alignMCP(reference, target, step)
+		if len(pairs) == 0 {
+			http.Error(w, "No concurrent data between target and reference", http.StatusUnprocessableEntity)
+			return
+		}
+
+		response.Concurrent.Pairs = len(pairs)
+		for _, p := range pairs {
+			response.Concurrent.ReferenceMean += p.Reference.Speed / float64(len(pairs))
+			response.Concurrent.TargetMean += p.Target / float64(len(pairs))
+		}
+		response.Concurrent.Start, response.Concurrent.End = &times[0], &times[len(times)-1]
+		samples := make([]analytics.WindSample, len(longTerm))
+		for i, s := range longTerm {
+			samples[i] = s.WindSample
+			response.LongTerm.ReferenceMean += s.Speed / float64(len(longTerm))
+		}
+		response.LongTerm.Samples = len(longTerm)
+		response.LongTerm.Start, response.LongTerm.End = longTerm[0].Time, longTerm[len(longTerm)-1].Time
+		if response.Concurrent.ReferenceMean > 0 {
+			response.LongTerm.Index = response.LongTerm.ReferenceMean / response.Concurrent.ReferenceMean
+		}
+
+		response.Models = []*analytics.MCPResult{}
+		for _, method := range analytics.MCPMethods {
+			result, err := analytics.RunMCP(method, pairs, samples, options, folds)
+			if err != nil {
+				if response.Errors == nil {
+					response.Errors = map[string]string{}
+				}
+				response.Errors[method] = err.Error()
+				continue
+			}
+			response.Models = append(response.Models, result)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"api/internal/analytics"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxProfilePoints limita a série devolvida pela análise de perfil; o resumo usa todos os pontos
+const maxProfilePoints = 100000
+
+// profilePoint é o ajuste do perfil vertical em um timestamp ou janela
+type profilePoint struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Levels        int       `json:"levels"`                    // Alturas com dado válido
+	Alpha         *float64  `json:"alpha"`                     // Expoente da lei de potência
+	AlphaR2       *float64  `json:"alpha_r2"`                  // R² do ajuste da lei de potência
+	Z0            *float64  `json:"z0"`                        // Comprimento de rugosidade (m)
+	UStar         *float64  `json:"u_star"`                    // Velocidade de fricção (m/s)
+	LogR2         *float64  `json:"log_r2"`                    // R² do ajuste da lei logarítmica
+	HubSpeedPower *float64  `json:"hub_speed_power,omitempty"` // Velocidade extrapolada pela lei de potência (m/s)
+	HubSpeedLog   *float64  `json:"hub_speed_log,omitempty"`   // Velocidade extrapolada pela lei logarítmica (m/s)
+}
+
+// profileGroup resume os ajustes de um grupo (hora do dia, mês ou período inteiro)
+type profileGroup struct {
+	Hour          *int                    `json:"hour,omitempty"`
+	Month         *int                    `json:"month,omitempty"`
+	Alpha         *analytics.Distribution `json:"alpha"`
+	Z0            *analytics.Distribution `json:"z0"`
+	HubSpeedPower *analytics.Distribution `json:"hub_speed_power,omitempty"`
+	HubSpeedLog   *analytics.Distribution `json:"hub_speed_log,omitempty"`
+}
+
+// profileValues acumula os valores de um grupo para o resumo
+type profileValues struct {
+	alpha, z0, hubPower, hubLog []float64
+}
+
+func (v *profileValues) add(p profilePoint) {
+	if p.Alpha != nil {
+		v.alpha = append(v.alpha, *p.Alpha)
+	}
+	if p.Z0 != nil {
+		v.z0 = append(v.z0, *p.Z0)
+	}
+	if p.HubSpeedPower != nil {
+		v.hubPower = append(v.hubPower, *p.HubSpeedPower)
+	}
+	if p.HubSpeedLog != nil {
+		v.hubLog = append(v.hubLog, *p.HubSpeedLog)
+	}
+}
+
+func (v *profileValues) summarize() profileGroup {
+	return profileGroup{
+		Alpha: analytics.Summarize(v.alpha), Z0: analytics.Summarize(v.z0),
+		HubSpeedPower: analytics.Summarize(v.hubPower), HubSpeedLog: analytics.Summarize(v.hubLog),
+	}
+}
+
+// profileResponse é a resposta de /api/series/{instrument}/profile
+type profileResponse struct {
+	Instrument string         `json:"instrument"`
+	Interval   string         `json:"interval,omitempty"`
+	HubHeight  *float64       `json:"hub_height,omitempty"`
+	Timezone   string         `json:"timezone"`
+	Heights    []float64      `json:"heights"`             // Alturas encontradas nos dados (m)
+	Truncated  bool           `json:"truncated,omitempty"` // Série cortada em maxProfilePoints
+	Overall    profileGroup   `json:"overall"`
+	ByHour     []profileGroup `json:"by_hour"`
+	ByMonth    []profileGroup `json:"by_month"`
+	Series     []profilePoint `json:"series,omitempty"`
+}
+
+// floatPointer retorna nil para NaN e infinitos
+func floatPointer(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
+
+// profileSpeedQuery monta a consulta que devolve a velocidade em formato longo (ts, height, speed),
+// uma linha por timestamp (ou janela) e altura
+func profileSpeedQuery(ds *instrumentDataset, opts readOptions, filter dataFilter, interval string) (string, []interface{}, error) {
+	if ds != lidarWindCubeDadosDataset && ds != sodarDadosDataset {
+		return "", nil, errors.New("Profile analysis is available for lidarwindcube and sodar")
+	}
+
+	var args []interface{}
+	bucket := "ts"
+	if interval != "" {
+		args = append(args, interval)
+		bucket = "time_bucket($1::interval, ts)"
+	}
+	inner, args := longSeriesQuery(ds, opts, filter, args, datasetWind[ds].Speed)
+	sql := fmt.Sprintf(`SELECT %s AS ts, height, avg(v1)
+		FROM (%s) s WHERE v1 IS NOT NULL GROUP BY 1, 2 ORDER BY 1, 2`, bucket, inner)
+	return sql, args, nil
+}
+
+// GetWindProfile ajusta, para cada timestamp (ou janela de "interval"), a lei de potência (α) e a lei
+// logarítmica (z0, u*) ao perfil de velocidade do LIDAR WindCube ou do SODAR, com a qualidade dos
+// ajustes e a extrapolação para hub_height. Devolve a série e as distribuições por hora do dia e por
+// mês (no fuso de "timezone"). Exige equipment_id; aceita heights, min_levels (padrão 3), series=false
+// e as opções de leitura (qc_max, min_availability, min_cnr).
+func GetWindProfile(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		instrument := chi.URLParam(r, "instrument")
+		ds, ok := instrumentDatasets[instrument]
+		if !ok {
+			http.Error(w, "Unknown instrument", http.StatusNotFound)
+			return
+		}
+
+		filter, err := parseDataFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if filter.EquipmentID == "" {
+			http.Error(w, "equipment_id is required", http.StatusBadRequest)
+			return
+		}
+		opts, err := parseReadOptions(r, ds)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		q := r.URL.Query()
+		response := profileResponse{Instrument: instrument, Timezone: "UTC", Heights: []float64{}}
+		if v := q.Get("interval"); v != "" {
+			if response.Interval, err = parseBucketInterval(v); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("hub_height"); v != "" {
+			hub, err := strconv.ParseFloat(v, 64)
+			if err != nil || hub <= 0 {
+				http.Error(w, "Invalid hub_height", http.StatusBadRequest)
+				return
+			}
+			response.HubHeight = &hub
+		}
+		minLevels := 3
+		if v := q.Get("min_levels"); v != "" {
+			if minLevels, err = strconv.Atoi(v); err != nil || minLevels < 2 {
+				http.Error(w, "Invalid min_levels: use 2 or more", http.StatusBadRequest)
+				return
+			}
+		}
+		heights, err := parseHeights(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		loc := time.UTC
+		if v := q.Get("timezone"); v != "" {
+			if loc, err = time.LoadLocation(v); err != nil {
+				http.Error(w, "Invalid timezone", http.StatusBadRequest)
+				return
+			}
+			response.Timezone = v
+		}
+		includeSeries := q.Get("series") != "false"
+
+		sql, args, err := profileSpeedQuery(ds, opts, filter, response.Interval)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rows, err := db.Query(r.Context(), sql, args...)
+		if err != nil {
+			http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to analyze", ds.Name+":", err)
+			return
+		}
+		defer rows.Close()
+
+		var overall profileValues
+		byHour := make([]profileValues, 24)
+		byMonth := make([]profileValues, 12)
+		seen := map[float64]bool{}
+
+		var current time.Time
+		var levels, speeds []float64
+		fit := func() {
+			if len(levels) == 0 {
+				return
+			}
+			result, ok := analytics.FitProfile(levels, speeds, minLevels)
+			levels, speeds = levels[:0], speeds[:0]
+			if !ok {
+				return
+			}
+			p := profilePoint{Timestamp: current, Levels: result.Levels}
+			if result.PowerOK {
+				p.Alpha, p.AlphaR2 = floatPointer(result.Alpha), floatPointer(result.AlphaR2)
+			}
+			if result.LogOK {
+				p.Z0, p.UStar, p.LogR2 = floatPointer(result.Z0), floatPointer(result.UStar), floatPointer(result.LogR2)
+			}
+			if response.HubHeight != nil {
+				p.HubSpeedPower = floatPointer(result.PowerSpeed(*response.HubHeight))
+				p.HubSpeedLog = floatPointer(result.LogSpeed(*response.HubHeight))
+			}
+
+			local := current.In(loc)
+			overall.add(p)
+			byHour[local.Hour()].add(p)
+			byMonth[local.Month()-1].add(p)
+			if includeSeries {
+				if len(response.Series) < maxProfilePoints {
+					response.Series = append(response.Series, p)
+				} else {
+					response.Truncated = true
+				}
+			}
+		}
+
+		for rows.Next() {
+			var ts time.Time
+			var height, speed float64
+			if err := rows.Scan(&ts, &height, &speed); err != nil {
+				http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+				log.Println("Failed to analyze", ds.Name+":", err)
+				return
+			}
+			if heights != nil && !heights[height] {
+				continue
+			}
+			if !ts.Equal(current) {
+				fit()
+				current = ts
+			}
+			levels = append(levels, height)
+			speeds = append(speeds, speed)
+			if !seen[height] {
+				seen[height] = true
+				response.Heights = append(response.Heights, height)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to analyze", ds.Name+":", err)
+			return
+		}
+		fit()
+		sort.Float64s(response.Heights)
+
+		response.Overall = overall.summarize()
+		for hour := range byHour {
+			group := byHour[hour].summarize()
+			h := hour
+			group.Hour = &h
+			response.ByHour = append(response.ByHour, group)
+		}
+		for month := range byMonth {
+			group := byMonth[month].summarize()
+			m := month + 1
+			group.Month = &m
+			response.ByMonth = append(response.ByMonth, group)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api/internal/qc"
+	"api/internal/stream"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// qcOptions controla o uso das flags de QCFlags nas consultas de dados
+type qcOptions struct {
+	Include bool    // Parâmetro qc_flags=true: inclui as flags na resposta
+	Max     qc.Flag // Parâmetro qc_max: valores com flag pior (suspect ou bad) são devolvidos como nulos
+}
+
+// parseQCOptions lê os parâmetros qc_flags e qc_max. Valores não avaliados nunca são mascarados.
+func parseQCOptions(r *http.Request) (qcOptions, error) {
+	q := r.URL.Query()
+	var opts qcOptions
+	if include := q.Get("qc_flags"); include != "" {
+		v, err := strconv.ParseBool(include)
+		if err != nil {
+			return opts, errors.New("Invalid qc_flags: use true or false")
+		}
+		opts.Include = v
+	}
+	if max := q.Get("qc_max"); max != "" {
+		flag, err := qc.ParseFlag(max)
+		if err != nil || flag != qc.Good && flag != qc.Suspect {
+			return opts, errors.New("Invalid qc_max: use good or suspect")
+		}
+		opts.Max = flag
+	}
+	return opts, nil
+}
+
+// active indica se a consulta precisa da junção com QCFlags
+func (o qcOptions) active() bool {
+	return o.Include || o.Max != 0
+}
+
+// value retorna a expressão de uma coluna, mascarada conforme qc_max
+func (o qcOptions) value(column string) string {
+	switch o.Max {
+	case qc.Good:
+		return fmt.Sprintf("(CASE WHEN (qc_flags->>'%[1]s')::smallint IN (3, 4) THEN NULL ELSE %[1]s END)", column)
+	case qc.Suspect:
+		return fmt.Sprintf("(CASE WHEN (qc_flags->>'%[1]s')::smallint = 4 THEN NULL ELSE %[1]s END)", column)
+	}
+	return column
+}
+
+// selectValue é value com o nome da coluna como alias, para listas de SELECT
+func (o qcOptions) selectValue(column string) string {
+	if o.Max == 0 {
+		return column
+	}
+	return o.value(column) + " AS " + column
+}
+
+// flagExpression retorna a expressão do código de qualidade de uma coluna (nulo quando não avaliada)
+func flagExpression(column string) string {
+	return fmt.Sprintf("(qc_flags->>'%s')::smallint", column)
+}
+
+// namedFlags converte o JSON de QCFlags.Flags em nomes de código
+func namedFlags(v interface{}) map[string]string {
+	raw, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	named := make(map[string]string, len(raw))
+	for column, code := range raw {
+		if n, ok := code.(float64); ok {
+			named[column] = qc.Flag(n).String()
+		}
+	}
+	return named
+}
+
+// evaluateInsertedRow avalia a qualidade de uma linha criada pelos handlers Create*Data e avisa os
+// streams ao vivo. A linha já foi gravada, então uma falha é apenas registrada no log.
+func evaluateInsertedRow(ctx context.Context, db *pgxpool.Pool, table, equipmentID string, ts time.Time) {
+	if _, err := qc.Run(ctx, db, table, equipmentID, ts, ts); err != nil {
+		log.Println("Failed to run QC on", table+":", err)
+	}
+	if err := stream.Notify(ctx, db, table, equipmentID); err != nil {
+		log.Println("Failed to notify streams of", table+":", err)
+	}
+}
+
+// qcSchemeResponse é a resposta de /api/series/qc
+type qcSchemeResponse struct {
+	Flags  []qc.Scheme          `json:"flags"`
+	Tables map[string]*qc.Table `json:"tables"`
+}
+
+// GetQCScheme retorna o esquema de códigos de qualidade e as regras configuradas por tabela
+func GetQCScheme(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(qcSchemeResponse{Flags: qc.Schemes, Tables: qc.Active.Tables})
+}
+
+// RunSeriesQC reavalia a qualidade de um equipamento em um período (equipment_id, start e end
+// obrigatórios), por exemplo após uma mudança nas regras
+func RunSeriesQC(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ds, ok := instrumentDatasets[chi.URLParam(r, "instrument")]
+		if !ok {
+			http.Error(w, "Unknown instrument", http.StatusNotFound)
+			return
+		}
+
+		filter, err := parseDataFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if filter.EquipmentID == "" || filter.Start == nil || filter.End == nil {
+			http.Error(w, "equipment_id, start and end are required", http.StatusBadRequest)
+			return
+		}
+
+		// end é exclusivo nas consultas e inclusivo em qc.Run
+		result, err := qc.Run(r.Context(), db, ds.Table, filter.EquipmentID, *filter.Start, filter.End.Add(-time.Nanosecond))
+		if errors.Is(err, qc.ErrUnknownTable) {
+			http.Error(w, "No QC rules configured for "+ds.Name, http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, qc.ErrInvalidEquipment) {
+			http.Error(w, "Invalid equipment_id", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to run QC on "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to run QC on", ds.Name+":", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
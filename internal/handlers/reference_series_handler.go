@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api/internal/ingest"
+	"api/internal/parsers"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// referenceSeries é uma série de referência de longo prazo com o período coberto
+type referenceSeries struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Source    *string    `json:"source"`
+	FileName  *string    `json:"file_name"`
+	FileType  *string    `json:"file_type"`
+	Latitude  *float64   `json:"latitude"`
+	Longitude *float64   `json:"longitude"`
+	Height    *float64   `json:"height"`
+	CreatedAt time.Time  `json:"created_at"`
+	Samples   int64      `json:"samples"`
+	Start     *time.Time `json:"start"`
+	End       *time.Time `json:"end"`
+}
+
+// formFloat lê um campo numérico opcional do formulário
+func formFloat(r *http.Request, name string) (*float64, error) {
+	v := r.FormValue(name)
+	if v == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s", name)
+	}
+	return &f, nil
+}
+
+// UploadReferenceSeries importa uma série de referência de longo prazo (CSV ou NetCDF clássico).
+// Campos do formulário: file e name (obrigatórios), source, height, latitude e longitude (no NetCDF,
+// o nó da grade a extrair), timezone (CSV) e os nomes das colunas ou variáveis time_column,
+// speed_column, direction_column, u_column e v_column, detectados quando ausentes.
+func UploadReferenceSeries(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+			http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "File is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		series := ingest.ReferenceSeries{Name: r.FormValue("name"), Source: r.FormValue("source")}
+		if series.Name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		for _, f := range []struct {
+			name string
+			dest **float64
+		}{{"height", &series.Height}, {"latitude", &series.Latitude}, {"longitude", &series.Longitude}} {
+			if *f.dest, err = formFloat(r, f.name); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		location, err := formLocation(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts := parsers.ReferenceOptions{
+			Location:  location,
+			Time:      r.FormValue("time_column"),
+			Speed:     r.FormValue("speed_column"),
+			Direction: r.FormValue("direction_column"),
+			U:         r.FormValue("u_column"),
+			V:         r.FormValue("v_column"),
+		}
+
+		summary, err := ingest.LoadReferenceSeries(r.Context(), db, file, header.Size, header.Filename, series, opts)
+		writeIngestResult(w, summary, err)
+	}
+}
+
+// GetReferenceSeries lista as séries de referência com a quantidade de amostras e o período coberto
+func GetReferenceSeries(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rows, err := db.Query(r.Context(), `
+			SELECT s.referenceseriesid, s.name, s.source, s.filename, s.filetype, s.latitude, s.longitude,
+			       s.height, s.createdat, d.samples, d.start, d.finish
+			FROM ReferenceSeries s
+			CROSS JOIN LATERAL (
+				SELECT count(*) AS samples, min(timestamp) AS start, max(timestamp) AS finish
+				FROM ReferenceSeriesData WHERE referenceseriesid = s.referenceseriesid
+			) d
+			ORDER BY s.createdat DESC`)
+		if err != nil {
+			http.Error(w, "Failed to query reference series", http.StatusInternalServerError)
+			log.Println("Failed to query reference series:", err)
+			return
+		}
+		defer rows.Close()
+
+		list := []referenceSeries{}
+		for rows.Next() {
+			var s referenceSeries
+			if err := rows.Scan(&s.ID, &s.Name, &s.Source, &s.FileName, &s.FileType, &s.Latitude, &s.Longitude,
+				&s.Height, &s.CreatedAt, &s.Samples, &s.Start, &s.End); err != nil {
+				http.Error(w, "Failed to scan reference series", http.StatusInternalServerError)
+				log.Println("Failed to scan reference series:", err)
+				return
+			}
+			list = append(list, s)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Error iterating over reference series rows", http.StatusInternalServerError)
+			log.Println("Error iterating over reference series rows:", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// DeleteReferenceSeries remove uma série de referência e as suas amostras
+func DeleteReferenceSeries(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseUUIDParam("id", chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tag, err := db.Exec(r.Context(), "DELETE FROM ReferenceSeries WHERE referenceseriesid = $1::uuid", id)
+		if err != nil {
+			http.Error(w, "Failed to delete reference series", http.StatusInternalServerError)
+			log.Println("Failed to delete reference series:", err)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			http.Error(w, "Reference series not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		log.Println("Reference series successfully deleted:", id)
+	}
+}
+
+// errReferenceNotFound indica uma série de referência inexistente ou sem amostras no período
+var errReferenceNotFound = errors.New("Reference series not found or without data in the period")
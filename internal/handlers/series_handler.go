@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxAggregateRows limita a quantidade de buckets devolvidos por uma agregação
+const maxAggregateRows = 100000
+
+// aggregateFunctions lista as funções aceitas no parâmetro "fn"
+var aggregateFunctions = map[string]bool{"avg": true, "min": true, "max": true, "stddev": true, "count": true, "sum": true}
+
+// intervalPattern aceita intervalos como 10m, 1h, 1d, 1w e 1mo
+var intervalPattern = regexp.MustCompile(`^(\d+)\s*(m|min|h|d|w|mo|month|months)$`)
+
+// intervalUnits converte as unidades abreviadas para as do PostgreSQL
+var intervalUnits = map[string]string{
+	"m": "minutes", "min": "minutes", "h": "hours", "d": "days", "w": "weeks",
+	"mo": "months", "month": "months", "months": "months",
+}
+
+// parseBucketInterval converte o parâmetro "interval" para um intervalo do PostgreSQL
+func parseBucketInterval(s string) (string, error) {
+	m := intervalPattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return "", errors.New("Invalid interval: use e.g. 10m, 1h, 1d, 1w or 1mo")
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n < 1 {
+		return "", errors.New("Invalid interval")
+	}
+	return fmt.Sprintf("%d %s", n, intervalUnits[m[2]]), nil
+}
+
+// isDirectionColumn indica se a coluna guarda uma direção em graus, que precisa de média vetorial
+func isDirectionColumn(name string) bool {
+	return strings.Contains(name, "direction") || name == "winddir"
+}
+
+// aggregateColumns monta fn aplicada a value, a expressão da coluna, em duas partes: as colunas da
+// consulta interna, que agrega os buckets (com locf ou interpolate do gapfill quando fill é informado),
+// e a expressão da consulta externa que as combina. O gapfill aceita uma única chamada de locf ou
+// interpolate por coluna, então direções agregam os vetores unitários em colunas separadas (alias_sin
+// e alias_cos) e só a consulta externa calcula a média vetorial ou o desvio padrão de Yamartino.
+func aggregateColumns(fn, column, value, fill, alias string) ([]string, string) {
+	wrap := func(expr string) string {
+		switch fill {
+		case "locf":
+			return "locf(" + expr + ")"
+		case "linear":
+			return "interpolate(" + expr + ")"
+		}
+		return expr
+	}
+
+	if isDirectionColumn(column) && (fn == "avg" || fn == "stddev") {
+		sin, cos := alias+"_sin", alias+"_cos"
+		inner := []string{
+			wrap(fmt.Sprintf("avg(sin(radians(%s)))", value)) + " AS " + sin,
+			wrap(fmt.Sprintf("avg(cos(radians(%s)))", value)) + " AS " + cos,
+		}
+		if fn == "avg" {
+			return inner, fmt.Sprintf("mod(degrees(atan2(%s, %s))::numeric + 360, 360)::float8", sin, cos)
+		}
+		// Yamartino: ε = √(1 − (s̄² + c̄²)); σ = asin(ε)·(1 + (2/√3 − 1)·ε³)
+		eps := fmt.Sprintf("sqrt(greatest(0, 1 - (power(%s, 2) + power(%s, 2))))", sin, cos)
+		return inner, fmt.Sprintf("degrees(asin(least(1, %[1]s)) * (1 + (2 / sqrt(3) - 1) * power(%[1]s, 3)))", eps)
+	}
+
+	var expr string
+	switch fn {
+	case "count":
+		// Contagem de buckets vazios é zero; interpolar contagens não faz sentido
+		expr = fmt.Sprintf("count(%s)", value)
+		if fill != "" {
+			expr = fmt.Sprintf("coalesce(count(%s), 0)", value)
+		}
+	case "stddev":
+		expr = wrap(fmt.Sprintf("stddev_samp(%s)", value))
+	default:
+		expr = wrap(fmt.Sprintf("%s(%s)", fn, value))
+	}
+	return []string{expr + " AS " + alias}, alias
+}
+
+// aggregateQuery monta a consulta de GetSeriesAggregate: a interna agrupa as linhas de source por
+// bucket (e nível) e a externa combina as colunas de aggregateColumns, na ordem de fields × functions,
+// seguidas das contagens de registros mascarados de heights
+func aggregateQuery(bucket, level, source, where string, fields []dataColumn, functions []string,
+	value func(column string) string, fill string, heights []int, limit int) string {
+	inner := []string{bucket + " AS bucket"}
+	outer := []string{"bucket"}
+	groupBy, orderBy := "bucket", "bucket"
+	if level != "" {
+		inner = append(inner, level)
+		outer = append(outer, level)
+		groupBy = level + ", bucket"
+		orderBy = "bucket, " + level
+	}
+	for _, c := range fields {
+		for _, fn := range functions {
+			columns, expr := aggregateColumns(fn, c.Name, value(c.Name), fill, fmt.Sprintf("a%d", len(outer)))
+			inner = append(inner, columns...)
+			outer = append(outer, expr)
+		}
+	}
+	for _, height := range heights {
+		alias := fmt.Sprintf("a%d", len(outer))
+		inner = append(inner, fmt.Sprintf("sum(%s::int) AS %s", maskedColumn(height), alias))
+		outer = append(outer, alias)
+	}
+	return fmt.Sprintf("SELECT %s FROM (SELECT %s FROM %s%s GROUP BY %s) AS buckets ORDER BY %s LIMIT %d",
+		strings.Join(outer, ", "), strings.Join(inner, ", "), source, where, groupBy, orderBy, limit)
+}
+
+// aggregateResponse é a resposta de /api/series/{instrument}/aggregate
+type aggregateResponse struct {
+	Instrument string                   `json:"instrument"`
+	Interval   string                   `json:"interval"`
+	Fill       string                   `json:"fill,omitempty"`
+	Truncated  bool                     `json:"truncated,omitempty"` // Resultado cortado em maxAggregateRows
+	Masked     map[string]int64         `json:"masked,omitempty"`    // LIDAR: registros com o vento mascarado, por altura
+	Data       []map[string]interface{} `json:"data"`
+}
+
+// GetSeriesAggregate agrega uma série de instrumento em intervalos com time_bucket do TimescaleDB.
+// Parâmetros: interval (10m, 1h, 1d, 1mo), fn (avg,min,max,stddev,count,sum), fields (colunas),
+// fill (locf, linear ou null, com time_bucket_gapfill; exige start e end), timezone, qc_max (valores
+// reprovados no QC ficam fora da agregação), min_availability e min_cnr (LIDAR WindCube) e os filtros
+// start, end, equipment_id e campaign_id. Tabelas com uma linha por altura/célula são agregadas por nível.
+func GetSeriesAggregate(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		instrument := chi.URLParam(r, "instrument")
+		ds, ok := instrumentDatasets[instrument]
+		if !ok {
+			http.Error(w, "Unknown instrument", http.StatusNotFound)
+			return
+		}
+
+		filter, err := parseDataFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		opts, err := parseReadOptions(r, ds)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		q := r.URL.Query()
+		interval, err := parseBucketInterval(q.Get("interval"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		functions := []string{"avg"}
+		if fn := q.Get("fn"); fn != "" {
+			functions = nil
+			for _, name := range strings.Split(fn, ",") {
+				name = strings.ToLower(strings.TrimSpace(name))
+				if !aggregateFunctions[name] {
+					http.Error(w, "Unknown aggregate function: "+name, http.StatusBadRequest)
+					return
+				}
+				functions = append(functions, name)
+			}
+		}
+
+		var fields []dataColumn
+		if list := q.Get("fields"); list != "" {
+			for _, name := range strings.Split(list, ",") {
+				c, ok := ds.column(name)
+				if !ok {
+					http.Error(w, "Unknown field: "+strings.TrimSpace(name), http.StatusBadRequest)
+					return
+				}
+				fields = append(fields, c)
+			}
+		} else {
+			for _, c := range ds.Columns {
+				if c.Name != ds.LevelColumn {
+					fields = append(fields, c)
+				}
+			}
+		}
+		opts.Derived = ds.hasDerived(fields)
+
+		fill := strings.ToLower(q.Get("fill"))
+		if fill != "" && fill != "locf" && fill != "linear" && fill != "null" {
+			http.Error(w, "Invalid fill: use locf, linear or null", http.StatusBadRequest)
+			return
+		}
+		if fill != "" && (filter.Start == nil || filter.End == nil) {
+			http.Error(w, "start and end are required when fill is set", http.StatusBadRequest)
+			return
+		}
+
+		args := []interface{}{interval}
+		bucketFunction, bucketArgs := "time_bucket", "$1::interval, timestamp"
+		if fill != "" {
+			bucketFunction = "time_bucket_gapfill"
+			args = append(args, *filter.Start, *filter.End)
+			bucketArgs += ", start => $2, finish => $3"
+		}
+		if timezone := q.Get("timezone"); timezone != "" {
+			if _, err := time.LoadLocation(timezone); err != nil {
+				http.Error(w, "Invalid timezone", http.StatusBadRequest)
+				return
+			}
+			args = append(args, timezone)
+			bucketArgs += fmt.Sprintf(", timezone => $%d", len(args))
+		}
+
+		var keys []string
+		for _, c := range fields {
+			for _, fn := range functions {
+				keys = append(keys, c.JSON+"_"+fn)
+			}
+		}
+		var heights []int
+		if opts.LIDAR != nil {
+			heights = maskedHeights(fields)
+		}
+
+		where, args := filter.whereClause(ds, args)
+		sql := aggregateQuery(fmt.Sprintf("%s(%s)", bucketFunction, bucketArgs), ds.LevelColumn, opts.source(ds), where,
+			fields, functions, opts.value, fill, heights, maxAggregateRows+1)
+
+		rows, err := db.Query(r.Context(), sql, args...)
+		if err != nil {
+			http.Error(w, "Failed to aggregate "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to aggregate", ds.Name+":", err)
+			return
+		}
+		defer rows.Close()
+
+		response := aggregateResponse{Instrument: instrument, Interval: interval, Fill: fill, Data: []map[string]interface{}{}}
+		if len(heights) > 0 {
+			response.Masked = make(map[string]int64, len(heights))
+			for _, height := range heights {
+				response.Masked[strconv.Itoa(height)+"m"] = 0
+			}
+		}
+		offset := 1
+		if ds.LevelColumn != "" {
+			offset = 2
+		}
+		for rows.Next() {
+			if len(response.Data) == maxAggregateRows {
+				response.Truncated = true
+				break
+			}
+			values, err := rows.Values()
+			if err != nil {
+				http.Error(w, "Failed to scan "+ds.Name, http.StatusInternalServerError)
+				return
+			}
+			datum := map[string]interface{}{"bucket": values[0]}
+			if ds.LevelColumn != "" {
+				datum[ds.LevelColumn] = values[1]
+			}
+			for i, key := range keys {
+				datum[key] = values[offset+i]
+			}
+			for i, height := range heights {
+				if n, ok := toInt64(values[offset+len(keys)+i]); ok {
+					response.Masked[strconv.Itoa(height)+"m"] += n
+				}
+			}
+			response.Data = append(response.Data, datum)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Failed to aggregate "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to aggregate", ds.Name+":", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
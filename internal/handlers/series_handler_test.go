@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAggregateColumnsDirectionFill(t *testing.T) {
+	for _, fill := range []string{"locf", "linear"} {
+		for _, fn := range []string{"avg", "stddev"} {
+			inner, outer := aggregateColumns(fn, "winddirection", "winddirection", fill, "a2")
+			if len(inner) != 2 {
+				t.Fatalf("%s/%s: colunas internas = %v, esperado seno e cosseno", fill, fn, inner)
+			}
+			// O gapfill rejeita mais de uma chamada de locf/interpolate por coluna
+			for _, column := range inner {
+				if n := strings.Count(column, "locf(") + strings.Count(column, "interpolate("); n != 1 {
+					t.Errorf("%s/%s: coluna %q com %d chamadas de preenchimento", fill, fn, column, n)
+				}
+			}
+			if strings.Contains(outer, "locf(") || strings.Contains(outer, "interpolate(") || strings.Contains(outer, "avg(") {
+				t.Errorf("%s/%s: expressão externa %q deve apenas combinar as colunas internas", fill, fn, outer)
+			}
+			if !strings.Contains(outer, "a2_sin") || !strings.Contains(outer, "a2_cos") {
+				t.Errorf("%s/%s: expressão externa %q não usa a2_sin e a2_cos", fill, fn, outer)
+			}
+		}
+	}
+}
+
+func TestAggregateQuery(t *testing.T) {
+	fields := []dataColumn{{Name: "windspeed", JSON: "wind_speed"}, {Name: "winddirection", JSON: "wind_direction"}}
+	value := func(column string) string { return column }
+	got := aggregateQuery("time_bucket_gapfill($1::interval, timestamp, start => $2, finish => $3)", "height",
+		"sodardados", " WHERE equipmentid = $4", fields, []string{"avg"}, value, "linear", nil, 11)
+	want := "SELECT bucket, height, a2, mod(degrees(atan2(a3_sin, a3_cos))::numeric + 360, 360)::float8 FROM (" +
+		"SELECT time_bucket_gapfill($1::interval, timestamp, start => $2, finish => $3) AS bucket, height, " +
+		"interpolate(avg(windspeed)) AS a2, " +
+		"interpolate(avg(sin(radians(winddirection)))) AS a3_sin, interpolate(avg(cos(radians(winddirection)))) AS a3_cos " +
+		"FROM sodardados WHERE equipmentid = $4 GROUP BY height, bucket) AS buckets ORDER BY bucket, height LIMIT 11"
+	if got != want {
+		t.Errorf("aggregateQuery =\n%s\nesperado\n%s", got, want)
+	}
+
+	got = aggregateQuery("time_bucket($1::interval, timestamp)", "", "lidarwindcubedados", "",
+		fields[:1], []string{"count", "max"}, value, "", []int{40}, 5)
+	want = "SELECT bucket, a1, a2, a3 FROM (SELECT time_bucket($1::interval, timestamp) AS bucket, " +
+		"count(windspeed) AS a1, max(windspeed) AS a2, sum(" + maskedColumn(40) + "::int) AS a3 " +
+		"FROM lidarwindcubedados GROUP BY bucket) AS buckets ORDER BY bucket LIMIT 5"
+	if got != want {
+		t.Errorf("aggregateQuery =\n%s\nesperado\n%s", got, want)
+	}
+}
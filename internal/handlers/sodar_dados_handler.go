@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+
+	"api/internal/ingest"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UploadSODARFile importa um arquivo do SODAR (Scintec .mnd ou exportação do AQ500) para SODARDados.
+// Campo opcional do formulário: "timezone" (ex: "UTC-03:00").
+func UploadSODARFile(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		form, err := parseUploadForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer form.File.Close()
+		location, err := formLocation(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		runUploadJob(w, r, db, form, ingest.ParserSODAR, func(target ingest.Target) (*ingest.Summary, error) {
+			return ingest.LoadSODAR(r.Context(), db, form.File, form.FileName, target, location)
+		})
+	}
+}
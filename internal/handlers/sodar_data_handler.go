@@ -23,30 +23,9 @@ func SodarDataRouter(db *pgxpool.Pool) http.Handler {
 	return r
 }
 
-// GetAllSodarData retorna todos os registros de dados de Sodar
+// GetAllSodarData retorna os registros de dados de Sodar, com filtros de período, equipamento e campanha e paginação
 func GetAllSodarData(db *pgxpool.Pool) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		rows, err := db.Query(context.Background(), "SELECT id, equipmentid, timestamp, windspeed, winddirection, temperature, humidity FROM sodardata")
-		if err != nil {
-			http.Error(w, "Failed to query sodar data", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []models.SodarData
-		for rows.Next() {
-			var datum models.SodarData
-			err := rows.Scan(&datum.ID, &datum.EquipmentID, &datum.Timestamp, &datum.WindSpeed, &datum.WindDirection, &datum.Temperature, &datum.Humidity)
-			if err != nil {
-				http.Error(w, "Failed to scan sodar data", http.StatusInternalServerError)
-				return
-			}
-			data = append(data, datum)
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
-	}
+	return listInstrumentData(db, sodarDataDataset)
 }
 
 // GetSodarDataByID retorna um registro de dados de Sodar por ID
@@ -90,6 +69,7 @@ func CreateSodarData(db *pgxpool.Pool) http.HandlerFunc {
 			http.Error(w, "Failed to insert sodar data", http.StatusInternalServerError)
 			return
 		}
+		evaluateInsertedRow(r.Context(), db, "sodardata", datum.EquipmentID, datum.Timestamp)
 
 		w.WriteHeader(http.StatusCreated)
 	}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"api/internal/analytics"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Padrões do relatório de irradiação
+const (
+	defaultMaxFill         = time.Hour // Falha mais longa preenchida por interpolação
+	defaultMinCompleteness = 90.0      // Completude (%) para um dia ou mês entrar na média e no ano típico
+	defaultTMYMinYears     = 2         // Anos candidatos por mês do calendário para o ano típico
+	defaultEnergyStep      = 10 * time.Minute
+)
+
+// solarEnergyChannels associa cada canal de radiação à coluna de energia por intervalo (kJ/m²) e à de
+// irradiância média (W/m²), usada quando a energia falta
+var solarEnergyChannels = []struct{ Energy, Irradiance string }{
+	{"slrkj_cmp10_horizontal_tot", "slrw_cmp10_horizontal_avg"},
+	{"slrkj_cmp10_inclinado_tot", "slrw_cmp10_inclinado_avg"},
+	{"slrkj_chp1_tot", "slrw_chp1_avg"},
+}
+
+// solarEnergyChannel é a irradiação integrada de um canal
+type solarEnergyChannel struct {
+	Daily   []analytics.EnergyPeriod `json:"daily,omitempty"`
+	Monthly []analytics.EnergyPeriod `json:"monthly"`
+}
+
+// solarEnergyStation é o relatório de irradiação de uma estação
+type solarEnergyStation struct {
+	EquipmentID string                        `json:"equipment_id"`
+	Step        string                        `json:"step"` // Intervalo de integração
+	Channels    map[string]solarEnergyChannel `json:"channels"`
+	TMY         *analytics.TypicalYear        `json:"tmy,omitempty"` // Ano típico pela irradiação global horizontal
+}
+
+// solarEnergyResponse é a resposta de /api/analytics/solar-energy
+type solarEnergyResponse struct {
+	Timezone        string               `json:"timezone"`
+	MaxFill         string               `json:"max_fill"`
+	MinCompleteness float64              `json:"min_completeness"`
+	Stations        []solarEnergyStation `json:"stations"`
+}
+
+// medianStep retorna a mediana dos intervalos entre amostras consecutivas
+func medianStep(samples []analytics.EnergySample) time.Duration {
+	var diffs []time.Duration
+	for i := 1; i < len(samples); i++ {
+		if d := samples[i].Time.Sub(samples[i-1].Time); d > 0 {
+			diffs = append(diffs, d)
+		}
+	}
+	if len(diffs) == 0 {
+		return defaultEnergyStep
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i] < diffs[j] })
+	return diffs[len(diffs)/2]
+}
+
+// GetSolarEnergy integra a irradiação diária e mensal (kWh/m²) de cada estação solarimétrica a partir
+// das colunas SlrkJ_*_Tot, com a irradiância média vezes o intervalo quando a energia falta. Valores
+// negativos (offset noturno das termopilhas) contam como zero. Falhas de até max_fill (padrão 1h) são
+// interpoladas; as mais longas são listadas e o total do período é extrapolado pela completude. Exige
+// equipment_id ou campaign_id; aceita start, end, timezone (padrão UTC), step (padrão: mediana dos
+// intervalos), min_completeness (%), daily=false, tmy=true, min_years e qc_max.
+func GetSolarEnergy(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ds := estacaoSolarimetricaDataset
+		filter, err := parseDataFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if filter.EquipmentID == "" && filter.CampaignID == "" {
+			http.Error(w, "equipment_id or campaign_id is required", http.StatusBadRequest)
+			return
+		}
+		opts, err := parseReadOptions(r, ds)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		q := r.URL.Query()
+		response := solarEnergyResponse{Timezone: "UTC", MaxFill: defaultMaxFill.String(), MinCompleteness: defaultMinCompleteness}
+		loc := time.UTC
+		if v := q.Get("timezone"); v != "" {
+			if loc, err = time.LoadLocation(v); err != nil {
+				http.Error(w, "Invalid timezone", http.StatusBadRequest)
+				return
+			}
+			response.Timezone = v
+		}
+		var step time.Duration
+		if v := q.Get("step"); v != "" {
+			if step, err = time.ParseDuration(v); err != nil || step < time.Minute || step > 24*time.Hour {
+				http.Error(w, "Invalid step: use a duration between 1m and 24h", http.StatusBadRequest)
+				return
+			}
+		}
+		maxFill := defaultMaxFill
+		if v := q.Get("max_fill"); v != "" {
+			if maxFill, err = time.ParseDuration(v); err != nil || maxFill < 0 {
+				http.Error(w, "Invalid max_fill", http.StatusBadRequest)
+				return
+			}
+			response.MaxFill = maxFill.String()
+		}
+		if response.MinCompleteness, err = parseBoundedFloat(r, "min_completeness", defaultMinCompleteness, 0, 100); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		minYears := defaultTMYMinYears
+		if v := q.Get("min_years"); v != "" {
+			if minYears, err = strconv.Atoi(v); err != nil || minYears < 1 {
+				http.Error(w, "Invalid min_years", http.StatusBadRequest)
+				return
+			}
+		}
+		includeDaily := q.Get("daily") != "false"
+		includeTMY := q.Get("tmy") == "true"
+
+		selectList := []string{"equipmentid::text", "timestamp"}
+		for _, c := range solarEnergyChannels {
+			selectList = append(selectList, opts.value(c.Energy), opts.value(c.Irradiance))
+		}
+		where, args := filter.whereClause(ds, nil)
+		rows, err := db.Query(r.Context(), fmt.Sprintf("SELECT %s FROM %s%s ORDER BY equipmentid, timestamp",
+			strings.Join(selectList, ", "), opts.source(ds), where), args...)
+		if err != nil {
+			http.Error(w, "Failed to integrate "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to integrate", ds.Name+":", err)
+			return
+		}
+		defer rows.Close()
+
+		// Amostras por estação e canal, com a irradiância guardada para converter após inferir o passo
+		type stationSamples struct {
+			id         string
+			energy     [][]analytics.EnergySample
+			irradiance [][]float64
+		}
+		var stations []*stationSamples
+		for rows.Next() {
+			var (
+				id    string
+				ts    time.Time
+				value = make([]*float64, 2*len(solarEnergyChannels))
+			)
+			dest := []interface{}{&id, &ts}
+			for i := range value {
+				dest = append(dest, &value[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				http.Error(w, "Failed to integrate "+ds.Name, http.StatusInternalServerError)
+				log.Println("Failed to integrate", ds.Name+":", err)
+				return
+			}
+			if len(stations) == 0 || stations[len(stations)-1].id != id {
+				stations = append(stations, &stationSamples{
+					id: id, energy: make([][]analytics.EnergySample, len(solarEnergyChannels)),
+					irradiance: make([][]float64, len(solarEnergyChannels)),
+				})
+			}
+			s := stations[len(stations)-1]
+			for i := range solarEnergyChannels {
+				energy, irradiance := math.NaN(), math.NaN()
+				if v := value[2*i]; v != nil {
+					energy = math.Max(*v, 0) / 3.6 // kJ/m² → Wh/m²
+				}
+				if v := value[2*i+1]; v != nil {
+					irradiance = math.Max(*v, 0)
+				}
+				s.energy[i] = append(s.energy[i], analytics.EnergySample{Time: ts, Energy: energy})
+				s.irradiance[i] = append(s.irradiance[i], irradiance)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Failed to integrate "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to integrate", ds.Name+":", err)
+			return
+		}
+
+		response.Stations = []solarEnergyStation{}
+		for _, s := range stations {
+			stationStep := step
+			if stationStep == 0 {
+				stationStep = medianStep(s.energy[0])
+			}
+			station := solarEnergyStation{EquipmentID: s.id, Step: stationStep.String(), Channels: map[string]solarEnergyChannel{}}
+			for i, c := range solarEnergyChannels {
+				samples := s.energy[i]
+				for j := range samples {
+					if math.IsNaN(samples[j].Energy) && !math.IsNaN(s.irradiance[i][j]) {
+						samples[j].Energy = s.irradiance[i][j] * stationStep.Hours()
+					}
+				}
+				days := analytics.IntegrateDaily(samples, stationStep, loc, int(maxFill/stationStep))
+				channel := solarEnergyChannel{Monthly: analytics.Monthly(days, response.MinCompleteness)}
+				if includeDaily {
+					channel.Daily = days
+				}
+				column, _ := ds.column(c.Energy)
+				station.Channels[column.JSON] = channel
+				if includeTMY && i == 0 {
+					tmy := analytics.SelectTypicalYear(channel.Monthly, response.MinCompleteness, minYears)
+					station.TMY = &tmy
+				}
+			}
+			response.Stations = append(response.Stations, station)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api/internal/analytics"
+	"api/internal/solar"
+	"api/internal/store"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxSolarPoints limita a série devolvida pela análise solar; o resumo usa todas as linhas
+const maxSolarPoints = 100000
+
+// solarIndexMinElevation é a elevação mínima (°) para calcular os índices de claridade, que
+// divergem com o sol próximo do horizonte
+const solarIndexMinElevation = 5.0
+
+// stationGeometryColumns associa as grandezas da geometria solar às colunas da estação solarimétrica
+var stationGeometryColumns = []struct{ Quantity, Column string }{
+	{solar.Azimuth, "solarazimuth"},
+	{solar.Elevation, "sunelevation"},
+	{solar.HourAngle, "hourangle"},
+	{solar.Declination, "declination"},
+	{solar.AirMass, "airmass"},
+}
+
+// solarGeometry é a posição do sol, gravada pelo datalogger ou calculada
+type solarGeometry struct {
+	Azimuth     *float64 `json:"azimuth"`
+	Elevation   *float64 `json:"elevation"`
+	HourAngle   *float64 `json:"hour_angle"`
+	Declination *float64 `json:"declination"`
+	AirMass     *float64 `json:"air_mass"`
+}
+
+// field retorna o campo de uma grandeza
+func (g *solarGeometry) field(quantity string) **float64 {
+	switch quantity {
+	case solar.Azimuth:
+		return &g.Azimuth
+	case solar.Elevation:
+		return &g.Elevation
+	case solar.HourAngle:
+		return &g.HourAngle
+	case solar.Declination:
+		return &g.Declination
+	}
+	return &g.AirMass
+}
+
+// solarPoint compara a geometria de uma linha da estação com a calculada e acrescenta a irradiância
+// de céu claro e os índices de claridade
+type solarPoint struct {
+	Timestamp         time.Time     `json:"timestamp"`
+	Computed          solarGeometry `json:"computed"`
+	Logged            solarGeometry `json:"logged"`
+	Disagreements     []string      `json:"disagreements,omitempty"` // Grandezas fora da tolerância
+	Extraterrestrial  float64       `json:"extraterrestrial"`        // Irradiância extraterrestre normal (W/m²)
+	GHI               *float64      `json:"slrw_cmp10_horizontal_avg"`
+	DNI               *float64      `json:"slrw_chp1_avg"`
+	ClearSkyGHI       *float64      `json:"clear_sky_ghi"`
+	ClearSkyDNI       *float64      `json:"clear_sky_dni,omitempty"`
+	ClearSkyDHI       *float64      `json:"clear_sky_dhi,omitempty"`
+	ClearnessIndex    *float64      `json:"clearness_index"`      // kt: GHI / irradiância extraterrestre horizontal
+	ClearSkyIndex     *float64      `json:"clear_sky_index"`      // GHI / GHI de céu claro
+	BeamClearSkyIndex *float64      `json:"beam_clear_sky_index"` // DNI / DNI de céu claro
+}
+
+// solarResponse é a resposta de /api/analytics/solar
+type solarResponse struct {
+	EquipmentID    string                  `json:"equipment_id"`
+	Latitude       float64                 `json:"latitude"`
+	Longitude      float64                 `json:"longitude"`
+	Altitude       float64                 `json:"altitude"`
+	ClearSkyModel  string                  `json:"clear_sky_model"`
+	LinkeTurbidity *float64                `json:"linke_turbidity,omitempty"`
+	Tolerance      float64                 `json:"tolerance"`
+	Count          int                     `json:"count"`
+	Disagreements  map[string]int          `json:"disagreements"`        // Linhas divergentes por grandeza
+	ElevationError *analytics.Distribution `json:"elevation_error"`      // Elevação gravada − calculada (°)
+	ClockOffset    *analytics.Distribution `json:"clock_offset_minutes"` // Deslocamento de relógio que explica a diferença de elevação
+	Truncated      bool                    `json:"truncated,omitempty"`
+	Series         []solarPoint            `json:"series,omitempty"`
+}
+
+// ratio retorna a razão ou nil quando o numerador falta ou o denominador não é positivo
+func ratio(v *float64, reference float64) *float64 {
+	if v == nil || !(reference > 0) {
+		return nil
+	}
+	return floatPointer(*v / reference)
+}
+
+// GetSolarGeometry recalcula a posição do sol (algoritmo da NOAA) pela localização do equipamento e
+// pelo timestamp de cada linha da estação solarimétrica e compara com a geometria gravada pelo
+// datalogger, com uma estimativa do erro de relógio. Acrescenta a irradiância de céu claro
+// (clear_sky=ineichen, padrão, com linke e altitude, ou haurwitz) e os índices de claridade ao lado
+// de SlrW_CMP10_Horizontal_Avg e SlrW_CHP1_Avg. Exige equipment_id; aceita start, end, tolerance (°),
+// series=false e qc_max.
+func GetSolarGeometry(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ds := estacaoSolarimetricaDataset
+		filter, err := parseDataFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if filter.EquipmentID == "" {
+			http.Error(w, "equipment_id is required", http.StatusBadRequest)
+			return
+		}
+		opts, err := parseReadOptions(r, ds)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		q := r.URL.Query()
+		response := solarResponse{
+			EquipmentID: filter.EquipmentID, ClearSkyModel: "ineichen", Tolerance: solar.DefaultTolerance,
+			Disagreements: map[string]int{},
+		}
+		linke := solar.DefaultLinkeTurbidity
+		switch model := strings.ToLower(q.Get("clear_sky")); model {
+		case "", "ineichen":
+			if v := q.Get("linke"); v != "" {
+				if linke, err = strconv.ParseFloat(v, 64); err != nil || linke < 1 || linke > 10 {
+					http.Error(w, "Invalid linke: use a Linke turbidity between 1 and 10", http.StatusBadRequest)
+					return
+				}
+			}
+			response.LinkeTurbidity = &linke
+		case "haurwitz":
+			response.ClearSkyModel = model
+		default:
+			http.Error(w, "Invalid clear_sky: use ineichen or haurwitz", http.StatusBadRequest)
+			return
+		}
+		if v := q.Get("altitude"); v != "" {
+			if response.Altitude, err = strconv.ParseFloat(v, 64); err != nil || response.Altitude < -500 || response.Altitude > 9000 {
+				http.Error(w, "Invalid altitude", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("tolerance"); v != "" {
+			if response.Tolerance, err = strconv.ParseFloat(v, 64); err != nil || response.Tolerance <= 0 {
+				http.Error(w, "Invalid tolerance", http.StatusBadRequest)
+				return
+			}
+		}
+		includeSeries := q.Get("series") != "false"
+
+		lat, lon, found, err := store.EquipmentLocation(r.Context(), db, filter.EquipmentID)
+		if err != nil {
+			http.Error(w, "Failed to load equipment location", http.StatusInternalServerError)
+			log.Println("Failed to load equipment location:", err)
+			return
+		}
+		if !found {
+			http.Error(w, "Equipment not found or without location", http.StatusNotFound)
+			return
+		}
+		response.Latitude, response.Longitude = lat, lon
+		site := solar.Site{Latitude: lat, Longitude: lon, Altitude: response.Altitude}
+
+		selectList := []string{"timestamp"}
+		for _, g := range stationGeometryColumns {
+			selectList = append(selectList, opts.value(g.Column))
+		}
+		selectList = append(selectList, opts.value("slrw_cmp10_horizontal_avg"), opts.value("slrw_chp1_avg"))
+		where, args := filter.whereClause(ds, nil)
+		rows, err := db.Query(r.Context(), fmt.Sprintf("SELECT %s FROM %s%s ORDER BY timestamp",
+			strings.Join(selectList, ", "), opts.source(ds), where), args...)
+		if err != nil {
+			http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to analyze", ds.Name+":", err)
+			return
+		}
+		defer rows.Close()
+
+		var elevationErrors, offsets []float64
+		for rows.Next() {
+			var p solarPoint
+			dest := []interface{}{&p.Timestamp}
+			for _, g := range stationGeometryColumns {
+				dest = append(dest, p.Logged.field(g.Quantity))
+			}
+			dest = append(dest, &p.GHI, &p.DNI)
+			if err := rows.Scan(dest...); err != nil {
+				http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+				log.Println("Failed to analyze", ds.Name+":", err)
+				return
+			}
+			response.Count++
+
+			position := solar.Compute(p.Timestamp, site)
+			for _, g := range stationGeometryColumns {
+				value, _ := position.Value(g.Quantity)
+				*p.Computed.field(g.Quantity) = floatPointer(value)
+				if logged := *p.Logged.field(g.Quantity); logged != nil && position.Disagrees(g.Quantity, *logged, response.Tolerance) {
+					p.Disagreements = append(p.Disagreements, g.Quantity)
+					response.Disagreements[g.Quantity]++
+				}
+			}
+
+			if p.Logged.Elevation != nil {
+				diff := *p.Logged.Elevation - position.Elevation
+				elevationErrors = append(elevationErrors, diff)
+				// Minutos que o relógio precisaria andar para a elevação calculada alcançar a gravada
+				rate := solar.Compute(p.Timestamp.Add(time.Minute), site).Elevation - position.Elevation
+				if position.Elevation > solarIndexMinElevation && math.Abs(rate) > 0.05 {
+					offsets = append(offsets, diff/rate)
+				}
+			}
+
+			p.Extraterrestrial = position.Extraterrestrial()
+			var clear solar.ClearSky
+			if response.ClearSkyModel == "haurwitz" {
+				clear = solar.Haurwitz(position)
+			} else {
+				clear = solar.Ineichen(position, linke, response.Altitude)
+			}
+			p.ClearSkyGHI, p.ClearSkyDNI, p.ClearSkyDHI = floatPointer(clear.GHI), floatPointer(clear.DNI), floatPointer(clear.DHI)
+			if position.Elevation > solarIndexMinElevation {
+				p.ClearnessIndex = ratio(p.GHI, p.Extraterrestrial*math.Sin(position.Elevation*math.Pi/180))
+				p.ClearSkyIndex = ratio(p.GHI, clear.GHI)
+				p.BeamClearSkyIndex = ratio(p.DNI, clear.DNI)
+			}
+
+			if includeSeries {
+				if len(response.Series) < maxSolarPoints {
+					response.Series = append(response.Series, p)
+				} else {
+					response.Truncated = true
+				}
+			}
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to analyze", ds.Name+":", err)
+			return
+		}
+		response.ElevationError = analytics.Summarize(elevationErrors)
+		response.ClockOffset = analytics.Summarize(offsets)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
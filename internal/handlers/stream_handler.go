@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api/internal/alerts"
+	"api/internal/stream"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Parâmetros dos streams ao vivo
+const (
+	streamBatchSize    = 500                  // Linhas por evento "data"
+	streamBacklogLimit = 20 * streamBatchSize // Acima disso o stream salta para o fim e envia "reset"
+	streamHeartbeat    = 15 * time.Second
+	streamRetry        = 5 * time.Second // Intervalo de reconexão sugerido ao EventSource
+)
+
+// streamCursor é a posição de um stream: o último ID da tabela de dados e a última revisão de
+// AlertFirings entregues. É enviado como ID de cada evento ("<dados>:<alertas>"). O cursor só avança
+// até onde stream.Horizon garante que não há IDs menores de transações ainda abertas.
+type streamCursor struct {
+	Data   int64
+	Alerts int64
+}
+
+// String serializa o cursor no formato do campo id do SSE
+func (c streamCursor) String() string {
+	return strconv.FormatInt(c.Data, 10) + ":" + strconv.FormatInt(c.Alerts, 10)
+}
+
+// parseStreamCursor interpreta o Last-Event-ID enviado pelo EventSource na reconexão
+func parseStreamCursor(s string) (streamCursor, error) {
+	var c streamCursor
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return c, errors.New("Invalid Last-Event-ID")
+	}
+	var err1, err2 error
+	c.Data, err1 = strconv.ParseInt(parts[0], 10, 64)
+	c.Alerts, err2 = strconv.ParseInt(parts[1], 10, 64)
+	if err1 != nil || err2 != nil || c.Data < 0 || c.Alerts < 0 {
+		return c, errors.New("Invalid Last-Event-ID")
+	}
+	return c, nil
+}
+
+// dataSequence retorna a sequência que gera a coluna de ID da tabela do dataset
+func dataSequence(ctx context.Context, db *pgxpool.Pool, ds *instrumentDataset) (string, error) {
+	var seq *string
+	if err := db.QueryRow(ctx, "SELECT pg_get_serial_sequence($1, $2)", ds.Table, ds.IDColumn).Scan(&seq); err != nil {
+		return "", err
+	}
+	if seq == nil {
+		return "", fmt.Errorf("%s.%s is not generated by a sequence", ds.Table, ds.IDColumn)
+	}
+	return *seq, nil
+}
+
+// instrumentStream entrega a um cliente as linhas e os disparos de alerta posteriores ao cursor
+type instrumentStream struct {
+	db      *pgxpool.Pool
+	ds      *instrumentDataset
+	query   *dataQuery
+	data    *stream.Horizon // IDs da tabela de dados
+	alerts  *stream.Horizon // Revisões de AlertFirings
+	skips   int             // Avanços por stream.MaxWait dos dois Horizons já informados ao cliente
+	cursor  streamCursor
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// send grava um evento SSE com o cursor atual como ID
+func (s *instrumentStream) send(event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "id: %s\nevent: %s\ndata: %s\n\n", s.cursor, event, data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// fetchData lê até streamBatchSize linhas do equipamento com ID posterior ao cursor e até until
+func (s *instrumentStream) fetchData(ctx context.Context, until int64) ([]map[string]interface{}, int64, error) {
+	ds, query := s.ds, s.query
+	selectList := []string{ds.IDColumn, "equipmentid::text", "timestamp"}
+	if ds.HasCampaign {
+		selectList = append(selectList, "campaignid::text")
+	}
+	for _, c := range query.Columns {
+		selectList = append(selectList, query.Options.selectValue(c.Name))
+	}
+	if query.Options.Include {
+		selectList = append(selectList, "qc_flags")
+	}
+
+	where, args := query.whereClause(ds, nil)
+	args = append(args, s.cursor.Data, until)
+	after := fmt.Sprintf("%[1]s > $%[2]d AND %[1]s <= $%[3]d", ds.IDColumn, len(args)-1, len(args))
+	if where == "" {
+		where = " WHERE " + after
+	} else {
+		where += " AND " + after
+	}
+	args = append(args, streamBatchSize)
+
+	rows, err := s.db.Query(ctx, fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s LIMIT $%d",
+		strings.Join(selectList, ", "), query.Options.source(ds), where, ds.IDColumn, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	data := []map[string]interface{}{}
+	last := s.cursor.Data
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, 0, err
+		}
+		last, _ = toInt64(values[0])
+		datum := map[string]interface{}{
+			ds.IDJSON:      values[0],
+			"equipment_id": values[1],
+			"timestamp":    values[2],
+		}
+		offset := 3
+		if ds.HasCampaign {
+			datum["campaign_id"] = values[3]
+			offset = 4
+		}
+		for i, c := range query.Columns {
+			datum[c.JSON] = values[offset+i]
+		}
+		if query.Options.Include {
+			datum["qc_flags"] = namedFlags(values[offset+len(query.Columns)])
+		}
+		data = append(data, datum)
+	}
+	return data, last, rows.Err()
+}
+
+// drain envia o que houver entre o cursor e os horizontes definitivos: as linhas em eventos "data" de
+// até streamBatchSize linhas e cada mudança de disparo em um evento "alert". Um atraso maior que
+// streamBacklogLimit linhas (uma importação grande, por exemplo) não é enviado: o stream salta para o
+// horizonte e envia "reset", para que o cliente recarregue a série pelas rotas de listagem. O mesmo
+// "reset" é enviado quando um horizonte avança sem esperar uma escrita aberta há mais de
+// stream.MaxWait, cujas linhas não serão entregues. As linhas de transações ainda abertas ficam para
+// o próximo aviso do Hub.
+func (s *instrumentStream) drain(ctx context.Context) error {
+	dataUntil, dataSkips := s.data.Position()
+	alertsUntil, alertSkips := s.alerts.Position()
+
+	sent := 0
+	for s.cursor.Data < dataUntil {
+		data, last, err := s.fetchData(ctx, dataUntil)
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			s.cursor.Data = dataUntil
+			break
+		}
+		s.cursor.Data = last
+		if len(data) < streamBatchSize {
+			s.cursor.Data = dataUntil
+		}
+		if err := s.send("data", data); err != nil {
+			return err
+		}
+		if sent += len(data); s.cursor.Data < dataUntil && sent >= streamBacklogLimit {
+			s.cursor.Data = dataUntil
+			if err := s.send("reset", map[string]string{"reason": "backlog exceeded " + strconv.Itoa(streamBacklogLimit) + " rows"}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for s.cursor.Alerts < alertsUntil {
+		firings, err := alerts.FiringsSince(ctx, s.db, s.query.EquipmentID, s.cursor.Alerts, alertsUntil, streamBatchSize)
+		if err != nil {
+			return err
+		}
+		for i, f := range firings {
+			s.cursor.Alerts = f.Revision
+			if i == len(firings)-1 && len(firings) < streamBatchSize {
+				s.cursor.Alerts = alertsUntil
+			}
+			if err := s.send("alert", f); err != nil {
+				return err
+			}
+		}
+		if len(firings) < streamBatchSize {
+			s.cursor.Alerts = alertsUntil
+		}
+	}
+
+	if skips := dataSkips + alertSkips; skips != s.skips {
+		s.skips = skips
+		return s.send("reset", map[string]string{"reason": "a write was pending for more than " + stream.MaxWait.String()})
+	}
+	return nil
+}
+
+// StreamInstrumentData envia por Server-Sent Events as linhas gravadas e os disparos de alerta de um
+// equipamento (equipment_id obrigatório) à medida que chegam, com as mesmas opções de colunas e QC das
+// listagens. Os avisos vêm do LISTEN/NOTIFY do PostgreSQL (pacote stream), então valem para gravações
+// feitas por qualquer réplica, pelo cmd/ingestd ou pelo cmd/mqttd. O ID de cada evento é o cursor do
+// stream: na reconexão, o EventSource o envia em Last-Event-ID e o stream retoma a partir dele (o
+// parâmetro last_event_id tem o mesmo efeito na primeira conexão). Sem cursor, só o que chegar depois
+// da conexão é enviado.
+func StreamInstrumentData(db *pgxpool.Pool, hub *stream.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ds, ok := instrumentDatasets[chi.URLParam(r, "instrument")]
+		if !ok {
+			http.Error(w, "Unknown instrument", http.StatusNotFound)
+			return
+		}
+		query, err := parseDataQuery(r, ds)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if query.EquipmentID == "" {
+			http.Error(w, "equipment_id is required", http.StatusBadRequest)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		ctx := r.Context()
+		var exists bool
+		if err := db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM equipments WHERE equipmentid = $1::uuid)", query.EquipmentID).Scan(&exists); err != nil {
+			http.Error(w, "Failed to query equipment", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "Equipment not found", http.StatusNotFound)
+			return
+		}
+
+		// A assinatura vem antes da leitura do cursor para que nada gravado entre as duas se perca
+		sub := hub.Subscribe(query.EquipmentID, ds.Table, stream.AlertsTable)
+		defer sub.Close()
+
+		s := &instrumentStream{db: db, ds: ds, query: query, w: w, flusher: flusher}
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = r.URL.Query().Get("last_event_id")
+		}
+		if lastEventID != "" {
+			if s.cursor, err = parseStreamCursor(lastEventID); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		seq, err := dataSequence(ctx, db, ds)
+		if err == nil {
+			s.data, err = hub.Horizon(ctx, ds.Table, seq)
+		}
+		if err == nil {
+			s.alerts, err = hub.Horizon(ctx, stream.AlertsTable, stream.AlertsSequence)
+		}
+		if err == nil && lastEventID == "" {
+			if s.cursor.Data, err = s.data.Allocated(ctx, db); err == nil {
+				s.cursor.Alerts, err = s.alerts.Allocated(ctx, db)
+			}
+		}
+		if err != nil {
+			http.Error(w, "Failed to open stream", http.StatusInternalServerError)
+			log.Println("Failed to open stream of", ds.Name+":", err)
+			return
+		}
+		_, dataSkips := s.data.Position()
+		_, alertSkips := s.alerts.Position()
+		s.skips = dataSkips + alertSkips
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no") // Desativa o buffer de proxies nginx
+		fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+		flusher.Flush()
+
+		drain := func() bool {
+			err := s.drain(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Println("Failed to stream", ds.Name+":", err)
+			}
+			return err == nil
+		}
+		if !drain() {
+			return
+		}
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sub.C:
+				if !drain() {
+					return
+				}
+			case <-heartbeat.C:
+				// Comentário SSE: mantém a conexão aberta em proxies e revela clientes desconectados
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -23,30 +23,9 @@ func TowerMicrometeorologicalDataRouter(db *pgxpool.Pool) http.Handler {
 	return r
 }
 
-// GetAllTowerMicrometeorologicalData retorna todos os registros de dados micrometeorológicos da torre
+// GetAllTowerMicrometeorologicalData retorna os registros de dados micrometeorológicos da torre, com filtros de período, equipamento e campanha e paginação
 func GetAllTowerMicrometeorologicalData(db *pgxpool.Pool) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		rows, err := db.Query(context.Background(), "SELECT id, equipmentid, timestamp, windspeed, winddirection, temperature, humidity, solarradiation, barometricpressure FROM towermicrometeorologicaldata")
-		if err != nil {
-			http.Error(w, "Failed to query tower micrometeorological data", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []models.TowerMicrometeorologicalData
-		for rows.Next() {
-			var datum models.TowerMicrometeorologicalData
-			err := rows.Scan(&datum.ID, &datum.EquipmentID, &datum.Timestamp, &datum.WindSpeed, &datum.WindDirection, &datum.Temperature, &datum.Humidity, &datum.SolarRadiation, &datum.BarometricPressure)
-			if err != nil {
-				http.Error(w, "Failed to scan tower micrometeorological data", http.StatusInternalServerError)
-				return
-			}
-			data = append(data, datum)
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
-	}
+	return listInstrumentData(db, towerMicrometeorologicalDataset)
 }
 
 // GetTowerMicrometeorologicalDataByID retorna um registro de dados micrometeorológicos da torre por ID
@@ -90,6 +69,7 @@ func CreateTowerMicrometeorologicalData(db *pgxpool.Pool) http.HandlerFunc {
 			http.Error(w, "Failed to insert tower micrometeorological data", http.StatusInternalServerError)
 			return
 		}
+		evaluateInsertedRow(r.Context(), db, "towermicrometeorologicaldata", datum.EquipmentID, datum.Timestamp)
 
 		w.WriteHeader(http.StatusCreated)
 	}
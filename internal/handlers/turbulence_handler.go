@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"api/internal/analytics"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// turbulenceHeight é a tabela de intensidade de turbulência e a classificação de uma altura
+type turbulenceHeight struct {
+	Height *float64          `json:"height"`
+	Count  int               `json:"count"`
+	Class  string            `json:"class"` // Categoria IEC 61400-1 (A+, A, B, C), "S" acima de A+ ou vazia sem dados suficientes
+	TI15   *analytics.TIBin  `json:"ti15,omitempty"`
+	Bins   []analytics.TIBin `json:"bins"`
+}
+
+// turbulenceResponse é a resposta de /api/analytics/turbulence
+type turbulenceResponse struct {
+	Instrument string                      `json:"instrument"`
+	BinWidth   float64                     `json:"bin_width"`
+	MinSpeed   float64                     `json:"min_speed"`
+	MinCount   int                         `json:"min_count"`
+	Classes    []analytics.TurbulenceClass `json:"classes"`
+	Heights    []turbulenceHeight          `json:"heights"`
+}
+
+// GetTurbulence calcula, por altura, a intensidade de turbulência (desvio padrão / média da velocidade)
+// do SODAR ou do LIDAR WindCube em classes de velocidade, a intensidade representativa
+// (média + 1,28σ) e a categoria de turbulência IEC 61400-1 do local. Exige equipment_id ou
+// campaign_id; aceita start, end, heights, bin_width (padrão 1 m/s), min_speed (padrão 5 m/s),
+// min_count (padrão 10) e as opções de leitura (qc_max, min_availability, min_cnr).
+func GetTurbulence(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ds, err := analyticsDataset(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		columns, ok := datasetTurbulence[ds]
+		if !ok {
+			http.Error(w, "Turbulence analysis is available for sodar and lidarwindcube", http.StatusBadRequest)
+			return
+		}
+		filter, opts, heights, err := parseAnalyticsFilter(r, ds)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		q := r.URL.Query()
+		response := turbulenceResponse{
+			Instrument: q.Get("instrument"), BinWidth: 1, MinSpeed: 5, MinCount: 10,
+			Classes: analytics.IECTurbulenceClasses, Heights: []turbulenceHeight{},
+		}
+		if v := q.Get("bin_width"); v != "" {
+			if response.BinWidth, err = strconv.ParseFloat(v, 64); err != nil || response.BinWidth <= 0 {
+				http.Error(w, "Invalid bin_width", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("min_speed"); v != "" {
+			if response.MinSpeed, err = strconv.ParseFloat(v, 64); err != nil || response.MinSpeed < 0 {
+				http.Error(w, "Invalid min_speed", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("min_count"); v != "" {
+			if response.MinCount, err = strconv.Atoi(v); err != nil || response.MinCount < 1 {
+				http.Error(w, "Invalid min_count", http.StatusBadRequest)
+				return
+			}
+		}
+
+		fields := []string{columns.Speed, columns.Dispersion}
+		intensity := "NULL::float8"
+		if columns.Intensity != "" {
+			fields = append(fields, columns.Intensity)
+			intensity = "v3"
+		}
+		inner, args := longSeriesQuery(ds, opts, filter, nil, fields...)
+		sql := fmt.Sprintf("SELECT height, v1, v2, %s FROM (%s) s WHERE v1 > 0", intensity, inner)
+		rows, err := db.Query(r.Context(), sql, args...)
+		if err != nil {
+			http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to analyze", ds.Name+":", err)
+			return
+		}
+		defer rows.Close()
+
+		tables := map[float64]*analytics.TurbulenceTable{}
+		counts := map[float64]int{}
+		for rows.Next() {
+			var height, dispersion, ti *float64
+			var speed float64
+			if err := rows.Scan(&height, &speed, &dispersion, &ti); err != nil {
+				http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+				log.Println("Failed to analyze", ds.Name+":", err)
+				return
+			}
+			if height == nil || (heights != nil && !heights[*height]) {
+				continue
+			}
+			// Intensidade calculada pelo desvio padrão; a do instrumento só na falta dele
+			switch {
+			case dispersion != nil:
+				v := *dispersion / speed
+				ti = &v
+			case ti == nil:
+				continue
+			}
+			table := tables[*height]
+			if table == nil {
+				table = analytics.NewTurbulenceTable(response.BinWidth)
+				tables[*height] = table
+			}
+			table.Add(speed, *ti)
+			counts[*height]++
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to analyze", ds.Name+":", err)
+			return
+		}
+
+		var levels []float64
+		for height := range tables {
+			levels = append(levels, height)
+		}
+		sort.Float64s(levels)
+		for _, height := range levels {
+			h := height
+			result := turbulenceHeight{Height: &h, Count: counts[height], Bins: tables[height].Bins()}
+			result.Class = analytics.ClassifyTurbulence(result.Bins, response.MinSpeed, response.MinCount)
+			for i := range result.Bins {
+				if result.Bins[i].From <= 15 && 15 < result.Bins[i].To {
+					result.TI15 = &result.Bins[i]
+				}
+			}
+			response.Heights = append(response.Heights, result)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
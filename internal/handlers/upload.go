@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"api/internal/ingest"
+	"api/internal/parsers"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxUploadMemory define quanto do formulário multipart fica em memória; o restante vai para arquivos temporários
+const maxUploadMemory = 32 << 20
+
+// uploadForm reúne os campos comuns aos endpoints de importação de arquivos de instrumentos
+type uploadForm struct {
+	File     multipart.File
+	FileName string
+	Target   ingest.Target
+}
+
+// parseUploadForm lê o arquivo ("file") e os campos equipment_id e campaign_id de um formulário multipart
+func parseUploadForm(r *http.Request) (*uploadForm, error) {
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		return nil, errors.New("Invalid multipart form")
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, errors.New("File is required")
+	}
+
+	form := &uploadForm{
+		File:     file,
+		FileName: header.Filename,
+		Target: ingest.Target{
+			EquipmentID: r.FormValue("equipment_id"),
+			CampaignID:  r.FormValue("campaign_id"),
+		},
+	}
+	if form.Target.EquipmentID == "" || form.Target.CampaignID == "" {
+		file.Close()
+		return nil, errors.New("equipment_id and campaign_id are required")
+	}
+	return form, nil
+}
+
+// formLocation retorna o fuso informado no campo "timezone" do formulário (nil quando ausente)
+func formLocation(r *http.Request) (*time.Location, error) {
+	if timezone := r.FormValue("timezone"); timezone != "" {
+		if loc, err := parsers.ParseTimezone(timezone); err == nil {
+			return loc, nil
+		}
+		return nil, errors.New("Invalid timezone")
+	}
+	return nil, nil
+}
+
+// runUploadJob registra o arquivo do formulário como um job de importação do parser e o executa com
+// load. Um arquivo idêntico (mesmo SHA-256) a um job que não falhou não é importado de novo: a
+// resposta é 409 com o job existente.
+func runUploadJob(w http.ResponseWriter, r *http.Request, db *pgxpool.Pool, form *uploadForm, parser string,
+	load func(target ingest.Target) (*ingest.Summary, error)) {
+	sum, size, err := ingest.Fingerprint(form.File)
+	if err == nil {
+		_, err = form.File.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		log.Println("Failed to read uploaded file:", err)
+		return
+	}
+
+	var submittedBy *string
+	if _, userID, err := getUserRole(r); err == nil && userID != "" {
+		submittedBy = &userID
+	}
+	job, err := ingest.CreateJob(r.Context(), db, sum, form.FileName, size, parser, ingest.SourceUpload, form.Target, submittedBy)
+	if errors.Is(err, ingest.ErrDuplicateFile) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(job)
+		return
+	}
+	if err != nil {
+		writeIngestResult(w, nil, err)
+		return
+	}
+
+	summary, err := job.Run(r.Context(), db, load)
+	writeIngestResult(w, summary, err)
+}
+
+// writeIngestResult responde com o resumo da importação ou com o status adequado ao erro
+func writeIngestResult(w http.ResponseWriter, summary *ingest.Summary, err error) {
+	if err != nil {
+		switch {
+		case errors.Is(err, ingest.ErrUnknownTarget), errors.Is(err, ingest.ErrInvalidHeader),
+			errors.Is(err, parsers.ErrUnknownTimezone):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, parsers.ErrInvalidFormat):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, "Failed to import file", http.StatusInternalServerError)
+			log.Println("Failed to import file:", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"api/internal/analytics"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Classes de velocidade padrão (limites inferiores, m/s) da rosa dos ventos e do histograma de Weibull
+var (
+	defaultWindRoseBins = []float64{0, 2, 4, 6, 8, 10, 12, 15}
+	defaultWeibullBins  = []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25}
+)
+
+// windSeries são as amostras de vento de uma altura
+type windSeries struct {
+	Height     *float64
+	Speeds     []float64
+	Directions []float64
+}
+
+// speedBin é uma classe de velocidade; To é nil na última classe (aberta)
+type speedBin struct {
+	From float64  `json:"from"`
+	To   *float64 `json:"to"`
+}
+
+// speedBins monta as classes a partir dos limites inferiores
+func speedBins(edges []float64) []speedBin {
+	bins := make([]speedBin, len(edges))
+	for i, from := range edges {
+		bins[i].From = from
+		if i+1 < len(edges) {
+			to := edges[i+1]
+			bins[i].To = &to
+		}
+	}
+	return bins
+}
+
+// parseSpeedEdges lê o parâmetro "bins" (limites inferiores crescentes, m/s, separados por vírgula)
+func parseSpeedEdges(r *http.Request, defaults []float64) ([]float64, error) {
+	v := r.URL.Query().Get("bins")
+	if v == "" {
+		return defaults, nil
+	}
+	var edges []float64
+	for _, s := range strings.Split(v, ",") {
+		edge, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || edge < 0 || (len(edges) > 0 && edge <= edges[len(edges)-1]) {
+			return nil, errors.New("Invalid bins: use increasing non-negative speeds")
+		}
+		edges = append(edges, edge)
+	}
+	return edges, nil
+}
+
+// parseWindRequest lê o dataset, os filtros, as opções de leitura e as alturas de uma análise de vento
+func parseWindRequest(r *http.Request) (*instrumentDataset, dataFilter, readOptions, map[float64]bool, error) {
+	ds, err := analyticsDataset(r)
+	if err != nil {
+		return nil, dataFilter{}, readOptions{}, nil, err
+	}
+	if _, ok := datasetWind[ds]; !ok {
+		return nil, dataFilter{}, readOptions{}, nil, fmt.Errorf("No wind data in %s", ds.Name)
+	}
+	filter, opts, heights, err := parseAnalyticsFilter(r, ds)
+	return ds, filter, opts, heights, err
+}
+
+// readWindSeries lê as velocidades (e, com withDirection, as direções) por altura, ordenadas pela altura
+func readWindSeries(ctx context.Context, db *pgxpool.Pool, ds *instrumentDataset, opts readOptions, filter dataFilter, heights map[float64]bool, withDirection bool) ([]*windSeries, error) {
+	columns := datasetWind[ds]
+	fields := []string{columns.Speed}
+	values, condition := "v1", "v1 IS NOT NULL"
+	if withDirection {
+		fields = append(fields, columns.Direction)
+		values, condition = "v1, v2", "v1 IS NOT NULL AND v2 IS NOT NULL"
+	}
+	inner, args := longSeriesQuery(ds, opts, filter, nil, fields...)
+	sql := fmt.Sprintf("SELECT height, %s FROM (%s) s WHERE %s", values, inner, condition)
+
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byHeight := map[float64]*windSeries{}
+	var single *windSeries
+	for rows.Next() {
+		var height *float64
+		var speed, direction float64
+		dest := []interface{}{&height, &speed}
+		if withDirection {
+			dest = append(dest, &direction)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		var series *windSeries
+		if height == nil {
+			if single == nil {
+				single = &windSeries{}
+			}
+			series = single
+		} else {
+			if heights != nil && !heights[*height] {
+				continue
+			}
+			if series = byHeight[*height]; series == nil {
+				series = &windSeries{Height: height}
+				byHeight[*height] = series
+			}
+		}
+		series.Speeds = append(series.Speeds, speed)
+		if withDirection {
+			series.Directions = append(series.Directions, direction)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var result []*windSeries
+	if single != nil {
+		result = append(result, single)
+	}
+	for _, series := range byHeight {
+		result = append(result, series)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Height == nil || result[j].Height == nil {
+			return result[j].Height != nil
+		}
+		return *result[i].Height < *result[j].Height
+	})
+	return result, nil
+}
+
+// windRoseSector é um setor da rosa dos ventos; frequências em % do total de amostras da altura
+type windRoseSector struct {
+	Sector    int       `json:"sector"`
+	Direction float64   `json:"direction"` // Centro do setor (°)
+	Frequency float64   `json:"frequency"`
+	MeanSpeed *float64  `json:"mean_speed"`
+	Bins      []float64 `json:"bins"` // Frequência por classe de velocidade
+}
+
+// windRoseHeight é a rosa dos ventos de uma altura
+type windRoseHeight struct {
+	Height  *float64         `json:"height"`
+	Count   int              `json:"count"`
+	Calm    float64          `json:"calm"` // % abaixo da primeira classe
+	Sectors []windRoseSector `json:"sectors"`
+}
+
+// windRoseResponse é a resposta de /api/analytics/windrose
+type windRoseResponse struct {
+	Instrument string           `json:"instrument"`
+	Sectors    int              `json:"sectors"`
+	Bins       []speedBin       `json:"bins"`
+	Heights    []windRoseHeight `json:"heights"`
+}
+
+// GetWindRose calcula, por altura, a rosa dos ventos do instrumento ("instrument") como matriz de
+// frequência por setor e classe de velocidade. Exige equipment_id ou campaign_id; aceita start, end,
+// sectors (padrão 12), bins (limites inferiores das classes; velocidades abaixo do primeiro contam
+// como calmaria), heights e as opções de leitura (qc_max, min_availability, min_cnr).
+func GetWindRose(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ds, filter, opts, heights, err := parseWindRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sectors := 12
+		if v := r.URL.Query().Get("sectors"); v != "" {
+			if sectors, err = strconv.Atoi(v); err != nil || sectors < 4 || sectors > 72 || 360%sectors != 0 {
+				http.Error(w, "Invalid sectors: use a divisor of 360 between 4 and 72 (e.g. 12 or 16)", http.StatusBadRequest)
+				return
+			}
+		}
+		edges, err := parseSpeedEdges(r, defaultWindRoseBins)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		series, err := readWindSeries(r.Context(), db, ds, opts, filter, heights, true)
+		if err != nil {
+			http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to analyze", ds.Name+":", err)
+			return
+		}
+
+		response := windRoseResponse{
+			Instrument: r.URL.Query().Get("instrument"), Sectors: sectors,
+			Bins: speedBins(edges), Heights: []windRoseHeight{},
+		}
+		for _, s := range series {
+			rose := analytics.NewWindRose(sectors, edges)
+			for i := range s.Speeds {
+				rose.Add(s.Speeds[i], s.Directions[i])
+			}
+			if rose.Total == 0 {
+				continue
+			}
+			total := float64(rose.Total)
+			height := windRoseHeight{Height: s.Height, Count: rose.Total, Calm: 100 * float64(rose.Calm) / total}
+			for i := 0; i < sectors; i++ {
+				sector := windRoseSector{Sector: i, Direction: float64(i) * 360 / float64(sectors), Bins: make([]float64, len(edges))}
+				var count int
+				for j, n := range rose.Counts[i] {
+					sector.Bins[j] = 100 * float64(n) / total
+					count += n
+				}
+				sector.Frequency = 100 * float64(count) / total
+				if count > 0 {
+					sector.MeanSpeed = floatPointer(rose.Speeds[i] / float64(count))
+				}
+				height.Sectors = append(height.Sectors, sector)
+			}
+			response.Heights = append(response.Heights, height)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// weibullBin compara a frequência observada de uma classe com as distribuições ajustadas (frações)
+type weibullBin struct {
+	speedBin
+	Observed float64  `json:"observed"`
+	MLE      *float64 `json:"mle,omitempty"`
+	WAsP     *float64 `json:"wasp,omitempty"`
+}
+
+// weibullHeight é o ajuste de Weibull de uma altura
+type weibullHeight struct {
+	Height    *float64           `json:"height"`
+	Count     int                `json:"count"`
+	MeanSpeed float64            `json:"mean_speed"`
+	MLE       *analytics.Weibull `json:"mle"`     // Máxima verossimilhança
+	WAsP      *analytics.Weibull `json:"wasp"`    // Método do WAsP (terceiro momento e frequência acima da média)
+	Moments   *analytics.Weibull `json:"moments"` // Média e desvio padrão (Justus)
+	Bins      []weibullBin       `json:"bins"`
+}
+
+// weibullResponse é a resposta de /api/analytics/weibull
+type weibullResponse struct {
+	Instrument string          `json:"instrument"`
+	Heights    []weibullHeight `json:"heights"`
+}
+
+// weibullPointer retorna o ajuste ou nil quando ele não convergiu
+func weibullPointer(fit analytics.Weibull, ok bool) *analytics.Weibull {
+	if !ok || math.IsNaN(fit.K) || math.IsNaN(fit.C) {
+		return nil
+	}
+	return &fit
+}
+
+// binProbability retorna a probabilidade da classe [from, to) pela distribuição ajustada
+func binProbability(fit *analytics.Weibull, bin speedBin) *float64 {
+	if fit == nil {
+		return nil
+	}
+	upper := 1.0
+	if bin.To != nil {
+		upper = fit.CDF(*bin.To)
+	}
+	return floatPointer(upper - fit.CDF(bin.From))
+}
+
+// GetWeibull ajusta, por altura, a distribuição de Weibull (k, c) às velocidades do instrumento
+// ("instrument") por máxima verossimilhança, pelo método do WAsP e pelos momentos, com o histograma
+// observado e ajustado nas classes de "bins". Exige equipment_id ou campaign_id; aceita start, end,
+// heights e as opções de leitura (qc_max, min_availability, min_cnr).
+func GetWeibull(db *pgxpool.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ds, filter, opts, heights, err := parseWindRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		edges, err := parseSpeedEdges(r, defaultWeibullBins)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		series, err := readWindSeries(r.Context(), db, ds, opts, filter, heights, false)
+		if err != nil {
+			http.Error(w, "Failed to analyze "+ds.Name, http.StatusInternalServerError)
+			log.Println("Failed to analyze", ds.Name+":", err)
+			return
+		}
+
+		response := weibullResponse{Instrument: r.URL.Query().Get("instrument"), Heights: []weibullHeight{}}
+		bins := speedBins(edges)
+		for _, s := range series {
+			var speeds []float64
+			var sum float64
+			for _, v := range s.Speeds {
+				if v >= 0 && !math.IsNaN(v) && !math.IsInf(v, 0) {
+					speeds = append(speeds, v)
+					sum += v
+				}
+			}
+			if len(speeds) == 0 {
+				continue
+			}
+			height := weibullHeight{
+				Height: s.Height, Count: len(speeds), MeanSpeed: sum / float64(len(speeds)),
+				MLE:     weibullPointer(analytics.FitWeibullMLE(speeds)),
+				WAsP:    weibullPointer(analytics.FitWeibullWAsP(speeds)),
+				Moments: weibullPointer(analytics.FitWeibullMoments(speeds)),
+			}
+
+			counts := make([]int, len(bins))
+			for _, v := range speeds {
+				i := sort.SearchFloat64s(edges, v)
+				if i == len(edges) || edges[i] != v {
+					i--
+				}
+				if i >= 0 {
+					counts[i]++
+				}
+			}
+			for i, bin := range bins {
+				height.Bins = append(height.Bins, weibullBin{
+					speedBin: bin, Observed: float64(counts[i]) / float64(len(speeds)),
+					MLE: binProbability(height.MLE, bin), WAsP: binProbability(height.WAsP, bin),
+				})
+			}
+			response.Heights = append(response.Heights, height)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
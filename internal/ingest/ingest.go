@@ -0,0 +1,258 @@
+// Package ingest grava nas tabelas de dados os arquivos nativos lidos pelo pacote parsers.
+// É usado tanto pelos endpoints de upload quanto por processos sem HTTP.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"api/internal/alerts"
+	"api/internal/availability"
+	"api/internal/parsers"
+	"api/internal/parsers/pd0"
+	"api/internal/qc"
+	"api/internal/stream"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxWarnings limita a quantidade de avisos devolvidos no resumo de uma importação
+const maxWarnings = 50
+
+// ErrUnknownTarget indica que o equipamento ou a campanha informados não existem
+var ErrUnknownTarget = errors.New("equipamento ou campanha inexistente")
+
+// ErrInvalidHeader indica um cabeçalho de arquivo que não pode ser gravado nem ajustado
+var ErrInvalidHeader = errors.New("cabeçalho do arquivo inválido")
+
+// Target identifica o equipamento e a campanha aos quais os dados importados pertencem
+type Target struct {
+	EquipmentID string
+	CampaignID  string
+	JobID       string // Job de importação gravado em IngestionJobID de cada linha (opcional)
+}
+
+// Summary resume o resultado da importação de um arquivo
+type Summary struct {
+	JobID          string                  `json:"job_id,omitempty"`          // Job de importação (IngestionJobs), quando houver
+	HeaderID       string                  `json:"header_id"`                 // UUID do registro de cabeçalho criado
+	FileName       string                  `json:"file_name"`                 // Nome do arquivo importado
+	FileType       string                  `json:"file_type"`                 // Formato detectado (STA, RTD, TOA5, ...)
+	RowsInserted   int64                   `json:"rows_inserted"`             // Linhas gravadas na tabela de dados
+	RowsSkipped    int                     `json:"rows_skipped"`              // Linhas descartadas por erro de leitura
+	RowsDuplicate  int64                   `json:"rows_duplicate,omitempty"`  // Linhas ignoradas por já existirem no banco
+	Heights        []float64               `json:"heights,omitempty"`         // Alturas detectadas (m)
+	IgnoredHeights []float64               `json:"ignored_heights,omitempty"` // Alturas presentes no arquivo mas sem colunas na tabela
+	Start          *time.Time              `json:"start,omitempty"`           // Primeiro timestamp importado
+	End            *time.Time              `json:"end,omitempty"`             // Último timestamp importado
+	Warnings       []string                `json:"warnings,omitempty"`        // Avisos sobre linhas descartadas
+	Diagnostics    *pd0.Stats              `json:"diagnostics,omitempty"`     // Diagnóstico da decodificação de arquivos binários PD0
+	QC             *qc.Result              `json:"qc,omitempty"`              // Resultado do controle de qualidade das linhas importadas
+	Availability   *availability.TableScan `json:"availability,omitempty"`    // Falhas de registro detectadas no trecho importado
+	Alerts         []*alerts.Transition    `json:"alerts,omitempty"`          // Alertas disparados ou normalizados pelos dados importados
+}
+
+// warn registra um aviso respeitando o limite de maxWarnings
+func (s *Summary) warn(format string, args ...interface{}) {
+	if len(s.Warnings) < maxWarnings {
+		s.Warnings = append(s.Warnings, fmt.Sprintf(format, args...))
+	} else if len(s.Warnings) == maxWarnings {
+		s.Warnings = append(s.Warnings, "avisos adicionais omitidos")
+	}
+}
+
+// skip contabiliza uma linha descartada
+func (s *Summary) skip(err error) {
+	s.RowsSkipped++
+	s.warn("%v", err)
+}
+
+// observe atualiza o intervalo de tempo coberto pela importação
+func (s *Summary) observe(ts time.Time) {
+	if s.Start == nil || ts.Before(*s.Start) {
+		t := ts
+		s.Start = &t
+	}
+	if s.End == nil || ts.After(*s.End) {
+		t := ts
+		s.End = &t
+	}
+}
+
+// runQC avalia a qualidade das linhas importadas. Os dados já foram gravados, então uma falha
+// aqui vira um aviso no resumo em vez de um erro da importação.
+func (s *Summary) runQC(ctx context.Context, db *pgxpool.Pool, table, equipmentID string) {
+	if s.Start == nil {
+		return
+	}
+	result, err := qc.Run(ctx, db, table, equipmentID, *s.Start, *s.End)
+	if err != nil {
+		s.warn("controle de qualidade não executado: %v", err)
+		return
+	}
+	s.QC = result
+}
+
+// scanGaps atualiza as falhas de registro do equipamento a partir do início dos dados importados.
+// Como runQC, uma falha aqui vira um aviso no resumo.
+func (s *Summary) scanGaps(ctx context.Context, db *pgxpool.Pool, table, equipmentID string) {
+	if s.Start == nil {
+		return
+	}
+	result, err := availability.ScanTable(ctx, db, equipmentID, table, s.Start, availability.DefaultOutage)
+	if err != nil {
+		s.warn("detecção de falhas não executada: %v", err)
+		return
+	}
+	s.Availability = result
+}
+
+// evaluateAlerts avalia as regras de alerta da tabela para o equipamento. Como runQC, uma falha aqui
+// vira um aviso no resumo.
+func (s *Summary) evaluateAlerts(ctx context.Context, db *pgxpool.Pool, table, equipmentID string) {
+	transitions, err := alerts.EvaluateEquipment(ctx, db, table, equipmentID)
+	s.Alerts = transitions
+	if err != nil {
+		s.warn("avaliação de alertas incompleta: %v", err)
+	}
+}
+
+// publish avisa os streams ao vivo (/api/stream) sobre as linhas importadas. Como runQC, uma falha
+// aqui vira um aviso no resumo.
+func (s *Summary) publish(ctx context.Context, db *pgxpool.Pool, table, equipmentID string) {
+	if s.RowsInserted == 0 {
+		return
+	}
+	if err := stream.Notify(ctx, db, table, equipmentID); err != nil {
+		s.warn("aviso aos streams não enviado: %v", err)
+	}
+}
+
+// resolvedTarget guarda os UUIDs já convertidos para gravação via COPY
+type resolvedTarget struct {
+	equipmentID pgtype.UUID
+	campaignID  pgtype.UUID
+	jobID       pgtype.UUID // Inválido (NULL) quando a importação não tem job
+}
+
+// jobColumn é a coluna das tabelas de dados com o job de importação que gravou a linha; vem depois
+// das colunas de dados no COPY
+const jobColumn = "ingestionjobid"
+
+// resolveTarget valida os UUIDs e confirma que o equipamento e a campanha existem
+func resolveTarget(ctx context.Context, db *pgxpool.Pool, target Target) (resolvedTarget, error) {
+	var rt resolvedTarget
+	if err := rt.equipmentID.Scan(target.EquipmentID); err != nil {
+		return rt, fmt.Errorf("%w: equipment_id inválido", ErrUnknownTarget)
+	}
+	if err := rt.campaignID.Scan(target.CampaignID); err != nil {
+		return rt, fmt.Errorf("%w: campaign_id inválido", ErrUnknownTarget)
+	}
+	if target.JobID != "" {
+		if err := rt.jobID.Scan(target.JobID); err != nil {
+			return rt, fmt.Errorf("job inválido: %w", err)
+		}
+	}
+
+	var equipmentExists, campaignExists bool
+	err := db.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM equipments WHERE equipmentid = $1),
+		       EXISTS(SELECT 1 FROM campaigns WHERE campaignid = $2)`,
+		rt.equipmentID, rt.campaignID).Scan(&equipmentExists, &campaignExists)
+	if err != nil {
+		return rt, err
+	}
+	if !equipmentExists {
+		return rt, fmt.Errorf("%w: equipamento %s", ErrUnknownTarget, target.EquipmentID)
+	}
+	if !campaignExists {
+		return rt, fmt.Errorf("%w: campanha %s", ErrUnknownTarget, target.CampaignID)
+	}
+	return rt, nil
+}
+
+// nullable converte um ponteiro em valor aceito pelo COPY (nil vira NULL)
+func nullable(v *float64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// integerColumns lista as colunas inteiras das tabelas de dados, que precisam de conversão antes do COPY
+var integerColumns = map[string]bool{
+	"wipercount":    true,
+	"pgz":           true,
+	"backscatterid": true,
+}
+
+// recordReader é implementado pelos leitores do pacote parsers que produzem parsers.Record
+type recordReader interface {
+	Next() (*parsers.Record, error)
+}
+
+// recordSource alimenta um COPY a partir de um recordReader, prefixando cada linha com
+// equipmentid, campaignid, o cabeçalho (quando definido) e timestamp e terminando-a com o job de
+// importação. Linhas inválidas são contabilizadas e descartadas.
+type recordSource struct {
+	reader  recordReader
+	integer []bool
+	target  resolvedTarget
+	header  *pgtype.UUID
+	summary *Summary
+	values  []interface{}
+	err     error
+}
+
+func newRecordSource(reader recordReader, columns []string, target resolvedTarget, summary *Summary) *recordSource {
+	integer := make([]bool, len(columns))
+	for i, column := range columns {
+		integer[i] = integerColumns[column]
+	}
+	return &recordSource{reader: reader, integer: integer, target: target, summary: summary}
+}
+
+func (s *recordSource) Next() bool {
+	for {
+		record, err := s.reader.Next()
+		if err == io.EOF {
+			return false
+		}
+		if err != nil {
+			if parsers.IsRowError(err) {
+				s.summary.skip(err)
+				continue
+			}
+			s.err = err
+			return false
+		}
+
+		s.summary.observe(record.Timestamp)
+		s.values = append(s.values[:0], s.target.equipmentID, s.target.campaignID)
+		if s.header != nil {
+			s.values = append(s.values, *s.header)
+		}
+		s.values = append(s.values, record.Timestamp)
+		for i, v := range record.Values {
+			if v != nil && s.integer[i] {
+				s.values = append(s.values, int32(*v))
+				continue
+			}
+			s.values = append(s.values, nullable(v))
+		}
+		s.values = append(s.values, s.target.jobID)
+		return true
+	}
+}
+
+func (s *recordSource) Values() ([]interface{}, error) {
+	return s.values, nil
+}
+
+func (s *recordSource) Err() error {
+	return s.err
+}
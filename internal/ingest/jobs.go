@@ -0,0 +1,339 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"api/internal/availability"
+	"api/internal/qc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Parsers registrados nos jobs de importação
+const (
+	ParserTOA5     = "TOA5"
+	ParserWindCube = "WINDCUBE"
+	ParserSODAR    = "SODAR"
+	ParserPD0      = "PD0"
+)
+
+// Situação de um job de importação
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Origem de um job de importação
+const (
+	SourceUpload  = "upload"
+	SourceIngestd = "ingestd"
+)
+
+// jobTimeout é o tempo após o qual um job ainda em execução é considerado abandonado (o processo que o
+// executava parou) e pode ser removido
+const jobTimeout = time.Hour
+
+var (
+	// ErrDuplicateFile indica que um arquivo com o mesmo SHA-256 já foi importado ou está em importação
+	ErrDuplicateFile = errors.New("arquivo idêntico já importado")
+	// ErrJobNotFound indica um job inexistente
+	ErrJobNotFound = errors.New("job de importação inexistente")
+	// ErrJobRunning indica um job em execução, que não pode ser removido
+	ErrJobRunning = errors.New("job de importação em execução")
+)
+
+// jobTable descreve onde cada parser grava os dados e o cabeçalho
+type jobTable struct {
+	data         string
+	header       string
+	headerColumn string
+}
+
+// jobTables associa os parsers às tabelas de dados e de cabeçalhos
+var jobTables = map[string]jobTable{
+	ParserTOA5:     {"estacaosolarimetricadados", "estacaosolarimetricaheaders", "solarimetricaheaderid"},
+	ParserWindCube: {"lidarwindcubedados", "lidarwindcubeheaders", "windcubeheaderid"},
+	ParserSODAR:    {"sodardados", "sodarheaders", "sodarheaderid"},
+	ParserPD0:      {"adcpdados", "adcpheaders", "adcpheaderid"},
+}
+
+// Job é um job de importação de arquivo
+type Job struct {
+	ID            string     `json:"id"`
+	SHA256        string     `json:"sha256"`
+	FileName      string     `json:"file_name"`
+	FileSize      int64      `json:"file_size"`
+	Parser        string     `json:"parser"`
+	Source        string     `json:"source"`
+	EquipmentID   *string    `json:"equipment_id"`
+	CampaignID    *string    `json:"campaign_id"`
+	Status        string     `json:"status"`
+	FileType      *string    `json:"file_type"`
+	HeaderID      *string    `json:"header_id"`
+	RowsInserted  *int64     `json:"rows_inserted"`
+	RowsDuplicate *int64     `json:"rows_duplicate"`
+	RowsSkipped   *int       `json:"rows_skipped"`
+	DataStart     *time.Time `json:"data_start"`
+	DataEnd       *time.Time `json:"data_end"`
+	Warnings      []string   `json:"warnings"`
+	Error         *string    `json:"error"`
+	SubmittedBy   *string    `json:"submitted_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	StartedAt     *time.Time `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+}
+
+// JobFilter filtra a listagem de jobs; campos vazios não filtram
+type JobFilter struct {
+	Status      string
+	EquipmentID string
+	Parser      string
+	Limit       int
+}
+
+// Rollback resume a remoção de um job e das linhas que ele gravou
+type Rollback struct {
+	JobID         string                  `json:"job_id"`
+	Table         string                  `json:"table"`
+	RowsDeleted   int64                   `json:"rows_deleted"`
+	FlagsDeleted  int64                   `json:"qc_flags_deleted"`
+	HeaderDeleted bool                    `json:"header_deleted"`
+	Availability  *availability.TableScan `json:"availability,omitempty"` // Falhas de registro recalculadas no trecho removido
+	Warning       string                  `json:"warning,omitempty"`
+}
+
+// Fingerprint calcula o SHA-256 do conteúdo de r e o seu tamanho
+func Fingerprint(r io.Reader) (string, int64, error) {
+	h := sha256.New()
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// jobSelect lista as colunas lidas por scanJob
+const jobSelect = `
+	SELECT ingestionjobid::text, sha256, filename, filesize, parser, source, equipmentid::text, campaignid::text,
+	       status, filetype, headerid::text, rowsinserted, rowsduplicate, rowsskipped, datastart, dataend,
+	       warnings, error, submittedby::text, createdat, startedat, finishedat
+	FROM IngestionJobs`
+
+// scanJob lê um job de uma linha de jobSelect
+func scanJob(row pgx.Row) (*Job, error) {
+	var j Job
+	err := row.Scan(&j.ID, &j.SHA256, &j.FileName, &j.FileSize, &j.Parser, &j.Source, &j.EquipmentID, &j.CampaignID,
+		&j.Status, &j.FileType, &j.HeaderID, &j.RowsInserted, &j.RowsDuplicate, &j.RowsSkipped, &j.DataStart, &j.DataEnd,
+		&j.Warnings, &j.Error, &j.SubmittedBy, &j.CreatedAt, &j.StartedAt, &j.FinishedAt)
+	if j.Warnings == nil {
+		j.Warnings = []string{}
+	}
+	return &j, err
+}
+
+// CreateJob registra um job na fila para o arquivo com o SHA-256 sum. Quando já existe um job que não
+// falhou para o mesmo conteúdo, retorna esse job com ErrDuplicateFile.
+func CreateJob(ctx context.Context, db *pgxpool.Pool, sum, fileName string, size int64, parser, source string, target Target, submittedBy *string) (*Job, error) {
+	if _, ok := jobTables[parser]; !ok {
+		return nil, fmt.Errorf("parser desconhecido: %s", parser)
+	}
+	rt, err := resolveTarget(ctx, db, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var id string
+	err = db.QueryRow(ctx, `
+		INSERT INTO IngestionJobs (sha256, filename, filesize, parser, source, equipmentid, campaignid, submittedby)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (sha256) WHERE status <> 'failed' DO NOTHING
+		RETURNING ingestionjobid::text`,
+		sum, fileName, size, parser, source, rt.equipmentID, rt.campaignID, submittedBy).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		existing, err := scanJob(db.QueryRow(ctx, jobSelect+" WHERE sha256 = $1 AND status <> 'failed'", sum))
+		if err != nil {
+			return nil, err
+		}
+		return existing, ErrDuplicateFile
+	}
+	if err != nil {
+		return nil, err
+	}
+	return GetJob(ctx, db, id)
+}
+
+// Run executa o job: load recebe o Target com o JobID preenchido e deve chamar o loader do parser.
+// A situação, as contagens e os avisos são gravados no job ao final.
+func (j *Job) Run(ctx context.Context, db *pgxpool.Pool, load func(target Target) (*Summary, error)) (*Summary, error) {
+	_, err := db.Exec(ctx, `
+		UPDATE IngestionJobs SET status = 'running', startedat = now() WHERE ingestionjobid = $1::uuid`, j.ID)
+	if err != nil {
+		return nil, err
+	}
+	target := Target{JobID: j.ID}
+	if j.EquipmentID != nil && j.CampaignID != nil {
+		target.EquipmentID, target.CampaignID = *j.EquipmentID, *j.CampaignID
+	}
+
+	summary, loadErr := load(target)
+
+	// O registro do resultado não depende da requisição, que pode ter sido cancelada durante a importação
+	done, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if loadErr != nil {
+		if _, err := db.Exec(done, `
+			UPDATE IngestionJobs SET status = 'failed', error = $2, finishedat = now()
+			WHERE ingestionjobid = $1::uuid`, j.ID, loadErr.Error()); err != nil {
+			return nil, fmt.Errorf("%w (falha ao registrar o job: %v)", loadErr, err)
+		}
+		return nil, loadErr
+	}
+
+	summary.JobID = j.ID
+	warnings := summary.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+	_, err = db.Exec(done, `
+		UPDATE IngestionJobs SET status = 'succeeded', filetype = $2, headerid = NULLIF($3, '')::uuid,
+		       rowsinserted = $4, rowsduplicate = $5, rowsskipped = $6, datastart = $7, dataend = $8,
+		       warnings = $9, finishedat = now()
+		WHERE ingestionjobid = $1::uuid`,
+		j.ID, summary.FileType, summary.HeaderID, summary.RowsInserted, summary.RowsDuplicate, summary.RowsSkipped,
+		summary.Start, summary.End, warnings)
+	if err != nil {
+		// Os dados já foram gravados: o job fica em execução até ser removido ou expirar
+		summary.warn("falha ao registrar o job de importação: %v", err)
+	}
+	return summary, nil
+}
+
+// GetJob retorna um job; ErrJobNotFound quando ele não existe
+func GetJob(ctx context.Context, db *pgxpool.Pool, id string) (*Job, error) {
+	j, err := scanJob(db.QueryRow(ctx, jobSelect+" WHERE ingestionjobid = $1::uuid", id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	return j, err
+}
+
+// ListJobs retorna os jobs do mais recente ao mais antigo
+func ListJobs(ctx context.Context, db *pgxpool.Pool, f JobFilter) ([]*Job, error) {
+	var equipmentID interface{}
+	if f.EquipmentID != "" {
+		equipmentID = f.EquipmentID
+	}
+	rows, err := db.Query(ctx, jobSelect+`
+		WHERE ($1 = '' OR status = $1) AND ($2::uuid IS NULL OR equipmentid = $2) AND ($3 = '' OR parser = $3)
+		ORDER BY createdat DESC LIMIT $4`, f.Status, equipmentID, f.Parser, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	jobs := []*Job{}
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// DeleteJob remove o job e desfaz a importação: apaga as linhas gravadas por ele (e as suas marcações
+// de controle de qualidade) e o cabeçalho criado, em uma única transação. As falhas de registro do
+// equipamento são recalculadas a partir do início dos dados removidos. Jobs em execução há menos de
+// jobTimeout não são removidos (ErrJobRunning).
+func DeleteJob(ctx context.Context, db *pgxpool.Pool, id string) (*Rollback, error) {
+	return deleteJob(ctx, db, id, false)
+}
+
+// DeleteInterruptedJob remove um job que o processo chamador sabe ter sido interrompido, mesmo que
+// ele ainda conste como em execução
+func DeleteInterruptedJob(ctx context.Context, db *pgxpool.Pool, id string) (*Rollback, error) {
+	return deleteJob(ctx, db, id, true)
+}
+
+// deleteJob implementa DeleteJob; force ignora a verificação de jobs em execução
+func deleteJob(ctx context.Context, db *pgxpool.Pool, id string, force bool) (*Rollback, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var parser, status string
+	var equipmentID, headerID *string
+	var dataStart *time.Time
+	var createdAt time.Time
+	var startedAt *time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT parser, status, equipmentid::text, headerid::text, datastart, createdat, startedat
+		FROM IngestionJobs WHERE ingestionjobid = $1::uuid FOR UPDATE`, id).
+		Scan(&parser, &status, &equipmentID, &headerID, &dataStart, &createdAt, &startedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !force && (status == JobQueued || status == JobRunning) {
+		since := createdAt
+		if startedAt != nil {
+			since = *startedAt
+		}
+		if time.Since(since) < jobTimeout {
+			return nil, ErrJobRunning
+		}
+	}
+
+	t := jobTables[parser]
+	rb := &Rollback{JobID: id, Table: t.data}
+	// Os nomes de tabelas e colunas vêm de jobTables e da configuração de QC
+	if cfg, ok := qc.Active.Tables[t.data]; ok {
+		tag, err := tx.Exec(ctx, fmt.Sprintf(`
+			DELETE FROM QCFlags WHERE datatable = $1
+			  AND rowid IN (SELECT %s FROM %s WHERE ingestionjobid = $2::uuid)`, cfg.IDColumn, t.data), t.data, id)
+		if err != nil {
+			return nil, err
+		}
+		rb.FlagsDeleted = tag.RowsAffected()
+	}
+	tag, err := tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE ingestionjobid = $1::uuid", t.data), id)
+	if err != nil {
+		return nil, err
+	}
+	rb.RowsDeleted = tag.RowsAffected()
+	if headerID != nil {
+		tag, err := tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s = $1::uuid", t.header, t.headerColumn), *headerID)
+		if err != nil {
+			return nil, err
+		}
+		rb.HeaderDeleted = tag.RowsAffected() > 0
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM IngestionJobs WHERE ingestionjobid = $1::uuid", id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	if equipmentID != nil && dataStart != nil && rb.RowsDeleted > 0 {
+		scan, err := availability.ScanTable(ctx, db, *equipmentID, t.data, dataStart, availability.DefaultOutage)
+		if err != nil {
+			rb.Warning = fmt.Sprintf("detecção de falhas não executada: %v", err)
+		}
+		rb.Availability = scan
+	}
+	return rb, nil
+}
@@ -0,0 +1,173 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"api/internal/models"
+	"api/internal/parsers"
+	"api/internal/parsers/pd0"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pd0Beams é a quantidade de feixes gravada em ADCPDados; o quinto feixe do Sentinel V é ignorado
+const pd0Beams = 4
+
+// LoadPD0 importa um arquivo binário PD0 do ADCP: grava o cabeçalho em ADCPHeaders e uma linha de
+// ADCPDados por ensemble e célula via COPY, na mesma transação. Ensembles com checksum inválido ou
+// incompletos são descartados e contabilizados em Summary.Diagnostics.
+func LoadPD0(ctx context.Context, db *pgxpool.Pool, r io.Reader, fileName string, target Target) (*Summary, error) {
+	rt, err := resolveTarget(ctx, db, target)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := pd0.NewDecoder(r)
+	summary := &Summary{FileName: fileName, FileType: "PD0", Diagnostics: &decoder.Stats}
+
+	// O fixed leader do primeiro ensemble válido descreve a configuração gravada no cabeçalho
+	var first *pd0.Ensemble
+	for first == nil {
+		first, err = decoder.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: nenhum ensemble PD0 válido em %s", parsers.ErrInvalidFormat, fileName)
+		}
+		if err != nil {
+			if !isEnsembleError(err) {
+				return nil, err
+			}
+			summary.warn("%v", err)
+		}
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	f := first.Fixed
+	err = tx.QueryRow(ctx, `
+		INSERT INTO ADCPHeaders
+			(equipmentid, campaignid, filename, serialnumber, firmwareversion, frequency, beamangle,
+			numberofbeams, numberofcells, cellsize, blank, bin1distance, pingsperensemble,
+			coordinatesystem, beamsfacingup)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING adcpheaderid`,
+		rt.equipmentID, rt.campaignID, fileName, strconv.FormatUint(uint64(f.SerialNumber), 10), f.FirmwareVersion,
+		f.FrequencyKHz, f.BeamAngle, f.NumberOfBeams, f.NumberOfCells, f.CellSize, f.Blank, f.Bin1Distance,
+		f.PingsPerEnsemble, f.CoordinateSystem, f.BeamsFacingUp,
+	).Scan(&summary.HeaderID)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := append(append([]string{"equipmentid", "campaignid", "timestamp"}, models.ADCPColumns...), jobColumn)
+	source := &pd0Source{decoder: decoder, ensemble: first, target: rt, summary: summary, cell: -1}
+	summary.RowsInserted, err = tx.CopyFrom(ctx, pgx.Identifier{"adcpdados"}, columns, source)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := decoder.Stats
+	_, err = tx.Exec(ctx, `
+		UPDATE ADCPHeaders SET ensemblesread = $1, checksumfailures = $2, ensemblesdropped = $3
+		WHERE adcpheaderid = $4`,
+		stats.Ensembles, stats.ChecksumFailures, stats.Dropped, summary.HeaderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	summary.runQC(ctx, db, "adcpdados", target.EquipmentID)
+	summary.publish(ctx, db, "adcpdados", target.EquipmentID)
+	summary.scanGaps(ctx, db, "adcpdados", target.EquipmentID)
+	summary.evaluateAlerts(ctx, db, "adcpdados", target.EquipmentID)
+	return summary, nil
+}
+
+// isEnsembleError indica se o erro descreve um ensemble descartado, após o qual a leitura pode continuar
+func isEnsembleError(err error) bool {
+	var ensembleErr *pd0.EnsembleError
+	return errors.As(err, &ensembleErr)
+}
+
+// pd0Source alimenta o COPY de ADCPDados com uma linha por célula de cada ensemble
+type pd0Source struct {
+	decoder  *pd0.Decoder
+	ensemble *pd0.Ensemble
+	cell     int
+	target   resolvedTarget
+	summary  *Summary
+	values   []interface{}
+	err      error
+}
+
+func (s *pd0Source) Next() bool {
+	s.cell++
+	for s.ensemble == nil || s.cell >= s.ensemble.Fixed.NumberOfCells {
+		ensemble, err := s.decoder.Next()
+		if err == io.EOF {
+			return false
+		}
+		if err != nil {
+			if isEnsembleError(err) {
+				s.summary.warn("%v", err)
+				continue
+			}
+			s.err = err
+			return false
+		}
+		s.ensemble, s.cell = ensemble, 0
+	}
+
+	e, cell := s.ensemble, s.cell
+	v := e.Variable
+	if cell == 0 {
+		s.summary.observe(v.Time)
+	}
+
+	s.values = append(s.values[:0], s.target.equipmentID, s.target.campaignID, v.Time,
+		int32(v.EnsembleNumber), int32(cell+1), e.CellDistance(cell), e.CellDepth(cell))
+
+	velocities := make([]*float64, pd0Beams)
+	for beam := range velocities {
+		velocities[beam] = e.VelocityMS(cell, beam)
+		s.values = append(s.values, nullable(velocities[beam]))
+	}
+	if e.Fixed.CoordinateSystem == pd0.CoordinatesEarth && velocities[0] != nil && velocities[1] != nil {
+		speed, direction := pd0.CurrentSpeedDirection(*velocities[0], *velocities[1])
+		s.values = append(s.values, speed, direction)
+	} else {
+		s.values = append(s.values, nil, nil)
+	}
+
+	for _, matrix := range [][][]uint8{e.Correlation, e.EchoIntensity, e.PercentGood} {
+		for beam := 0; beam < pd0Beams; beam++ {
+			if cell < len(matrix) && beam < len(matrix[cell]) {
+				s.values = append(s.values, int32(matrix[cell][beam]))
+			} else {
+				s.values = append(s.values, nil)
+			}
+		}
+	}
+
+	s.values = append(s.values, v.Heading, v.Pitch, v.Roll, v.Temperature, v.Salinity, v.Pressure,
+		v.TransducerDepth, v.SpeedOfSound, s.target.jobID)
+	return true
+}
+
+func (s *pd0Source) Values() ([]interface{}, error) {
+	return s.values, nil
+}
+
+func (s *pd0Source) Err() error {
+	return s.err
+}
@@ -0,0 +1,143 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"api/internal/parsers"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ReferenceSeries descreve uma série de referência de longo prazo a importar
+type ReferenceSeries struct {
+	Name      string
+	Source    string
+	Height    *float64
+	Latitude  *float64 // CSV: coordenadas da série; NetCDF: nó da grade a extrair
+	Longitude *float64
+}
+
+// LoadReferenceSeries importa uma série de referência (CSV ou NetCDF clássico, detectado pelo
+// conteúdo): grava ReferenceSeries e as amostras em ReferenceSeriesData via COPY, na mesma transação.
+// Timestamps repetidos no arquivo são ignorados.
+func LoadReferenceSeries(ctx context.Context, db *pgxpool.Pool, r io.ReaderAt, size int64, fileName string, series ReferenceSeries, opts parsers.ReferenceOptions) (*Summary, error) {
+	magic := make([]byte, 4)
+	n, _ := r.ReadAt(magic, 0)
+	magic = magic[:n]
+
+	var reader recordReader
+	summary := &Summary{FileName: filepath.Base(fileName), FileType: "CSV"}
+	latitude, longitude := series.Latitude, series.Longitude
+	switch {
+	case bytes.HasPrefix(magic, []byte("\x89HDF")):
+		return nil, fmt.Errorf("%w: NetCDF-4/HDF5 não suportado; converta para o formato clássico (nccopy -k classic)", parsers.ErrInvalidFormat)
+	case bytes.HasPrefix(magic, []byte("CDF")):
+		opts.Latitude, opts.Longitude = series.Latitude, series.Longitude
+		nr, err := parsers.NewReferenceNetCDFReader(r, size, opts)
+		if err != nil {
+			return nil, err
+		}
+		reader, summary.FileType = nr, "NetCDF"
+		latitude, longitude = nr.Latitude, nr.Longitude
+	default:
+		cr, err := parsers.NewReferenceCSVReader(io.NewSectionReader(r, 0, size), opts)
+		if err != nil {
+			return nil, err
+		}
+		reader = cr
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO ReferenceSeries (name, source, filename, filetype, latitude, longitude, height)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING referenceseriesid`,
+		series.Name, series.Source, summary.FileName, summary.FileType, latitude, longitude, series.Height,
+	).Scan(&summary.HeaderID)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &referenceSource{reader: reader, summary: summary, seen: map[time.Time]bool{}}
+	if err := source.seriesID.Scan(summary.HeaderID); err != nil {
+		return nil, err
+	}
+	columns := append([]string{"referenceseriesid", "timestamp"}, parsers.ReferenceColumns...)
+	summary.RowsInserted, err = tx.CopyFrom(ctx, pgx.Identifier{"referenceseriesdata"}, columns, source)
+	if err != nil {
+		return nil, err
+	}
+	if summary.RowsInserted == 0 {
+		return nil, fmt.Errorf("%w: nenhuma amostra válida no arquivo", parsers.ErrInvalidFormat)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// referenceSource alimenta o COPY de ReferenceSeriesData, descartando linhas inválidas, sem velocidade
+// ou com timestamp repetido
+type referenceSource struct {
+	reader   recordReader
+	seriesID pgtype.UUID
+	summary  *Summary
+	seen     map[time.Time]bool
+	values   []interface{}
+	err      error
+}
+
+func (s *referenceSource) Next() bool {
+	for {
+		record, err := s.reader.Next()
+		if err == io.EOF {
+			return false
+		}
+		if err != nil {
+			if parsers.IsRowError(err) {
+				s.summary.skip(err)
+				continue
+			}
+			s.err = err
+			return false
+		}
+		if record.Values[0] == nil {
+			s.summary.skip(&parsers.RowError{Line: record.Line, Err: fmt.Errorf("velocidade ausente")})
+			continue
+		}
+		ts := record.Timestamp.UTC()
+		if s.seen[ts] {
+			s.summary.RowsDuplicate++
+			continue
+		}
+		s.seen[ts] = true
+
+		s.summary.observe(record.Timestamp)
+		s.values = append(s.values[:0], s.seriesID, record.Timestamp)
+		for _, v := range record.Values {
+			s.values = append(s.values, nullable(v))
+		}
+		return true
+	}
+}
+
+func (s *referenceSource) Values() ([]interface{}, error) {
+	return s.values, nil
+}
+
+func (s *referenceSource) Err() error {
+	return s.err
+}
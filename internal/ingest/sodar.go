@@ -0,0 +1,66 @@
+package ingest
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"api/internal/parsers"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LoadSODAR importa um arquivo do SODAR (Scintec .mnd ou exportação do AQ500): grava o cabeçalho em
+// SODARHeaders e uma linha de SODARDados por timestamp e altura via COPY, na mesma transação.
+// Os timestamps são interpretados no fuso loc (UTC quando nil).
+func LoadSODAR(ctx context.Context, db *pgxpool.Pool, r io.Reader, fileName string, target Target, loc *time.Location) (*Summary, error) {
+	rt, err := resolveTarget(ctx, db, target)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := parsers.NewSODARReader(r, fileName, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{FileName: reader.Header.FileName, FileType: reader.Format}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	h := reader.Header
+	err = tx.QueryRow(ctx, `
+		INSERT INTO SODARHeaders
+			(equipmentid, campaignid, filename, deviceserialnumber, stationcode, softwareversion,
+			antennaazimuthangle, heightaboveground, heightabovesealevel)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING sodarheaderid`,
+		rt.equipmentID, rt.campaignID, h.FileName, h.DeviceSerialNumber, h.StationCode, h.SoftwareVersion,
+		h.AntennaAzimuthAngle, h.HeightAboveGround, h.HeightAboveSeaLevel,
+	).Scan(&summary.HeaderID)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := append(append([]string{"equipmentid", "campaignid", "timestamp"}, reader.Columns...), jobColumn)
+	source := newRecordSource(reader, reader.Columns, rt, summary)
+	summary.RowsInserted, err = tx.CopyFrom(ctx, pgx.Identifier{"sodardados"}, columns, source)
+	if err != nil {
+		return nil, err
+	}
+	summary.Heights = reader.Heights()
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	summary.runQC(ctx, db, "sodardados", target.EquipmentID)
+	summary.publish(ctx, db, "sodardados", target.EquipmentID)
+	summary.scanGaps(ctx, db, "sodardados", target.EquipmentID)
+	summary.evaluateAlerts(ctx, db, "sodardados", target.EquipmentID)
+	return summary, nil
+}
@@ -0,0 +1,102 @@
+package ingest
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"api/internal/parsers"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TOA5Options ajusta a importação de arquivos TOA5
+type TOA5Options struct {
+	Aliases  map[string]string // Apelidos adicionais (campo Campbell → coluna), aplicados sobre parsers.DefaultTOA5Aliases
+	Location *time.Location    // Fuso horário do relógio do registrador (UTC quando nil)
+}
+
+// LoadTOA5 importa um arquivo TOA5 da estação solarimétrica. O cabeçalho vai para EstacaoSolarimetricaHeaders
+// e as linhas são carregadas com um único COPY em uma tabela temporária; apenas os timestamps que ainda
+// não existem para o equipamento são copiados para EstacaoSolarimetricaDados. O índice único
+// (EquipmentID, timestamp) resolve também importações simultâneas de arquivos sobrepostos.
+func LoadTOA5(ctx context.Context, db *pgxpool.Pool, r io.Reader, fileName string, target Target, opts TOA5Options) (*Summary, error) {
+	rt, err := resolveTarget(ctx, db, target)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases := parsers.DefaultTOA5Aliases()
+	for field, column := range opts.Aliases {
+		aliases[field] = column
+	}
+
+	reader, err := parsers.NewTOA5Reader(r, fileName, aliases, opts.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{FileName: reader.Header.FileName, FileType: reader.Environment.FileFormat}
+	for _, field := range reader.Unmapped {
+		summary.warn("campo %s sem coluna correspondente", field)
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	h := reader.Header
+	err = tx.QueryRow(ctx, `
+		INSERT INTO EstacaoSolarimetricaHeaders
+			(equipmentid, campaignid, filename, solarradiationheader, temperatureheader, barometricpressureheader,
+			stationname, loggermodel, loggerserial, loggeros, programname, programsignature, tablename,
+			fieldnames, units, processing)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		RETURNING solarimetricaheaderid`,
+		rt.equipmentID, rt.campaignID, h.FileName, h.SolarRadiationHeader, h.TemperatureHeader, h.BarometricPressureHeader,
+		h.StationName, h.LoggerModel, h.LoggerSerial, h.LoggerOS, h.ProgramName, h.ProgramSignature, h.TableName,
+		h.FieldNames, h.Units, h.Processing,
+	).Scan(&summary.HeaderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// As colunas vêm de models.EstacaoSolarimetricaColumns (validadas pelo parser), então podem ser interpoladas
+	columns := append(append([]string{"equipmentid", "campaignid", "timestamp"}, reader.Columns...), jobColumn)
+	columnList := strings.Join(columns, ", ")
+
+	_, err = tx.Exec(ctx, `CREATE TEMP TABLE toa5_staging ON COMMIT DROP AS
+		SELECT `+columnList+` FROM EstacaoSolarimetricaDados WITH NO DATA`)
+	if err != nil {
+		return nil, err
+	}
+
+	source := newRecordSource(reader, reader.Columns, rt, summary)
+	copied, err := tx.CopyFrom(ctx, pgx.Identifier{"toa5_staging"}, columns, source)
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO EstacaoSolarimetricaDados (`+columnList+`)
+		SELECT `+columnList+` FROM toa5_staging ORDER BY timestamp
+		ON CONFLICT (equipmentid, timestamp) DO NOTHING`)
+	if err != nil {
+		return nil, err
+	}
+	summary.RowsInserted = tag.RowsAffected()
+	summary.RowsDuplicate = copied - summary.RowsInserted
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	summary.runQC(ctx, db, "estacaosolarimetricadados", target.EquipmentID)
+	summary.publish(ctx, db, "estacaosolarimetricadados", target.EquipmentID)
+	summary.scanGaps(ctx, db, "estacaosolarimetricadados", target.EquipmentID)
+	summary.evaluateAlerts(ctx, db, "estacaosolarimetricadados", target.EquipmentID)
+	return summary, nil
+}
@@ -0,0 +1,130 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"api/internal/models"
+	"api/internal/parsers"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LoadWindCube importa um arquivo .sta/.rtd do WindCube: grava o cabeçalho em LIDARWindCubeHeaders
+// e envia as linhas para LIDARWindCubeDados via COPY, na mesma transação.
+func LoadWindCube(ctx context.Context, db *pgxpool.Pool, r io.Reader, fileName string, target Target) (*Summary, error) {
+	rt, err := resolveTarget(ctx, db, target)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := parsers.NewWindCubeReader(r, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{FileName: reader.Header.FileName, FileType: reader.FileType}
+	if err := fitWindCubeHeader(&reader.Header, summary); err != nil {
+		return nil, err
+	}
+	for _, h := range reader.Heights {
+		summary.Heights = append(summary.Heights, float64(h))
+	}
+	for _, h := range reader.IgnoredHeights {
+		summary.IgnoredHeights = append(summary.IgnoredHeights, float64(h))
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	h := reader.Header
+	err = tx.QueryRow(ctx, `
+		INSERT INTO LIDARWindCubeHeaders
+			(equipmentid, campaignid, filename, headersize, version, idsystem, idclient, location, gpslocation, comments,
+			fcroption, timezone, laserdiodecurrent, los, installationoffsetagl, cnrthreshold, vrthreshold, sigmafreqthreshold,
+			wipercnrthreshold, wiperaltitude, wiperduration, altitudesagl, samplingfrequency, reffrequency, pulsesperlos,
+			samplesperpulse, reflectedpulsestart, reflectedpulseend, refpulsesamplesnb, nbhighpassfilterpoints, fftwindowwidth,
+			pulserepetitionrate, pulseduration, triggerdelaytime, wavelength, scanangle, directionoffset, declination,
+			pitchangle, rollangle)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24,
+			$25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37, $38, $39, $40)
+		RETURNING windcubeheaderid`,
+		rt.equipmentID, rt.campaignID, h.FileName, h.HeaderSize, h.Version, h.IDSystem, h.IDClient, h.Location, h.GPSLocation, h.Comments,
+		h.FCROption, h.Timezone, h.LaserDiodeCurrent, h.LOS, h.InstallationOffsetAGL, h.CNRThreshold, h.VrThreshold, h.SigmaFreqThreshold,
+		h.WiperCNRThreshold, h.WiperAltitude, h.WiperDuration, h.AltitudesAGL, h.SamplingFrequency, h.RefFrequency, h.PulsesPerLOS,
+		h.SamplesPerPulse, h.ReflectedPulseStart, h.ReflectedPulseEnd, h.RefPulseSamplesNb, h.NbHighPassFilterPoints, h.FFTWindowWidth,
+		h.PulseRepetitionRate, h.PulseDuration, h.TriggerDelayTime, h.Wavelength, h.ScanAngle, h.DirectionOffset, h.Declination,
+		h.PitchAngle, h.RollAngle,
+	).Scan(&summary.HeaderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cada linha referencia o cabeçalho do arquivo, de onde vem o CNRThreshold usado na leitura
+	var headerID pgtype.UUID
+	if err := headerID.Scan(summary.HeaderID); err != nil {
+		return nil, err
+	}
+	columns := append(append([]string{"equipmentid", "campaignid", "windcubeheaderid", "timestamp"}, reader.Columns...), jobColumn)
+	source := newRecordSource(reader, reader.Columns, rt, summary)
+	source.header = &headerID
+	summary.RowsInserted, err = tx.CopyFrom(ctx, pgx.Identifier{"lidarwindcubedados"}, columns, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	summary.runQC(ctx, db, "lidarwindcubedados", target.EquipmentID)
+	summary.publish(ctx, db, "lidarwindcubedados", target.EquipmentID)
+	summary.scanGaps(ctx, db, "lidarwindcubedados", target.EquipmentID)
+	summary.evaluateAlerts(ctx, db, "lidarwindcubedados", target.EquipmentID)
+	return summary, nil
+}
+
+// headerField é um campo texto do cabeçalho e o tamanho da coluna VARCHAR que o recebe (0 para TEXT)
+type headerField struct {
+	name  string
+	value *string
+	size  int
+}
+
+// fitWindCubeHeader ajusta os campos texto do cabeçalho aos tamanhos das colunas de
+// LIDARWindCubeHeaders, registrando um aviso para cada campo truncado. Texto que o banco não aceita
+// (UTF-8 inválido ou byte nulo) devolve ErrInvalidHeader. O fuso já foi validado pelo parser e é
+// gravado como declarado, pois a coluna comporta qualquer nome IANA.
+func fitWindCubeHeader(h *models.LIDARWindCubeHeader, s *Summary) error {
+	fields := []headerField{
+		{"FileName", &h.FileName, 255}, {"Version", &h.Version, 50}, {"ID System", &h.IDSystem, 50},
+		{"ID Client", &h.IDClient, 50}, {"Location", &h.Location, 50}, {"GPS Location", &h.GPSLocation, 100},
+		{"Comments", &h.Comments, 0}, {"FCR Option", &h.FCROption, 50}, {"Timezone", &h.Timezone, 64},
+		{"Vr Threshold", &h.VrThreshold, 50}, {"Sigma Freq Threshold", &h.SigmaFreqThreshold, 50},
+		{"Altitudes AGL", &h.AltitudesAGL, 0}, {"Sampling Frequency", &h.SamplingFrequency, 50},
+		{"Ref Frequency", &h.RefFrequency, 50}, {"Pulses/LOS", &h.PulsesPerLOS, 50},
+		{"Samples/Pulse", &h.SamplesPerPulse, 50}, {"Reflected Pulse Start", &h.ReflectedPulseStart, 50},
+		{"Reflected Pulse End", &h.ReflectedPulseEnd, 50}, {"Ref Pulse Samples Nb", &h.RefPulseSamplesNb, 50},
+		{"Nb High Pass Filter Points", &h.NbHighPassFilterPoints, 50}, {"FFT Window Width", &h.FFTWindowWidth, 50},
+		{"Pulse Repetition Rate", &h.PulseRepetitionRate, 50}, {"Pulse Duration", &h.PulseDuration, 50},
+		{"Trigger Delay Time", &h.TriggerDelayTime, 50}, {"Wavelength", &h.Wavelength, 50},
+		{"Scan Angle", &h.ScanAngle, 50}, {"Declination", &h.Declination, 50},
+	}
+	for _, f := range fields {
+		if !utf8.ValidString(*f.value) || strings.ContainsRune(*f.value, 0) {
+			return fmt.Errorf("%w: campo %s contém caracteres inválidos", ErrInvalidHeader, f.name)
+		}
+		if f.size > 0 && utf8.RuneCountInString(*f.value) > f.size {
+			s.warn("cabeçalho: campo %s truncado para %d caracteres", f.name, f.size)
+			*f.value = string([]rune(*f.value)[:f.size])
+		}
+	}
+	return nil
+}
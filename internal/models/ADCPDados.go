@@ -0,0 +1,36 @@
+package models
+
+// ADCPColumns lista as colunas de medição de ADCPDados (nomes em minúsculas, como no PostgreSQL).
+// Cada linha da tabela corresponde a um ensemble e a uma célula de profundidade.
+var ADCPColumns = []string{
+	"ensemblenumber",   // Número do ensemble
+	"cell",             // Número da célula (1 = mais próxima do transdutor)
+	"distance",         // Distância do transdutor ao centro da célula (m)
+	"depth",            // Profundidade do centro da célula (m)
+	"velocity1",        // Velocidade do feixe 1 ou leste (m/s)
+	"velocity2",        // Velocidade do feixe 2 ou norte (m/s)
+	"velocity3",        // Velocidade do feixe 3 ou vertical (m/s)
+	"velocity4",        // Velocidade do feixe 4 ou erro (m/s)
+	"currentspeed",     // Velocidade horizontal da corrente (m/s)
+	"currentdirection", // Direção para onde a corrente flui (°)
+	"correlation1",     // Correlação do feixe 1 (contagens)
+	"correlation2",     // Correlação do feixe 2 (contagens)
+	"correlation3",     // Correlação do feixe 3 (contagens)
+	"correlation4",     // Correlação do feixe 4 (contagens)
+	"echointensity1",   // Intensidade de eco do feixe 1 (contagens)
+	"echointensity2",   // Intensidade de eco do feixe 2 (contagens)
+	"echointensity3",   // Intensidade de eco do feixe 3 (contagens)
+	"echointensity4",   // Intensidade de eco do feixe 4 (contagens)
+	"percentgood1",     // Percentual bom do feixe 1 (%)
+	"percentgood2",     // Percentual bom do feixe 2 (%)
+	"percentgood3",     // Percentual bom do feixe 3 (%)
+	"percentgood4",     // Percentual bom do feixe 4 (%)
+	"heading",          // Rumo (°)
+	"pitch",            // Arfagem (°)
+	"roll",             // Rolagem (°)
+	"watertemperature", // Temperatura da água (°C)
+	"salinity",         // Salinidade (ppt)
+	"pressure",         // Pressão (dbar)
+	"transducerdepth",  // Profundidade do transdutor (m)
+	"speedofsound",     // Velocidade do som (m/s)
+}
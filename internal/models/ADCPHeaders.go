@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// ADCPHeader representa o cabeçalho de um arquivo PD0 do ADCP
+type ADCPHeader struct {
+	ADCPHeaderID     string    `json:"adcp_header_id"`     // UUID do cabeçalho
+	EquipmentID      string    `json:"equipment_id"`       // UUID do equipamento
+	CampaignID       string    `json:"campaign_id"`        // UUID da campanha associada
+	FileName         string    `json:"file_name"`          // Nome do arquivo
+	SerialNumber     string    `json:"serial_number"`      // Número de série do instrumento
+	FirmwareVersion  string    `json:"firmware_version"`   // Versão do firmware
+	Frequency        int       `json:"frequency"`          // Frequência do sistema (kHz)
+	BeamAngle        int       `json:"beam_angle"`         // Ângulo dos feixes (°)
+	NumberOfBeams    int       `json:"number_of_beams"`    // Quantidade de feixes
+	NumberOfCells    int       `json:"number_of_cells"`    // Quantidade de células
+	CellSize         float64   `json:"cell_size"`          // Tamanho da célula (m)
+	Blank            float64   `json:"blank"`              // Distância em branco (m)
+	Bin1Distance     float64   `json:"bin1_distance"`      // Distância ao centro da primeira célula (m)
+	PingsPerEnsemble int       `json:"pings_per_ensemble"` // Pings por ensemble
+	CoordinateSystem string    `json:"coordinate_system"`  // beam, instrument, ship ou earth
+	BeamsFacingUp    bool      `json:"beams_facing_up"`    // Orientação dos feixes
+	EnsemblesRead    int       `json:"ensembles_read"`     // Ensembles decodificados
+	ChecksumFailures int       `json:"checksum_failures"`  // Ensembles com checksum inválido
+	EnsemblesDropped int       `json:"ensembles_dropped"`  // Ensembles descartados
+	UploadDate       time.Time `json:"upload_date"`        // Data de upload
+}
@@ -43,3 +43,14 @@ type EstacaoSolarimetricaDados struct {
 	Declination                 float64   `json:"declination"`                    // Declinação solar
 	AirMass                     float64   `json:"air_mass"`                       // Massa de ar
 }
+
+// EstacaoSolarimetricaColumns lista as colunas de medição de EstacaoSolarimetricaDados (nomes em minúsculas, como no PostgreSQL)
+var EstacaoSolarimetricaColumns = []string{
+	"battv", "ptemp_c", "winddir", "ws_ms_avg", "ws_ms_max", "ws_ms_min",
+	"airtc_avg", "airtc_max", "airtc_min", "rh_max", "rh_min", "rh", "rain_mm_tot",
+	"bp_mbar_avg", "bp_mbar_max", "bp_mbar_min",
+	"slrw_cmp10_horizontal_avg", "slrw_cmp10_horizontal_max", "slrw_cmp10_horizontal_min", "slrkj_cmp10_horizontal_tot",
+	"slrw_cmp10_inclinado_avg", "slrw_cmp10_inclinado_max", "slrw_cmp10_inclinado_min", "slrkj_cmp10_inclinado_tot",
+	"slrw_chp1_avg", "slrw_chp1_max", "slrw_chp1_min", "slrkj_chp1_tot",
+	"solarazimuth", "sunelevation", "hourangle", "declination", "airmass",
+}
@@ -13,5 +13,15 @@ type EstacaoSolarimetricaHeader struct {
 	SolarRadiationHeader     string    `json:"solar_radiation_header"`     // Cabeçalho específico para radiação solar
 	TemperatureHeader        string    `json:"temperature_header"`         // Cabeçalho específico para temperatura
 	BarometricPressureHeader string    `json:"barometric_pressure_header"` // Cabeçalho específico para pressão barométrica
+	StationName              string    `json:"station_name"`               // Nome da estação declarado no registrador (TOA5)
+	LoggerModel              string    `json:"logger_model"`               // Modelo do registrador (ex: CR1000)
+	LoggerSerial             string    `json:"logger_serial"`              // Número de série do registrador
+	LoggerOS                 string    `json:"logger_os"`                  // Versão do sistema operacional do registrador
+	ProgramName              string    `json:"program_name"`               // Programa em execução no registrador
+	ProgramSignature         string    `json:"program_signature"`          // Assinatura do programa
+	TableName                string    `json:"table_name"`                 // Tabela do registrador exportada no arquivo
+	FieldNames               []string  `json:"field_names"`                // Nomes dos campos (linha 2 do TOA5)
+	Units                    []string  `json:"units"`                      // Unidades dos campos (linha 3 do TOA5)
+	Processing               []string  `json:"processing"`                 // Processamento dos campos (linha 4 do TOA5)
 	UploadDate               time.Time `json:"upload_date"`                // Data de upload
 }
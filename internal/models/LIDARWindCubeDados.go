@@ -0,0 +1,42 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+// LIDARWindCubeHeights lista as alturas (m AGL) que possuem colunas na tabela LIDARWindCubeDados
+var LIDARWindCubeHeights = []int{40, 50, 60, 70, 80, 90, 100, 110, 120, 130, 140, 150, 160, 170, 180, 190, 200, 220, 240, 260}
+
+// LIDARWindCubeHeightFields lista as grandezas gravadas para cada altura em LIDARWindCubeDados
+var LIDARWindCubeHeightFields = []string{
+	"WindSpeed",           // Velocidade horizontal do vento (m/s)
+	"WindSpeedDispersion", // Desvio padrão da velocidade (m/s)
+	"WindSpeedMin",        // Velocidade mínima (m/s)
+	"WindSpeedMax",        // Velocidade máxima (m/s)
+	"WindDirection",       // Direção do vento (°)
+	"ZWind",               // Componente vertical (m/s)
+	"ZWindDispersion",     // Desvio padrão da componente vertical (m/s)
+	"CNR",                 // Relação portadora-ruído (dB)
+	"CNRMin",              // CNR mínimo (dB)
+	"DoppSpectBroad",      // Alargamento espectral Doppler (m/s)
+	"DataAvailability",    // Disponibilidade de dados (%)
+}
+
+// LIDARWindCubeBaseFields lista as colunas de diagnóstico do LIDAR que não dependem da altura
+var LIDARWindCubeBaseFields = []string{"IntTemp", "ExtTemp", "Pressure", "RelHumidity", "WiperCount", "Vbatt"}
+
+// LIDARWindCubeColumn retorna o nome da coluna (em minúsculas, como no PostgreSQL) de uma grandeza em uma altura
+func LIDARWindCubeColumn(field string, height int) string {
+	return strings.ToLower(field) + "_" + strconv.Itoa(height) + "m"
+}
+
+// HasLIDARWindCubeHeight indica se a altura possui colunas na tabela LIDARWindCubeDados
+func HasLIDARWindCubeHeight(height int) bool {
+	for _, h := range LIDARWindCubeHeights {
+		if h == height {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package models
+
+// SODARColumns lista as colunas de medição de SODARDados (nomes em minúsculas, como no PostgreSQL).
+// Cada linha da tabela corresponde a um timestamp e a uma altura (coluna height).
+var SODARColumns = []string{
+	"height",              // Altura do nível de medição (m)
+	"windspeed",           // Velocidade horizontal do vento (m/s)
+	"winddirection",       // Direção do vento (°)
+	"u_geo",               // Componente zonal geográfica (m/s)
+	"v_geo",               // Componente meridional geográfica (m/s)
+	"u",                   // Componente U no referencial da antena (m/s)
+	"v",                   // Componente V no referencial da antena (m/s)
+	"w",                   // Componente vertical (m/s)
+	"sigmau",              // Desvio padrão de U (m/s)
+	"sigmau_radial",       // Desvio padrão radial de U (m/s)
+	"sigmav",              // Desvio padrão de V (m/s)
+	"sigmav_radial",       // Desvio padrão radial de V (m/s)
+	"sigmaw",              // Desvio padrão de W (m/s)
+	"windshear",           // Cisalhamento do vento (1/s)
+	"windsheardirection",  // Direção do cisalhamento (°)
+	"sigmaspeed",          // Desvio padrão da velocidade (m/s)
+	"sigmalateral",        // Desvio padrão lateral (m/s)
+	"sigmaphi",            // Desvio padrão do ângulo de elevação (°)
+	"sigmatheta",          // Desvio padrão da direção (°)
+	"turbulenceintensity", // Intensidade de turbulência
+	"pgz",                 // Classe de estabilidade de Pasquill-Gifford
+	"tke",                 // Energia cinética turbulenta (m²/s²)
+	"edr",                 // Taxa de dissipação de energia (m²/s³)
+	"backscatterraw",      // Retroespalhamento bruto
+	"backscatter",         // Retroespalhamento corrigido
+	"backscatterid",       // Identificador da camada de retroespalhamento
+	"ct2",                 // Parâmetro de estrutura de temperatura (K²/m^(2/3))
+}
@@ -26,4 +26,5 @@ type Equipment struct {
 	Location               sql.NullString  `json:"location"`                 // Localização (WKT)
 	CampaignIDs            []string        `json:"campaign_ids"`             // IDs das campanhas associadas (UUID)
 	EquipmentImage         sql.NullString  `json:"equipment_image"`          // Caminho ou URL da imagem do equipamento
+	LoggingInterval        *int            `json:"logging_interval"`         // Intervalo de registro esperado (s); nulo: estimado pelos dados
 }
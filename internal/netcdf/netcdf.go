@@ -0,0 +1,353 @@
+// Package netcdf grava arquivos NetCDF no formato clássico com offsets de 64 bits (CDF-2) e lê os
+// formatos clássicos CDF-1 e CDF-2.
+//
+// O cabeçalho é gravado primeiro e os dados em seguida, em ordem: as variáveis fixas na ordem em que
+// foram declaradas e depois um registro por vez com todas as variáveis da dimensão ilimitada. Assim o
+// arquivo pode ser enviado em fluxo, bastando conhecer de antemão a quantidade de registros.
+package netcdf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Type é o tipo de dado de uma variável ou atributo
+type Type uint32
+
+// Tipos suportados do formato clássico
+const (
+	Byte   Type = 1
+	Char   Type = 2
+	Short  Type = 3
+	Int    Type = 4
+	Float  Type = 5
+	Double Type = 6
+)
+
+// Valores de preenchimento padrão da biblioteca netCDF
+const (
+	FillFloat  float32 = 9.9692099683868690e+36
+	FillDouble float64 = 9.9692099683868690e+36
+)
+
+// Marcadores das listas do cabeçalho
+const (
+	tagDimension = 0x0A
+	tagVariable  = 0x0B
+	tagAttribute = 0x0C
+)
+
+// size retorna o tamanho em bytes de um valor do tipo
+func (t Type) size() int {
+	switch t {
+	case Byte, Char:
+		return 1
+	case Short:
+		return 2
+	case Int, Float:
+		return 4
+	case Double:
+		return 8
+	}
+	return 0
+}
+
+// Dimension descreve uma dimensão; Length zero indica a dimensão ilimitada (de registros)
+type Dimension struct {
+	Name   string
+	Length int
+}
+
+// Attribute é um atributo global ou de variável. Value pode ser string, int32, float32, float64,
+// []int32, []float32 ou []float64.
+type Attribute struct {
+	Name  string
+	Value interface{}
+}
+
+// Variable descreve uma variável e as dimensões que a indexam
+type Variable struct {
+	Name       string
+	Type       Type
+	Dimensions []string
+	Attributes []Attribute
+}
+
+// File descreve a estrutura completa do arquivo a ser gravado
+type File struct {
+	NumRecs    int // Quantidade de registros da dimensão ilimitada
+	Dimensions []Dimension
+	Attributes []Attribute
+	Variables  []Variable
+}
+
+// variableLayout guarda o tamanho e a posição calculados de cada variável
+type variableLayout struct {
+	variable *Variable
+	length   int // Quantidade de valores (por registro, nas variáveis de registro)
+	vsize    int // Tamanho em bytes com alinhamento de 4
+	record   bool
+}
+
+// Writer grava os dados do arquivo na ordem do formato clássico
+type Writer struct {
+	w       *bufio.Writer
+	file    *File
+	fixed   []variableLayout
+	records []variableLayout
+	next    int // Próxima variável fixa ou índice no registro corrente
+	written int // Registros completos gravados
+	err     error
+}
+
+// NewWriter valida a estrutura e grava o cabeçalho
+func NewWriter(w io.Writer, file *File) (*Writer, error) {
+	dimIndex := make(map[string]int, len(file.Dimensions))
+	recordDims := 0
+	for i, d := range file.Dimensions {
+		if d.Length == 0 {
+			recordDims++
+		}
+		dimIndex[d.Name] = i
+	}
+	if recordDims > 1 {
+		return nil, errors.New("netcdf: apenas uma dimensão ilimitada é permitida")
+	}
+
+	nw := &Writer{w: bufio.NewWriterSize(w, 1<<16), file: file}
+	var layouts []variableLayout
+	for i := range file.Variables {
+		v := &file.Variables[i]
+		if v.Type.size() == 0 {
+			return nil, fmt.Errorf("netcdf: tipo inválido na variável %s", v.Name)
+		}
+		layout := variableLayout{variable: v, length: 1}
+		for j, name := range v.Dimensions {
+			idx, ok := dimIndex[name]
+			if !ok {
+				return nil, fmt.Errorf("netcdf: dimensão %s inexistente na variável %s", name, v.Name)
+			}
+			if file.Dimensions[idx].Length == 0 {
+				if j != 0 {
+					return nil, fmt.Errorf("netcdf: a dimensão ilimitada deve ser a primeira em %s", v.Name)
+				}
+				layout.record = true
+				continue
+			}
+			layout.length *= file.Dimensions[idx].Length
+		}
+		layout.vsize = pad4(layout.length * v.Type.size())
+		layouts = append(layouts, layout)
+		if layout.record {
+			nw.records = append(nw.records, layout)
+		} else {
+			nw.fixed = append(nw.fixed, layout)
+		}
+	}
+
+	// O tamanho do cabeçalho não depende dos offsets, que são calculados a partir dele. O formato
+	// exige que todas as variáveis fixas precedam as de registro nos dados.
+	offset := int64(len(nw.encodeHeader(layouts, dimIndex, nil)))
+	begins := make(map[*Variable]int64, len(layouts))
+	for _, l := range nw.fixed {
+		begins[l.variable] = offset
+		offset += int64(l.vsize)
+	}
+	for _, l := range nw.records {
+		begins[l.variable] = offset
+		offset += int64(l.vsize)
+	}
+
+	header := nw.encodeHeader(layouts, dimIndex, begins)
+	if _, err := nw.w.Write(header); err != nil {
+		return nil, err
+	}
+	return nw, nil
+}
+
+// encodeHeader serializa o cabeçalho CDF-2
+func (nw *Writer) encodeHeader(layouts []variableLayout, dimIndex map[string]int, begins map[*Variable]int64) []byte {
+	var b []byte
+	b = append(b, 'C', 'D', 'F', 2)
+	b = binary.BigEndian.AppendUint32(b, uint32(nw.file.NumRecs))
+
+	if len(nw.file.Dimensions) == 0 {
+		b = append(b, make([]byte, 8)...)
+	} else {
+		b = binary.BigEndian.AppendUint32(b, tagDimension)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(nw.file.Dimensions)))
+		for _, d := range nw.file.Dimensions {
+			b = appendName(b, d.Name)
+			b = binary.BigEndian.AppendUint32(b, uint32(d.Length))
+		}
+	}
+
+	b = appendAttributes(b, nw.file.Attributes)
+
+	if len(layouts) == 0 {
+		b = append(b, make([]byte, 8)...)
+		return b
+	}
+	b = binary.BigEndian.AppendUint32(b, tagVariable)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(layouts)))
+	for _, l := range layouts {
+		v := l.variable
+		b = appendName(b, v.Name)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(v.Dimensions)))
+		for _, name := range v.Dimensions {
+			b = binary.BigEndian.AppendUint32(b, uint32(dimIndex[name]))
+		}
+		b = appendAttributes(b, v.Attributes)
+		b = binary.BigEndian.AppendUint32(b, uint32(v.Type))
+		b = binary.BigEndian.AppendUint32(b, uint32(l.vsize))
+		b = binary.BigEndian.AppendUint64(b, uint64(begins[v]))
+	}
+	return b
+}
+
+// Write grava os valores da próxima variável: primeiro cada variável fixa, depois, registro a
+// registro, cada variável de registro. Valores NaN são gravados como o valor de preenchimento.
+func (nw *Writer) Write(values []float64) error {
+	if nw.err != nil {
+		return nw.err
+	}
+
+	var layout variableLayout
+	if nw.next < len(nw.fixed) {
+		layout = nw.fixed[nw.next]
+	} else {
+		if nw.written >= nw.file.NumRecs {
+			return errors.New("netcdf: registros além de NumRecs")
+		}
+		layout = nw.records[nw.next-len(nw.fixed)]
+	}
+	if len(values) != layout.length {
+		return fmt.Errorf("netcdf: %s espera %d valores, recebeu %d", layout.variable.Name, layout.length, len(values))
+	}
+
+	nw.err = nw.writeValues(layout, values)
+	nw.next++
+	if nw.next == len(nw.fixed)+len(nw.records) && len(nw.records) > 0 {
+		nw.next = len(nw.fixed)
+		nw.written++
+	}
+	return nw.err
+}
+
+func (nw *Writer) writeValues(layout variableLayout, values []float64) error {
+	var buf [8]byte
+	for _, v := range values {
+		var err error
+		switch layout.variable.Type {
+		case Double:
+			if math.IsNaN(v) {
+				v = FillDouble
+			}
+			binary.BigEndian.PutUint64(buf[:], math.Float64bits(v))
+			_, err = nw.w.Write(buf[:8])
+		case Float:
+			f := float32(v)
+			if math.IsNaN(v) {
+				f = FillFloat
+			}
+			binary.BigEndian.PutUint32(buf[:], math.Float32bits(f))
+			_, err = nw.w.Write(buf[:4])
+		case Int:
+			binary.BigEndian.PutUint32(buf[:], uint32(int32(v)))
+			_, err = nw.w.Write(buf[:4])
+		case Short:
+			binary.BigEndian.PutUint16(buf[:], uint16(int16(v)))
+			_, err = nw.w.Write(buf[:2])
+		case Byte, Char:
+			err = nw.w.WriteByte(byte(v))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// Com uma única variável de registro os registros são gravados sem alinhamento de 4
+	if layout.record && len(nw.records) == 1 {
+		return nil
+	}
+	if padding := layout.vsize - layout.length*layout.variable.Type.size(); padding > 0 {
+		_, err := nw.w.Write(make([]byte, padding))
+		return err
+	}
+	return nil
+}
+
+// Close confere se todas as variáveis e registros anunciados foram gravados e descarrega o buffer
+func (nw *Writer) Close() error {
+	if nw.err != nil {
+		return nw.err
+	}
+	if err := nw.w.Flush(); err != nil {
+		return err
+	}
+	if nw.next != len(nw.fixed) || len(nw.records) > 0 && nw.written != nw.file.NumRecs {
+		return fmt.Errorf("netcdf: arquivo incompleto (%d de %d registros)", nw.written, nw.file.NumRecs)
+	}
+	return nil
+}
+
+// appendName serializa um nome: tamanho seguido dos bytes com alinhamento de 4
+func appendName(b []byte, name string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(name)))
+	b = append(b, name...)
+	return append(b, make([]byte, pad4(len(name))-len(name))...)
+}
+
+// appendAttributes serializa uma lista de atributos (ou ABSENT quando vazia)
+func appendAttributes(b []byte, attributes []Attribute) []byte {
+	if len(attributes) == 0 {
+		return append(b, make([]byte, 8)...)
+	}
+	b = binary.BigEndian.AppendUint32(b, tagAttribute)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(attributes)))
+	for _, a := range attributes {
+		b = appendName(b, a.Name)
+		var t Type
+		var data []byte
+		switch v := a.Value.(type) {
+		case string:
+			t, data = Char, []byte(v)
+		case int32:
+			t, data = Int, binary.BigEndian.AppendUint32(nil, uint32(v))
+		case []int32:
+			t = Int
+			for _, x := range v {
+				data = binary.BigEndian.AppendUint32(data, uint32(x))
+			}
+		case float32:
+			t, data = Float, binary.BigEndian.AppendUint32(nil, math.Float32bits(v))
+		case []float32:
+			t = Float
+			for _, x := range v {
+				data = binary.BigEndian.AppendUint32(data, math.Float32bits(x))
+			}
+		case float64:
+			t, data = Double, binary.BigEndian.AppendUint64(nil, math.Float64bits(v))
+		case []float64:
+			t = Double
+			for _, x := range v {
+				data = binary.BigEndian.AppendUint64(data, math.Float64bits(x))
+			}
+		default:
+			t, data = Char, []byte(fmt.Sprint(v))
+		}
+		b = binary.BigEndian.AppendUint32(b, uint32(t))
+		b = binary.BigEndian.AppendUint32(b, uint32(len(data)/t.size()))
+		b = append(b, data...)
+		b = append(b, make([]byte, pad4(len(data))-len(data))...)
+	}
+	return b
+}
+
+// pad4 arredonda n para o próximo múltiplo de 4
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
@@ -0,0 +1,185 @@
+package netcdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// writeFile grava o arquivo descrito por file com os valores de cada chamada a Write, em ordem
+func writeFile(t *testing.T, file *File, writes [][]float64) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, file)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	for _, values := range writes {
+		if err := w.Write(values); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	file := &File{
+		NumRecs:    3,
+		Dimensions: []Dimension{{Name: "time"}, {Name: "height", Length: 2}},
+		Attributes: []Attribute{{Name: "Conventions", Value: "CF-1.8"}, {Name: "version", Value: int32(2)}},
+		Variables: []Variable{
+			{Name: "height", Type: Double, Dimensions: []string{"height"},
+				Attributes: []Attribute{{Name: "units", Value: "m"}}},
+			{Name: "time", Type: Double, Dimensions: []string{"time"}},
+			{Name: "ws", Type: Float, Dimensions: []string{"time", "height"},
+				Attributes: []Attribute{{Name: "_FillValue", Value: FillFloat}}},
+			{Name: "flag", Type: Short, Dimensions: []string{"time", "height"},
+				Attributes: []Attribute{{Name: "scale_factor", Value: 0.5}, {Name: "add_offset", Value: 1.0}}},
+		},
+	}
+	data := writeFile(t, file, [][]float64{
+		{40, 60},
+		{0}, {1.5, 2.5}, {1, 2},
+		{600}, {math.NaN(), 3.5}, {3, 4},
+		{1200}, {4.5, 5.5}, {5, 6},
+	})
+	if string(data[:4]) != "CDF\x02" {
+		t.Fatalf("assinatura %q, esperado CDF-2", data[:4])
+	}
+
+	d, err := Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if d.NumRecs != 3 || !reflect.DeepEqual(d.Dimensions, file.Dimensions) {
+		t.Errorf("NumRecs = %d, Dimensions = %+v", d.NumRecs, d.Dimensions)
+	}
+	if len(d.Attributes) != 2 || d.Attributes[0].Value != "CF-1.8" ||
+		!reflect.DeepEqual(d.Attributes[1].Value, []float64{2}) {
+		t.Errorf("Attributes = %+v", d.Attributes)
+	}
+	if got := d.Shape("ws"); !reflect.DeepEqual(got, []int{3, 2}) {
+		t.Errorf("Shape(ws) = %v", got)
+	}
+	if v, _ := d.Variable("height"); v.Attribute("units") != "m" {
+		t.Errorf("units de height = %v", v.Attribute("units"))
+	}
+
+	tests := []struct {
+		name  string
+		index []int
+		want  []float64
+	}{
+		{"height", nil, []float64{40, 60}},
+		{"time", nil, []float64{0, 600, 1200}},
+		{"ws", []int{0}, []float64{1.5, math.NaN(), 4.5}},
+		{"ws", []int{1}, []float64{2.5, 3.5, 5.5}},
+		{"flag", []int{1}, []float64{2, 3, 4}},
+	}
+	for _, tt := range tests {
+		got, err := d.ReadSeries(tt.name, tt.index)
+		if err != nil {
+			t.Errorf("ReadSeries(%s, %v): %v", tt.name, tt.index, err)
+			continue
+		}
+		if !sameValues(got, tt.want) {
+			t.Errorf("ReadSeries(%s, %v) = %v, esperado %v", tt.name, tt.index, got, tt.want)
+		}
+	}
+
+	if _, err := d.ReadSeries("ws", []int{2}); err == nil {
+		t.Error("ReadSeries com índice fora dos limites deveria falhar")
+	}
+}
+
+// Com uma única variável de registro os registros não têm alinhamento de 4 bytes
+func TestSingleRecordVariable(t *testing.T) {
+	file := &File{
+		NumRecs:    3,
+		Dimensions: []Dimension{{Name: "time"}},
+		Variables:  []Variable{{Name: "counts", Type: Short, Dimensions: []string{"time"}}},
+	}
+	data := writeFile(t, file, [][]float64{{7}, {-8}, {9}})
+	d, err := Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, err := d.ReadSeries("counts", nil)
+	if err != nil {
+		t.Fatalf("ReadSeries: %v", err)
+	}
+	if !sameValues(got, []float64{7, -8, 9}) {
+		t.Errorf("ReadSeries = %v", got)
+	}
+}
+
+// Um cabeçalho que declara mais registros do que o arquivo contém não pode provocar a alocação
+func TestReadSeriesRejectsLengthBeyondFile(t *testing.T) {
+	file := &File{
+		NumRecs:    3,
+		Dimensions: []Dimension{{Name: "time"}, {Name: "height", Length: 2}},
+		Variables: []Variable{
+			{Name: "height", Type: Double, Dimensions: []string{"height"}},
+			{Name: "ws", Type: Double, Dimensions: []string{"time", "height"}},
+		},
+	}
+	data := writeFile(t, file, [][]float64{{40, 60}, {1, 2}, {3, 4}, {5, 6}})
+	binary.BigEndian.PutUint32(data[4:], 0x7FFFFFFF)
+
+	d, err := Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := d.ReadSeries("ws", []int{0}); err == nil {
+		t.Error("ReadSeries com NumRecs além do arquivo deveria falhar")
+	}
+	if got, err := d.ReadSeries("height", nil); err != nil || !sameValues(got, []float64{40, 60}) {
+		t.Errorf("ReadSeries(height) = %v, %v", got, err)
+	}
+}
+
+func TestWriterRejectsIncompleteFile(t *testing.T) {
+	file := &File{
+		NumRecs:    2,
+		Dimensions: []Dimension{{Name: "time"}},
+		Variables:  []Variable{{Name: "time", Type: Double, Dimensions: []string{"time"}}},
+	}
+	w, err := NewWriter(&bytes.Buffer{}, file)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if err := w.Write([]float64{0, 1}); err == nil {
+		t.Error("Write com quantidade errada de valores deveria falhar")
+	}
+	if err := w.Write([]float64{0}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Close com registros faltando deveria falhar")
+	}
+}
+
+func TestOpenRejectsNonClassic(t *testing.T) {
+	hdf := []byte("\x89HDF\r\n\x1a\n")
+	if _, err := Open(bytes.NewReader(hdf), int64(len(hdf))); err != ErrUnsupported {
+		t.Errorf("esperado ErrUnsupported, obtido %v", err)
+	}
+}
+
+// sameValues compara séries considerando NaN igual a NaN
+func sameValues(got, want []float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if math.IsNaN(want[i]) != math.IsNaN(got[i]) || !math.IsNaN(want[i]) && got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
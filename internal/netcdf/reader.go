@@ -0,0 +1,328 @@
+package netcdf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ErrUnsupported indica um arquivo que não está no formato clássico (NetCDF-4/HDF5 ou CDF-5)
+var ErrUnsupported = errors.New("netcdf: apenas os formatos clássicos CDF-1 e CDF-2 são suportados")
+
+// readBlock é o tamanho máximo de cada leitura contígua de ReadSeries
+const readBlock = 1 << 20
+
+// Dataset é um arquivo NetCDF clássico aberto para leitura. Os atributos numéricos lidos têm
+// Value []float64; os de texto, string.
+type Dataset struct {
+	NumRecs    int
+	Dimensions []Dimension
+	Attributes []Attribute
+	Variables  []Variable
+	r          io.ReaderAt
+	size       int64 // Tamanho do arquivo, que limita as dimensões declaradas no cabeçalho
+	layouts    map[string]readLayout
+	recSize    int64
+}
+
+// readLayout é a posição dos dados de uma variável no arquivo
+type readLayout struct {
+	shape  []int // Tamanho de cada dimensão (a de registros com NumRecs)
+	record bool
+	begin  int64
+}
+
+// headerReader decodifica os campos big-endian do cabeçalho
+type headerReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (h *headerReader) uint32() uint32 {
+	var b [4]byte
+	if h.err == nil {
+		_, h.err = io.ReadFull(h.r, b[:])
+	}
+	return binary.BigEndian.Uint32(b[:])
+}
+
+func (h *headerReader) uint64() uint64 {
+	var b [8]byte
+	if h.err == nil {
+		_, h.err = io.ReadFull(h.r, b[:])
+	}
+	return binary.BigEndian.Uint64(b[:])
+}
+
+func (h *headerReader) bytes(n int) []byte {
+	b := make([]byte, pad4(n))
+	if h.err == nil {
+		_, h.err = io.ReadFull(h.r, b)
+	}
+	return b[:n]
+}
+
+func (h *headerReader) name() string {
+	n := int(h.uint32())
+	if h.err == nil && n > 1<<16 {
+		h.err = errors.New("netcdf: nome inválido no cabeçalho")
+		return ""
+	}
+	return string(h.bytes(n))
+}
+
+// list lê o marcador e a quantidade de elementos de uma lista (zero quando ABSENT)
+func (h *headerReader) list(tag uint32) int {
+	got, n := h.uint32(), h.uint32()
+	if h.err == nil && got != tag && !(got == 0 && n == 0) {
+		h.err = errors.New("netcdf: cabeçalho inválido")
+	}
+	return int(n)
+}
+
+func (h *headerReader) attributes() []Attribute {
+	n := h.list(tagAttribute)
+	var attributes []Attribute
+	for i := 0; i < n && h.err == nil; i++ {
+		name := h.name()
+		t := Type(h.uint32())
+		count := int(h.uint32())
+		if t.size() == 0 || count > 1<<20 {
+			h.err = fmt.Errorf("netcdf: atributo %s inválido", name)
+			break
+		}
+		data := h.bytes(count * t.size())
+		if t == Char {
+			attributes = append(attributes, Attribute{Name: name, Value: string(data)})
+			continue
+		}
+		values := make([]float64, count)
+		for j := range values {
+			values[j] = decode(t, data[j*t.size():])
+		}
+		attributes = append(attributes, Attribute{Name: name, Value: values})
+	}
+	return attributes
+}
+
+// decode converte um valor big-endian do tipo t
+func decode(t Type, b []byte) float64 {
+	switch t {
+	case Byte:
+		return float64(int8(b[0]))
+	case Char:
+		return float64(b[0])
+	case Short:
+		return float64(int16(binary.BigEndian.Uint16(b)))
+	case Int:
+		return float64(int32(binary.BigEndian.Uint32(b)))
+	case Float:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(b))
+}
+
+// Open lê o cabeçalho de um arquivo NetCDF clássico com size bytes
+func Open(r io.ReaderAt, size int64) (*Dataset, error) {
+	h := &headerReader{r: bufio.NewReader(io.NewSectionReader(r, 0, size))}
+	magic := h.bytes(4)
+	if h.err != nil || string(magic[:3]) != "CDF" || (magic[3] != 1 && magic[3] != 2) {
+		return nil, ErrUnsupported
+	}
+	d := &Dataset{r: r, size: size, layouts: map[string]readLayout{}}
+	numRecs := h.uint32()
+	if numRecs == math.MaxUint32 {
+		return nil, errors.New("netcdf: arquivo com quantidade de registros indeterminada")
+	}
+	d.NumRecs = int(numRecs)
+
+	for i, n := 0, h.list(tagDimension); i < n && h.err == nil; i++ {
+		d.Dimensions = append(d.Dimensions, Dimension{Name: h.name(), Length: int(h.uint32())})
+	}
+	d.Attributes = h.attributes()
+
+	var records []string
+	for i, n := 0, h.list(tagVariable); i < n && h.err == nil; i++ {
+		v := Variable{Name: h.name()}
+		layout := readLayout{}
+		for j, dims := 0, int(h.uint32()); j < dims && h.err == nil; j++ {
+			id := int(h.uint32())
+			if id >= len(d.Dimensions) {
+				return nil, fmt.Errorf("netcdf: dimensão inexistente na variável %s", v.Name)
+			}
+			dim := d.Dimensions[id]
+			v.Dimensions = append(v.Dimensions, dim.Name)
+			if dim.Length == 0 {
+				layout.record = true
+				layout.shape = append(layout.shape, d.NumRecs)
+			} else {
+				layout.shape = append(layout.shape, dim.Length)
+			}
+		}
+		v.Attributes = h.attributes()
+		v.Type = Type(h.uint32())
+		vsize := int64(h.uint32())
+		if magic[3] == 1 {
+			layout.begin = int64(h.uint32())
+		} else {
+			layout.begin = int64(h.uint64())
+		}
+		if v.Type.size() == 0 {
+			return nil, fmt.Errorf("netcdf: tipo inválido na variável %s", v.Name)
+		}
+		if layout.record {
+			d.recSize += vsize
+			records = append(records, v.Name)
+		}
+		d.Variables = append(d.Variables, v)
+		d.layouts[v.Name] = layout
+	}
+	if h.err != nil {
+		return nil, fmt.Errorf("netcdf: cabeçalho inválido: %w", h.err)
+	}
+	// Com uma única variável de registro, os registros não têm alinhamento de 4
+	if len(records) == 1 {
+		v, _ := d.Variable(records[0])
+		d.recSize = int64(d.layouts[v.Name].inner() * v.Type.size())
+	}
+	return d, nil
+}
+
+// inner retorna a quantidade de valores de um índice da primeira dimensão
+func (l readLayout) inner() int {
+	n := 1
+	for _, s := range l.shape[1:] {
+		n *= s
+	}
+	return n
+}
+
+// Variable retorna a variável pelo nome
+func (d *Dataset) Variable(name string) (*Variable, bool) {
+	for i := range d.Variables {
+		if d.Variables[i].Name == name {
+			return &d.Variables[i], true
+		}
+	}
+	return nil, false
+}
+
+// Shape retorna o tamanho de cada dimensão da variável (a de registros com NumRecs)
+func (d *Dataset) Shape(name string) []int {
+	return d.layouts[name].shape
+}
+
+// Attribute retorna o valor de um atributo da variável (nil quando ausente)
+func (v *Variable) Attribute(name string) interface{} {
+	for _, a := range v.Attributes {
+		if a.Name == name {
+			return a.Value
+		}
+	}
+	return nil
+}
+
+// numberAttribute retorna o primeiro valor de um atributo numérico
+func (v *Variable) numberAttribute(name string) (float64, bool) {
+	if values, ok := v.Attribute(name).([]float64); ok && len(values) > 0 {
+		return values[0], true
+	}
+	return 0, false
+}
+
+// ReadSeries lê os valores da variável ao longo da primeira dimensão, fixando as demais em index.
+// Valores iguais a _FillValue ou missing_value viram NaN e scale_factor e add_offset são aplicados.
+// Dimensões que apontariam para além do fim do arquivo são rejeitadas antes de qualquer alocação.
+func (d *Dataset) ReadSeries(name string, index []int) ([]float64, error) {
+	v, ok := d.Variable(name)
+	if !ok {
+		return nil, fmt.Errorf("netcdf: variável %s inexistente", name)
+	}
+	layout := d.layouts[name]
+	if len(layout.shape) == 0 || len(index) != len(layout.shape)-1 {
+		return nil, fmt.Errorf("netcdf: %s exige %d índices", name, len(layout.shape)-1)
+	}
+	offset, inner := int64(0), int64(1)
+	for i, idx := range index {
+		if idx < 0 || idx >= layout.shape[i+1] {
+			return nil, fmt.Errorf("netcdf: índice fora dos limites em %s", name)
+		}
+		offset = offset*int64(layout.shape[i+1]) + int64(idx)
+		inner *= int64(layout.shape[i+1])
+		if inner > d.size {
+			return nil, fmt.Errorf("netcdf: %s ultrapassa o tamanho do arquivo", name)
+		}
+	}
+
+	size := int64(v.Type.size())
+	stride := inner * size
+	if layout.record {
+		stride = d.recSize
+	}
+	n := int64(layout.shape[0])
+	start := layout.begin + offset*size
+	if n > 0 && (start < 0 || start+size > d.size || n > 1 && (stride <= 0 || n-1 > (d.size-start-size)/stride)) {
+		return nil, fmt.Errorf("netcdf: %s ultrapassa o tamanho do arquivo", name)
+	}
+	p := newPacking(v)
+
+	// Os valores são lidos em blocos contíguos de até readBlock bytes, com vários passos cada
+	values := make([]float64, n)
+	perRead := int64(1)
+	if stride > 0 && stride < readBlock {
+		perRead = readBlock / stride
+	}
+	var buf []byte
+	for first := int64(0); first < n; first += perRead {
+		count := perRead
+		if first+count > n {
+			count = n - first
+		}
+		length := (count-1)*stride + size
+		if int64(cap(buf)) < length {
+			buf = make([]byte, length)
+		}
+		if _, err := d.r.ReadAt(buf[:length], start+first*stride); err != nil {
+			return nil, fmt.Errorf("netcdf: falha ao ler %s: %w", name, err)
+		}
+		for i := int64(0); i < count; i++ {
+			values[first+i] = p.value(buf[i*stride:])
+		}
+	}
+	return values, nil
+}
+
+// packing reúne os atributos que convertem os valores gravados de uma variável
+type packing struct {
+	t                   Type
+	fill, missing       float64
+	hasFill, hasMissing bool
+	scale, addOffset    float64
+	hasScale            bool
+}
+
+func newPacking(v *Variable) packing {
+	p := packing{t: v.Type}
+	p.fill, p.hasFill = v.numberAttribute("_FillValue")
+	p.missing, p.hasMissing = v.numberAttribute("missing_value")
+	p.scale, p.hasScale = v.numberAttribute("scale_factor")
+	p.addOffset, _ = v.numberAttribute("add_offset")
+	return p
+}
+
+// value decodifica um valor: _FillValue e missing_value viram NaN e scale_factor e add_offset são aplicados
+func (p packing) value(b []byte) float64 {
+	x := decode(p.t, b)
+	switch {
+	case p.hasFill && x == p.fill, p.hasMissing && x == p.missing:
+		return math.NaN()
+	case p.t == Double && x == FillDouble, p.t == Float && x == float64(FillFloat):
+		return math.NaN()
+	case p.hasScale:
+		return x*p.scale + p.addOffset
+	}
+	return x + p.addOffset
+}
@@ -0,0 +1,433 @@
+// Package parquet grava arquivos Apache Parquet com esquema plano, codificação PLAIN e sem compressão.
+//
+// As linhas são acumuladas por coluna até completar um row group, que é gravado em seguida; os
+// metadados ficam no rodapé. Assim o arquivo pode ser enviado em fluxo com memória limitada ao
+// tamanho de um row group.
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultRowGroupSize é a quantidade de linhas por row group quando não informada
+const DefaultRowGroupSize = 50000
+
+// Type é o tipo lógico de uma coluna
+type Type int
+
+// Tipos de coluna suportados
+const (
+	Double    Type = iota // DOUBLE
+	Int32                 // INT32
+	Int64                 // INT64
+	Timestamp             // INT64 com TIMESTAMP(MICROS, UTC)
+	String                // BYTE_ARRAY com STRING (UTF-8)
+	Boolean               // BOOLEAN
+)
+
+// Tipos físicos, codificações e tipos convertidos da especificação
+const (
+	physicalBoolean   = 0
+	physicalInt32     = 1
+	physicalInt64     = 2
+	physicalDouble    = 5
+	physicalByteArray = 6
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+
+	repetitionRequired = 0
+	repetitionOptional = 1
+)
+
+// Column descreve uma coluna do esquema. Colunas opcionais aceitam nil.
+type Column struct {
+	Name     string
+	Type     Type
+	Optional bool
+}
+
+func (c Column) physicalType() int32 {
+	switch c.Type {
+	case Int32:
+		return physicalInt32
+	case Int64, Timestamp:
+		return physicalInt64
+	case String:
+		return physicalByteArray
+	case Boolean:
+		return physicalBoolean
+	}
+	return physicalDouble
+}
+
+// columnBuffer acumula os valores de uma coluna no row group corrente
+type columnBuffer struct {
+	values  []byte // Valores codificados em PLAIN (exceto booleanos)
+	bools   []bool
+	defined []bool // Níveis de definição (colunas opcionais)
+}
+
+// chunkMeta guarda os metadados de uma coluna gravada em um row group
+type chunkMeta struct {
+	offset    int64
+	size      int64
+	numValues int64
+}
+
+// rowGroupMeta guarda os metadados de um row group gravado
+type rowGroupMeta struct {
+	numRows int64
+	size    int64
+	chunks  []chunkMeta
+}
+
+// Writer grava linhas em um arquivo Parquet
+type Writer struct {
+	w            io.Writer
+	columns      []Column
+	rowGroupSize int
+	buffers      []columnBuffer
+	rows         int // Linhas no row group corrente
+	offset       int64
+	rowGroups    []rowGroupMeta
+	totalRows    int64
+	metadata     map[string]string
+	err          error
+}
+
+// NewWriter grava a assinatura do arquivo e prepara os buffers das colunas
+func NewWriter(w io.Writer, columns []Column, rowGroupSize int) (*Writer, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("parquet: esquema vazio")
+	}
+	if rowGroupSize <= 0 {
+		rowGroupSize = DefaultRowGroupSize
+	}
+	pw := &Writer{w: w, columns: columns, rowGroupSize: rowGroupSize, buffers: make([]columnBuffer, len(columns))}
+	pw.write([]byte("PAR1"))
+	return pw, pw.err
+}
+
+// SetMetadata adiciona um par chave/valor aos metadados do rodapé
+func (pw *Writer) SetMetadata(key, value string) {
+	if pw.metadata == nil {
+		pw.metadata = map[string]string{}
+	}
+	pw.metadata[key] = value
+}
+
+func (pw *Writer) write(b []byte) {
+	if pw.err != nil {
+		return
+	}
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	pw.err = err
+}
+
+// WriteRow acrescenta uma linha. Os valores seguem a ordem das colunas; valores numéricos são
+// convertidos para o tipo da coluna e time.Time é aceito em colunas Timestamp.
+func (pw *Writer) WriteRow(values []interface{}) error {
+	if pw.err != nil {
+		return pw.err
+	}
+	if len(values) != len(pw.columns) {
+		return fmt.Errorf("parquet: %d valores para %d colunas", len(values), len(pw.columns))
+	}
+
+	for i, c := range pw.columns {
+		b := &pw.buffers[i]
+		v := values[i]
+		if f, ok := v.(float64); ok && math.IsNaN(f) && c.Type != Double {
+			v = nil
+		}
+		if v == nil {
+			if !c.Optional {
+				pw.err = fmt.Errorf("parquet: valor nulo na coluna obrigatória %s", c.Name)
+				return pw.err
+			}
+			b.defined = append(b.defined, false)
+			continue
+		}
+		if c.Optional {
+			b.defined = append(b.defined, true)
+		}
+		// Um erro no meio da linha deixaria as colunas desalinhadas, por isso invalida o writer
+		if pw.err = b.appendValue(c, v); pw.err != nil {
+			return pw.err
+		}
+	}
+
+	pw.rows++
+	if pw.rows >= pw.rowGroupSize {
+		return pw.flush()
+	}
+	return nil
+}
+
+// appendValue codifica um valor em PLAIN
+func (b *columnBuffer) appendValue(c Column, v interface{}) error {
+	switch c.Type {
+	case Double:
+		f, ok := toFloat(v)
+		if !ok {
+			return fmt.Errorf("parquet: valor inválido na coluna %s", c.Name)
+		}
+		b.values = binary.LittleEndian.AppendUint64(b.values, math.Float64bits(f))
+	case Int32, Int64:
+		n, ok := toInt(v)
+		if !ok {
+			return fmt.Errorf("parquet: valor inválido na coluna %s", c.Name)
+		}
+		if c.Type == Int32 {
+			b.values = binary.LittleEndian.AppendUint32(b.values, uint32(int32(n)))
+		} else {
+			b.values = binary.LittleEndian.AppendUint64(b.values, uint64(n))
+		}
+	case Timestamp:
+		t, ok := v.(time.Time)
+		if !ok {
+			return fmt.Errorf("parquet: valor inválido na coluna %s", c.Name)
+		}
+		b.values = binary.LittleEndian.AppendUint64(b.values, uint64(t.UnixMicro()))
+	case String:
+		s := fmt.Sprint(v)
+		b.values = binary.LittleEndian.AppendUint32(b.values, uint32(len(s)))
+		b.values = append(b.values, s...)
+	case Boolean:
+		x, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("parquet: valor inválido na coluna %s", c.Name)
+		}
+		b.bools = append(b.bools, x)
+	}
+	return nil
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	}
+	n, ok := toInt(v)
+	return float64(n), ok
+}
+
+func toInt(v interface{}) (int64, bool) {
+	switch x := v.(type) {
+	case int:
+		return int64(x), true
+	case int16:
+		return int64(x), true
+	case int32:
+		return int64(x), true
+	case int64:
+		return x, true
+	case float64:
+		return int64(x), true
+	}
+	return 0, false
+}
+
+// flush grava o row group corrente: uma página de dados por coluna
+func (pw *Writer) flush() error {
+	if pw.rows == 0 || pw.err != nil {
+		return pw.err
+	}
+
+	group := rowGroupMeta{numRows: int64(pw.rows)}
+	for i, c := range pw.columns {
+		b := &pw.buffers[i]
+		var page []byte
+		if c.Optional {
+			levels := encodeDefinitionLevels(b.defined)
+			page = binary.LittleEndian.AppendUint32(page, uint32(len(levels)))
+			page = append(page, levels...)
+		}
+		if c.Type == Boolean {
+			page = append(page, packBools(b.bools)...)
+		} else {
+			page = append(page, b.values...)
+		}
+
+		header := pageHeader(len(page), pw.rows)
+		chunk := chunkMeta{offset: pw.offset, size: int64(len(header) + len(page)), numValues: int64(pw.rows)}
+		pw.write(header)
+		pw.write(page)
+		group.chunks = append(group.chunks, chunk)
+		group.size += chunk.size
+
+		*b = columnBuffer{values: b.values[:0], bools: b.bools[:0], defined: b.defined[:0]}
+	}
+
+	pw.rowGroups = append(pw.rowGroups, group)
+	pw.totalRows += int64(pw.rows)
+	pw.rows = 0
+	return pw.err
+}
+
+// Close grava o último row group e o rodapé com os metadados do arquivo
+func (pw *Writer) Close() error {
+	if err := pw.flush(); err != nil {
+		return err
+	}
+	footer := pw.fileMetadata()
+	pw.write(footer)
+	pw.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+	pw.write([]byte("PAR1"))
+	return pw.err
+}
+
+// encodeDefinitionLevels codifica os níveis de definição (largura de 1 bit) no híbrido RLE/bit-packed,
+// usando apenas sequências RLE
+func encodeDefinitionLevels(defined []bool) []byte {
+	var out []byte
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		if defined[i] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+// packBools codifica booleanos em PLAIN (bit-packed, LSB primeiro)
+func packBools(values []bool) []byte {
+	out := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
+
+// pageHeader serializa o PageHeader de uma página de dados v1 sem compressão
+func pageHeader(size, numValues int) []byte {
+	c := &compactWriter{}
+	c.beginStruct(0)
+	c.i32(1, 0) // DATA_PAGE
+	c.i32(2, int32(size))
+	c.i32(3, int32(size))
+	c.beginStruct(5)
+	c.i32(1, int32(numValues))
+	c.i32(2, encodingPlain)
+	c.i32(3, encodingRLE)
+	c.i32(4, encodingRLE)
+	c.endStruct()
+	c.endStruct()
+	return c.buf
+}
+
+// fileMetadata serializa o FileMetaData do rodapé
+func (pw *Writer) fileMetadata() []byte {
+	c := &compactWriter{}
+	c.beginStruct(0)
+	c.i32(1, 1)
+
+	c.list(2, ctStruct, len(pw.columns)+1)
+	c.beginStruct(0)
+	c.string(4, "schema")
+	c.i32(5, int32(len(pw.columns)))
+	c.endStruct()
+	for _, col := range pw.columns {
+		c.beginStruct(0)
+		c.i32(1, col.physicalType())
+		if col.Optional {
+			c.i32(3, repetitionOptional)
+		} else {
+			c.i32(3, repetitionRequired)
+		}
+		c.string(4, col.Name)
+		switch col.Type {
+		case String:
+			c.i32(6, convertedUTF8)
+			c.beginStruct(10)
+			c.beginStruct(1) // STRING
+			c.endStruct()
+			c.endStruct()
+		case Timestamp:
+			c.i32(6, convertedTimestampMicros)
+			c.beginStruct(10)
+			c.beginStruct(8) // TIMESTAMP
+			c.bool(1, true)
+			c.beginStruct(2)
+			c.beginStruct(2) // MICROS
+			c.endStruct()
+			c.endStruct()
+			c.endStruct()
+			c.endStruct()
+		}
+		c.endStruct()
+	}
+
+	c.i64(3, pw.totalRows)
+
+	c.list(4, ctStruct, len(pw.rowGroups))
+	for _, group := range pw.rowGroups {
+		c.beginStruct(0)
+		c.list(1, ctStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			col := pw.columns[i]
+			c.beginStruct(0)
+			c.i64(2, chunk.offset)
+			c.beginStruct(3)
+			c.i32(1, col.physicalType())
+			c.list(2, ctI32, 2)
+			c.listI32(encodingPlain, encodingRLE)
+			c.list(3, ctBinary, 1)
+			c.rawString(col.Name)
+			c.i32(4, 0) // UNCOMPRESSED
+			c.i64(5, chunk.numValues)
+			c.i64(6, chunk.size)
+			c.i64(7, chunk.size)
+			c.i64(9, chunk.offset)
+			c.endStruct()
+			c.endStruct()
+		}
+		c.i64(2, group.size)
+		c.i64(3, group.numRows)
+		c.endStruct()
+	}
+
+	if len(pw.metadata) > 0 {
+		c.list(5, ctStruct, len(pw.metadata))
+		for _, key := range sortedKeys(pw.metadata) {
+			c.beginStruct(0)
+			c.string(1, key)
+			c.string(2, pw.metadata[key])
+			c.endStruct()
+		}
+	}
+	c.string(6, "api parquet writer")
+	c.endStruct()
+	return c.buf
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,310 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// compactReader decodifica estruturas Thrift Compact em mapas de id de campo para valor, o
+// suficiente para conferir os metadados gravados pelo Writer
+type compactReader struct {
+	buf []byte
+	pos int
+	t   *testing.T
+}
+
+func (r *compactReader) byte() byte {
+	if r.pos >= len(r.buf) {
+		r.t.Fatalf("thrift: fim inesperado dos dados no byte %d", r.pos)
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *compactReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		r.t.Fatalf("thrift: varint inválido no byte %d", r.pos)
+	}
+	r.pos += n
+	return v
+}
+
+func (r *compactReader) varint() int64 {
+	v, n := binary.Varint(r.buf[r.pos:])
+	if n <= 0 {
+		r.t.Fatalf("thrift: varint inválido no byte %d", r.pos)
+	}
+	r.pos += n
+	return v
+}
+
+// value lê um valor do tipo informado: int64, bool, []byte, []interface{} ou map[int16]interface{}
+func (r *compactReader) value(fieldType byte) interface{} {
+	switch fieldType {
+	case ctBoolTrue:
+		return true
+	case ctBoolFalse:
+		return false
+	case ctI32, ctI64:
+		return r.varint()
+	case ctBinary:
+		n := int(r.uvarint())
+		b := r.buf[r.pos : r.pos+n]
+		r.pos += n
+		return b
+	case ctList:
+		head := r.byte()
+		size, elemType := int(head>>4), head&0x0F
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(elemType)
+		}
+		return list
+	case ctStruct:
+		return r.structure()
+	}
+	r.t.Fatalf("thrift: tipo %d não suportado", fieldType)
+	return nil
+}
+
+func (r *compactReader) structure() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	var last int16
+	for {
+		head := r.byte()
+		if head == 0 {
+			return fields
+		}
+		id := last + int16(head>>4)
+		if head>>4 == 0 {
+			id = int16(r.varint())
+		}
+		fields[id] = r.value(head & 0x0F)
+		last = id
+	}
+}
+
+// field percorre estruturas aninhadas pelos ids informados
+func field(t *testing.T, v interface{}, ids ...int16) interface{} {
+	t.Helper()
+	for _, id := range ids {
+		s, ok := v.(map[int16]interface{})
+		if !ok {
+			t.Fatalf("campo %d: %T não é uma estrutura", id, v)
+		}
+		v = s[id]
+	}
+	return v
+}
+
+// readFooter confere as assinaturas e decodifica o FileMetaData do rodapé
+func readFooter(t *testing.T, data []byte) map[int16]interface{} {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatal("assinatura PAR1 ausente")
+	}
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	start := len(data) - 8 - size
+	r := &compactReader{buf: data[start : len(data)-8], t: t}
+	return r.structure()
+}
+
+// readColumnChunk decodifica a página de dados de uma coluna em um row group. Valores nulos de
+// colunas opcionais viram nil.
+func readColumnChunk(t *testing.T, data []byte, col Column, chunk interface{}) []interface{} {
+	t.Helper()
+	offset := int(field(t, chunk, 3, 9).(int64))
+	r := &compactReader{buf: data, pos: offset, t: t}
+	header := r.structure()
+	if field(t, header, 1).(int64) != 0 {
+		t.Fatalf("coluna %s: página não é DATA_PAGE", col.Name)
+	}
+	size := int(field(t, header, 3).(int64))
+	numValues := int(field(t, header, 5, 1).(int64))
+	if got := int64(r.pos - offset + size); got != field(t, chunk, 3, 7).(int64) {
+		t.Errorf("coluna %s: total_compressed_size = %d, página ocupa %d", col.Name, field(t, chunk, 3, 7), got)
+	}
+	page := data[r.pos : r.pos+size]
+
+	defined := make([]bool, numValues)
+	for i := range defined {
+		defined[i] = true
+	}
+	if col.Optional {
+		n := int(binary.LittleEndian.Uint32(page))
+		levels := page[4 : 4+n]
+		page = page[4+n:]
+		for i := 0; len(levels) > 0; {
+			run, k := binary.Uvarint(levels)
+			if run&1 != 0 {
+				t.Fatalf("coluna %s: sequência bit-packed inesperada", col.Name)
+			}
+			for j := 0; j < int(run>>1); j++ {
+				defined[i] = levels[k] == 1
+				i++
+			}
+			levels = levels[k+1:]
+		}
+	}
+
+	values := make([]interface{}, numValues)
+	bit := 0
+	for i := range values {
+		if !defined[i] {
+			continue
+		}
+		switch col.Type {
+		case Double:
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(page))
+			page = page[8:]
+		case Int32:
+			values[i] = int64(int32(binary.LittleEndian.Uint32(page)))
+			page = page[4:]
+		case Int64:
+			values[i] = int64(binary.LittleEndian.Uint64(page))
+			page = page[8:]
+		case Timestamp:
+			values[i] = time.UnixMicro(int64(binary.LittleEndian.Uint64(page))).UTC()
+			page = page[8:]
+		case String:
+			n := int(binary.LittleEndian.Uint32(page))
+			values[i] = string(page[4 : 4+n])
+			page = page[4+n:]
+		case Boolean:
+			values[i] = page[bit/8]&(1<<(bit%8)) != 0
+			bit++
+		}
+	}
+	return values
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	columns := []Column{
+		{Name: "timestamp", Type: Timestamp},
+		{Name: "station", Type: String},
+		{Name: "ws_80m", Type: Double, Optional: true},
+		{Name: "samples", Type: Int32, Optional: true},
+		{Name: "count", Type: Int64},
+		{Name: "valid", Type: Boolean},
+	}
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	rows := [][]interface{}{
+		{start, "A", 7.5, 600, int64(1), true},
+		{start.Add(10 * time.Minute), "B", nil, nil, int64(2), false},
+		{start.Add(20 * time.Minute), "C", math.NaN(), 598, int64(3), true},
+		{start.Add(30 * time.Minute), "D", 8.25, math.NaN(), int64(4), true},
+		{start.Add(40 * time.Minute), "E", 9.0, 12, int64(5), false},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, columns, 2)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	w.SetMetadata("campaign", "teste")
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatalf("WriteRow: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	data := buf.Bytes()
+	meta := readFooter(t, data)
+
+	schema := field(t, meta, 2).([]interface{})
+	if len(schema) != len(columns)+1 || field(t, schema[0], 5).(int64) != int64(len(columns)) {
+		t.Fatalf("esquema com %d elementos", len(schema))
+	}
+	for i, col := range columns {
+		element := schema[i+1]
+		if name := string(field(t, element, 4).([]byte)); name != col.Name {
+			t.Errorf("esquema[%d] = %s, esperado %s", i, name, col.Name)
+		}
+		if got := field(t, element, 1).(int64); got != int64(col.physicalType()) {
+			t.Errorf("%s: tipo físico %d", col.Name, got)
+		}
+		repetition := int64(repetitionRequired)
+		if col.Optional {
+			repetition = repetitionOptional
+		}
+		if got := field(t, element, 3).(int64); got != repetition {
+			t.Errorf("%s: repetição %d", col.Name, got)
+		}
+	}
+	if got := field(t, schema[1], 6); got != int64(convertedTimestampMicros) {
+		t.Errorf("timestamp: converted_type %v", got)
+	}
+	if got := field(t, schema[2], 6); got != int64(convertedUTF8) {
+		t.Errorf("station: converted_type %v", got)
+	}
+
+	if got := field(t, meta, 3).(int64); got != int64(len(rows)) {
+		t.Errorf("num_rows = %d", got)
+	}
+	kv := field(t, meta, 5).([]interface{})
+	if len(kv) != 1 || string(field(t, kv[0], 1).([]byte)) != "campaign" || string(field(t, kv[0], 2).([]byte)) != "teste" {
+		t.Errorf("key_value_metadata = %v", kv)
+	}
+
+	groups := field(t, meta, 4).([]interface{})
+	if len(groups) != 3 {
+		t.Fatalf("%d row groups, esperado 3", len(groups))
+	}
+	got := make([][]interface{}, 0, len(rows))
+	for _, group := range groups {
+		chunks := field(t, group, 1).([]interface{})
+		numRows := int(field(t, group, 3).(int64))
+		var size int64
+		groupRows := make([][]interface{}, numRows)
+		for i, col := range columns {
+			size += field(t, chunks[i], 3, 7).(int64)
+			for j, v := range readColumnChunk(t, data, col, chunks[i]) {
+				groupRows[j] = append(groupRows[j], v)
+			}
+		}
+		if total := field(t, group, 2).(int64); total != size {
+			t.Errorf("total_byte_size = %d, soma das colunas %d", total, size)
+		}
+		got = append(got, groupRows...)
+	}
+
+	want := [][]interface{}{
+		{start, "A", 7.5, int64(600), int64(1), true},
+		{start.Add(10 * time.Minute), "B", nil, nil, int64(2), false},
+		{start.Add(20 * time.Minute), "C", nil, int64(598), int64(3), true},
+		{start.Add(30 * time.Minute), "D", 8.25, nil, int64(4), true},
+		{start.Add(40 * time.Minute), "E", 9.0, int64(12), int64(5), false},
+	}
+	// NaN em coluna Double é gravado como valor; os demais NaN viram nulos
+	if v, ok := got[2][2].(float64); !ok || !math.IsNaN(v) {
+		t.Errorf("linha 2, ws_80m = %v, esperado NaN", got[2][2])
+	}
+	got[2][2] = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("linhas lidas:\n%v\nesperado:\n%v", got, want)
+	}
+}
+
+func TestWriterRejectsNullInRequiredColumn(t *testing.T) {
+	w, err := NewWriter(&bytes.Buffer{}, []Column{{Name: "count", Type: Int64}}, 0)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if err := w.WriteRow([]interface{}{nil}); err == nil {
+		t.Fatal("WriteRow com nulo em coluna obrigatória deveria falhar")
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Close após erro deveria devolver o erro")
+	}
+}
@@ -0,0 +1,90 @@
+package parquet
+
+import "encoding/binary"
+
+// Tipos de campo do protocolo Thrift Compact, usado nos metadados do Parquet
+const (
+	ctBoolTrue  = 1
+	ctBoolFalse = 2
+	ctI32       = 5
+	ctI64       = 6
+	ctBinary    = 8
+	ctList      = 9
+	ctStruct    = 12
+)
+
+// compactWriter serializa estruturas Thrift no protocolo Compact
+type compactWriter struct {
+	buf    []byte
+	fields []int16 // Pilha com o último id de campo de cada estrutura aberta
+}
+
+func (c *compactWriter) fieldHeader(id int16, fieldType byte) {
+	last := c.fields[len(c.fields)-1]
+	if delta := id - last; delta > 0 && delta <= 15 {
+		c.buf = append(c.buf, byte(delta)<<4|fieldType)
+	} else {
+		c.buf = append(c.buf, fieldType)
+		c.buf = binary.AppendVarint(c.buf, int64(id))
+	}
+	c.fields[len(c.fields)-1] = id
+}
+
+func (c *compactWriter) i32(id int16, v int32) {
+	c.fieldHeader(id, ctI32)
+	c.buf = binary.AppendVarint(c.buf, int64(v))
+}
+
+func (c *compactWriter) i64(id int16, v int64) {
+	c.fieldHeader(id, ctI64)
+	c.buf = binary.AppendVarint(c.buf, v)
+}
+
+func (c *compactWriter) bool(id int16, v bool) {
+	if v {
+		c.fieldHeader(id, ctBoolTrue)
+	} else {
+		c.fieldHeader(id, ctBoolFalse)
+	}
+}
+
+func (c *compactWriter) string(id int16, v string) {
+	c.fieldHeader(id, ctBinary)
+	c.rawString(v)
+}
+
+func (c *compactWriter) rawString(v string) {
+	c.buf = binary.AppendUvarint(c.buf, uint64(len(v)))
+	c.buf = append(c.buf, v...)
+}
+
+// beginStruct abre um campo do tipo estrutura (id zero abre a estrutura raiz ou um elemento de lista)
+func (c *compactWriter) beginStruct(id int16) {
+	if id != 0 {
+		c.fieldHeader(id, ctStruct)
+	}
+	c.fields = append(c.fields, 0)
+}
+
+func (c *compactWriter) endStruct() {
+	c.buf = append(c.buf, 0)
+	c.fields = c.fields[:len(c.fields)-1]
+}
+
+// list grava o cabeçalho de um campo lista; os elementos são gravados em seguida pelo chamador
+func (c *compactWriter) list(id int16, elemType byte, size int) {
+	c.fieldHeader(id, ctList)
+	if size < 15 {
+		c.buf = append(c.buf, byte(size)<<4|elemType)
+	} else {
+		c.buf = append(c.buf, 0xF0|elemType)
+		c.buf = binary.AppendUvarint(c.buf, uint64(size))
+	}
+}
+
+// listI32 grava os elementos inteiros de uma lista
+func (c *compactWriter) listI32(values ...int32) {
+	for _, v := range values {
+		c.buf = binary.AppendVarint(c.buf, int64(v))
+	}
+}
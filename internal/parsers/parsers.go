@@ -0,0 +1,143 @@
+// Package parsers lê os formatos nativos dos instrumentos (WindCube, TOA5, SODAR, ...) e
+// os converte para as estruturas usadas pelas tabelas de dados.
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidFormat indica que o conteúdo não corresponde ao formato esperado pelo parser
+var ErrInvalidFormat = errors.New("formato de arquivo inválido")
+
+// ErrUnknownTimezone indica um fuso horário que não é UTC, um deslocamento nem um nome IANA conhecido
+var ErrUnknownTimezone = errors.New("fuso horário desconhecido")
+
+// RowError descreve uma linha de dados que não pôde ser interpretada; o arquivo pode continuar a ser lido
+type RowError struct {
+	Line int   // Número da linha no arquivo (começando em 1)
+	Err  error // Motivo da rejeição
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("linha %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Record é uma linha de dados já associada às colunas da tabela de destino
+type Record struct {
+	Line      int        // Número da linha no arquivo
+	Timestamp time.Time  // Timestamp da medição
+	Values    []*float64 // Valores alinhados com as colunas do leitor (nil = ausente)
+}
+
+// IsRowError indica se o erro se refere apenas a uma linha inválida
+func IsRowError(err error) bool {
+	var rowErr *RowError
+	return errors.As(err, &rowErr)
+}
+
+// unitsPattern remove unidades entre parênteses ou colchetes dos nomes de campos
+var unitsPattern = regexp.MustCompile(`\([^)]*\)|\[[^\]]*\]`)
+
+// normalizeKey converte um nome de campo em uma chave comparável: sem unidades, minúsculo e apenas alfanumérico
+func normalizeKey(s string) string {
+	s = unitsPattern.ReplaceAllString(s, "")
+	var sb strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// parseFloat interpreta um valor numérico aceitando vírgula decimal; valores vazios ou NaN retornam nil
+func parseFloat(s string) (*float64, error) {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	if s == "" || strings.EqualFold(s, "nan") || strings.EqualFold(s, "na") {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, nil
+	}
+	return &v, nil
+}
+
+// floatOrZero interpreta um número de cabeçalho, retornando zero quando ausente ou inválido
+func floatOrZero(s string) float64 {
+	v, err := parseFloat(s)
+	if err != nil || v == nil {
+		return 0
+	}
+	return *v
+}
+
+// timestampLayouts lista os formatos de data e hora encontrados nos arquivos dos registradores
+var timestampLayouts = []string{
+	"2006/01/02 15:04:05.999999999",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	"02/01/2006 15:04:05.999999999",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"02.01.2006 15:04:05",
+}
+
+// parseTimestamp interpreta um timestamp em um dos formatos conhecidos, no fuso informado
+func parseTimestamp(s string, loc *time.Location) (time.Time, error) {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("timestamp inválido: %q", s)
+}
+
+// utcOffsetPattern reconhece fusos no formato "UTC", "UTC+1", "UTC-03:00" ou "GMT+02"
+var utcOffsetPattern = regexp.MustCompile(`^(?:UTC|GMT)?\s*([+-])\s*(\d{1,2})(?::?(\d{2}))?$`)
+
+// ParseTimezone converte o fuso declarado em um *time.Location: vazio, "UTC"/"GMT" e deslocamentos
+// ("UTC-03:00") sem distinção de maiúsculas, ou um nome IANA exatamente como no tz database
+// ("America/Sao_Paulo"). Qualquer outro valor devolve ErrUnknownTimezone, pois lê-lo como UTC
+// deslocaria todos os timestamps do arquivo.
+func ParseTimezone(s string) (*time.Location, error) {
+	s = strings.TrimSpace(s)
+	upper := strings.ToUpper(s)
+	if s == "" || upper == "UTC" || upper == "GMT" {
+		return time.UTC, nil
+	}
+	if m := utcOffsetPattern.FindStringSubmatch(upper); m != nil {
+		hours, _ := strconv.Atoi(m[2])
+		minutes, _ := strconv.Atoi(m[3])
+		offset := hours*3600 + minutes*60
+		if m[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(s, offset), nil
+	}
+	if s != "Local" {
+		if loc, err := time.LoadLocation(s); err == nil {
+			return loc, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownTimezone, s)
+}
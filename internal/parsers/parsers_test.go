@@ -0,0 +1,99 @@
+package parsers
+
+import (
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseTimezone(t *testing.T) {
+	// 2024-07-01 12:00 UTC: horário de verão em Paris, sem horário de verão em São Paulo
+	instant := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in     string
+		offset int // Segundos a leste de UTC em instant
+	}{
+		{"", 0},
+		{"utc", 0},
+		{" GMT ", 0},
+		{"UTC-03:00", -3 * 3600},
+		{"utc+1", 3600},
+		{"GMT + 05:30", 5*3600 + 1800},
+		{"-0300", -3 * 3600},
+		{"America/Sao_Paulo", -3 * 3600},
+		{"Europe/Paris", 2 * 3600},
+	}
+	for _, tt := range tests {
+		loc, err := ParseTimezone(tt.in)
+		if err != nil {
+			t.Errorf("ParseTimezone(%q): %v", tt.in, err)
+			continue
+		}
+		if _, offset := instant.In(loc).Zone(); offset != tt.offset {
+			t.Errorf("ParseTimezone(%q): deslocamento %d, esperado %d", tt.in, offset, tt.offset)
+		}
+	}
+
+	for _, in := range []string{"EUROPE/PARIS", "Mars/Olympus", "Local", "BRT-ish"} {
+		if _, err := ParseTimezone(in); !errors.Is(err, ErrUnknownTimezone) {
+			t.Errorf("ParseTimezone(%q) = %v, esperado ErrUnknownTimezone", in, err)
+		}
+	}
+}
+
+// row é uma linha lida por um parser, com os valores desreferenciados (NaN = ausente) para comparação
+type row struct {
+	line   int
+	ts     time.Time
+	values []float64
+}
+
+// readRows lê todas as linhas com next até io.EOF, separando as linhas válidas das rejeitadas
+func readRows(t *testing.T, next func() (*Record, error)) ([]row, []int) {
+	t.Helper()
+	var rows []row
+	var rejected []int
+	for {
+		rec, err := next()
+		if err == io.EOF {
+			return rows, rejected
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			rejected = append(rejected, rowErr.Line)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := row{line: rec.Line, ts: rec.Timestamp, values: make([]float64, len(rec.Values))}
+		for i, v := range rec.Values {
+			r.values[i] = math.NaN()
+			if v != nil {
+				r.values[i] = *v
+			}
+		}
+		rows = append(rows, r)
+	}
+}
+
+// checkRows compara as linhas lidas com as esperadas
+func checkRows(t *testing.T, got, want []row) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("lidas %d linhas, esperado %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		same := g.line == w.line && g.ts.Equal(w.ts) && len(g.values) == len(w.values)
+		for j := 0; same && j < len(w.values); j++ {
+			same = g.values[j] == w.values[j] || math.IsNaN(g.values[j]) && math.IsNaN(w.values[j])
+		}
+		if !same {
+			t.Errorf("linha %d = %d %s %v, esperado %d %s %v", i, g.line, g.ts.UTC(), g.values, w.line, w.ts.UTC(), w.values)
+		}
+	}
+}
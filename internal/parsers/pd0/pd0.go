@@ -0,0 +1,377 @@
+// Package pd0 decodifica o formato binário PD0 dos ADCPs Teledyne RDI (Workhorse, Sentinel V, ...).
+//
+// Cada ensemble começa com o cabeçalho 0x7F7F seguido do tamanho, da lista de deslocamentos dos
+// blocos de dados e termina com um checksum de 16 bits. O decodificador lê o arquivo em fluxo,
+// ressincronizando no próximo 0x7F7F quando um ensemble está corrompido.
+package pd0
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Identificadores dos blocos de dados de um ensemble
+const (
+	idHeader         = 0x7F7F
+	idFixedLeader    = 0x0000
+	idVariableLeader = 0x0080
+	idVelocity       = 0x0100
+	idCorrelation    = 0x0200
+	idEchoIntensity  = 0x0300
+	idPercentGood    = 0x0400
+)
+
+// BadVelocity é o valor gravado pelo ADCP quando a velocidade de uma célula é inválida
+const BadVelocity = -32768
+
+// Sistemas de coordenadas das velocidades (bits 3-4 do byte EX do fixed leader)
+const (
+	CoordinatesBeam       = "beam"
+	CoordinatesInstrument = "instrument"
+	CoordinatesShip       = "ship"
+	CoordinatesEarth      = "earth"
+)
+
+var (
+	// ErrChecksum indica que o checksum do ensemble não confere
+	ErrChecksum = errors.New("checksum inválido")
+	// ErrMissingLeader indica que o ensemble não possui fixed leader ou variable leader
+	ErrMissingLeader = errors.New("fixed leader ou variable leader ausente")
+	// ErrTruncated indica que um bloco ultrapassa o tamanho declarado do ensemble
+	ErrTruncated = errors.New("bloco truncado")
+)
+
+// EnsembleError descreve um ensemble descartado; a decodificação pode continuar
+type EnsembleError struct {
+	Offset int64 // Posição do ensemble no arquivo (bytes)
+	Err    error
+}
+
+func (e *EnsembleError) Error() string {
+	return fmt.Sprintf("ensemble no byte %d: %v", e.Offset, e.Err)
+}
+
+func (e *EnsembleError) Unwrap() error {
+	return e.Err
+}
+
+// FixedLeader contém a configuração do instrumento, repetida em todos os ensembles
+type FixedLeader struct {
+	FirmwareVersion    string  `json:"firmware_version"`
+	SystemConfig       uint16  `json:"system_config"`
+	FrequencyKHz       int     `json:"frequency_khz"`
+	BeamsFacingUp      bool    `json:"beams_facing_up"`
+	NumberOfBeams      int     `json:"number_of_beams"`
+	NumberOfCells      int     `json:"number_of_cells"`
+	PingsPerEnsemble   int     `json:"pings_per_ensemble"`
+	CellSize           float64 `json:"cell_size"`         // Tamanho da célula (m)
+	Blank              float64 `json:"blank"`             // Distância em branco após a transmissão (m)
+	Bin1Distance       float64 `json:"bin1_distance"`     // Distância do transdutor ao centro da célula 1 (m)
+	TransmitPulse      float64 `json:"transmit_pulse"`    // Comprimento do pulso transmitido (m)
+	CoordinateSystem   string  `json:"coordinate_system"` // beam, instrument, ship ou earth
+	HeadingAlignment   float64 `json:"heading_alignment"` // (°)
+	HeadingBias        float64 `json:"heading_bias"`      // (°)
+	LowCorrThreshold   int     `json:"low_corr_threshold"`
+	ErrorVelocityMax   float64 `json:"error_velocity_max"` // (m/s)
+	BeamAngle          int     `json:"beam_angle"`         // (°)
+	SerialNumber       uint32  `json:"serial_number"`
+	PercentGoodMinimum int     `json:"percent_good_minimum"`
+}
+
+// VariableLeader contém os dados de estado do instrumento em cada ensemble
+type VariableLeader struct {
+	EnsembleNumber  int       `json:"ensemble_number"`
+	Time            time.Time `json:"time"`
+	SpeedOfSound    float64   `json:"speed_of_sound"`   // (m/s)
+	TransducerDepth float64   `json:"transducer_depth"` // (m)
+	Heading         float64   `json:"heading"`          // (°)
+	Pitch           float64   `json:"pitch"`            // (°)
+	Roll            float64   `json:"roll"`             // (°)
+	Salinity        float64   `json:"salinity"`         // (ppt)
+	Temperature     float64   `json:"temperature"`      // (°C)
+	Pressure        float64   `json:"pressure"`         // (dbar)
+	BITResult       uint16    `json:"bit_result"`
+}
+
+// Ensemble é um perfil completo decodificado. As matrizes são indexadas por [célula][feixe].
+type Ensemble struct {
+	Offset        int64
+	Fixed         FixedLeader
+	Variable      VariableLeader
+	Velocity      [][]int16 // mm/s; BadVelocity indica valor inválido
+	Correlation   [][]uint8 // Contagens (0-255)
+	EchoIntensity [][]uint8 // Contagens (0-255)
+	PercentGood   [][]uint8 // (%)
+}
+
+// VelocityMS retorna a velocidade de uma célula e feixe em m/s, ou nil quando inválida
+func (e *Ensemble) VelocityMS(cell, beam int) *float64 {
+	if cell >= len(e.Velocity) || beam >= len(e.Velocity[cell]) || e.Velocity[cell][beam] == BadVelocity {
+		return nil
+	}
+	v := float64(e.Velocity[cell][beam]) / 1000
+	return &v
+}
+
+// CellDistance retorna a distância (m) do transdutor ao centro da célula (0 = primeira célula)
+func (e *Ensemble) CellDistance(cell int) float64 {
+	return e.Fixed.Bin1Distance + float64(cell)*e.Fixed.CellSize
+}
+
+// CellDepth retorna a profundidade (m) do centro da célula, considerando a orientação dos feixes
+func (e *Ensemble) CellDepth(cell int) float64 {
+	if e.Fixed.BeamsFacingUp {
+		return e.Variable.TransducerDepth - e.CellDistance(cell)
+	}
+	return e.Variable.TransducerDepth + e.CellDistance(cell)
+}
+
+// Stats acumula o diagnóstico da decodificação de um arquivo
+type Stats struct {
+	Ensembles        int   `json:"ensembles"`         // Ensembles decodificados com sucesso
+	ChecksumFailures int   `json:"checksum_failures"` // Ensembles com checksum inválido
+	Dropped          int   `json:"dropped"`           // Ensembles descartados (inclui falhas de checksum)
+	BytesSkipped     int64 `json:"bytes_skipped"`     // Bytes ignorados durante a ressincronização
+}
+
+// Decoder lê ensembles PD0 de um io.Reader
+type Decoder struct {
+	r      *bufio.Reader
+	offset int64
+	Stats  Stats
+}
+
+// NewDecoder cria um decodificador PD0
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReaderSize(r, 1<<17)}
+}
+
+// Next retorna o próximo ensemble válido. Retorna io.EOF ao final e *EnsembleError quando um
+// ensemble é descartado; nesse caso basta chamar Next novamente para continuar.
+func (d *Decoder) Next() (*Ensemble, error) {
+	for {
+		head, err := d.r.Peek(4)
+		if err != nil {
+			d.Stats.BytesSkipped += int64(d.r.Buffered())
+			return nil, io.EOF
+		}
+		if binary.LittleEndian.Uint16(head) != idHeader {
+			d.skip(1)
+			continue
+		}
+
+		size := int(binary.LittleEndian.Uint16(head[2:]))
+		if size < 6 {
+			d.skip(1)
+			continue
+		}
+		raw, err := d.r.Peek(size + 2)
+		if err != nil {
+			// Tamanho maior que o restante do arquivo: falso cabeçalho ou ensemble incompleto no final
+			d.skip(1)
+			continue
+		}
+
+		// Falsos cabeçalhos no meio dos dados são ignorados sem contar como falha
+		types := int(raw[5])
+		if types == 0 || 6+2*types > size {
+			d.skip(1)
+			continue
+		}
+
+		offset := d.offset
+		var sum uint16
+		for _, b := range raw[:size] {
+			sum += uint16(b)
+		}
+		if sum != binary.LittleEndian.Uint16(raw[size:]) {
+			d.Stats.ChecksumFailures++
+			d.Stats.Dropped++
+			d.skip(2)
+			return nil, &EnsembleError{Offset: offset, Err: ErrChecksum}
+		}
+
+		ensemble, err := decodeEnsemble(raw[:size])
+		d.consume(size + 2)
+		if err != nil {
+			d.Stats.Dropped++
+			return nil, &EnsembleError{Offset: offset, Err: err}
+		}
+		ensemble.Offset = offset
+		d.Stats.Ensembles++
+		return ensemble, nil
+	}
+}
+
+// skip descarta bytes que não pertencem a um ensemble válido
+func (d *Decoder) skip(n int) {
+	d.Stats.BytesSkipped += int64(n)
+	d.consume(n)
+}
+
+func (d *Decoder) consume(n int) {
+	discarded, _ := d.r.Discard(n)
+	d.offset += int64(discarded)
+}
+
+// decodeEnsemble interpreta os blocos de um ensemble cujo checksum já foi verificado
+func decodeEnsemble(raw []byte) (*Ensemble, error) {
+	types := int(raw[5])
+	offsets := make([]int, types)
+	for i := range offsets {
+		offsets[i] = int(binary.LittleEndian.Uint16(raw[6+2*i:]))
+		if offsets[i]+2 > len(raw) {
+			return nil, ErrTruncated
+		}
+	}
+
+	e := &Ensemble{}
+	var hasFixed, hasVariable bool
+	// O fixed leader define o número de células e feixes, por isso é decodificado primeiro
+	for _, off := range offsets {
+		if binary.LittleEndian.Uint16(raw[off:]) == idFixedLeader {
+			if err := decodeFixedLeader(raw[off:], &e.Fixed); err != nil {
+				return nil, err
+			}
+			hasFixed = true
+		}
+	}
+	if !hasFixed {
+		return nil, ErrMissingLeader
+	}
+
+	cells, beams := e.Fixed.NumberOfCells, e.Fixed.NumberOfBeams
+	for _, off := range offsets {
+		block := raw[off:]
+		var err error
+		switch binary.LittleEndian.Uint16(block) {
+		case idVariableLeader:
+			err = decodeVariableLeader(block, &e.Variable)
+			hasVariable = err == nil
+		case idVelocity:
+			e.Velocity, err = decodeInt16Matrix(block[2:], cells, beams)
+		case idCorrelation:
+			e.Correlation, err = decodeUint8Matrix(block[2:], cells, beams)
+		case idEchoIntensity:
+			e.EchoIntensity, err = decodeUint8Matrix(block[2:], cells, beams)
+		case idPercentGood:
+			e.PercentGood, err = decodeUint8Matrix(block[2:], cells, beams)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !hasVariable {
+		return nil, ErrMissingLeader
+	}
+	return e, nil
+}
+
+// decodeFixedLeader interpreta o bloco 0x0000
+func decodeFixedLeader(b []byte, f *FixedLeader) error {
+	if len(b) < 59 {
+		return ErrTruncated
+	}
+	le := binary.LittleEndian
+	f.FirmwareVersion = fmt.Sprintf("%d.%02d", b[2], b[3])
+	f.SystemConfig = le.Uint16(b[4:])
+	f.FrequencyKHz = [...]int{75, 150, 300, 600, 1200, 2400, 38, 0}[f.SystemConfig&0x07]
+	f.BeamsFacingUp = f.SystemConfig&0x80 != 0
+	f.BeamAngle = [...]int{15, 20, 30, 0}[(f.SystemConfig>>8)&0x03]
+	f.NumberOfBeams = int(b[8])
+	f.NumberOfCells = int(b[9])
+	f.PingsPerEnsemble = int(le.Uint16(b[10:]))
+	f.CellSize = float64(le.Uint16(b[12:])) / 100
+	f.Blank = float64(le.Uint16(b[14:])) / 100
+	f.LowCorrThreshold = int(b[17])
+	f.PercentGoodMinimum = int(b[19])
+	f.ErrorVelocityMax = float64(le.Uint16(b[20:])) / 1000
+	f.CoordinateSystem = [...]string{CoordinatesBeam, CoordinatesInstrument, CoordinatesShip, CoordinatesEarth}[(b[25]>>3)&0x03]
+	f.HeadingAlignment = float64(int16(le.Uint16(b[26:]))) / 100
+	f.HeadingBias = float64(int16(le.Uint16(b[28:]))) / 100
+	f.Bin1Distance = float64(le.Uint16(b[32:])) / 100
+	f.TransmitPulse = float64(le.Uint16(b[34:])) / 100
+	f.SerialNumber = le.Uint32(b[54:])
+	if len(b) > 58 && b[58] != 0 {
+		f.BeamAngle = int(b[58])
+	}
+	if f.NumberOfBeams == 0 || f.NumberOfCells == 0 {
+		return fmt.Errorf("fixed leader sem células ou feixes")
+	}
+	return nil
+}
+
+// decodeVariableLeader interpreta o bloco 0x0080
+func decodeVariableLeader(b []byte, v *VariableLeader) error {
+	if len(b) < 52 {
+		return ErrTruncated
+	}
+	le := binary.LittleEndian
+	v.EnsembleNumber = int(le.Uint16(b[2:])) + int(b[11])<<16
+	v.BITResult = le.Uint16(b[12:])
+	v.SpeedOfSound = float64(le.Uint16(b[14:]))
+	v.TransducerDepth = float64(le.Uint16(b[16:])) / 10
+	v.Heading = float64(le.Uint16(b[18:])) / 100
+	v.Pitch = float64(int16(le.Uint16(b[20:]))) / 100
+	v.Roll = float64(int16(le.Uint16(b[22:]))) / 100
+	v.Salinity = float64(le.Uint16(b[24:]))
+	v.Temperature = float64(int16(le.Uint16(b[26:]))) / 100
+	// Pressão em decapascal; 1 dbar = 1000 daPa
+	v.Pressure = float64(le.Uint32(b[48:])) / 1000
+
+	// Relógio com século (Y2K) quando disponível, senão o relógio de dois dígitos
+	year := 2000 + int(b[4])
+	month, day, hour, minute, second, hundredths := b[5], b[6], b[7], b[8], b[9], b[10]
+	if len(b) >= 65 && b[57] >= 19 && b[57] <= 21 {
+		year = int(b[57])*100 + int(b[58])
+		month, day, hour, minute, second, hundredths = b[59], b[60], b[61], b[62], b[63], b[64]
+	} else if b[4] >= 80 {
+		year = 1900 + int(b[4])
+	}
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || minute > 59 || second > 59 {
+		return fmt.Errorf("relógio inválido no variable leader")
+	}
+	v.Time = time.Date(year, time.Month(month), int(day), int(hour), int(minute), int(second),
+		int(hundredths)*int(10*time.Millisecond), time.UTC)
+	return nil
+}
+
+// decodeInt16Matrix lê cells×beams valores int16 little-endian
+func decodeInt16Matrix(b []byte, cells, beams int) ([][]int16, error) {
+	if len(b) < cells*beams*2 {
+		return nil, ErrTruncated
+	}
+	m := make([][]int16, cells)
+	for c := range m {
+		m[c] = make([]int16, beams)
+		for k := range m[c] {
+			m[c][k] = int16(binary.LittleEndian.Uint16(b[(c*beams+k)*2:]))
+		}
+	}
+	return m, nil
+}
+
+// decodeUint8Matrix lê cells×beams valores de um byte
+func decodeUint8Matrix(b []byte, cells, beams int) ([][]uint8, error) {
+	if len(b) < cells*beams {
+		return nil, ErrTruncated
+	}
+	m := make([][]uint8, cells)
+	for c := range m {
+		m[c] = append([]uint8(nil), b[c*beams:(c+1)*beams]...)
+	}
+	return m, nil
+}
+
+// CurrentSpeedDirection calcula a velocidade (m/s) e a direção para onde a corrente flui (° a partir do norte)
+// a partir das componentes leste e norte. Só faz sentido para ensembles em coordenadas terrestres.
+func CurrentSpeedDirection(east, north float64) (float64, float64) {
+	speed := math.Hypot(east, north)
+	direction := math.Mod(math.Atan2(east, north)*180/math.Pi+360, 360)
+	return speed, direction
+}
@@ -0,0 +1,196 @@
+package pd0
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+const (
+	testCells = 3
+	testBeams = 4
+)
+
+// buildEnsemble monta um ensemble PD0 com fixed leader, variable leader e velocidades, seguido do checksum
+func buildEnsemble(number int, when time.Time) []byte {
+	le := binary.LittleEndian
+
+	fixed := make([]byte, 59)
+	le.PutUint16(fixed[0:], idFixedLeader)
+	fixed[2], fixed[3] = 51, 7
+	le.PutUint16(fixed[4:], 0x0182) // 300 kHz, feixes para cima, 20°
+	fixed[8], fixed[9] = testBeams, testCells
+	le.PutUint16(fixed[10:], 60)
+	le.PutUint16(fixed[12:], 100)
+	le.PutUint16(fixed[14:], 176)
+	fixed[17], fixed[19] = 64, 25
+	le.PutUint16(fixed[20:], 2000)
+	fixed[25] = 3 << 3 // Coordenadas terrestres
+	le.PutUint16(fixed[26:], uint16(0x10000-1250))
+	le.PutUint16(fixed[32:], 262)
+	le.PutUint16(fixed[34:], 140)
+	le.PutUint32(fixed[54:], 12345)
+
+	variable := make([]byte, 65)
+	le.PutUint16(variable[0:], idVariableLeader)
+	le.PutUint16(variable[2:], uint16(number&0xFFFF))
+	variable[11] = byte(number >> 16)
+	variable[4] = byte(when.Year() % 100)
+	variable[5], variable[6] = byte(when.Month()), byte(when.Day())
+	variable[7], variable[8], variable[9] = byte(when.Hour()), byte(when.Minute()), byte(when.Second())
+	le.PutUint16(variable[14:], 1500)
+	le.PutUint16(variable[16:], 55)
+	le.PutUint16(variable[18:], 27000)
+	le.PutUint16(variable[20:], uint16(0x10000-150))
+	le.PutUint16(variable[22:], 200)
+	le.PutUint16(variable[24:], 35)
+	le.PutUint16(variable[26:], 1525)
+	le.PutUint32(variable[48:], 12500)
+	variable[57], variable[58] = byte(when.Year()/100), byte(when.Year()%100)
+	variable[59], variable[60] = byte(when.Month()), byte(when.Day())
+	variable[61], variable[62], variable[63] = byte(when.Hour()), byte(when.Minute()), byte(when.Second())
+	variable[64] = byte(when.Nanosecond() / int(10*time.Millisecond))
+
+	velocity := make([]byte, 2+testCells*testBeams*2)
+	le.PutUint16(velocity[0:], idVelocity)
+	for i := 0; i < testCells*testBeams; i++ {
+		v := int16(i*100 - 300)
+		if i == 5 {
+			v = BadVelocity
+		}
+		le.PutUint16(velocity[2+2*i:], uint16(v))
+	}
+
+	blocks := [][]byte{fixed, variable, velocity}
+	header := make([]byte, 6+2*len(blocks))
+	le.PutUint16(header[0:], idHeader)
+	header[5] = byte(len(blocks))
+	offset := len(header)
+	for i, block := range blocks {
+		le.PutUint16(header[6+2*i:], uint16(offset))
+		offset += len(block)
+	}
+	le.PutUint16(header[2:], uint16(offset))
+
+	raw := append([]byte(nil), header...)
+	for _, block := range blocks {
+		raw = append(raw, block...)
+	}
+	var sum uint16
+	for _, b := range raw {
+		sum += uint16(b)
+	}
+	return le.AppendUint16(raw, sum)
+}
+
+func TestDecodeEnsemble(t *testing.T) {
+	when := time.Date(2024, 3, 15, 12, 30, 45, 250*int(time.Millisecond), time.UTC)
+	d := NewDecoder(bytes.NewReader(buildEnsemble(65538, when)))
+
+	e, err := d.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+
+	f := e.Fixed
+	if f.FirmwareVersion != "51.07" || f.FrequencyKHz != 300 || !f.BeamsFacingUp || f.BeamAngle != 20 {
+		t.Errorf("configuração do fixed leader: %+v", f)
+	}
+	if f.NumberOfBeams != testBeams || f.NumberOfCells != testCells || f.PingsPerEnsemble != 60 {
+		t.Errorf("células/feixes/pings: %+v", f)
+	}
+	if f.CellSize != 1 || f.Blank != 1.76 || f.Bin1Distance != 2.62 || f.TransmitPulse != 1.4 {
+		t.Errorf("distâncias do fixed leader: %+v", f)
+	}
+	if f.CoordinateSystem != CoordinatesEarth || f.HeadingAlignment != -12.5 || f.SerialNumber != 12345 {
+		t.Errorf("coordenadas/alinhamento/série: %+v", f)
+	}
+	if f.LowCorrThreshold != 64 || f.PercentGoodMinimum != 25 || f.ErrorVelocityMax != 2 {
+		t.Errorf("limiares do fixed leader: %+v", f)
+	}
+
+	v := e.Variable
+	if v.EnsembleNumber != 65538 || !v.Time.Equal(when) {
+		t.Errorf("número/relógio: %d %v", v.EnsembleNumber, v.Time)
+	}
+	if v.SpeedOfSound != 1500 || v.TransducerDepth != 5.5 || v.Heading != 270 || v.Pitch != -1.5 || v.Roll != 2 {
+		t.Errorf("atitude do variable leader: %+v", v)
+	}
+	if v.Salinity != 35 || v.Temperature != 15.25 || v.Pressure != 12.5 {
+		t.Errorf("ambiente do variable leader: %+v", v)
+	}
+
+	if got := e.VelocityMS(0, 0); got == nil || *got != -0.3 {
+		t.Errorf("VelocityMS(0, 0) = %v, esperado -0.3", got)
+	}
+	if got := e.VelocityMS(1, 1); got != nil {
+		t.Errorf("VelocityMS(1, 1) = %v, esperado nil (BadVelocity)", *got)
+	}
+	if got := e.CellDepth(1); got != 5.5-3.62 {
+		t.Errorf("CellDepth(1) = %v", got)
+	}
+
+	if _, err := d.Next(); err != io.EOF {
+		t.Errorf("esperado io.EOF, obtido %v", err)
+	}
+	if d.Stats.Ensembles != 1 || d.Stats.Dropped != 0 || d.Stats.BytesSkipped != 0 {
+		t.Errorf("Stats = %+v", d.Stats)
+	}
+}
+
+func TestDecoderChecksumAndResync(t *testing.T) {
+	when := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	bad := buildEnsemble(1, when)
+	bad[len(bad)-1] ^= 0xFF
+	good := buildEnsemble(2, when.Add(time.Minute))
+
+	var file []byte
+	file = append(file, 0x01, 0x02, 0x7F) // Lixo antes do primeiro ensemble
+	file = append(file, bad...)
+	file = append(file, good...)
+	d := NewDecoder(bytes.NewReader(file))
+
+	_, err := d.Next()
+	var ensembleErr *EnsembleError
+	if !errors.As(err, &ensembleErr) || !errors.Is(err, ErrChecksum) {
+		t.Fatalf("esperado ErrChecksum, obtido %v", err)
+	}
+	if ensembleErr.Offset != 3 {
+		t.Errorf("Offset = %d, esperado 3", ensembleErr.Offset)
+	}
+
+	e, err := d.Next()
+	if err != nil {
+		t.Fatalf("Next após ressincronização: %v", err)
+	}
+	if e.Variable.EnsembleNumber != 2 || e.Offset != int64(3+len(bad)) {
+		t.Errorf("ensemble %d no byte %d", e.Variable.EnsembleNumber, e.Offset)
+	}
+	if d.Stats.Ensembles != 1 || d.Stats.ChecksumFailures != 1 || d.Stats.Dropped != 1 {
+		t.Errorf("Stats = %+v", d.Stats)
+	}
+	if d.Stats.BytesSkipped != int64(3+len(bad)) {
+		t.Errorf("BytesSkipped = %d, esperado %d", d.Stats.BytesSkipped, 3+len(bad))
+	}
+}
+
+func TestDecodeEnsembleMissingVariableLeader(t *testing.T) {
+	raw := buildEnsemble(1, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	size := int(binary.LittleEndian.Uint16(raw[2:]))
+	// Troca o identificador do variable leader por um bloco desconhecido
+	off := int(binary.LittleEndian.Uint16(raw[8:]))
+	binary.LittleEndian.PutUint16(raw[off:], 0x7000)
+	var sum uint16
+	for _, b := range raw[:size] {
+		sum += uint16(b)
+	}
+	binary.LittleEndian.PutUint16(raw[size:], sum)
+
+	_, err := NewDecoder(bytes.NewReader(raw)).Next()
+	if !errors.Is(err, ErrMissingLeader) {
+		t.Errorf("esperado ErrMissingLeader, obtido %v", err)
+	}
+}
@@ -0,0 +1,354 @@
+package parsers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"api/internal/netcdf"
+)
+
+// ReferenceColumns são as colunas de ReferenceSeriesData preenchidas pelos leitores de séries de
+// referência, na ordem de Record.Values
+var ReferenceColumns = []string{"windspeed", "winddirection"}
+
+// ReferenceOptions indica os nomes das colunas (CSV) ou variáveis (NetCDF) da série de referência.
+// Nomes vazios são detectados; U e V (componentes zonal e meridional) são usados quando não há
+// velocidade e direção.
+type ReferenceOptions struct {
+	Location  *time.Location // Fuso dos timestamps do CSV (UTC quando nil)
+	Time      string
+	Speed     string
+	Direction string
+	U         string
+	V         string
+	Latitude  *float64 // NetCDF: nó da grade mais próximo destas coordenadas (o primeiro quando nil)
+	Longitude *float64
+}
+
+// Padrões dos nomes normalizados (normalizeKey) aceitos na detecção das colunas e variáveis
+var (
+	referenceTimeKeys      = regexp.MustCompile(`^(timestamp|time|validtime|datetime|date|datahora|data)$`)
+	referenceSpeedKeys     = regexp.MustCompile(`^(ws|wspd|windspeed|speed|velocidade|si)\d*m?$`)
+	referenceDirectionKeys = regexp.MustCompile(`^(wd|wdir|winddirection|direction|dir|direcao)\d*m?$`)
+	referenceUKeys         = regexp.MustCompile(`^(u|ua|uwind)\d*m?$`)
+	referenceVKeys         = regexp.MustCompile(`^(v|va|vwind)\d*m?$`)
+)
+
+// windFromComponents converte as componentes u (leste) e v (norte) em velocidade e direção de origem
+func windFromComponents(u, v float64) (speed, direction float64) {
+	direction = math.Mod(math.Atan2(-u, -v)*180/math.Pi+360, 360)
+	return math.Hypot(u, v), direction
+}
+
+// referenceIndexes localiza as colunas pelo nome informado ou pelos padrões de detecção
+type referenceIndexes struct {
+	time, speed, direction, u, v int
+}
+
+func findReferenceFields(names []string, opts ReferenceOptions) (referenceIndexes, error) {
+	idx := referenceIndexes{-1, -1, -1, -1, -1}
+	find := func(explicit string, pattern *regexp.Regexp) (int, error) {
+		for i, name := range names {
+			if explicit != "" {
+				if strings.EqualFold(strings.TrimSpace(name), explicit) {
+					return i, nil
+				}
+			} else if pattern.MatchString(normalizeKey(name)) {
+				return i, nil
+			}
+		}
+		if explicit != "" {
+			return -1, fmt.Errorf("%w: campo %q não encontrado", ErrInvalidFormat, explicit)
+		}
+		return -1, nil
+	}
+	var err error
+	for _, f := range []struct {
+		dest     *int
+		explicit string
+		pattern  *regexp.Regexp
+	}{
+		{&idx.time, opts.Time, referenceTimeKeys},
+		{&idx.speed, opts.Speed, referenceSpeedKeys},
+		{&idx.direction, opts.Direction, referenceDirectionKeys},
+		{&idx.u, opts.U, referenceUKeys},
+		{&idx.v, opts.V, referenceVKeys},
+	} {
+		if *f.dest, err = find(f.explicit, f.pattern); err != nil {
+			return idx, err
+		}
+	}
+	if idx.speed < 0 && (idx.u < 0 || idx.v < 0) {
+		return idx, fmt.Errorf("%w: velocidade (ou componentes u e v) não encontrada", ErrInvalidFormat)
+	}
+	return idx, nil
+}
+
+// ReferenceCSVReader lê uma série de referência em CSV com cabeçalho (separador vírgula, ponto e
+// vírgula ou tabulação)
+type ReferenceCSVReader struct {
+	csv      *csv.Reader
+	line     int
+	location *time.Location
+	fields   referenceIndexes
+}
+
+// NewReferenceCSVReader lê o cabeçalho do CSV e localiza as colunas de tempo e vento
+func NewReferenceCSVReader(r io.Reader, opts ReferenceOptions) (*ReferenceCSVReader, error) {
+	br := bufio.NewReader(r)
+	first, _ := br.Peek(4096)
+	header := strings.SplitN(string(first), "\n", 2)[0]
+	comma := ','
+	for _, c := range []rune{';', '\t'} {
+		if strings.Count(header, string(c)) > strings.Count(header, string(comma)) {
+			comma = c
+		}
+	}
+
+	cr := csv.NewReader(br)
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.Comment = '#'
+	names, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: cabeçalho do CSV ausente", ErrInvalidFormat)
+	}
+	if len(names) > 0 {
+		names[0] = strings.TrimPrefix(names[0], "\ufeff")
+	}
+	rr := &ReferenceCSVReader{csv: cr, line: 1, location: opts.Location}
+	if rr.location == nil {
+		rr.location = time.UTC
+	}
+	if rr.fields, err = findReferenceFields(names, opts); err != nil {
+		return nil, err
+	}
+	if rr.fields.time < 0 {
+		return nil, fmt.Errorf("%w: coluna de tempo não encontrada", ErrInvalidFormat)
+	}
+	return rr, nil
+}
+
+// Next retorna a próxima linha, io.EOF ao final do arquivo ou *RowError para linhas inválidas
+func (rr *ReferenceCSVReader) Next() (*Record, error) {
+	for {
+		fields, err := rr.csv.Read()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		rr.line++
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); ok {
+				return nil, &RowError{Line: rr.line, Err: err}
+			}
+			return nil, err
+		}
+		if len(fields) == 1 && strings.TrimSpace(fields[0]) == "" {
+			continue
+		}
+		get := func(i int) (*float64, error) {
+			if i < 0 || i >= len(fields) {
+				return nil, nil
+			}
+			return parseFloat(fields[i])
+		}
+		if rr.fields.time >= len(fields) {
+			return nil, &RowError{Line: rr.line, Err: fmt.Errorf("linha incompleta (%d campos)", len(fields))}
+		}
+
+		raw := strings.Trim(strings.TrimSpace(fields[rr.fields.time]), `"`)
+		ts, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			if ts, err = parseTimestamp(raw, rr.location); err != nil {
+				return nil, &RowError{Line: rr.line, Err: err}
+			}
+		}
+
+		var values [4]*float64
+		for i, index := range []int{rr.fields.speed, rr.fields.direction, rr.fields.u, rr.fields.v} {
+			if values[i], err = get(index); err != nil {
+				return nil, &RowError{Line: rr.line, Err: err}
+			}
+		}
+		speed, direction := values[0], values[1]
+		if speed == nil && values[2] != nil && values[3] != nil {
+			s, d := windFromComponents(*values[2], *values[3])
+			speed, direction = &s, &d
+		}
+		return &Record{Line: rr.line, Timestamp: ts, Values: []*float64{speed, direction}}, nil
+	}
+}
+
+// ReferenceNetCDFReader extrai a série de um nó de grade de um arquivo NetCDF clássico (reanálise)
+type ReferenceNetCDFReader struct {
+	Latitude  *float64 // Coordenadas do nó escolhido, quando o arquivo tem latitude e longitude
+	Longitude *float64
+	records   []*Record
+	next      int
+}
+
+// netcdfTimeUnits aceita unidades CF como "hours since 1900-01-01 00:00:00.0"
+var netcdfTimeUnits = regexp.MustCompile(`^\s*(seconds?|minutes?|hours?|days?)\s+since\s+(.+?)\s*$`)
+
+// parseNetCDFTime converte os valores de tempo pelas unidades CF (calendário gregoriano)
+func parseNetCDFTime(units string, values []float64) ([]time.Time, error) {
+	m := netcdfTimeUnits.FindStringSubmatch(units)
+	if m == nil {
+		return nil, fmt.Errorf("%w: unidades de tempo %q não suportadas", ErrInvalidFormat, units)
+	}
+	epochText := strings.TrimSuffix(strings.TrimSuffix(m[2], " UTC"), "Z")
+	var epoch time.Time
+	var err error
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999", "2006-1-2 15:4:5", "2006-01-02 15:04", "2006-01-02", "2006-1-2"} {
+		if epoch, err = time.ParseInLocation(layout, epochText, time.UTC); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: origem de tempo %q inválida", ErrInvalidFormat, m[2])
+	}
+	unit := map[byte]time.Duration{'s': time.Second, 'm': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour}[m[1][0]]
+	times := make([]time.Time, len(values))
+	for i, v := range values {
+		if math.IsNaN(v) {
+			return nil, fmt.Errorf("%w: tempo ausente no registro %d", ErrInvalidFormat, i)
+		}
+		times[i] = epoch.Add(time.Duration(math.Round(v * float64(unit))))
+	}
+	return times, nil
+}
+
+// NewReferenceNetCDFReader lê do arquivo a série de vento no nó mais próximo de opts.Latitude e
+// opts.Longitude. Variáveis com dimensões além de tempo, latitude e longitude (nível) usam o índice 0.
+func NewReferenceNetCDFReader(r io.ReaderAt, size int64, opts ReferenceOptions) (*ReferenceNetCDFReader, error) {
+	d, err := netcdf.Open(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFormat, err)
+	}
+	names := make([]string, len(d.Variables))
+	for i, v := range d.Variables {
+		names[i] = v.Name
+	}
+	fields, err := findReferenceFields(names, opts)
+	if err != nil {
+		return nil, err
+	}
+	if fields.time < 0 {
+		return nil, fmt.Errorf("%w: variável de tempo não encontrada", ErrInvalidFormat)
+	}
+	timeVar := &d.Variables[fields.time]
+	units, _ := timeVar.Attribute("units").(string)
+	timeValues, err := d.ReadSeries(timeVar.Name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFormat, err)
+	}
+	times, err := parseNetCDFTime(units, timeValues)
+	if err != nil {
+		return nil, err
+	}
+
+	nr := &ReferenceNetCDFReader{}
+	// Índice do nó em cada dimensão de coordenada (latitude/longitude), escolhido pelo mais próximo
+	nodes := map[string]int{}
+	for _, c := range []struct {
+		names  []string
+		target *float64
+		circle bool
+		chosen **float64
+	}{
+		{[]string{"latitude", "lat"}, opts.Latitude, false, &nr.Latitude},
+		{[]string{"longitude", "lon"}, opts.Longitude, true, &nr.Longitude},
+	} {
+		for _, name := range c.names {
+			v, ok := d.Variable(name)
+			if !ok || len(v.Dimensions) != 1 {
+				continue
+			}
+			coords, err := d.ReadSeries(name, nil)
+			if err != nil || len(coords) == 0 {
+				continue
+			}
+			best := 0
+			if c.target != nil {
+				bestDist := math.Inf(1)
+				for i, x := range coords {
+					dist := math.Abs(x - *c.target)
+					if c.circle {
+						dist = math.Mod(dist, 360)
+						dist = math.Min(dist, 360-dist)
+					}
+					if dist < bestDist {
+						best, bestDist = i, dist
+					}
+				}
+			}
+			value := coords[best]
+			*c.chosen = &value
+			nodes[v.Dimensions[0]] = best
+			break
+		}
+	}
+
+	series := func(index int) ([]float64, error) {
+		if index < 0 {
+			return nil, nil
+		}
+		v := d.Variables[index]
+		if len(v.Dimensions) < 1 {
+			return nil, fmt.Errorf("%w: %s não acompanha a dimensão de tempo", ErrInvalidFormat, v.Name)
+		}
+		position := make([]int, 0, len(v.Dimensions))
+		for _, dim := range v.Dimensions[1:] {
+			position = append(position, nodes[dim])
+		}
+		values, err := d.ReadSeries(v.Name, position)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFormat, err)
+		}
+		if len(values) != len(times) {
+			return nil, fmt.Errorf("%w: %s não acompanha a dimensão de tempo", ErrInvalidFormat, v.Name)
+		}
+		return values, nil
+	}
+	var columns [4][]float64
+	for i, index := range []int{fields.speed, fields.direction, fields.u, fields.v} {
+		if columns[i], err = series(index); err != nil {
+			return nil, err
+		}
+	}
+
+	value := func(column []float64, i int) *float64 {
+		if column == nil || math.IsNaN(column[i]) {
+			return nil
+		}
+		v := column[i]
+		return &v
+	}
+	for i, ts := range times {
+		speed, direction := value(columns[0], i), value(columns[1], i)
+		if speed == nil {
+			if u, v := value(columns[2], i), value(columns[3], i); u != nil && v != nil {
+				s, dir := windFromComponents(*u, *v)
+				speed, direction = &s, &dir
+			}
+		}
+		nr.records = append(nr.records, &Record{Line: i + 1, Timestamp: ts, Values: []*float64{speed, direction}})
+	}
+	return nr, nil
+}
+
+// Next retorna o próximo registro ou io.EOF
+func (nr *ReferenceNetCDFReader) Next() (*Record, error) {
+	if nr.next >= len(nr.records) {
+		return nil, io.EOF
+	}
+	nr.next++
+	return nr.records[nr.next-1], nil
+}
@@ -0,0 +1,546 @@
+package parsers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"api/internal/models"
+)
+
+// Formatos de arquivo SODAR suportados
+const (
+	SODARFormatMND   = "MND"   // Scintec FORMAT-1 (.mnd)
+	SODARFormatAQ500 = "AQ500" // Exportação tabular do AQ500 (uma coluna por grandeza e altura)
+)
+
+// DefaultSODARSentinels lista os valores usados pelo AQ500 para indicar medição ausente. No MND cada
+// variável declara o próprio código de falha.
+var DefaultSODARSentinels = []float64{99999, -99999, 9999, -9999}
+
+// sodarAliases associa os símbolos normalizados dos arquivos SODAR às colunas de SODARDados
+var sodarAliases = map[string]string{
+	"z":                   "height",
+	"height":              "height",
+	"speed":               "windspeed",
+	"windspeed":           "windspeed",
+	"ws":                  "windspeed",
+	"hws":                 "windspeed",
+	"vh":                  "windspeed",
+	"dir":                 "winddirection",
+	"winddirection":       "winddirection",
+	"wd":                  "winddirection",
+	"ugeo":                "u_geo",
+	"vgeo":                "v_geo",
+	"u":                   "u",
+	"v":                   "v",
+	"w":                   "w",
+	"vws":                 "w",
+	"sigu":                "sigmau",
+	"sigur":               "sigmau_radial",
+	"sigv":                "sigmav",
+	"sigvr":               "sigmav_radial",
+	"sigw":                "sigmaw",
+	"sdw":                 "sigmaw",
+	"shear":               "windshear",
+	"sdir":                "windsheardirection",
+	"sigspeed":            "sigmaspeed",
+	"siglat":              "sigmalateral",
+	"sigphi":              "sigmaphi",
+	"sigtheta":            "sigmatheta",
+	"ti":                  "turbulenceintensity",
+	"turbulenceintensity": "turbulenceintensity",
+	"pgz":                 "pgz",
+	"tke":                 "tke",
+	"edr":                 "edr",
+	"bckraw":              "backscatterraw",
+	"bck":                 "backscatter",
+	"bckid":               "backscatterid",
+	"ct2":                 "ct2",
+}
+
+// sodarHeaderLine reconhece linhas "Chave: valor" do bloco de informações do arquivo
+var sodarHeaderLine = regexp.MustCompile(`^\s*([^:#]+?)\s*(?:\[[^\]]*\])?\s*:\s*(.*)$`)
+
+// aq500Column reconhece colunas do AQ500 com a altura antes ou depois da grandeza ("40m WS", "WS_40", "ws 40m")
+var aq500Column = regexp.MustCompile(`^\s*(?:(\d+(?:\.\d+)?)\s*m?[\s_-]+(.+?)|(.+?)[\s_-]+(\d+(?:\.\d+)?)\s*m?)\s*$`)
+
+// sodarDateTime reconhece o início de um bloco de dados do MND ("2024-01-01 00:30:00 00:30:00" ou
+// "2024-01-01T00:30:00"), capturando a data e a hora
+var sodarDateTime = regexp.MustCompile(`^\s*(\d{4}-\d{2}-\d{2})[ T](\d{2}:\d{2}(?::\d{2})?)`)
+
+// SODARReader lê arquivos do SODAR e produz uma linha de SODARDados por timestamp e altura
+type SODARReader struct {
+	Header  models.SODARHeader // Cabeçalho preenchido a partir do bloco de informações
+	Format  string             // SODARFormatMND ou SODARFormatAQ500
+	Columns []string           // Colunas de SODARDados preenchidas (a primeira é sempre "height")
+
+	scanner   *bufio.Scanner
+	line      int
+	location  *time.Location
+	missing   []map[float64]bool // Códigos de valor ausente de cada coluna de Columns
+	heights   map[float64]bool
+	pending   []*Record // Linhas já montadas ainda não entregues (AQ500 gera várias por linha)
+	lookahead string    // Primeira linha do bloco de dados, lida durante o cabeçalho
+
+	// MND
+	symbols   []string  // Símbolos das variáveis, na ordem das colunas do bloco
+	gaps      []float64 // Valor de falha declarado para cada variável
+	blockTime time.Time
+	blockOK   bool
+	indexes   []int // Índice em Columns de cada coluna do bloco (-1 = ignorada)
+
+	// AQ500
+	delimiter  string
+	timeIndex  int
+	dateIndex  int
+	clockIndex int
+	cells      []aq500Cell
+	aqHeights  []float64
+}
+
+// aq500Cell associa uma coluna do arquivo AQ500 a uma altura e a uma coluna de SODARDados
+type aq500Cell struct {
+	index  int
+	height float64
+	column int
+}
+
+// NewSODARReader identifica o formato (MND ou AQ500), lê o cabeçalho e prepara a leitura dos dados.
+// Os timestamps são interpretados no fuso loc (UTC quando nil).
+func NewSODARReader(r io.Reader, fileName string, loc *time.Location) (*SODARReader, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	sr := &SODARReader{
+		scanner:  scanner,
+		location: loc,
+		heights:  map[float64]bool{},
+	}
+	sr.Header.FileName = filepath.Base(fileName)
+
+	first, ok := sr.nextLine()
+	if !ok {
+		return nil, fmt.Errorf("%w: arquivo SODAR vazio", ErrInvalidFormat)
+	}
+	first = strings.TrimPrefix(first, "\ufeff")
+
+	var err error
+	if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(first)), "FORMAT-") {
+		sr.Format = SODARFormatMND
+		err = sr.readMNDHeader()
+	} else {
+		sr.Format = SODARFormatAQ500
+		err = sr.readAQ500Header(first)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := sr.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sr, nil
+}
+
+// nextLine retorna a próxima linha não vazia do arquivo
+func (sr *SODARReader) nextLine() (string, bool) {
+	for sr.scanner.Scan() {
+		sr.line++
+		text := strings.TrimRight(sr.scanner.Text(), "\r")
+		if strings.TrimSpace(text) != "" {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+// Heights retorna as alturas encontradas até o momento, em ordem crescente
+func (sr *SODARReader) Heights() []float64 {
+	heights := make([]float64, 0, len(sr.heights))
+	for h := range sr.heights {
+		heights = append(heights, h)
+	}
+	sort.Float64s(heights)
+	return heights
+}
+
+// setHeaderField copia um item "Chave: valor" do arquivo para models.SODARHeader
+func (sr *SODARReader) setHeaderField(key, value string) {
+	h := &sr.Header
+	switch normalizeKey(key) {
+	case "deviceserialnumber", "serialnumber":
+		h.DeviceSerialNumber = value
+	case "stationcode", "station":
+		h.StationCode = value
+	case "softwareversion":
+		h.SoftwareVersion = value
+	case "antennaazimuthangle", "azimuth":
+		h.AntennaAzimuthAngle = floatOrZero(value)
+	case "heightaboveground":
+		h.HeightAboveGround = floatOrZero(value)
+	case "heightabovesealevel", "altitude":
+		h.HeightAboveSeaLevel = floatOrZero(value)
+	}
+}
+
+// readMNDHeader lê o cabeçalho Scintec FORMAT-1 até o início do bloco de dados
+func (sr *SODARReader) readMNDHeader() error {
+	section := ""
+	for {
+		text, ok := sr.nextLine()
+		if !ok {
+			return fmt.Errorf("%w: bloco de dados do MND não encontrado", ErrInvalidFormat)
+		}
+		trimmed := strings.TrimSpace(text)
+
+		if strings.HasPrefix(trimmed, "#") && !strings.Contains(trimmed[1:], "#") {
+			title := strings.ToLower(strings.TrimSpace(trimmed[1:]))
+			switch {
+			case strings.Contains(title, "beginning of data"):
+				return sr.finishMNDHeader()
+			case strings.Contains(title, "variable"):
+				section = "variables"
+			default:
+				section = title
+			}
+			continue
+		}
+
+		if section == "variables" && strings.Count(trimmed, "#") >= 2 {
+			parts := strings.Split(trimmed, "#")
+			symbol := strings.TrimSpace(parts[1])
+			gap := 99999.0
+			if len(parts) >= 6 {
+				if v, err := parseFloat(parts[5]); err == nil && v != nil {
+					gap = *v
+				}
+			}
+			sr.symbols = append(sr.symbols, symbol)
+			sr.gaps = append(sr.gaps, gap)
+			continue
+		}
+
+		// Os Scintec mais antigos encerram o cabeçalho sem o comentário "beginning of data block"
+		if sodarDateTime.MatchString(trimmed) && len(sr.symbols) > 0 {
+			sr.lookahead = text
+			return sr.finishMNDHeader()
+		}
+
+		if m := sodarHeaderLine.FindStringSubmatch(trimmed); m != nil {
+			sr.setHeaderField(m[1], strings.TrimSpace(m[2]))
+		}
+	}
+}
+
+// finishMNDHeader define as colunas de saída a partir das variáveis declaradas
+func (sr *SODARReader) finishMNDHeader() error {
+	if len(sr.symbols) == 0 {
+		return fmt.Errorf("%w: definição de variáveis do MND não encontrada", ErrInvalidFormat)
+	}
+	sr.Columns = []string{"height"}
+	sr.missing = []map[float64]bool{{}}
+	seen := map[string]bool{}
+	for i, symbol := range sr.symbols {
+		column, ok := sodarAliases[normalizeKey(symbol)]
+		if !ok || seen[column] {
+			continue
+		}
+		seen[column] = true
+		index := 0
+		if column != "height" {
+			index = len(sr.Columns)
+			sr.Columns = append(sr.Columns, column)
+			sr.missing = append(sr.missing, map[float64]bool{})
+		}
+		// O código de falha vale só para a coluna da própria variável
+		if sr.gaps[i] != 0 {
+			sr.missing[index][sr.gaps[i]] = true
+		}
+	}
+	sr.mapMNDBlock(sr.symbols)
+	if indexOfColumn(sr.indexes, 0) < 0 {
+		return fmt.Errorf("%w: variável de altura (z) não declarada no MND", ErrInvalidFormat)
+	}
+	return nil
+}
+
+// mapMNDBlock associa as colunas de um bloco (na ordem dos símbolos) às colunas de saída
+func (sr *SODARReader) mapMNDBlock(symbols []string) {
+	sr.indexes = make([]int, len(symbols))
+	for i, symbol := range symbols {
+		sr.indexes[i] = -1
+		column, ok := sodarAliases[normalizeKey(symbol)]
+		if !ok {
+			continue
+		}
+		for j, c := range sr.Columns {
+			if c == column && indexOfColumn(sr.indexes[:i], j) < 0 {
+				sr.indexes[i] = j
+				break
+			}
+		}
+	}
+}
+
+// indexOfColumn retorna a posição de column em indexes, ou -1
+func indexOfColumn(indexes []int, column int) int {
+	for i, c := range indexes {
+		if c == column {
+			return i
+		}
+	}
+	return -1
+}
+
+// value converte um campo numérico da coluna column de Columns, transformando os códigos de valor
+// ausente dessa coluna em NULL
+func (sr *SODARReader) value(s string, column int) (*float64, error) {
+	v, err := parseFloat(s)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	if sr.missing[column][*v] {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// Next retorna a próxima linha (timestamp e altura), io.EOF ao final ou *RowError para linhas inválidas
+func (sr *SODARReader) Next() (*Record, error) {
+	if len(sr.pending) > 0 {
+		record := sr.pending[0]
+		sr.pending = sr.pending[1:]
+		return record, nil
+	}
+	for {
+		text, ok := sr.lookahead, sr.lookahead != ""
+		sr.lookahead = ""
+		if !ok {
+			text, ok = sr.nextLine()
+		}
+		if !ok {
+			if err := sr.scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+
+		var record *Record
+		var err error
+		if sr.Format == SODARFormatMND {
+			record, err = sr.parseMNDLine(text)
+		} else {
+			record, err = sr.parseAQ500Line(text)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			return record, nil
+		}
+	}
+}
+
+// parseMNDLine trata uma linha do bloco de dados do MND; retorna nil para linhas de controle
+func (sr *SODARReader) parseMNDLine(text string) (*Record, error) {
+	trimmed := strings.TrimSpace(text)
+	if strings.HasPrefix(trimmed, "#") {
+		// Linha de títulos do bloco: "# z speed dir ..."
+		symbols := strings.Fields(strings.TrimPrefix(trimmed, "#"))
+		if len(symbols) > 0 {
+			sr.mapMNDBlock(symbols)
+		}
+		return nil, nil
+	}
+
+	if m := sodarDateTime.FindStringSubmatch(trimmed); m != nil {
+		ts, err := parseTimestamp(m[1]+" "+m[2], sr.location)
+		sr.blockOK = err == nil
+		if err != nil {
+			return nil, &RowError{Line: sr.line, Err: err}
+		}
+		sr.blockTime = ts
+		return nil, nil
+	}
+
+	if !sr.blockOK {
+		return nil, &RowError{Line: sr.line, Err: fmt.Errorf("dados fora de um bloco com timestamp válido")}
+	}
+
+	fields := strings.Fields(trimmed)
+	if len(fields) < len(sr.indexes) {
+		return nil, &RowError{Line: sr.line, Err: fmt.Errorf("esperados %d campos, encontrados %d", len(sr.indexes), len(fields))}
+	}
+	record := &Record{Line: sr.line, Timestamp: sr.blockTime, Values: make([]*float64, len(sr.Columns))}
+	for i, column := range sr.indexes {
+		if column < 0 {
+			continue
+		}
+		v, err := sr.value(fields[i], column)
+		if err != nil {
+			return nil, &RowError{Line: sr.line, Err: fmt.Errorf("coluna %s: %w", sr.Columns[column], err)}
+		}
+		record.Values[column] = v
+	}
+	if record.Values[0] == nil {
+		return nil, &RowError{Line: sr.line, Err: fmt.Errorf("altura ausente")}
+	}
+	sr.heights[*record.Values[0]] = true
+	return record, nil
+}
+
+// readAQ500Header interpreta a linha de títulos da exportação tabular do AQ500
+func (sr *SODARReader) readAQ500Header(first string) error {
+	// Linhas "Chave: valor" antes dos títulos descrevem o equipamento
+	titles := first
+	for {
+		if m := sodarHeaderLine.FindStringSubmatch(titles); m != nil && !strings.ContainsAny(titles, ";,\t") {
+			sr.setHeaderField(m[1], strings.TrimSpace(m[2]))
+			next, ok := sr.nextLine()
+			if !ok {
+				return fmt.Errorf("%w: títulos do AQ500 não encontrados", ErrInvalidFormat)
+			}
+			titles = next
+			continue
+		}
+		break
+	}
+
+	sr.delimiter = detectDelimiter(titles)
+	sr.timeIndex, sr.dateIndex, sr.clockIndex = -1, -1, -1
+	sr.Columns = []string{"height"}
+	columnIndex := map[string]int{"height": 0}
+	heights := map[float64]bool{}
+
+	for i, title := range strings.Split(titles, sr.delimiter) {
+		title = strings.Trim(strings.TrimSpace(title), `"`)
+		key := normalizeKey(title)
+		switch key {
+		case "timestamp", "datetime", "timestampend", "datetimeend":
+			sr.timeIndex = i
+			continue
+		case "date":
+			sr.dateIndex = i
+			continue
+		case "time":
+			sr.clockIndex = i
+			continue
+		}
+
+		m := aq500Column.FindStringSubmatch(title)
+		if m == nil {
+			continue
+		}
+		heightText, name := m[1], m[2]
+		if heightText == "" {
+			heightText, name = m[4], m[3]
+		}
+		column, ok := sodarAliases[normalizeKey(name)]
+		if !ok || column == "height" {
+			continue
+		}
+		height, err := strconv.ParseFloat(heightText, 64)
+		if err != nil {
+			continue
+		}
+		index, ok := columnIndex[column]
+		if !ok {
+			index = len(sr.Columns)
+			columnIndex[column] = index
+			sr.Columns = append(sr.Columns, column)
+		}
+		heights[height] = true
+		sr.cells = append(sr.cells, aq500Cell{index: i, height: height, column: index})
+	}
+
+	if sr.timeIndex < 0 && sr.dateIndex < 0 {
+		return fmt.Errorf("%w: coluna de data/hora não encontrada", ErrInvalidFormat)
+	}
+	if len(sr.cells) == 0 {
+		return fmt.Errorf("%w: nenhuma coluna por altura reconhecida", ErrInvalidFormat)
+	}
+	for h := range heights {
+		sr.aqHeights = append(sr.aqHeights, h)
+	}
+	sentinels := map[float64]bool{}
+	for _, v := range DefaultSODARSentinels {
+		sentinels[v] = true
+	}
+	sr.missing = make([]map[float64]bool, len(sr.Columns))
+	for i := range sr.missing {
+		sr.missing[i] = sentinels
+	}
+	sort.Float64s(sr.aqHeights)
+	return nil
+}
+
+// parseAQ500Line converte uma linha do AQ500 em uma linha por altura; a primeira é retornada e as demais ficam pendentes
+func (sr *SODARReader) parseAQ500Line(text string) (*Record, error) {
+	fields := strings.Split(text, sr.delimiter)
+	var stamp string
+	switch {
+	case sr.timeIndex >= 0 && sr.timeIndex < len(fields):
+		stamp = fields[sr.timeIndex]
+	case sr.dateIndex >= 0 && sr.dateIndex < len(fields) && sr.clockIndex >= 0 && sr.clockIndex < len(fields):
+		stamp = strings.Trim(fields[sr.dateIndex], `" `) + " " + strings.Trim(fields[sr.clockIndex], `" `)
+	default:
+		return nil, &RowError{Line: sr.line, Err: fmt.Errorf("linha sem data/hora")}
+	}
+	ts, err := parseTimestamp(stamp, sr.location)
+	if err != nil {
+		return nil, &RowError{Line: sr.line, Err: err}
+	}
+
+	byHeight := make(map[float64]*Record, len(sr.aqHeights))
+	for _, cell := range sr.cells {
+		if cell.index >= len(fields) {
+			continue
+		}
+		v, err := sr.value(fields[cell.index], cell.column)
+		if err != nil {
+			return nil, &RowError{Line: sr.line, Err: fmt.Errorf("coluna %s a %gm: %w", sr.Columns[cell.column], cell.height, err)}
+		}
+		record, ok := byHeight[cell.height]
+		if !ok {
+			height := cell.height
+			record = &Record{Line: sr.line, Timestamp: ts, Values: make([]*float64, len(sr.Columns))}
+			record.Values[0] = &height
+			byHeight[cell.height] = record
+		}
+		record.Values[cell.column] = v
+	}
+
+	sr.pending = sr.pending[:0]
+	for _, h := range sr.aqHeights {
+		if record, ok := byHeight[h]; ok {
+			sr.heights[h] = true
+			sr.pending = append(sr.pending, record)
+		}
+	}
+	if len(sr.pending) == 0 {
+		return nil, nil
+	}
+	record := sr.pending[0]
+	sr.pending = sr.pending[1:]
+	return record, nil
+}
+
+// detectDelimiter escolhe o separador de campos mais frequente na linha de títulos
+func detectDelimiter(line string) string {
+	best, count := "\t", strings.Count(line, "\t")
+	for _, d := range []string{";", ","} {
+		if c := strings.Count(line, d); c > count {
+			best, count = d, c
+		}
+	}
+	return best
+}
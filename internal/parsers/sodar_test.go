@@ -0,0 +1,99 @@
+package parsers
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mndSample é um Scintec FORMAT-1 com códigos de falha distintos por variável, um bloco com data e
+// hora separadas por "T" e um bloco com data inválida
+const mndSample = `FORMAT-1
+# file information
+Device serial number : A0123
+Antenna azimuth angle [deg] : 12.5
+# variable definitions
+height # z # m # G1 # 0 # 99999
+wind speed # speed # m/s # G1 # 0 # 99.99
+wind direction # dir # deg # G1 # 0 # 999.9
+vertical wind # W # m/s # G1 # 0 # 99.99
+# beginning of data block
+2024-01-01 00:30:00 00:30:00
+# z speed dir W
+30 5.20 180.0 0.10
+40 999.9 99.99 99.99
+2024-01-01T01:00:00
+30 6.00 999.9 -0.20
+2024-13-01 01:30:00 00:30:00
+30 6.10 190.0 0.00
+`
+
+func TestSODARReaderMND(t *testing.T) {
+	loc := time.FixedZone("UTC-03:00", -3*3600)
+	sr, err := NewSODARReader(strings.NewReader(mndSample), "/dados/A0123.mnd", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sr.Format != SODARFormatMND || sr.Header.DeviceSerialNumber != "A0123" || sr.Header.AntennaAzimuthAngle != 12.5 {
+		t.Errorf("cabeçalho = %s %+v", sr.Format, sr.Header)
+	}
+	if want := "height,windspeed,winddirection,w"; strings.Join(sr.Columns, ",") != want {
+		t.Errorf("colunas = %v, esperado %s", sr.Columns, want)
+	}
+
+	rows, rejected := readRows(t, sr.Next)
+	nan := math.NaN()
+	first := time.Date(2024, 1, 1, 3, 30, 0, 0, time.UTC)
+	// 999.9 só é ausente na direção e 99.99 só na velocidade e no vento vertical
+	checkRows(t, rows, []row{
+		{13, first, []float64{30, 5.2, 180, 0.1}},
+		{14, first, []float64{40, 999.9, 99.99, nan}},
+		{16, first.Add(30 * time.Minute), []float64{30, 6, nan, -0.2}},
+	})
+	if want := []int{17, 18}; len(rejected) != 2 || rejected[0] != want[0] || rejected[1] != want[1] {
+		t.Errorf("linhas rejeitadas = %v, esperado %v", rejected, want)
+	}
+	if h := sr.Heights(); len(h) != 2 || h[0] != 30 || h[1] != 40 {
+		t.Errorf("alturas = %v", h)
+	}
+}
+
+func TestSODARReaderMNDWithoutVariables(t *testing.T) {
+	sample := "FORMAT-1\n# beginning of data block\n2024-01-01T00:30:00\n"
+	if _, err := NewSODARReader(strings.NewReader(sample), "a.mnd", nil); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("esperado ErrInvalidFormat, obtido %v", err)
+	}
+}
+
+func TestSODARReaderAQ500(t *testing.T) {
+	sample := "Serial number: AQ-77\n" +
+		"Date;Time;WS 40m;WD 40m;50m WS;50m WD;Status\n" +
+		"2024-01-01;00:10:00;5,5;180;9999;185;ok\n" +
+		"2024-01-01;00:20:00;x;180;6;185;ok\n" +
+		"2024-01-01;00:30:00;-9999;175;6,5;;ok\n"
+	sr, err := NewSODARReader(strings.NewReader(sample), "aq500.csv", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sr.Format != SODARFormatAQ500 || sr.Header.DeviceSerialNumber != "AQ-77" {
+		t.Errorf("cabeçalho = %s %+v", sr.Format, sr.Header)
+	}
+	if want := "height,windspeed,winddirection"; strings.Join(sr.Columns, ",") != want {
+		t.Errorf("colunas = %v, esperado %s", sr.Columns, want)
+	}
+
+	rows, rejected := readRows(t, sr.Next)
+	nan := math.NaN()
+	t0 := time.Date(2024, 1, 1, 0, 10, 0, 0, time.UTC)
+	checkRows(t, rows, []row{
+		{3, t0, []float64{40, 5.5, 180}},
+		{3, t0, []float64{50, nan, 185}},
+		{5, t0.Add(20 * time.Minute), []float64{40, nan, 175}},
+		{5, t0.Add(20 * time.Minute), []float64{50, 6.5, nan}},
+	})
+	if len(rejected) != 1 || rejected[0] != 4 {
+		t.Errorf("linhas rejeitadas = %v, esperado [4]", rejected)
+	}
+}
@@ -0,0 +1,242 @@
+package parsers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"api/internal/models"
+)
+
+// toa5ExtraAliases complementa os nomes idênticos às colunas com variações comuns dos programas Campbell
+var toa5ExtraAliases = map[string]string{
+	"ws_ms":          "ws_ms_avg",
+	"ws_ms_s_wvt":    "ws_ms_avg",
+	"winddir_d1_wvt": "winddir",
+	"winddir_avg":    "winddir",
+	"rh_avg":         "rh",
+	"rain_mm":        "rain_mm_tot",
+	"bp_mbar":        "bp_mbar_avg",
+	"slrw_chp1":      "slrw_chp1_avg",
+	"slrkj_chp1":     "slrkj_chp1_tot",
+	"battv_avg":      "battv",
+	"ptemp_c_avg":    "ptemp_c",
+	"sunelev":        "sunelevation",
+	"solarelevation": "sunelevation",
+	"solaraz":        "solarazimuth",
+	"decl":           "declination",
+}
+
+// DefaultTOA5Aliases retorna a tabela padrão de apelidos (nome do campo Campbell → coluna de
+// EstacaoSolarimetricaDados). As chaves são comparadas sem diferenciar maiúsculas de minúsculas.
+func DefaultTOA5Aliases() map[string]string {
+	aliases := make(map[string]string, len(models.EstacaoSolarimetricaColumns)+len(toa5ExtraAliases))
+	for _, column := range models.EstacaoSolarimetricaColumns {
+		aliases[column] = column
+	}
+	for field, column := range toa5ExtraAliases {
+		aliases[field] = column
+	}
+	return aliases
+}
+
+// TOA5Environment corresponde à primeira linha (ambiente) de um arquivo TOA5
+type TOA5Environment struct {
+	FileFormat       string `json:"file_format"`
+	StationName      string `json:"station_name"`
+	LoggerModel      string `json:"logger_model"`
+	LoggerSerial     string `json:"logger_serial"`
+	LoggerOS         string `json:"logger_os"`
+	ProgramName      string `json:"program_name"`
+	ProgramSignature string `json:"program_signature"`
+	TableName        string `json:"table_name"`
+}
+
+// TOA5Reader lê arquivos TOA5 (.dat) dos registradores Campbell Scientific linha a linha
+type TOA5Reader struct {
+	Header      models.EstacaoSolarimetricaHeader // Cabeçalho pronto para EstacaoSolarimetricaHeaders
+	Environment TOA5Environment                   // Linha de ambiente
+	Columns     []string                          // Colunas de EstacaoSolarimetricaDados preenchidas pelo arquivo
+	Unmapped    []string                          // Campos do arquivo sem coluna correspondente
+
+	csv       *csv.Reader
+	line      int
+	location  *time.Location
+	timeIndex int
+	indexes   []int
+}
+
+// NewTOA5Reader lê as quatro linhas de cabeçalho do TOA5 e associa os campos às colunas usando aliases
+// (nil usa DefaultTOA5Aliases). Os timestamps são interpretados no fuso loc (UTC quando nil).
+func NewTOA5Reader(r io.Reader, fileName string, aliases map[string]string, loc *time.Location) (*TOA5Reader, error) {
+	if aliases == nil {
+		aliases = DefaultTOA5Aliases()
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	valid := map[string]bool{}
+	for _, column := range models.EstacaoSolarimetricaColumns {
+		valid[column] = true
+	}
+	lookup := make(map[string]string, len(aliases))
+	for field, column := range aliases {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !valid[column] {
+			return nil, fmt.Errorf("%w: apelido %q aponta para coluna desconhecida %q", ErrInvalidFormat, field, column)
+		}
+		lookup[strings.ToLower(strings.TrimSpace(field))] = column
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.ReuseRecord = false
+
+	tr := &TOA5Reader{csv: cr, location: loc, timeIndex: -1}
+
+	var lines [4][]string
+	for i := range lines {
+		record, err := cr.Read()
+		if err != nil {
+			return nil, fmt.Errorf("%w: cabeçalho TOA5 incompleto", ErrInvalidFormat)
+		}
+		tr.line++
+		lines[i] = record
+	}
+
+	env := lines[0]
+	if len(env) == 0 || !strings.EqualFold(strings.TrimPrefix(env[0], "\ufeff"), "TOA5") {
+		return nil, fmt.Errorf("%w: assinatura TOA5 não encontrada", ErrInvalidFormat)
+	}
+	get := func(i int) string {
+		if i < len(env) {
+			return env[i]
+		}
+		return ""
+	}
+	tr.Environment = TOA5Environment{
+		FileFormat:       "TOA5",
+		StationName:      get(1),
+		LoggerModel:      get(2),
+		LoggerSerial:     get(3),
+		LoggerOS:         get(4),
+		ProgramName:      get(5),
+		ProgramSignature: get(6),
+		TableName:        get(7),
+	}
+
+	fields, units, processing := lines[1], lines[2], lines[3]
+	var solar, temperature, pressure []string
+	seen := map[string]bool{}
+	for i, field := range fields {
+		name := strings.ToLower(strings.TrimSpace(field))
+		if name == "timestamp" {
+			tr.timeIndex = i
+			continue
+		}
+		if name == "record" {
+			continue
+		}
+		column, ok := lookup[name]
+		if !ok || seen[column] {
+			tr.Unmapped = append(tr.Unmapped, field)
+			continue
+		}
+		seen[column] = true
+		tr.Columns = append(tr.Columns, column)
+		tr.indexes = append(tr.indexes, i)
+
+		switch {
+		case strings.HasPrefix(column, "slr"):
+			solar = append(solar, field)
+		case strings.HasPrefix(column, "airtc"), strings.HasPrefix(column, "ptemp"):
+			temperature = append(temperature, field)
+		case strings.HasPrefix(column, "bp_"):
+			pressure = append(pressure, field)
+		}
+	}
+	if tr.timeIndex < 0 {
+		return nil, fmt.Errorf("%w: campo TIMESTAMP não encontrado", ErrInvalidFormat)
+	}
+	if len(tr.Columns) == 0 {
+		return nil, fmt.Errorf("%w: nenhum campo do arquivo corresponde a EstacaoSolarimetricaDados", ErrInvalidFormat)
+	}
+
+	tr.Header = models.EstacaoSolarimetricaHeader{
+		FileName:                 filepath.Base(fileName),
+		SolarRadiationHeader:     strings.Join(solar, ","),
+		TemperatureHeader:        strings.Join(temperature, ","),
+		BarometricPressureHeader: strings.Join(pressure, ","),
+		StationName:              tr.Environment.StationName,
+		LoggerModel:              tr.Environment.LoggerModel,
+		LoggerSerial:             tr.Environment.LoggerSerial,
+		LoggerOS:                 tr.Environment.LoggerOS,
+		ProgramName:              tr.Environment.ProgramName,
+		ProgramSignature:         tr.Environment.ProgramSignature,
+		TableName:                tr.Environment.TableName,
+		FieldNames:               fields,
+		Units:                    units,
+		Processing:               processing,
+	}
+	return tr, nil
+}
+
+// Next retorna a próxima linha de dados, io.EOF ao final do arquivo ou *RowError para linhas inválidas
+func (tr *TOA5Reader) Next() (*Record, error) {
+	for {
+		fields, err := tr.csv.Read()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			if pe, ok := err.(*csv.ParseError); ok {
+				tr.line = pe.StartLine
+				return nil, &RowError{Line: tr.line, Err: err}
+			}
+			return nil, err
+		}
+		// O leitor CSV pula linhas em branco; a posição do primeiro campo dá a linha real no arquivo
+		tr.line, _ = tr.csv.FieldPos(0)
+		if len(fields) == 1 && strings.TrimSpace(fields[0]) == "" {
+			continue
+		}
+		if len(fields) <= tr.timeIndex {
+			return nil, &RowError{Line: tr.line, Err: fmt.Errorf("linha incompleta (%d campos)", len(fields))}
+		}
+
+		ts, err := parseTimestamp(fields[tr.timeIndex], tr.location)
+		if err != nil {
+			return nil, &RowError{Line: tr.line, Err: err}
+		}
+
+		record := &Record{Line: tr.line, Timestamp: ts, Values: make([]*float64, len(tr.Columns))}
+		for i, index := range tr.indexes {
+			if index >= len(fields) {
+				continue
+			}
+			v, err := parseFloat(fields[index])
+			if err != nil {
+				if isCampbellMissing(fields[index]) {
+					continue
+				}
+				return nil, &RowError{Line: tr.line, Err: fmt.Errorf("campo %s: %w", tr.Columns[i], err)}
+			}
+			record.Values[i] = v
+		}
+		return record, nil
+	}
+}
+
+// isCampbellMissing reconhece os marcadores de valor ausente gravados pelos registradores Campbell
+func isCampbellMissing(s string) bool {
+	switch strings.ToUpper(strings.Trim(strings.TrimSpace(s), `"`)) {
+	case "NAN", "INF", "-INF", "+INF":
+		return true
+	}
+	return false
+}
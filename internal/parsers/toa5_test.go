@@ -0,0 +1,69 @@
+package parsers
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+const toa5Sample = `"TOA5","Estacao1","CR1000X","4321","CR1000X.Std.05","CPU:solar.CR1X","27719","Tabela10min"
+"TIMESTAMP","RECORD","BattV","AirTC_Avg","Sensor_X","WS_ms","BP_mbar"
+"TS","RN","Volts","Deg C","","meters/second","mbar"
+"","","Smp","Avg","Smp","WVc","Avg"
+"2024-01-01 00:10:00",1,12.5,25.1,3,"NAN",1012
+"2024-01-01 00:20:00",2,12.4,abc,3,4.2,1012
+
+"2024-01-01 00:30:00",3,12.4,24.8,3,4.5
+`
+
+func TestTOA5Reader(t *testing.T) {
+	loc := time.FixedZone("UTC-03:00", -3*3600)
+	tr, err := NewTOA5Reader(strings.NewReader(toa5Sample), "/dados/Estacao1_Tabela10min.dat", nil, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "battv,airtc_avg,ws_ms_avg,bp_mbar_avg"; strings.Join(tr.Columns, ",") != want {
+		t.Errorf("colunas = %v, esperado %s", tr.Columns, want)
+	}
+	if len(tr.Unmapped) != 1 || tr.Unmapped[0] != "Sensor_X" {
+		t.Errorf("campos sem coluna = %v", tr.Unmapped)
+	}
+	h := tr.Header
+	if h.FileName != "Estacao1_Tabela10min.dat" || h.StationName != "Estacao1" || h.TableName != "Tabela10min" ||
+		h.TemperatureHeader != "AirTC_Avg" || h.BarometricPressureHeader != "BP_mbar" {
+		t.Errorf("cabeçalho = %+v", h)
+	}
+
+	rows, rejected := readRows(t, tr.Next)
+	nan := math.NaN()
+	t0 := time.Date(2024, 1, 1, 3, 10, 0, 0, time.UTC)
+	checkRows(t, rows, []row{
+		{5, t0, []float64{12.5, 25.1, nan, 1012}},
+		{8, t0.Add(20 * time.Minute), []float64{12.4, 24.8, 4.5, nan}},
+	})
+	if len(rejected) != 1 || rejected[0] != 6 {
+		t.Errorf("linhas rejeitadas = %v, esperado [6]", rejected)
+	}
+}
+
+func TestTOA5ReaderAliases(t *testing.T) {
+	if _, err := NewTOA5Reader(strings.NewReader(toa5Sample), "a.dat", map[string]string{"Sensor_X": "nope"}, nil); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("apelido para coluna desconhecida: esperado ErrInvalidFormat, obtido %v", err)
+	}
+	tr, err := NewTOA5Reader(strings.NewReader(toa5Sample), "a.dat", map[string]string{"Sensor_X": "rh", "BattV": "battv"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "battv,rh"; strings.Join(tr.Columns, ",") != want {
+		t.Errorf("colunas = %v, esperado %s", tr.Columns, want)
+	}
+}
+
+func TestTOA5ReaderSignature(t *testing.T) {
+	sample := strings.Replace(toa5Sample, `"TOA5"`, `"TOB1"`, 1)
+	if _, err := NewTOA5Reader(strings.NewReader(sample), "a.dat", nil, nil); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("esperado ErrInvalidFormat, obtido %v", err)
+	}
+}
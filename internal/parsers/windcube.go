@@ -0,0 +1,313 @@
+package parsers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"api/internal/models"
+)
+
+// Tipos de arquivo gerados pelo LIDAR WindCube
+const (
+	WindCubeSTA = "STA" // Médias de 10 minutos
+	WindCubeRTD = "RTD" // Dados em tempo real (uma linha por linha de visada)
+)
+
+// windCubeHeightColumn reconhece colunas por altura, por exemplo "40m Wind Speed (m/s)"
+var windCubeHeightColumn = regexp.MustCompile(`^\s*(\d+)\s*m\s+(.+)$`)
+
+// windCubeFieldAliases associa os nomes normalizados das colunas do WindCube às grandezas de LIDARWindCubeDados
+var windCubeFieldAliases = map[string]string{
+	"windspeed":           "WindSpeed",
+	"horizontalwindspeed": "WindSpeed",
+	"windspeeddispersion": "WindSpeedDispersion",
+	"windspeedstd":        "WindSpeedDispersion",
+	"windspeedmin":        "WindSpeedMin",
+	"windspeedmax":        "WindSpeedMax",
+	"winddirection":       "WindDirection",
+	"zwind":               "ZWind",
+	"zwinddispersion":     "ZWindDispersion",
+	"cnr":                 "CNR",
+	"cnrmin":              "CNRMin",
+	"doppspectbroad":      "DoppSpectBroad",
+	"dataavailability":    "DataAvailability",
+}
+
+// windCubeBaseAliases associa as colunas de diagnóstico do WindCube às colunas de LIDARWindCubeDados
+var windCubeBaseAliases = map[string]string{
+	"inttemp":     "IntTemp",
+	"exttemp":     "ExtTemp",
+	"pressure":    "Pressure",
+	"relhumidity": "RelHumidity",
+	"wipercount":  "WiperCount",
+	"vbatt":       "Vbatt",
+}
+
+// WindCubeReader lê arquivos .sta/.rtd do WindCube linha a linha, sem carregar o arquivo inteiro
+type WindCubeReader struct {
+	Header         models.LIDARWindCubeHeader // Cabeçalho preenchido a partir do bloco chave=valor
+	HeaderInfo     map[string]string          // Todas as chaves do cabeçalho, como aparecem no arquivo
+	FileType       string                     // WindCubeSTA ou WindCubeRTD
+	Columns        []string                   // Colunas de LIDARWindCubeDados preenchidas pelo arquivo
+	Heights        []int                      // Alturas encontradas no arquivo e presentes na tabela
+	IgnoredHeights []int                      // Alturas encontradas no arquivo sem colunas na tabela
+
+	scanner   *bufio.Scanner
+	line      int
+	location  *time.Location
+	timeIndex int   // Índice da coluna de timestamp no arquivo
+	indexes   []int // Índice no arquivo de cada elemento de Columns
+	width     int   // Número de campos da linha de títulos
+}
+
+// NewWindCubeReader lê o cabeçalho e a linha de títulos de um arquivo WindCube.
+// O nome do arquivo é usado para distinguir .sta de .rtd quando o conteúdo não é conclusivo.
+func NewWindCubeReader(r io.Reader, fileName string) (*WindCubeReader, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	wr := &WindCubeReader{
+		scanner:    scanner,
+		HeaderInfo: map[string]string{},
+		timeIndex:  -1,
+	}
+	wr.Header.FileName = filepath.Base(fileName)
+
+	var titles string
+	for scanner.Scan() {
+		wr.line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if wr.line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok || strings.Contains(key, "\t") {
+			titles = text
+			break
+		}
+		wr.HeaderInfo[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if titles == "" || len(wr.HeaderInfo) == 0 {
+		return nil, fmt.Errorf("%w: cabeçalho WindCube não encontrado", ErrInvalidFormat)
+	}
+
+	wr.fillHeader()
+	location, err := ParseTimezone(wr.Header.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	wr.location = location
+	if err := wr.mapColumns(strings.Split(titles, "\t")); err != nil {
+		return nil, err
+	}
+
+	wr.FileType = WindCubeSTA
+	if strings.EqualFold(filepath.Ext(fileName), ".rtd") {
+		wr.FileType = WindCubeRTD
+	}
+	for _, title := range strings.Split(titles, "\t") {
+		if normalizeKey(title) == "position" {
+			wr.FileType = WindCubeRTD
+		}
+	}
+
+	return wr, nil
+}
+
+// fillHeader copia as chaves conhecidas do cabeçalho para models.LIDARWindCubeHeader
+func (wr *WindCubeReader) fillHeader() {
+	h := &wr.Header
+	for key, value := range wr.HeaderInfo {
+		switch normalizeKey(key) {
+		case "headersize":
+			h.HeaderSize, _ = strconv.Atoi(value)
+		case "version":
+			h.Version = value
+		case "idsystem":
+			h.IDSystem = value
+		case "idclient":
+			h.IDClient = value
+		case "location":
+			h.Location = value
+		case "gpslocation":
+			h.GPSLocation = value
+		case "comments":
+			h.Comments = value
+		case "fcroption":
+			h.FCROption = value
+		case "timezone":
+			h.Timezone = value
+		case "laserdiodecurrent":
+			h.LaserDiodeCurrent = floatOrZero(value)
+		case "los":
+			h.LOS = floatOrZero(value)
+		case "installationoffsetagl", "installationoffset":
+			h.InstallationOffsetAGL = floatOrZero(value)
+		case "cnrthreshold":
+			h.CNRThreshold = floatOrZero(value)
+		case "vrthreshold":
+			h.VrThreshold = value
+		case "sigmafreqthreshold":
+			h.SigmaFreqThreshold = value
+		case "wipercnrthreshold":
+			h.WiperCNRThreshold = floatOrZero(value)
+		case "wiperaltitude":
+			h.WiperAltitude = floatOrZero(value)
+		case "wiperduration":
+			h.WiperDuration = int(floatOrZero(value))
+		case "altitudesagl", "altitudes":
+			h.AltitudesAGL = strings.Join(strings.Fields(value), " ")
+		case "samplingfrequency":
+			h.SamplingFrequency = value
+		case "reffrequency":
+			h.RefFrequency = value
+		case "pulseslos":
+			h.PulsesPerLOS = value
+		case "samplespulse":
+			h.SamplesPerPulse = value
+		case "reflectedpulsestart":
+			h.ReflectedPulseStart = value
+		case "reflectedpulseend":
+			h.ReflectedPulseEnd = value
+		case "refpulsesamplesnb":
+			h.RefPulseSamplesNb = value
+		case "nbhighpassfilterpoints":
+			h.NbHighPassFilterPoints = value
+		case "fftwindowwidth":
+			h.FFTWindowWidth = value
+		case "pulserepetitionrate":
+			h.PulseRepetitionRate = value
+		case "pulseduration":
+			h.PulseDuration = value
+		case "triggerdelaytime":
+			h.TriggerDelayTime = value
+		case "wavelength":
+			h.Wavelength = value
+		case "scanangle":
+			h.ScanAngle = value
+		case "directionoffset":
+			h.DirectionOffset = floatOrZero(value)
+		case "declination":
+			h.Declination = value
+		case "pitchangle":
+			h.PitchAngle = floatOrZero(value)
+		case "rollangle":
+			h.RollAngle = floatOrZero(value)
+		}
+	}
+}
+
+// mapColumns associa cada título do arquivo a uma coluna de LIDARWindCubeDados
+func (wr *WindCubeReader) mapColumns(titles []string) error {
+	wr.width = len(titles)
+	seen := map[string]bool{}
+	heights := map[int]bool{}
+	ignored := map[int]bool{}
+
+	for i, title := range titles {
+		key := normalizeKey(title)
+		if wr.timeIndex < 0 && (strings.HasPrefix(key, "timestamp") || key == "date" || key == "datetime") {
+			wr.timeIndex = i
+			continue
+		}
+
+		var column string
+		if m := windCubeHeightColumn.FindStringSubmatch(title); m != nil {
+			height, _ := strconv.Atoi(m[1])
+			field, ok := windCubeFieldAliases[normalizeKey(m[2])]
+			if !ok {
+				continue
+			}
+			if !models.HasLIDARWindCubeHeight(height) {
+				ignored[height] = true
+				continue
+			}
+			heights[height] = true
+			column = models.LIDARWindCubeColumn(field, height)
+		} else if field, ok := windCubeBaseAliases[key]; ok {
+			column = strings.ToLower(field)
+		} else {
+			continue
+		}
+
+		// Mantém apenas a primeira ocorrência quando o arquivo repete uma coluna
+		if seen[column] {
+			continue
+		}
+		seen[column] = true
+		wr.Columns = append(wr.Columns, column)
+		wr.indexes = append(wr.indexes, i)
+	}
+
+	if wr.timeIndex < 0 {
+		return fmt.Errorf("%w: coluna de timestamp não encontrada", ErrInvalidFormat)
+	}
+	if len(heights) == 0 {
+		return fmt.Errorf("%w: nenhuma coluna de altura reconhecida", ErrInvalidFormat)
+	}
+
+	for h := range heights {
+		wr.Heights = append(wr.Heights, h)
+	}
+	for h := range ignored {
+		wr.IgnoredHeights = append(wr.IgnoredHeights, h)
+	}
+	sort.Ints(wr.Heights)
+	sort.Ints(wr.IgnoredHeights)
+	return nil
+}
+
+// Next retorna a próxima linha de dados. Retorna io.EOF ao final do arquivo e *RowError
+// para linhas inválidas, que podem ser descartadas sem interromper a leitura.
+func (wr *WindCubeReader) Next() (*Record, error) {
+	for wr.scanner.Scan() {
+		wr.line++
+		text := strings.TrimRight(wr.scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) < wr.width {
+			// Algumas versões omitem o tabulador final; campos realmente faltantes invalidam a linha
+			if len(fields) <= wr.timeIndex || len(fields) < wr.width-1 {
+				return nil, &RowError{Line: wr.line, Err: fmt.Errorf("esperados %d campos, encontrados %d", wr.width, len(fields))}
+			}
+		}
+
+		ts, err := parseTimestamp(fields[wr.timeIndex], wr.location)
+		if err != nil {
+			return nil, &RowError{Line: wr.line, Err: err}
+		}
+
+		record := &Record{Line: wr.line, Timestamp: ts, Values: make([]*float64, len(wr.Columns))}
+		for i, index := range wr.indexes {
+			if index >= len(fields) {
+				continue
+			}
+			v, err := parseFloat(fields[index])
+			if err != nil {
+				return nil, &RowError{Line: wr.line, Err: fmt.Errorf("coluna %s: %w", wr.Columns[i], err)}
+			}
+			record.Values[i] = v
+		}
+		return record, nil
+	}
+	if err := wr.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
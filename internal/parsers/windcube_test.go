@@ -0,0 +1,65 @@
+package parsers
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// windCubeSample é um .sta mínimo em Europe/Paris que atravessa o fim do horário de verão de 2024
+// (27/10, 03:00 CEST -> 02:00 CET)
+const windCubeSample = "HeaderSize=4\r\n" +
+	"Version=2.1\r\n" +
+	"ID System=WLS7-0001\r\n" +
+	"Timezone=Europe/Paris\r\n" +
+	"Timestamp (end of interval)\tInt Temp (°C)\t40m Wind Speed (m/s)\t40m Wind Direction (°)\t45m Wind Speed (m/s)\r\n" +
+	"2024/10/26 12:00:00\t21.5\t7.25\t184\t8\r\n" +
+	"2024/10/28 12:00:00\t19\tNaN\t190\t8.1\r\n" +
+	"2024/10/28 12:10:00\t19\n"
+
+func TestWindCubeReader(t *testing.T) {
+	wr, err := NewWindCubeReader(strings.NewReader(windCubeSample), "/dados/WLS7-0001_2024_10_26.sta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wr.FileType != WindCubeSTA || wr.Header.Timezone != "Europe/Paris" || wr.Header.FileName != "WLS7-0001_2024_10_26.sta" {
+		t.Errorf("cabeçalho = %s %q %q", wr.FileType, wr.Header.Timezone, wr.Header.FileName)
+	}
+	if want := []string{"inttemp", "windspeed_40m", "winddirection_40m"}; strings.Join(wr.Columns, ",") != strings.Join(want, ",") {
+		t.Errorf("colunas = %v, esperado %v", wr.Columns, want)
+	}
+	if len(wr.Heights) != 1 || wr.Heights[0] != 40 || len(wr.IgnoredHeights) != 1 || wr.IgnoredHeights[0] != 45 {
+		t.Errorf("alturas = %v, ignoradas %v", wr.Heights, wr.IgnoredHeights)
+	}
+
+	// O deslocamento de cada linha é o da data da linha, não o do momento da importação
+	for _, want := range []time.Time{
+		time.Date(2024, 10, 26, 10, 0, 0, 0, time.UTC),
+		time.Date(2024, 10, 28, 11, 0, 0, 0, time.UTC),
+	} {
+		rec, err := wr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rec.Timestamp.Equal(want) {
+			t.Errorf("linha %d: timestamp %s, esperado %s", rec.Line, rec.Timestamp.UTC(), want)
+		}
+	}
+
+	var rowErr *RowError
+	if _, err := wr.Next(); !errors.As(err, &rowErr) || rowErr.Line != 8 {
+		t.Errorf("linha incompleta: %v, esperado *RowError na linha 8", err)
+	}
+	if _, err := wr.Next(); err != io.EOF {
+		t.Errorf("esperado io.EOF, obtido %v", err)
+	}
+}
+
+func TestWindCubeReaderUnknownTimezone(t *testing.T) {
+	sample := strings.Replace(windCubeSample, "Europe/Paris", "Europe/Atlantis", 1)
+	if _, err := NewWindCubeReader(strings.NewReader(sample), "a.sta"); !errors.Is(err, ErrUnknownTimezone) {
+		t.Errorf("esperado ErrUnknownTimezone, obtido %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+// Package qc avalia a qualidade dos dados de instrumentos e grava um código de qualidade por valor
+// na tabela QCFlags.
+//
+// Os códigos seguem o esquema do QARTOD (IOOS):
+//
+//	1 good          o valor passou em todos os testes configurados
+//	2 not_evaluated nenhum teste está configurado para a coluna (ou não foi possível avaliá-lo)
+//	3 suspect       o valor passou nos limites físicos mas falhou em um teste de consistência
+//	4 bad           o valor está fora dos limites físicos ou é fisicamente impossível
+//	9 missing       o valor é nulo
+//
+// Colunas sem regra não aparecem em QCFlags.Flags e são tratadas como not_evaluated.
+package qc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Flag é o código de qualidade de um valor
+type Flag int16
+
+// Códigos de qualidade (QARTOD)
+const (
+	Good         Flag = 1
+	NotEvaluated Flag = 2
+	Suspect      Flag = 3
+	Bad          Flag = 4
+	Missing      Flag = 9
+)
+
+// flagNames associa os códigos aos nomes usados na API
+var flagNames = map[Flag]string{
+	Good:         "good",
+	NotEvaluated: "not_evaluated",
+	Suspect:      "suspect",
+	Bad:          "bad",
+	Missing:      "missing",
+}
+
+// String retorna o nome do código
+func (f Flag) String() string {
+	if name, ok := flagNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("flag(%d)", int16(f))
+}
+
+// ParseFlag interpreta o nome ou o número de um código de qualidade
+func ParseFlag(s string) (Flag, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for flag, name := range flagNames {
+		if s == name || s == fmt.Sprint(int16(flag)) {
+			return flag, nil
+		}
+	}
+	return 0, fmt.Errorf("Invalid QC flag: %s", s)
+}
+
+// worse retorna o pior de dois códigos. Missing prevalece sobre os demais; entre os outros,
+// vale a ordem numérica.
+func worse(a, b Flag) Flag {
+	if a == Missing || b == Missing {
+		return Missing
+	}
+	if b > a {
+		return b
+	}
+	return a
+}
+
+// Scheme descreve o esquema de códigos para documentação na API
+type Scheme struct {
+	Code    Flag   `json:"code"`
+	Name    string `json:"name"`
+	Meaning string `json:"meaning"`
+}
+
+// Schemes lista os códigos na ordem em que são documentados
+var Schemes = []Scheme{
+	{Good, "good", "Passed every configured test"},
+	{NotEvaluated, "not_evaluated", "No test is configured for the variable or the test could not be applied"},
+	{Suspect, "suspect", "Within physical limits but failed a consistency test (climatology, step, spike, persistence, solar)"},
+	{Bad, "bad", "Outside physical limits or physically impossible"},
+	{Missing, "missing", "Value is null"},
+}
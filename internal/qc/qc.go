@@ -0,0 +1,453 @@
+package qc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"api/internal/solar"
+	"api/internal/store"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrUnknownTable indica que a tabela não possui configuração de QC
+var ErrUnknownTable = errors.New("qc: tabela sem configuração")
+
+// ErrInvalidEquipment indica um equipment_id que não é um UUID
+var ErrInvalidEquipment = errors.New("qc: equipment_id inválido")
+
+// fetchSize é a quantidade de linhas lidas do cursor a cada FETCH em Run
+const fetchSize = 5000
+
+// Result resume uma avaliação
+type Result struct {
+	Table  string           `json:"table"`
+	Rows   int64            `json:"rows"`   // Linhas avaliadas e gravadas em QCFlags
+	Counts map[string]int64 `json:"counts"` // Quantidade de valores por código
+}
+
+// sample é uma linha lida da tabela de dados
+type sample struct {
+	id        int64
+	timestamp time.Time
+	level     *float64
+	elevation *float64
+	values    []*float64
+}
+
+// columnState guarda o histórico de uma coluna dentro de uma série
+type columnState struct {
+	prev *float64
+	run  int // Valores iguais consecutivos, incluindo o último
+}
+
+// evaluator aplica as regras às linhas de uma série, com uma linha de atraso para que o teste de
+// spike conheça o valor seguinte
+type evaluator struct {
+	columns  []string
+	rules    []Rule
+	lookback time.Duration
+	state    []columnState
+	pending  *sample
+
+	site      *solar.Site // Localização do equipamento, quando a tabela recalcula a geometria solar
+	geometry  []string    // Grandeza da geometria solar de cada coluna (vazia quando não há)
+	tolerance float64
+}
+
+func newEvaluator(columns []string, rules []Rule, lookback time.Duration) *evaluator {
+	return &evaluator{columns: columns, rules: rules, lookback: lookback, state: make([]columnState, len(columns))}
+}
+
+// reset inicia uma nova série (outro nível ou após um intervalo maior que lookback)
+func (e *evaluator) reset() {
+	for i := range e.state {
+		e.state[i] = columnState{}
+	}
+}
+
+// angularDiff retorna a diferença absoluta entre dois valores, no círculo quando circular
+func angularDiff(a, b float64, circular bool) float64 {
+	d := math.Abs(a - b)
+	if circular {
+		d = math.Mod(d, 360)
+		if d > 180 {
+			d = 360 - d
+		}
+	}
+	return d
+}
+
+// evaluation é o resultado de uma linha
+type evaluation struct {
+	sample *sample
+	flags  map[string]Flag
+	tests  map[string][]string
+	worst  Flag // Pior código entre os valores presentes (nulos não contam)
+}
+
+// evaluate avalia s, cujo valor seguinte na série é next (nil quando não há)
+func (e *evaluator) evaluate(s, next *sample) evaluation {
+	ev := evaluation{sample: s, flags: make(map[string]Flag, len(e.columns)), tests: map[string][]string{}, worst: Good}
+	elevation := s.elevation
+	var position solar.Position
+	if e.site != nil {
+		position = solar.Compute(s.timestamp, *e.site)
+		elevation = &position.Elevation
+	}
+	for i, column := range e.columns {
+		rule, st := e.rules[i], &e.state[i]
+		v := s.values[i]
+		if v == nil {
+			ev.flags[column] = Missing
+			st.prev, st.run = nil, 0
+			continue
+		}
+
+		flag := Good
+		fail := func(test string, f Flag) {
+			flag = worse(flag, f)
+			ev.tests[column] = append(ev.tests[column], test)
+		}
+
+		if rule.Min != nil && *v < *rule.Min || rule.Max != nil && *v > *rule.Max {
+			fail("range", Bad)
+		} else if rule.SuspectMin != nil && *v < *rule.SuspectMin || rule.SuspectMax != nil && *v > *rule.SuspectMax {
+			fail("climatology", Suspect)
+		}
+
+		if rule.Step != nil && st.prev != nil && angularDiff(*v, *st.prev, rule.Circular) > *rule.Step {
+			fail("step", Suspect)
+		}
+
+		if rule.Spike != nil && st.prev != nil && next != nil && next.values[i] != nil {
+			prev, following := *st.prev, *next.values[i]
+			spike := math.Abs(*v-(prev+following)/2) - math.Abs(following-prev)/2
+			if spike > 2**rule.Spike {
+				fail("spike", Bad)
+			} else if spike > *rule.Spike {
+				fail("spike", Suspect)
+			}
+		}
+
+		if st.prev != nil && angularDiff(*v, *st.prev, rule.Circular) <= rule.Tolerance {
+			st.run++
+		} else {
+			st.run = 1
+		}
+		if rule.Persistence > 0 && st.run >= rule.Persistence {
+			fail("persistence", Suspect)
+		}
+
+		if e.site != nil && e.geometry[i] != "" && position.Disagrees(e.geometry[i], *v, e.tolerance) {
+			fail("geometry", Suspect)
+		}
+
+		if rule.Solar != "" && elevation != nil {
+			if f := solarFlag(rule.Solar, *v, *elevation, s.timestamp); f != Good {
+				fail("solar", f)
+			}
+		}
+
+		st.prev = v
+		ev.flags[column] = flag
+		ev.worst = worse(ev.worst, flag)
+	}
+	return ev
+}
+
+// push recebe a próxima linha da série e devolve a avaliação da linha anterior, se houver
+func (e *evaluator) push(s *sample) *evaluation {
+	p := e.pending
+	e.pending = s
+	if p == nil {
+		return nil
+	}
+
+	sameSeries := floatEqual(p.level, s.level) && s.timestamp.Sub(p.timestamp) <= e.lookback
+	var next *sample
+	if sameSeries {
+		next = s
+	}
+	ev := e.evaluate(p, next)
+	if !sameSeries {
+		e.reset()
+	}
+	return &ev
+}
+
+// flush avalia a última linha pendente
+func (e *evaluator) flush() *evaluation {
+	p := e.pending
+	e.pending = nil
+	if p == nil {
+		return nil
+	}
+	ev := e.evaluate(p, nil)
+	return &ev
+}
+
+func floatEqual(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// flagSource alimenta o COPY em QCFlags a partir das linhas lidas da tabela de dados. As linhas
+// chegam em lotes por load e as avaliações prontas são entregues ao COPY seguinte.
+type flagSource struct {
+	eval        *evaluator
+	table       string
+	equipmentID pgtype.UUID
+	start, end  time.Time
+	hasLevel    bool
+	hasSun      bool
+	result      *Result
+	queue       []*evaluation
+	values      []interface{}
+	done        bool // O cursor não tem mais linhas
+}
+
+// load avalia um lote de linhas lido do cursor. Um lote menor que fetchSize é o último.
+func (s *flagSource) load(rows pgx.Rows) error {
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return err
+		}
+		n++
+		if ev := s.eval.push(s.read(values)); ev != nil {
+			s.queue = append(s.queue, ev)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if n < fetchSize {
+		s.done = true
+		if ev := s.eval.flush(); ev != nil {
+			s.queue = append(s.queue, ev)
+		}
+	}
+	return nil
+}
+
+// read converte uma linha do cursor em sample
+func (s *flagSource) read(values []interface{}) *sample {
+	smp := &sample{timestamp: values[1].(time.Time), values: make([]*float64, len(s.eval.columns))}
+	if id, ok := values[0].(int64); ok {
+		smp.id = id
+	}
+	offset := 2
+	if s.hasLevel {
+		smp.level = floatValue(values[offset])
+		offset++
+	}
+	if s.hasSun {
+		smp.elevation = floatValue(values[offset])
+		offset++
+	}
+	for i := range smp.values {
+		smp.values[i] = floatValue(values[offset+i])
+	}
+	return smp
+}
+
+func floatValue(v interface{}) *float64 {
+	if f, ok := v.(float64); ok && !math.IsNaN(f) {
+		return &f
+	}
+	return nil
+}
+
+// Next entrega as avaliações do último lote carregado
+func (s *flagSource) Next() bool {
+	for len(s.queue) > 0 {
+		ev := s.queue[0]
+		s.queue = s.queue[1:]
+		// Linhas fora do intervalo servem apenas de contexto para os testes temporais
+		if ev.sample.timestamp.Before(s.start) || ev.sample.timestamp.After(s.end) {
+			continue
+		}
+		s.record(ev)
+		return true
+	}
+	return false
+}
+
+// record prepara os valores do COPY e atualiza as contagens
+func (s *flagSource) record(ev *evaluation) {
+	codes := make(map[string]int16, len(ev.flags))
+	for column, flag := range ev.flags {
+		codes[column] = int16(flag)
+		s.result.Counts[flag.String()]++
+	}
+	var tests interface{}
+	if len(ev.tests) > 0 {
+		tests = ev.tests
+	}
+	s.result.Rows++
+	s.values = []interface{}{s.table, ev.sample.id, s.equipmentID, ev.sample.timestamp, codes, tests, int16(ev.worst)}
+}
+
+func (s *flagSource) Values() ([]interface{}, error) {
+	return s.values, nil
+}
+
+func (s *flagSource) Err() error {
+	return nil
+}
+
+// ruleColumns retorna as colunas numéricas da tabela que possuem regra, na ordem da tabela
+func ruleColumns(ctx context.Context, db *pgxpool.Pool, table string, cfg *Table) ([]string, []Rule, bool, error) {
+	rows, err := db.Query(ctx, `
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		  AND data_type IN ('double precision', 'real', 'integer', 'smallint', 'bigint', 'numeric')
+		ORDER BY ordinal_position`, table)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	var rules []Rule
+	hasSun := false
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, nil, false, err
+		}
+		if name == cfg.SunElevation {
+			hasSun = true
+		}
+		if name == cfg.IDColumn || name == cfg.LevelColumn {
+			continue
+		}
+		if rule, ok := cfg.Rule(name); ok {
+			columns = append(columns, name)
+			rules = append(rules, rule)
+		}
+	}
+	return columns, rules, hasSun && cfg.SunElevation != "", rows.Err()
+}
+
+// equipmentSite retorna a localização do equipamento ou nil quando ela não está cadastrada
+func equipmentSite(ctx context.Context, db *pgxpool.Pool, equipmentID string) (*solar.Site, error) {
+	lat, lon, found, err := store.EquipmentLocation(ctx, db, equipmentID)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &solar.Site{Latitude: lat, Longitude: lon}, nil
+}
+
+// Run avalia as linhas de um equipamento em uma tabela entre start e end (inclusive) e substitui
+// as flags gravadas nesse intervalo. Linhas até Lookback antes e depois do intervalo são lidas como
+// contexto dos testes de step, spike e persistência.
+func Run(ctx context.Context, db *pgxpool.Pool, table, equipmentID string, start, end time.Time) (*Result, error) {
+	cfg := Active
+	tableCfg, ok := cfg.Tables[table]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTable, table)
+	}
+	var uuid pgtype.UUID
+	if err := uuid.Scan(equipmentID); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEquipment, equipmentID)
+	}
+
+	columns, rules, hasSun, err := ruleColumns(ctx, db, table, tableCfg)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Table: table, Counts: map[string]int64{}}
+	if len(columns) == 0 {
+		return result, nil
+	}
+
+	eval := newEvaluator(columns, rules, cfg.Lookback)
+	if len(tableCfg.Geometry) > 0 {
+		if eval.site, err = equipmentSite(ctx, db, equipmentID); err != nil {
+			return nil, err
+		}
+		eval.geometry = make([]string, len(columns))
+		for quantity, column := range tableCfg.Geometry {
+			for i := range columns {
+				if columns[i] == column {
+					eval.geometry[i] = quantity
+				}
+			}
+		}
+		eval.tolerance = tableCfg.GeometryTolerance
+		if eval.tolerance <= 0 {
+			eval.tolerance = solar.DefaultTolerance
+		}
+	}
+
+	// Os nomes vêm de information_schema e da configuração, então podem ser interpolados
+	selectList := []string{tableCfg.IDColumn + "::bigint", "timestamp"}
+	orderBy := "timestamp, " + tableCfg.IDColumn
+	if tableCfg.LevelColumn != "" {
+		selectList = append(selectList, tableCfg.LevelColumn+"::float8")
+		orderBy = tableCfg.LevelColumn + ", " + orderBy
+	}
+	if hasSun {
+		selectList = append(selectList, tableCfg.SunElevation+"::float8")
+	}
+	for _, column := range columns {
+		selectList = append(selectList, column+"::float8")
+	}
+
+	// A leitura e a gravação usam a mesma conexão: as linhas vêm de um cursor da transação em lotes,
+	// alternando FETCH e COPY
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+		DECLARE qc_rows NO SCROLL CURSOR FOR
+		SELECT %s FROM %s WHERE equipmentid = $1::uuid AND timestamp >= $2::timestamptz AND timestamp <= $3::timestamptz ORDER BY %s`,
+		strings.Join(selectList, ", "), table, orderBy), uuid, start.Add(-cfg.Lookback), end.Add(cfg.Lookback))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM qcflags WHERE datatable = $1 AND equipmentid = $2 AND timestamp >= $3 AND timestamp <= $4",
+		table, uuid, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &flagSource{
+		eval: eval, table: table, equipmentID: uuid,
+		start: start, end: end, hasLevel: tableCfg.LevelColumn != "", hasSun: hasSun, result: result,
+	}
+	for !source.done {
+		rows, err := tx.Query(ctx, fmt.Sprintf("FETCH %d FROM qc_rows", fetchSize))
+		if err != nil {
+			return nil, err
+		}
+		if err := source.load(rows); err != nil {
+			return nil, err
+		}
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"qcflags"},
+			[]string{"datatable", "rowid", "equipmentid", "timestamp", "flags", "tests", "worstflag"}, source)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,263 @@
+package qc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"api/internal/solar"
+)
+
+// Rule configura os testes aplicados a uma coluna. Campos nulos ou zerados desativam o teste.
+type Rule struct {
+	Min         *float64 `json:"min,omitempty"`         // Limite físico inferior (abaixo: bad)
+	Max         *float64 `json:"max,omitempty"`         // Limite físico superior (acima: bad)
+	SuspectMin  *float64 `json:"suspect_min,omitempty"` // Limite climatológico inferior (abaixo: suspect)
+	SuspectMax  *float64 `json:"suspect_max,omitempty"` // Limite climatológico superior (acima: suspect)
+	Step        *float64 `json:"step,omitempty"`        // Variação máxima em relação ao valor anterior (acima: suspect)
+	Spike       *float64 `json:"spike,omitempty"`       // Desvio máximo em relação aos vizinhos (acima: suspect; o dobro: bad)
+	Persistence int      `json:"persistence,omitempty"` // Valores iguais consecutivos a partir dos quais o sensor é considerado travado
+	Tolerance   float64  `json:"tolerance,omitempty"`   // Diferença tolerada entre valores "iguais" no teste de persistência
+	Circular    bool     `json:"circular,omitempty"`    // Direção em graus: diferenças calculadas no círculo
+	Solar       string   `json:"solar,omitempty"`       // "ghi" ou "dni": comparação com a irradiância extraterrestre
+}
+
+// Table configura a avaliação de uma tabela de dados
+type Table struct {
+	IDColumn     string          `json:"id_column"`               // Chave da linha, gravada em QCFlags.RowID
+	LevelColumn  string          `json:"level_column,omitempty"`  // Altura/célula: cada nível é uma série independente
+	SunElevation string          `json:"sun_elevation,omitempty"` // Coluna com a elevação solar (°), usada pelos testes solares
+	Rules        map[string]Rule `json:"rules"`                   // Por coluna; aceita padrões de path.Match (ex.: "windspeed_*m")
+
+	// Geometry associa as grandezas da geometria solar (azimuth, elevation, hour_angle, declination,
+	// air_mass) às colunas gravadas pelo datalogger. Com a localização do equipamento, a posição do sol
+	// é recalculada: divergências acima de GeometryTolerance (°) são marcadas como suspect (teste
+	// "geometry") e os testes solares passam a usar a elevação calculada.
+	Geometry          map[string]string `json:"geometry,omitempty"`
+	GeometryTolerance float64           `json:"geometry_tolerance,omitempty"` // Padrão: solar.DefaultTolerance
+}
+
+// Config reúne as tabelas avaliadas
+type Config struct {
+	Lookback time.Duration     `json:"-"`
+	Tables   map[string]*Table `json:"tables"`
+}
+
+// configFile é o formato do arquivo JSON de configuração
+type configFile struct {
+	Lookback string            `json:"lookback"` // Duração Go (ex.: "6h")
+	Tables   map[string]*Table `json:"tables"`
+}
+
+// Active é a configuração usada pela avaliação; pode ser substituída na inicialização
+var Active = DefaultConfig()
+
+// LoadConfig lê um arquivo JSON de configuração. As tabelas do arquivo substituem as da
+// configuração padrão; as demais são mantidas.
+func LoadConfig(file string) (*Config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var raw configFile
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("qc: configuração inválida em %s: %w", file, err)
+	}
+
+	cfg := DefaultConfig()
+	if raw.Lookback != "" {
+		if cfg.Lookback, err = time.ParseDuration(raw.Lookback); err != nil {
+			return nil, fmt.Errorf("qc: lookback inválido: %w", err)
+		}
+	}
+	for name, table := range raw.Tables {
+		if table.IDColumn == "" {
+			return nil, fmt.Errorf("qc: id_column ausente na tabela %s", name)
+		}
+		for pattern, rule := range table.Rules {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("qc: padrão inválido %q na tabela %s", pattern, name)
+			}
+			if rule.Solar != "" && rule.Solar != "ghi" && rule.Solar != "dni" {
+				return nil, fmt.Errorf("qc: teste solar inválido %q na tabela %s", rule.Solar, name)
+			}
+		}
+		for quantity := range table.Geometry {
+			if _, ok := (solar.Position{}).Value(quantity); !ok {
+				return nil, fmt.Errorf("qc: grandeza de geometria solar inválida %q na tabela %s", quantity, name)
+			}
+		}
+		cfg.Tables[name] = table
+	}
+	return cfg, nil
+}
+
+// Rule retorna a regra da coluna: primeiro pelo nome exato, depois pelo primeiro padrão que corresponder
+func (t *Table) Rule(column string) (Rule, bool) {
+	if rule, ok := t.Rules[column]; ok {
+		return rule, true
+	}
+	patterns := make([]string, 0, len(t.Rules))
+	for pattern := range t.Rules {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, column); ok {
+			return t.Rules[pattern], true
+		}
+	}
+	return Rule{}, false
+}
+
+// Construtores usados na configuração padrão
+
+func value(v float64) *float64 { return &v }
+
+func limits(min, max float64) Rule { return Rule{Min: value(min), Max: value(max)} }
+
+func (r Rule) suspect(min, max float64) Rule {
+	r.SuspectMin, r.SuspectMax = value(min), value(max)
+	return r
+}
+
+func (r Rule) step(v float64) Rule { r.Step = value(v); return r }
+
+func (r Rule) spike(v float64) Rule { r.Spike = value(v); return r }
+
+func (r Rule) persistence(n int, tolerance float64) Rule {
+	r.Persistence, r.Tolerance = n, tolerance
+	return r
+}
+
+func (r Rule) circular() Rule { r.Circular = true; return r }
+
+func (r Rule) solar(kind string) Rule { r.Solar = kind; return r }
+
+// DefaultConfig retorna os limites padrão das tabelas de dados de instrumentos
+func DefaultConfig() *Config {
+	windSpeed := limits(0, 75).suspect(0, 40).step(20).persistence(30, 0)
+	windDirection := limits(0, 360).circular().persistence(30, 0)
+	airTemperature := limits(-40, 60).suspect(-5, 45).step(5).persistence(60, 0)
+	humidity := limits(0, 105).suspect(2, 100).step(25).persistence(120, 0)
+	pressure := limits(500, 1100).suspect(850, 1060).step(5)
+	irradiance := limits(-4, 2000)
+
+	return &Config{
+		Lookback: 6 * time.Hour,
+		Tables: map[string]*Table{
+			"estacaosolarimetricadados": {
+				IDColumn: "estacaosolarimetricadadosid", SunElevation: "sunelevation",
+				Rules: map[string]Rule{
+					"battv":                     limits(0, 30).suspect(11, 15),
+					"ptemp_c":                   limits(-40, 80).suspect(-5, 60),
+					"winddir":                   windDirection,
+					"ws_ms_avg":                 windSpeed,
+					"ws_ms_max":                 limits(0, 90).suspect(0, 60),
+					"ws_ms_min":                 limits(0, 75),
+					"airtc_*":                   airTemperature,
+					"rh*":                       humidity,
+					"rain_mm_tot":               limits(0, 100).suspect(0, 50),
+					"bp_mbar_*":                 pressure,
+					"slrw_cmp10_horizontal_avg": irradiance.solar("ghi"),
+					"slrw_cmp10_*":              irradiance,
+					"slrw_chp1_avg":             limits(-4, 1500).solar("dni"),
+					"slrw_chp1_*":               limits(-4, 1500),
+					"sunelevation":              limits(-90, 90),
+					"solarazimuth":              limits(0, 360).circular(),
+					"declination":               limits(-24, 24),
+					"hourangle":                 {},
+					"airmass":                   {},
+				},
+				Geometry: map[string]string{
+					solar.Azimuth: "solarazimuth", solar.Elevation: "sunelevation", solar.HourAngle: "hourangle",
+					solar.Declination: "declination", solar.AirMass: "airmass",
+				},
+			},
+			"lidarwindcubedados": {
+				IDColumn: "lidarwindcubedadosid",
+				Rules: map[string]Rule{
+					"inttemp":             limits(-40, 70),
+					"exttemp":             airTemperature,
+					"pressure":            pressure,
+					"relhumidity":         humidity,
+					"vbatt":               limits(0, 40).suspect(10, 30),
+					"windspeed_*m":        windSpeed.spike(10),
+					"windspeedmin_*m":     limits(0, 75),
+					"windspeedmax_*m":     limits(0, 90).suspect(0, 60),
+					"winddirection_*m":    windDirection,
+					"zwind_*m":            limits(-15, 15).suspect(-5, 5),
+					"cnr_*m":              limits(-60, 20).suspect(-35, 10),
+					"dataavailability_*m": limits(0, 100),
+				},
+			},
+			"sodardados": {
+				IDColumn: "sodardadosid", LevelColumn: "height",
+				Rules: map[string]Rule{
+					"windspeed":           windSpeed.spike(10),
+					"winddirection":       windDirection,
+					"w":                   limits(-10, 10).suspect(-3, 3),
+					"turbulenceintensity": limits(0, 5).suspect(0, 1),
+				},
+			},
+			"adcpdados": {
+				IDColumn: "adcpdadosid", LevelColumn: "cell",
+				Rules: map[string]Rule{
+					"velocity*":        limits(-10, 10).suspect(-4, 4).spike(1),
+					"currentspeed":     limits(0, 10).suspect(0, 4).spike(1),
+					"currentdirection": limits(0, 360).circular(),
+					"correlation*":     limits(0, 255).suspect(64, 255),
+					"echointensity*":   limits(0, 255),
+					"percentgood*":     limits(0, 100).suspect(25, 100),
+					"heading":          limits(0, 360).circular(),
+					"pitch":            limits(-90, 90).suspect(-20, 20),
+					"roll":             limits(-180, 180).suspect(-20, 20),
+					"watertemperature": limits(-5, 45).suspect(-2, 35).step(3),
+					"salinity":         limits(0, 45),
+					"pressure":         limits(-1, 7000),
+					"speedofsound":     limits(1400, 1600),
+				},
+			},
+			"sodardata": {
+				IDColumn: "id",
+				Rules: map[string]Rule{
+					"windspeed": windSpeed, "winddirection": windDirection,
+					"temperature": airTemperature, "humidity": humidity,
+				},
+			},
+			"towermicrometeorologicaldata": {
+				IDColumn: "id",
+				Rules: map[string]Rule{
+					"windspeed": windSpeed, "winddirection": windDirection,
+					"temperature": airTemperature, "humidity": humidity,
+					"solarradiation": irradiance, "barometricpressure": pressure,
+				},
+			},
+			"lidarzephydata": {
+				IDColumn: "id",
+				Rules: map[string]Rule{
+					"windspeed": windSpeed, "winddirection": windDirection, "temperature": airTemperature,
+				},
+			},
+			"lidarwindcobedata": {
+				IDColumn: "id",
+				Rules: map[string]Rule{
+					"windspeed": windSpeed, "winddirection": windDirection, "pressure": pressure,
+				},
+			},
+			"adcpdata": {
+				IDColumn: "id",
+				Rules: map[string]Rule{
+					"watercurrentspeed":     limits(0, 10).suspect(0, 4),
+					"watercurrentdirection": limits(0, 360).circular(),
+					"watertemperature":      limits(-5, 45).suspect(-2, 35),
+					"salinity":              limits(0, 45),
+					"depth":                 limits(0, 11000),
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,43 @@
+package qc
+
+import (
+	"math"
+	"time"
+
+	"api/internal/solar"
+)
+
+// solarTolerance absorve o offset térmico dos piranômetros na comparação com a irradiância extraterrestre
+const solarTolerance = 10.0
+
+// solarFlag compara uma irradiância com os limites derivados da elevação solar (°).
+// GHI: suspect acima da irradiância extraterrestre horizontal e bad acima do limite "fisicamente
+// possível" do BSRN. DNI: suspect acima do limite "extremamente raro" do BSRN e bad acima da
+// irradiância extraterrestre normal.
+func solarFlag(kind string, v, elevation float64, t time.Time) Flag {
+	normal := solar.ExtraterrestrialNormal(t)
+	mu0 := math.Sin(elevation * math.Pi / 180)
+	if mu0 < 0 {
+		mu0 = 0
+	}
+
+	switch kind {
+	case "ghi":
+		if v > normal*1.5*math.Pow(mu0, 1.2)+100 {
+			return Bad
+		}
+		if v > normal*mu0+solarTolerance {
+			return Suspect
+		}
+	case "dni":
+		if v > normal {
+			return Bad
+		}
+		if v > normal*0.95*math.Pow(mu0, 0.2)+10 {
+			return Suspect
+		}
+	default:
+		return NotEvaluated
+	}
+	return Good
+}
@@ -0,0 +1,49 @@
+package solar
+
+import "math"
+
+// DefaultLinkeTurbidity é a turbidez de Linke usada quando não há climatologia do local
+const DefaultLinkeTurbidity = 3.0
+
+// ClearSky é a irradiância de céu claro (W/m²)
+type ClearSky struct {
+	GHI float64 // Global horizontal
+	DNI float64 // Direta normal
+	DHI float64 // Difusa horizontal
+}
+
+// Haurwitz retorna a irradiância global de céu claro pelo modelo de Haurwitz (1945), que depende
+// apenas do ângulo zenital. DNI e DHI não são estimadas (NaN).
+func Haurwitz(p Position) ClearSky {
+	mu := cos(p.Zenith)
+	if mu <= 0 {
+		return ClearSky{DNI: math.NaN(), DHI: math.NaN()}
+	}
+	return ClearSky{GHI: 1098 * mu * math.Exp(-0.057/mu), DNI: math.NaN(), DHI: math.NaN()}
+}
+
+// Ineichen retorna a irradiância de céu claro pelo modelo de Ineichen e Perez (2002), com a turbidez
+// de Linke linke e a altitude do local (m)
+func Ineichen(p Position, linke, altitude float64) ClearSky {
+	mu := cos(p.Zenith)
+	if mu <= 0 || math.IsNaN(p.AirMass) {
+		return ClearSky{}
+	}
+	// Massa de ar absoluta: correção da relativa pela pressão padrão na altitude
+	am := p.AirMass * math.Exp(-altitude/8434.5)
+	i0 := p.Extraterrestrial()
+
+	fh1 := math.Exp(-altitude / 8000)
+	fh2 := math.Exp(-altitude / 1250)
+	cg1 := 5.09e-5*altitude + 0.868
+	cg2 := 3.92e-5*altitude + 0.0387
+
+	ghi := cg1 * i0 * mu * math.Max(math.Exp(-cg2*am*(fh1+fh2*(linke-1))), 0)
+
+	b := 0.664 + 0.163/fh1
+	bnci := i0 * math.Max(b*math.Exp(-0.09*am*(linke-1)), 0)
+	bnci2 := ghi * math.Min(math.Max((1-(0.1-0.2*math.Exp(-linke))/(0.1+0.882/fh1))/mu, 0), 1e20)
+	dni := math.Min(bnci, bnci2)
+
+	return ClearSky{GHI: ghi, DNI: dni, DHI: ghi - dni*mu}
+}
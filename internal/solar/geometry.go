@@ -0,0 +1,69 @@
+package solar
+
+import "math"
+
+// Grandezas da geometria solar gravadas pelos dataloggers
+const (
+	Azimuth     = "azimuth"
+	Elevation   = "elevation"
+	HourAngle   = "hour_angle"
+	Declination = "declination"
+	AirMass     = "air_mass"
+)
+
+// DefaultTolerance é a diferença angular tolerada (°) entre a geometria gravada e a calculada. O sol
+// se move até 0,25°/min, então a tolerância absorve alguns minutos de diferença entre o instante do
+// cálculo do datalogger e o timestamp gravado.
+const DefaultTolerance = 2.0
+
+// airMassTolerance é a diferença relativa tolerada na massa de ar, comparada apenas com o sol acima
+// de airMassMinElevation, onde ela é pouco sensível à refração
+const (
+	airMassTolerance    = 0.05
+	airMassMinElevation = 10.0
+)
+
+// angularDiff retorna a diferença absoluta entre dois ângulos no círculo (°)
+func angularDiff(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 360)
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
+
+// Value retorna a grandeza da posição pelo nome; ok é falso para nomes desconhecidos
+func (p Position) Value(quantity string) (float64, bool) {
+	switch quantity {
+	case Azimuth:
+		return p.Azimuth, true
+	case Elevation:
+		return p.Elevation, true
+	case HourAngle:
+		return p.HourAngle, true
+	case Declination:
+		return p.Declination, true
+	case AirMass:
+		return p.AirMass, true
+	}
+	return 0, false
+}
+
+// Disagrees indica se o valor gravado de uma grandeza diverge da posição calculada. Azimute e ângulo
+// horário são comparados no círculo; a massa de ar, em diferença relativa.
+func (p Position) Disagrees(quantity string, logged, tolerance float64) bool {
+	computed, ok := p.Value(quantity)
+	if !ok || math.IsNaN(logged) {
+		return false
+	}
+	switch quantity {
+	case Azimuth, HourAngle:
+		return angularDiff(logged, computed) > tolerance
+	case AirMass:
+		if p.Elevation < airMassMinElevation {
+			return false
+		}
+		return math.Abs(logged-computed) > airMassTolerance*computed
+	}
+	return math.Abs(logged-computed) > tolerance
+}
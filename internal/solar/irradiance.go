@@ -0,0 +1,163 @@
+package solar
+
+import "math"
+
+// Fontes da irradiância difusa na separação das componentes
+const (
+	DiffuseClosure = "closure" // DHI = GHI − DNI·cos(z), com DNI medida
+	DiffuseErbs    = "erbs"    // Modelo de decomposição de Erbs et al. (1982)
+)
+
+// Components são as componentes da irradiância no plano horizontal (W/m²)
+type Components struct {
+	GHI    float64
+	DNI    float64
+	DHI    float64
+	Source string // DiffuseClosure ou DiffuseErbs
+}
+
+// cosZenith retorna o cosseno do ângulo zenital, nulo com o sol abaixo do horizonte
+func cosZenith(p Position) float64 {
+	return math.Max(cos(p.Zenith), 0)
+}
+
+// Closure separa a irradiância pela relação de fechamento DHI = GHI − DNI·cos(z). A difusa negativa
+// (DNI incoerente com a GHI) é limitada a zero.
+func Closure(ghi, dni float64, p Position) Components {
+	return Components{GHI: ghi, DNI: dni, DHI: math.Max(ghi-dni*cosZenith(p), 0), Source: DiffuseClosure}
+}
+
+// ClearnessIndex retorna kt, a razão entre a GHI e a irradiância extraterrestre horizontal
+func ClearnessIndex(ghi float64, p Position) float64 {
+	mu := cosZenith(p)
+	if mu <= 0 {
+		return 0
+	}
+	return ghi / (p.Extraterrestrial() * mu)
+}
+
+// Erbs separa a GHI em direta e difusa pela fração difusa do modelo de Erbs et al. (1982), usado
+// quando a DNI medida não está disponível
+func Erbs(ghi float64, p Position) Components {
+	mu := cosZenith(p)
+	if mu <= 0 || ghi <= 0 {
+		return Components{GHI: ghi, DHI: math.Max(ghi, 0), Source: DiffuseErbs}
+	}
+	kt := math.Max(0, math.Min(1, ClearnessIndex(ghi, p)))
+	var kd float64
+	switch {
+	case kt <= 0.22:
+		kd = 1 - 0.09*kt
+	case kt <= 0.8:
+		kd = 0.9511 - 0.1604*kt + 4.388*kt*kt - 16.638*kt*kt*kt + 12.336*kt*kt*kt*kt
+	default:
+		kd = 0.165
+	}
+	dhi := kd * ghi
+	return Components{GHI: ghi, DNI: (ghi - dhi) / mu, DHI: dhi, Source: DiffuseErbs}
+}
+
+// Surface é a orientação de um plano
+type Surface struct {
+	Tilt    float64 `json:"tilt"`    // Inclinação em relação à horizontal (°)
+	Azimuth float64 `json:"azimuth"` // Azimute da normal a partir do norte, no sentido horário (°)
+}
+
+// CosIncidence retorna o cosseno do ângulo de incidência do feixe direto no plano (negativo quando o
+// sol está atrás do plano)
+func (s Surface) CosIncidence(p Position) float64 {
+	return cos(p.Zenith)*cos(s.Tilt) + sin(p.Zenith)*sin(s.Tilt)*cos(p.Azimuth-s.Azimuth)
+}
+
+// PlaneOfArray é a irradiância no plano inclinado (W/m²)
+type PlaneOfArray struct {
+	Global        float64 `json:"global"`
+	Beam          float64 `json:"beam"`
+	SkyDiffuse    float64 `json:"sky_diffuse"`
+	GroundDiffuse float64 `json:"ground_diffuse"`
+}
+
+// Modelos de transposição
+const (
+	HayDaviesModel = "haydavies"
+	PerezModel     = "perez"
+)
+
+// Transpose calcula a irradiância no plano pelo modelo de difusa do céu indicado (HayDaviesModel ou
+// PerezModel), com o albedo do solo
+func Transpose(model string, s Surface, p Position, c Components, albedo float64) PlaneOfArray {
+	poa := PlaneOfArray{
+		Beam:          c.DNI * math.Max(s.CosIncidence(p), 0),
+		GroundDiffuse: c.GHI * albedo * (1 - cos(s.Tilt)) / 2,
+	}
+	if model == PerezModel {
+		poa.SkyDiffuse = perezSky(s, p, c)
+	} else {
+		poa.SkyDiffuse = hayDaviesSky(s, p, c)
+	}
+	poa.Global = poa.Beam + poa.SkyDiffuse + poa.GroundDiffuse
+	return poa
+}
+
+// beamRatio retorna a razão entre o cosseno de incidência e o do zênite, com o zênite limitado a 85°
+func beamRatio(s Surface, p Position) float64 {
+	return math.Max(s.CosIncidence(p), 0) / math.Max(cos(p.Zenith), cos(85))
+}
+
+// hayDaviesSky é a difusa do céu pelo modelo de Hay e Davies (1980): parcela circunsolar proporcional
+// ao índice de anisotropia DNI/I0 e o restante isotrópico
+func hayDaviesSky(s Surface, p Position, c Components) float64 {
+	anisotropy := math.Max(0, math.Min(1, c.DNI/p.Extraterrestrial()))
+	return c.DHI * (anisotropy*beamRatio(s, p) + (1-anisotropy)*(1+cos(s.Tilt))/2)
+}
+
+// Coeficientes do modelo de Perez et al. (1990), conjunto "allsitescomposite1990", por faixa de
+// claridade do céu (epsilon)
+var (
+	perezEpsilonBins = []float64{1.065, 1.23, 1.5, 1.95, 2.8, 4.5, 6.2}
+	perezF1          = [8][3]float64{
+		{-0.0083117, 0.5877285, -0.0620636},
+		{0.1299457, 0.6825954, -0.1513752},
+		{0.3296958, 0.4868735, -0.2210958},
+		{0.5682053, 0.1874525, -0.295129},
+		{0.873028, -0.3920403, -0.3616149},
+		{1.1326077, -1.2367284, -0.4118494},
+		{1.0601591, -1.5999137, -0.3589221},
+		{0.677747, -0.3272588, -0.2504286},
+	}
+	perezF2 = [8][3]float64{
+		{-0.0596012, 0.0721249, -0.0220216},
+		{-0.0189325, 0.065965, -0.0288748},
+		{0.055414, -0.0639588, -0.0260542},
+		{0.1088631, -0.1519229, -0.0139754},
+		{0.2255647, -0.4620442, 0.0012448},
+		{0.2877813, -0.8230357, 0.0558651},
+		{0.2642124, -1.127234, 0.1310694},
+		{0.1561313, -1.3765031, 0.2506212},
+	}
+)
+
+// perezSky é a difusa do céu pelo modelo de Perez et al. (1990), com componentes circunsolar e de
+// horizonte dependentes da claridade (epsilon) e do brilho (delta) do céu
+func perezSky(s Surface, p Position, c Components) float64 {
+	if c.DHI <= 0 || math.IsNaN(p.AirMass) {
+		return 0
+	}
+	z := p.Zenith * math.Pi / 180
+	const kappa = 1.041
+	epsilon := ((c.DHI+c.DNI)/c.DHI + kappa*z*z*z) / (1 + kappa*z*z*z)
+	delta := c.DHI * p.AirMass / p.Extraterrestrial()
+
+	bin := len(perezEpsilonBins)
+	for i, edge := range perezEpsilonBins {
+		if epsilon < edge {
+			bin = i
+			break
+		}
+	}
+	f1 := math.Max(0, perezF1[bin][0]+perezF1[bin][1]*delta+perezF1[bin][2]*z)
+	f2 := perezF2[bin][0] + perezF2[bin][1]*delta + perezF2[bin][2]*z
+
+	sky := c.DHI * ((1-f1)*(1+cos(s.Tilt))/2 + f1*beamRatio(s, p) + f2*sin(s.Tilt))
+	return math.Max(sky, 0)
+}
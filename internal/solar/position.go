@@ -0,0 +1,150 @@
+// Package solar calcula a posição do sol e a irradiância de céu claro para os dados das estações
+// solarimétricas. A posição segue o algoritmo da NOAA (Meeus), com erro da ordem de 0,02° entre
+// 1800 e 2100, suficiente para verificar a geometria gravada pelos dataloggers.
+package solar
+
+import (
+	"math"
+	"time"
+)
+
+// SolarConstant é a irradiância solar total média no topo da atmosfera (W/m²)
+const SolarConstant = 1361.0
+
+// Site é a localização de um equipamento
+type Site struct {
+	Latitude  float64 // Graus, positivo ao norte
+	Longitude float64 // Graus, positivo a leste
+	Altitude  float64 // Metros acima do nível do mar
+}
+
+// Position é a posição aparente do sol em um instante e local
+type Position struct {
+	Zenith         float64 // Ângulo zenital aparente, corrigido pela refração (°)
+	Elevation      float64 // Elevação aparente, corrigida pela refração (°)
+	Azimuth        float64 // Azimute a partir do norte, no sentido horário (°)
+	HourAngle      float64 // Ângulo horário, negativo pela manhã (°)
+	Declination    float64 // Declinação solar (°)
+	EquationOfTime float64 // Equação do tempo (minutos)
+	Distance       float64 // Distância Terra-Sol (UA)
+	AirMass        float64 // Massa de ar relativa (Kasten e Young, 1989); NaN com o sol abaixo do horizonte
+}
+
+func sin(deg float64) float64 { return math.Sin(deg * math.Pi / 180) }
+func cos(deg float64) float64 { return math.Cos(deg * math.Pi / 180) }
+func tan(deg float64) float64 { return math.Tan(deg * math.Pi / 180) }
+
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
+
+// asin e acos limitam o argumento a [-1, 1] para absorver erros de arredondamento
+func asin(x float64) float64 { return degrees(math.Asin(math.Max(-1, math.Min(1, x)))) }
+func acos(x float64) float64 { return degrees(math.Acos(math.Max(-1, math.Min(1, x)))) }
+
+func mod(x, m float64) float64 {
+	x = math.Mod(x, m)
+	if x < 0 {
+		x += m
+	}
+	return x
+}
+
+// orbit guarda as grandezas do sol que dependem apenas do instante
+type orbit struct {
+	declination    float64
+	equationOfTime float64
+	distance       float64
+}
+
+func computeOrbit(t time.Time) orbit {
+	jd := float64(t.UnixNano())/86400e9 + 2440587.5
+	jc := (jd - 2451545) / 36525
+
+	meanLong := mod(280.46646+jc*(36000.76983+jc*0.0003032), 360)
+	meanAnom := 357.52911 + jc*(35999.05029-0.0001537*jc)
+	ecc := 0.016708634 - jc*(0.000042037+0.0000001267*jc)
+	center := sin(meanAnom)*(1.914602-jc*(0.004817+0.000014*jc)) + sin(2*meanAnom)*(0.019993-0.000101*jc) + sin(3*meanAnom)*0.000289
+	trueLong := meanLong + center
+	trueAnom := meanAnom + center
+	distance := 1.000001018 * (1 - ecc*ecc) / (1 + ecc*cos(trueAnom))
+
+	omega := 125.04 - 1934.136*jc
+	appLong := trueLong - 0.00569 - 0.00478*sin(omega)
+	meanObliq := 23 + (26+(21.448-jc*(46.815+jc*(0.00059-jc*0.001813)))/60)/60
+	obliq := meanObliq + 0.00256*cos(omega)
+	declination := asin(sin(obliq) * sin(appLong))
+
+	y := tan(obliq/2) * tan(obliq/2)
+	eot := 4 * degrees(y*sin(2*meanLong)-2*ecc*sin(meanAnom)+4*ecc*y*sin(meanAnom)*cos(2*meanLong)-
+		0.5*y*y*sin(4*meanLong)-1.25*ecc*ecc*sin(2*meanAnom))
+
+	return orbit{declination: declination, equationOfTime: eot, distance: distance}
+}
+
+// refraction retorna a correção de refração atmosférica (°) para a elevação geométrica e (°)
+func refraction(e float64) float64 {
+	var arcsec float64
+	switch {
+	case e > 85:
+		return 0
+	case e > 5:
+		t := tan(e)
+		arcsec = 58.1/t - 0.07/(t*t*t) + 0.000086/math.Pow(t, 5)
+	case e > -0.575:
+		arcsec = 1735 + e*(-518.2+e*(103.4+e*(-12.79+e*0.711)))
+	default:
+		arcsec = -20.772 / tan(e)
+	}
+	return arcsec / 3600
+}
+
+// RelativeAirMass retorna a massa de ar relativa (Kasten e Young, 1989) para o ângulo zenital aparente (°)
+func RelativeAirMass(zenith float64) float64 {
+	if zenith >= 90 {
+		return math.NaN()
+	}
+	return 1 / (cos(zenith) + 0.50572*math.Pow(96.07995-zenith, -1.6364))
+}
+
+// Compute calcula a posição do sol no instante t para o local site
+func Compute(t time.Time, site Site) Position {
+	o := computeOrbit(t)
+	utc := t.UTC()
+	minutes := float64(utc.Hour()*60+utc.Minute()) + (float64(utc.Second())+float64(utc.Nanosecond())/1e9)/60
+	trueSolarTime := mod(minutes+o.equationOfTime+4*site.Longitude, 1440)
+	hourAngle := trueSolarTime/4 - 180
+
+	zenith := acos(sin(site.Latitude)*sin(o.declination) + cos(site.Latitude)*cos(o.declination)*cos(hourAngle))
+	elevation := 90 - zenith
+
+	var azimuth float64
+	if s := sin(zenith); s == 0 {
+		// Sol no zênite ou no nadir: azimute indefinido
+		azimuth = 180
+	} else {
+		azimuth = acos((sin(site.Latitude)*cos(zenith) - sin(o.declination)) / (cos(site.Latitude) * s))
+		if hourAngle > 0 {
+			azimuth = mod(azimuth+180, 360)
+		} else {
+			azimuth = mod(540-azimuth, 360)
+		}
+	}
+
+	elevation += refraction(elevation)
+	return Position{
+		Zenith: 90 - elevation, Elevation: elevation, Azimuth: azimuth, HourAngle: hourAngle,
+		Declination: o.declination, EquationOfTime: o.equationOfTime, Distance: o.distance,
+		AirMass: RelativeAirMass(90 - elevation),
+	}
+}
+
+// ExtraterrestrialNormal retorna a irradiância extraterrestre normal ao feixe no instante t (W/m²),
+// corrigida pela distância Terra-Sol
+func ExtraterrestrialNormal(t time.Time) float64 {
+	d := computeOrbit(t).distance
+	return SolarConstant / (d * d)
+}
+
+// Extraterrestrial retorna a irradiância extraterrestre normal ao feixe na posição p (W/m²)
+func (p Position) Extraterrestrial() float64 {
+	return SolarConstant / (p.Distance * p.Distance)
+}
@@ -0,0 +1,23 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EquipmentLocation retorna a latitude e a longitude (°) da coluna Location de um equipamento.
+// found é falso quando o equipamento não existe ou não tem localização.
+func EquipmentLocation(ctx context.Context, db *pgxpool.Pool, equipmentID string) (lat, lon float64, found bool, err error) {
+	err = db.QueryRow(ctx, `SELECT ST_Y(location::geometry), ST_X(location::geometry)
+		FROM equipments WHERE equipmentid = $1::uuid AND location IS NOT NULL`, equipmentID).Scan(&lat, &lon)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, 0, false, nil
+	}
+	if err != nil {
+		return 0, 0, false, err
+	}
+	return lat, lon, true, nil
+}
@@ -0,0 +1,200 @@
+package stream
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// maxCheckpoints limita os checkpoints aguardando escritas em andamento; ao excedê-lo, o mais
+	// antigo é descartado (os seguintes têm valores maiores, então só a entrega atrasa)
+	maxCheckpoints = 64
+
+	// MaxWait é o maior tempo que um Horizon espera uma escrita em andamento na tabela. Depois dele o
+	// horizonte avança mesmo assim: as linhas que essa escrita gravar com IDs já ultrapassados não são
+	// entregues pelos streams, que recebem um "reset" para recarregar a série pelas rotas de listagem.
+	MaxWait = 2 * time.Minute
+)
+
+// queryer é satisfeito por *pgxpool.Pool e *pgx.Conn
+type queryer interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// checkpoint guarda o último valor alocado pela sequência e as transações que, logo depois dessa
+// leitura, escreviam na tabela: só elas podem ter alocado valores até ele sem tê-los confirmado
+type checkpoint struct {
+	value   int64
+	writers map[string]bool // Transações virtuais ("3/1207"), como em pg_locks
+	taken   time.Time
+}
+
+// Horizon acompanha até onde os IDs gerados pela sequência de uma tabela são definitivos. Um ID
+// alocado por uma transação ainda aberta só fica visível quando ela termina, possivelmente depois de
+// IDs maiores de transações já confirmadas; um cursor que avançasse pelo maior ID visível perderia
+// essas linhas. INSERT, UPDATE e COPY obtêm RowExclusiveLock na tabela antes de chamar nextval e só o
+// liberam depois do commit, então o valor de um checkpoint é definitivo quando nenhuma das transações
+// que tinham esse lock na hora da leitura o mantém. Transações em outras tabelas não atrasam o
+// horizonte; escritas na tabela que durem mais que MaxWait, sim, até esse limite.
+//
+// Um Horizon é compartilhado por todos os streams da tabela nesta réplica: o Hub o consulta uma vez
+// por aviso ou por segundo, enquanto houver checkpoints pendentes, e acorda as assinaturas quando os
+// avisos que recebeu passam a ser definitivos.
+type Horizon struct {
+	table    string
+	sequence string
+
+	mu       sync.Mutex
+	safe     int64
+	skips    int
+	pending  []checkpoint
+	wakes    map[string]int64 // Equipamentos avisados e o valor que precisa ser definitivo para acordá-los
+	incoming map[string]bool  // Equipamentos avisados desde o último checkpoint
+}
+
+// newHorizon cria o Horizon da tabela, cujos IDs são gerados por sequence
+func newHorizon(table, sequence string) *Horizon {
+	return &Horizon{table: table, sequence: sequence, wakes: map[string]int64{}, incoming: map[string]bool{}}
+}
+
+// Position retorna o maior ID até o qual todas as linhas da tabela já estão visíveis ou nunca estarão
+// (transações desfeitas) e quantas vezes o horizonte avançou por MaxWait, descartando essa garantia
+func (h *Horizon) Position() (int64, int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.safe, h.skips
+}
+
+// Allocated retorna o último valor alocado pela sequência, usado como cursor inicial de quem não tem
+// cursor: linhas de transações em andamento com IDs até ele são tratadas como anteriores ao stream
+func (h *Horizon) Allocated(ctx context.Context, db queryer) (int64, error) {
+	var value int64
+	err := db.QueryRow(ctx, "SELECT COALESCE(pg_sequence_last_value($1::regclass), 0)", h.sequence).Scan(&value)
+	return value, err
+}
+
+// notify registra um aviso para o equipamento; ele é acordado depois do próximo checkpoint definitivo
+func (h *Horizon) notify(equipmentID string) {
+	h.mu.Lock()
+	h.incoming[equipmentID] = true
+	h.mu.Unlock()
+}
+
+// idle indica que não há checkpoints nem avisos aguardando
+func (h *Horizon) idle() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.pending) == 0 && len(h.wakes) == 0 && len(h.incoming) == 0
+}
+
+// poll registra um checkpoint, consolida os definitivos e retorna os equipamentos a acordar. skipped
+// indica que o horizonte avançou por MaxWait e todas as assinaturas da tabela precisam ser acordadas.
+func (h *Horizon) poll(ctx context.Context, db queryer) (wake []string, skipped bool, err error) {
+	h.mu.Lock()
+	incoming := h.incoming
+	h.incoming = map[string]bool{}
+	h.mu.Unlock()
+
+	// A ordem importa: lidos antes do valor, os locks não cobririam as transações que alocassem
+	// valores entre as duas leituras
+	value, err := h.Allocated(ctx, db)
+	var writers map[string]bool
+	if err == nil {
+		writers, err = h.writers(ctx, db)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		for e := range incoming {
+			h.incoming[e] = true
+		}
+		return nil, false, err
+	}
+	for e := range incoming {
+		if h.wakes[e] < value {
+			h.wakes[e] = value
+		}
+	}
+	h.add(checkpoint{value: value, writers: writers, taken: time.Now()})
+	skipped = h.advance(writers, time.Now())
+	for e, v := range h.wakes {
+		if v <= h.safe {
+			wake = append(wake, e)
+			delete(h.wakes, e)
+		}
+	}
+	return wake, skipped, nil
+}
+
+// writers retorna as transações que escrevem na tabela no momento
+func (h *Horizon) writers(ctx context.Context, db queryer) (map[string]bool, error) {
+	var list []string
+	err := db.QueryRow(ctx, `
+		SELECT COALESCE(array_agg(DISTINCT virtualtransaction), '{}')
+		FROM pg_locks
+		WHERE locktype = 'relation' AND relation = $1::regclass AND mode = 'RowExclusiveLock' AND granted`,
+		h.table).Scan(&list)
+	if err != nil {
+		return nil, err
+	}
+	writers := make(map[string]bool, len(list))
+	for _, w := range list {
+		writers[w] = true
+	}
+	return writers, nil
+}
+
+// add acrescenta um checkpoint aos pendentes, a menos que ele não traga valores novos
+func (h *Horizon) add(cp checkpoint) {
+	last := h.safe
+	if n := len(h.pending); n > 0 {
+		last = h.pending[n-1].value
+	}
+	if cp.value <= last {
+		return
+	}
+	if len(h.pending) == maxCheckpoints {
+		h.pending = h.pending[1:]
+	}
+	h.pending = append(h.pending, cp)
+}
+
+// advance consolida os checkpoints cujas transações não escrevem mais na tabela e os pendentes há
+// mais de MaxWait, informando se algum foi consolidado por tempo. Uma transação que ainda escreve
+// consta de todos os checkpoints posteriores ao primeiro em que apareceu, então a consolidação para
+// no primeiro checkpoint pendente.
+func (h *Horizon) advance(writers map[string]bool, now time.Time) bool {
+	skipped := false
+	done := 0
+	for _, cp := range h.pending {
+		if !disjoint(cp.writers, writers) {
+			if now.Sub(cp.taken) < MaxWait {
+				break
+			}
+			skipped = true
+			log.Printf("stream: %s held by a transaction open for more than %s, advancing to %d", h.table, MaxWait, cp.value)
+		}
+		h.safe = cp.value
+		done++
+	}
+	h.pending = h.pending[done:]
+	if skipped {
+		h.skips++
+	}
+	return skipped
+}
+
+// disjoint indica se a e b não têm elementos em comum
+func disjoint(a, b map[string]bool) bool {
+	for k := range a {
+		if b[k] {
+			return false
+		}
+	}
+	return true
+}
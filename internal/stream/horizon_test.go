@@ -0,0 +1,288 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// set monta o conjunto de transações de um checkpoint
+func set(writers ...string) map[string]bool {
+	m := map[string]bool{}
+	for _, w := range writers {
+		m[w] = true
+	}
+	return m
+}
+
+func TestHorizonAdvance(t *testing.T) {
+	h := newHorizon("sodardados", "sodardados_id_seq")
+	t0 := time.Now()
+
+	h.add(checkpoint{value: 10, writers: set("3/7"), taken: t0})
+	if h.advance(set("3/7", "4/2"), t0); h.safe != 0 || len(h.pending) != 1 {
+		t.Fatalf("com 3/7 aberta: safe %d, %d pendentes; esperado 0 e 1", h.safe, len(h.pending))
+	}
+	h.add(checkpoint{value: 20, writers: set("3/7", "4/2"), taken: t0})
+	h.add(checkpoint{value: 20, writers: set("4/2"), taken: t0}) // Sem valores novos: ignorado
+	if h.advance(set("4/2"), t0); h.safe != 10 || len(h.pending) != 1 {
+		t.Fatalf("com 4/2 aberta: safe %d, %d pendentes; esperado 10 e 1", h.safe, len(h.pending))
+	}
+	if h.advance(set("5/1"), t0); h.safe != 20 || len(h.pending) != 0 || h.skips != 0 {
+		t.Fatalf("sem escritas anteriores: safe %d, %d pendentes, %d avanços por tempo", h.safe, len(h.pending), h.skips)
+	}
+	h.add(checkpoint{value: 15, taken: t0})
+	if len(h.pending) != 0 {
+		t.Error("checkpoint abaixo do horizonte não deveria ficar pendente")
+	}
+}
+
+func TestHorizonMaxWait(t *testing.T) {
+	h := newHorizon("sodardados", "sodardados_id_seq")
+	t0 := time.Now()
+	h.add(checkpoint{value: 10, writers: set("3/7"), taken: t0})
+	h.add(checkpoint{value: 20, writers: set("3/7"), taken: t0.Add(time.Minute)})
+
+	if skipped := h.advance(set("3/7"), t0.Add(MaxWait-time.Second)); skipped || h.safe != 0 {
+		t.Fatalf("antes de MaxWait: skipped %v, safe %d", skipped, h.safe)
+	}
+	// Só o checkpoint pendente há mais de MaxWait é consolidado
+	if skipped := h.advance(set("3/7"), t0.Add(MaxWait)); !skipped || h.safe != 10 || h.skips != 1 || len(h.pending) != 1 {
+		t.Fatalf("após MaxWait: skipped %v, safe %d, %d avanços, %d pendentes", skipped, h.safe, h.skips, len(h.pending))
+	}
+	if skipped := h.advance(nil, t0.Add(MaxWait)); skipped || h.safe != 20 || h.skips != 1 {
+		t.Fatalf("após o commit: skipped %v, safe %d, %d avanços", skipped, h.safe, h.skips)
+	}
+}
+
+func TestHorizonMaxCheckpoints(t *testing.T) {
+	h := newHorizon("sodardados", "sodardados_id_seq")
+	for i := 1; i <= maxCheckpoints+1; i++ {
+		h.add(checkpoint{value: int64(i), writers: set("3/7"), taken: time.Now()})
+	}
+	if len(h.pending) != maxCheckpoints || h.pending[0].value != 2 {
+		t.Fatalf("pendentes = %d a partir de %d, esperado %d a partir de 2", len(h.pending), h.pending[0].value, maxCheckpoints)
+	}
+	if h.advance(nil, time.Now()); h.safe != maxCheckpoints+1 {
+		t.Errorf("safe = %d, esperado %d", h.safe, maxCheckpoints+1)
+	}
+}
+
+// testPool conecta ao banco de TEST_DATABASE_URL e cria tabelas descartáveis com ID serial
+func testPool(t *testing.T, tables int) (*pgxpool.Pool, []string) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL não definido")
+	}
+	ctx := context.Background()
+	db, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	var names []string
+	for i := 0; i < tables; i++ {
+		name := fmt.Sprintf("horizon_test_%d_%d", time.Now().UnixNano(), i)
+		if _, err := db.Exec(ctx, "CREATE TABLE "+name+" (id bigserial PRIMARY KEY, writer int NOT NULL)"); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Exec(context.Background(), "DROP TABLE "+name) })
+		names = append(names, name)
+	}
+	return db, names
+}
+
+// reader lê uma tabela como um stream: consulta o Horizon e lê os IDs entre o cursor e o horizonte
+type reader struct {
+	t      *testing.T
+	db     *pgxpool.Pool
+	h      *Horizon
+	cursor int64
+}
+
+func newReader(t *testing.T, db *pgxpool.Pool, table string) *reader {
+	h := newHorizon(table, table+"_id_seq")
+	if _, _, err := h.poll(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	cursor, err := h.Allocated(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &reader{t: t, db: db, h: h, cursor: cursor}
+}
+
+func (r *reader) read() []int64 {
+	ctx := context.Background()
+	r.h.notify("e")
+	if _, _, err := r.h.poll(ctx, r.db); err != nil {
+		r.t.Fatal(err)
+	}
+	safe, _ := r.h.Position()
+	rows, err := r.db.Query(ctx, "SELECT id FROM "+r.h.table+" WHERE id > $1 AND id <= $2 ORDER BY id", r.cursor, safe)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			r.t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		r.t.Fatal(err)
+	}
+	if safe > r.cursor {
+		r.cursor = safe
+	}
+	return ids
+}
+
+func TestHorizonOutOfOrderCommit(t *testing.T) {
+	db, tables := testPool(t, 2)
+	ctx := context.Background()
+	table, other := tables[0], tables[1]
+	r := newReader(t, db, table)
+
+	// Uma transação longa em outra tabela não atrasa o horizonte
+	long, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer long.Rollback(ctx)
+	if _, err := long.Exec(ctx, "INSERT INTO "+other+" (writer) VALUES (0)"); err != nil {
+		t.Fatal(err)
+	}
+	var unrelated int64
+	if err := db.QueryRow(ctx, "INSERT INTO "+table+" (writer) VALUES (0) RETURNING id").Scan(&unrelated); err != nil {
+		t.Fatal(err)
+	}
+	if ids := r.read(); !reflect.DeepEqual(ids, []int64{unrelated}) {
+		t.Fatalf("lidos %v com uma transação aberta em outra tabela, esperado [%d]", ids, unrelated)
+	}
+
+	t1, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer t1.Rollback(ctx)
+	var first int64
+	if err := t1.QueryRow(ctx, "INSERT INTO "+table+" (writer) VALUES (1) RETURNING id").Scan(&first); err != nil {
+		t.Fatal(err)
+	}
+	var second int64
+	if err := db.QueryRow(ctx, "INSERT INTO "+table+" (writer) VALUES (2) RETURNING id").Scan(&second); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := r.read(); len(ids) != 0 {
+		t.Fatalf("lidos %v com a transação de %d aberta, esperado nenhum", ids, first)
+	}
+	if err := t1.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ids := r.read(); !reflect.DeepEqual(ids, []int64{first, second}) {
+		t.Errorf("lidos %v, esperado [%d %d]", ids, first, second)
+	}
+}
+
+func TestHorizonConcurrentWriters(t *testing.T) {
+	db, tables := testPool(t, 1)
+	ctx := context.Background()
+	table := tables[0]
+	r := newReader(t, db, table)
+
+	const writers, perWriter = 8, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(w)))
+			for i := 0; i < perWriter; i++ {
+				if err := write(ctx, db, table, w, rnd); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+	}
+	done := make(chan struct{})
+	go func() { wg.Wait(); close(done) }()
+
+	delivered := map[int64]int{}
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		case <-time.After(2 * time.Millisecond):
+		}
+		for _, id := range r.read() {
+			delivered[id]++
+		}
+	}
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	for _, id := range r.read() {
+		delivered[id]++
+	}
+
+	rows, err := db.Query(ctx, "SELECT id FROM "+table+" ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var committed []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		committed = append(committed, id)
+		if n := delivered[id]; n != 1 {
+			t.Errorf("linha %d entregue %d vezes", id, n)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(delivered) != len(committed) {
+		ids := make([]int64, 0, len(delivered))
+		for id := range delivered {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		t.Errorf("entregues %v, confirmadas %v", ids, committed)
+	}
+}
+
+// write insere uma linha numa transação que demora um tempo aleatório e às vezes é desfeita
+func write(ctx context.Context, db *pgxpool.Pool, table string, writer int, rnd *rand.Rand) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, "INSERT INTO "+table+" (writer) VALUES ($1)", writer); err != nil {
+		return err
+	}
+	time.Sleep(time.Duration(rnd.Intn(5000)) * time.Microsecond)
+	if rnd.Intn(5) == 0 {
+		return tx.Rollback(ctx)
+	}
+	return tx.Commit(ctx)
+}
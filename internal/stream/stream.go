@@ -0,0 +1,268 @@
+// Package stream avisa as réplicas da API sobre linhas novas nas tabelas de dados e sobre mudanças nos
+// disparos de alerta, via LISTEN/NOTIFY do PostgreSQL. O aviso só identifica a tabela e o equipamento;
+// quem o recebe lê as linhas no banco a partir do próprio cursor até o Horizon da tabela, então avisos
+// repetidos, perdidos durante uma reconexão ou coalescidos pelo PostgreSQL na mesma transação não
+// perdem dados.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Channel é o canal do LISTEN/NOTIFY
+const Channel = "datalake_stream"
+
+// AlertsTable identifica os avisos de mudança em AlertFirings
+const AlertsTable = "alertfirings"
+
+// AlertsSequence gera as revisões de AlertFirings
+const AlertsSequence = "alertfirings_revision_seq"
+
+// reconnectDelay é o intervalo entre tentativas de reconexão do LISTEN
+const reconnectDelay = 5 * time.Second
+
+// pollInterval é o intervalo entre consultas aos Horizons com checkpoints ou avisos pendentes
+const pollInterval = time.Second
+
+// Event é o conteúdo de um aviso
+type Event struct {
+	Table       string `json:"table"`
+	EquipmentID string `json:"equipment_id"`
+}
+
+// execer é satisfeito por *pgxpool.Pool, *pgx.Conn e pgx.Tx
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+// Notify avisa que a tabela recebeu linhas do equipamento. Dentro de uma transação, o aviso só é
+// entregue no commit.
+func Notify(ctx context.Context, db execer, table, equipmentID string) error {
+	payload, err := json.Marshal(Event{Table: table, EquipmentID: strings.ToLower(equipmentID)})
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(ctx, "SELECT pg_notify($1, $2)", Channel, string(payload))
+	return err
+}
+
+// Subscription recebe um sinal em C sempre que há avisos para o equipamento em uma das tabelas.
+// Sinais consecutivos são coalescidos: quem recebe deve ler tudo o que houver após o seu cursor.
+type Subscription struct {
+	C           <-chan struct{}
+	c           chan struct{}
+	equipmentID string
+	tables      map[string]bool
+	hub         *Hub
+}
+
+// Close cancela a assinatura
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	delete(s.hub.subs, s)
+	s.hub.mu.Unlock()
+}
+
+// wake sinaliza a assinatura sem bloquear
+func (s *Subscription) wake() {
+	select {
+	case s.c <- struct{}{}:
+	default:
+	}
+}
+
+// Hub mantém uma conexão dedicada em LISTEN e distribui os avisos às assinaturas desta réplica. Os
+// avisos de tabelas com Horizon só são entregues quando as linhas que os geraram são definitivas.
+type Hub struct {
+	db       *pgxpool.Pool
+	mu       sync.Mutex
+	subs     map[*Subscription]struct{}
+	horizons map[string]*Horizon
+	poke     chan struct{}
+}
+
+// NewHub cria um Hub; Run precisa estar em execução para que os avisos cheguem
+func NewHub(db *pgxpool.Pool) *Hub {
+	return &Hub{db: db, subs: map[*Subscription]struct{}{}, horizons: map[string]*Horizon{}, poke: make(chan struct{}, 1)}
+}
+
+// Subscribe assina os avisos do equipamento nas tabelas informadas. A assinatura é acordada assim que
+// os Horizons das tabelas forem consultados, para que leia o que tiver sido gravado antes dela.
+func (h *Hub) Subscribe(equipmentID string, tables ...string) *Subscription {
+	c := make(chan struct{}, 1)
+	s := &Subscription{C: c, c: c, equipmentID: strings.ToLower(equipmentID), tables: map[string]bool{}, hub: h}
+	for _, t := range tables {
+		s.tables[t] = true
+	}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	for _, t := range tables {
+		h.notify(&Event{Table: t, EquipmentID: s.equipmentID})
+	}
+	return s
+}
+
+// Horizon retorna o Horizon compartilhado da tabela, cujos IDs são gerados por sequence, criando-o e
+// consultando-o na primeira chamada
+func (h *Hub) Horizon(ctx context.Context, table, sequence string) (*Horizon, error) {
+	h.mu.Lock()
+	hz, ok := h.horizons[table]
+	h.mu.Unlock()
+	if ok {
+		return hz, nil
+	}
+	hz = newHorizon(table, sequence)
+	if _, _, err := hz.poll(ctx, h.db); err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if existing, ok := h.horizons[table]; ok {
+		return existing, nil
+	}
+	h.horizons[table] = hz
+	return hz, nil
+}
+
+// notify entrega o aviso às assinaturas ou, se a tabela tiver Horizon, o registra para ser entregue
+// depois do próximo checkpoint definitivo
+func (h *Hub) notify(e *Event) {
+	h.mu.Lock()
+	hz, ok := h.horizons[e.Table]
+	h.mu.Unlock()
+	if !ok {
+		h.dispatch(e)
+		return
+	}
+	hz.notify(e.EquipmentID)
+	select {
+	case h.poke <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch sinaliza as assinaturas interessadas no evento; com EquipmentID vazio, todas as da tabela
+func (h *Hub) dispatch(e *Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		if s.tables[e.Table] && (e.EquipmentID == "" || s.equipmentID == e.EquipmentID) {
+			s.wake()
+		}
+	}
+}
+
+// resync registra um aviso para cada assinatura, como se todas as suas tabelas tivessem recebido linhas
+func (h *Hub) resync() {
+	h.mu.Lock()
+	var events []*Event
+	for s := range h.subs {
+		for t := range s.tables {
+			events = append(events, &Event{Table: t, EquipmentID: s.equipmentID})
+		}
+	}
+	h.mu.Unlock()
+	for _, e := range events {
+		h.notify(e)
+	}
+}
+
+// pollHorizons consulta os Horizons com checkpoints ou avisos pendentes a cada aviso recebido e a
+// cada pollInterval, acordando as assinaturas cujos avisos passaram a ser definitivos
+func (h *Hub) pollHorizons(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.poke:
+		case <-ticker.C:
+		}
+		h.mu.Lock()
+		horizons := make([]*Horizon, 0, len(h.horizons))
+		for _, hz := range h.horizons {
+			horizons = append(horizons, hz)
+		}
+		h.mu.Unlock()
+
+		for _, hz := range horizons {
+			if hz.idle() {
+				continue
+			}
+			wake, skipped, err := hz.poll(ctx, h.db)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("stream: failed to poll horizon of %s: %v", hz.table, err)
+				}
+				continue
+			}
+			if skipped {
+				h.dispatch(&Event{Table: hz.table})
+				continue
+			}
+			for _, e := range wake {
+				h.dispatch(&Event{Table: hz.table, EquipmentID: e})
+			}
+		}
+	}
+}
+
+// Run escuta o canal e consulta os Horizons até ctx ser cancelado, reconectando após falhas. A cada
+// reconexão todas as assinaturas são avisadas, pois avisos podem ter sido perdidos enquanto a conexão
+// estava fora.
+func (h *Hub) Run(ctx context.Context) {
+	go h.pollHorizons(ctx)
+	connected := false
+	for {
+		err := h.listen(ctx, connected)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("stream: listen failed, reconnecting in %s: %v", reconnectDelay, err)
+		connected = true
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// listen abre a conexão dedicada e entrega os avisos até ocorrer um erro
+func (h *Hub) listen(ctx context.Context, resync bool) error {
+	conn, err := pgx.ConnectConfig(ctx, h.db.Config().ConnConfig)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+	if resync {
+		h.resync()
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(n.Payload), &e); err != nil {
+			log.Printf("stream: ignoring malformed notification %q: %v", n.Payload, err)
+			continue
+		}
+		h.notify(&e)
+	}
+}
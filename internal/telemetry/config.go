@@ -0,0 +1,191 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Valores padrão da configuração
+const (
+	DefaultClientID      = "datalake-mqttd"
+	DefaultQoS           = 1
+	DefaultBatchSize     = 500
+	DefaultFlushInterval = 5 * time.Second
+	DefaultBacklog       = 20 // Lotes mantidos em memória enquanto o banco estiver indisponível
+)
+
+// Route associa os tópicos que correspondem a Topic a uma tabela e a um equipamento
+type Route struct {
+	Topic          string `json:"topic"`                     // Filtro MQTT, com os curingas + e # (ex.: "stations/+/tower")
+	Table          string `json:"table"`                     // Tabela de dados (ex.: "towermicrometeorologicaldata")
+	EquipmentID    string `json:"equipment_id,omitempty"`    // Equipamento fixo da rota
+	EquipmentLevel *int   `json:"equipment_level,omitempty"` // Nível do tópico (a partir de 0) com o UUID do equipamento
+	Format         string `json:"format,omitempty"`          // "json", "senml" ou vazio (identificado pelo payload)
+
+	model *Model
+}
+
+// Config configura o assinante MQTT
+type Config struct {
+	Broker        string // URL do broker (ex.: "tcp://localhost:1883")
+	ClientID      string // Identificador fixo: com QoS 1, o broker guarda as mensagens durante as paradas
+	Username      string
+	Password      string
+	QoS           byte
+	BatchSize     int           // Leituras acumuladas antes de uma gravação
+	FlushInterval time.Duration // Intervalo máximo entre gravações
+	MaxBacklog    int           // Leituras pendentes acima das quais novas mensagens não são confirmadas
+	Routes        []*Route      // Avaliadas em ordem; vale a primeira que corresponder ao tópico
+}
+
+// configFile é o formato do arquivo JSON de configuração
+type configFile struct {
+	Broker        string   `json:"broker"`
+	ClientID      string   `json:"client_id"`
+	Username      string   `json:"username"`
+	Password      string   `json:"password"` // MQTT_PASSWORD tem precedência
+	QoS           *int     `json:"qos"`
+	BatchSize     int      `json:"batch_size"`
+	FlushInterval string   `json:"flush_interval"` // Duração Go (ex.: "5s")
+	MaxBacklog    int      `json:"max_backlog"`    // Padrão: DefaultBacklog lotes
+	Routes        []*Route `json:"routes"`
+}
+
+// LoadConfig lê e valida o arquivo JSON de configuração
+func LoadConfig(file string) (*Config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var raw configFile
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("telemetry: configuração inválida em %s: %w", file, err)
+	}
+
+	cfg := &Config{
+		Broker: raw.Broker, ClientID: raw.ClientID, Username: raw.Username, Password: raw.Password,
+		QoS: DefaultQoS, BatchSize: raw.BatchSize, FlushInterval: DefaultFlushInterval, MaxBacklog: raw.MaxBacklog,
+		Routes: raw.Routes,
+	}
+	if password := os.Getenv("MQTT_PASSWORD"); password != "" {
+		cfg.Password = password
+	}
+	if cfg.Broker == "" {
+		return nil, fmt.Errorf("telemetry: broker ausente em %s", file)
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = DefaultClientID
+	}
+	if raw.QoS != nil {
+		if *raw.QoS < 0 || *raw.QoS > 2 {
+			return nil, fmt.Errorf("telemetry: qos inválido: %d", *raw.QoS)
+		}
+		cfg.QoS = byte(*raw.QoS)
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.BatchSize < 0 {
+		return nil, fmt.Errorf("telemetry: batch_size inválido: %d", cfg.BatchSize)
+	}
+	if cfg.MaxBacklog == 0 {
+		cfg.MaxBacklog = DefaultBacklog * cfg.BatchSize
+	}
+	if cfg.MaxBacklog < cfg.BatchSize {
+		return nil, fmt.Errorf("telemetry: max_backlog deve ser ao menos batch_size: %d", cfg.MaxBacklog)
+	}
+	if raw.FlushInterval != "" {
+		if cfg.FlushInterval, err = time.ParseDuration(raw.FlushInterval); err != nil || cfg.FlushInterval <= 0 {
+			return nil, fmt.Errorf("telemetry: flush_interval inválido: %q", raw.FlushInterval)
+		}
+	}
+	if len(cfg.Routes) == 0 {
+		return nil, fmt.Errorf("telemetry: nenhuma rota configurada em %s", file)
+	}
+
+	for _, route := range cfg.Routes {
+		if err := validFilter(route.Topic); err != nil {
+			return nil, err
+		}
+		route.Table = strings.ToLower(route.Table)
+		if route.model = Models[route.Table]; route.model == nil {
+			return nil, fmt.Errorf("telemetry: tabela %q não recebe leituras na rota %q", route.Table, route.Topic)
+		}
+		switch route.Format {
+		case FormatAuto, FormatJSON, FormatSenML:
+		default:
+			return nil, fmt.Errorf("telemetry: formato inválido %q na rota %q", route.Format, route.Topic)
+		}
+		if route.EquipmentID != "" && route.EquipmentLevel != nil {
+			return nil, fmt.Errorf("telemetry: use equipment_id ou equipment_level na rota %q, não ambos", route.Topic)
+		}
+		if route.EquipmentID != "" {
+			var id pgtype.UUID
+			if err := id.Scan(route.EquipmentID); err != nil {
+				return nil, fmt.Errorf("telemetry: equipment_id inválido na rota %q", route.Topic)
+			}
+		}
+		if route.EquipmentLevel != nil && *route.EquipmentLevel < 0 {
+			return nil, fmt.Errorf("telemetry: equipment_level inválido na rota %q", route.Topic)
+		}
+	}
+	return cfg, nil
+}
+
+// validFilter confere a sintaxe de um filtro de tópico MQTT
+func validFilter(filter string) error {
+	if filter == "" {
+		return fmt.Errorf("telemetry: rota sem tópico")
+	}
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.Contains(level, "#") && (level != "#" || i != len(levels)-1) {
+			return fmt.Errorf("telemetry: # deve ocupar sozinho o último nível em %q", filter)
+		}
+		if strings.Contains(level, "+") && level != "+" {
+			return fmt.Errorf("telemetry: + deve ocupar um nível inteiro em %q", filter)
+		}
+	}
+	return nil
+}
+
+// matchTopic indica se o tópico corresponde ao filtro MQTT
+func matchTopic(filter, topic string) bool {
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) || (level != "+" && level != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+// route retorna a primeira rota que corresponde ao tópico
+func (c *Config) route(topic string) *Route {
+	for _, r := range c.Routes {
+		if matchTopic(r.Topic, topic) {
+			return r
+		}
+	}
+	return nil
+}
+
+// equipment retorna o equipamento definido pela rota para o tópico (vazio quando vem do payload)
+func (r *Route) equipment(topic string) string {
+	if r.EquipmentLevel != nil {
+		levels := strings.Split(topic, "/")
+		if *r.EquipmentLevel < len(levels) {
+			return levels[*r.EquipmentLevel]
+		}
+		return ""
+	}
+	return r.EquipmentID
+}
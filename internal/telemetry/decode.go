@@ -0,0 +1,230 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Formatos de mensagem aceitos
+const (
+	FormatAuto  = ""      // SenML quando o payload é uma lista de registros com "n" ou "bn"; JSON caso contrário
+	FormatJSON  = "json"  // Objeto (ou lista de objetos) com "timestamp" e os campos do modelo
+	FormatSenML = "senml" // SenML JSON (RFC 8428), com os campos do modelo em "n"
+)
+
+// senmlRelativeLimit é o limite abaixo do qual os tempos SenML são relativos ao recebimento (RFC 8428
+// §4.5.3). Eles não são aceitos: uma mensagem reentregue pelo broker receberia outros timestamps e
+// escaparia da deduplicação por (equipamento, timestamp), gravando as leituras duas vezes.
+const senmlRelativeLimit = 1 << 28
+
+// ErrMalformed indica uma mensagem que não corresponde ao modelo da tabela
+var ErrMalformed = errors.New("mensagem inválida")
+
+// Reading é uma leitura validada, pronta para gravação
+type Reading struct {
+	EquipmentID string             // Vazio quando o payload não informa; a rota define o equipamento
+	Timestamp   time.Time          // UTC
+	Values      map[string]float64 // Por coluna
+}
+
+// Decode valida o payload contra o modelo e retorna as leituras
+func Decode(format string, payload []byte, m *Model) ([]Reading, error) {
+	if format == FormatAuto {
+		format = detectFormat(payload)
+	}
+	switch format {
+	case FormatJSON:
+		return decodeJSON(payload, m)
+	case FormatSenML:
+		return decodeSenML(payload, m)
+	}
+	return nil, fmt.Errorf("formato desconhecido: %s", format)
+}
+
+// detectFormat distingue SenML de JSON pelo primeiro registro da lista
+func detectFormat(payload []byte) string {
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return FormatJSON
+	}
+	var records []map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &records); err != nil || len(records) == 0 {
+		return FormatJSON
+	}
+	_, hasName := records[0]["n"]
+	_, hasBaseName := records[0]["bn"]
+	if hasName || hasBaseName {
+		return FormatSenML
+	}
+	return FormatJSON
+}
+
+// decodeJSON lê um objeto ou uma lista de objetos com "timestamp", "equipment_id" (opcional) e os
+// campos numéricos do modelo. Campos desconhecidos invalidam a mensagem.
+func decodeJSON(payload []byte, m *Model) ([]Reading, error) {
+	var objects []map[string]json.RawMessage
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &objects); err != nil {
+			return nil, fmt.Errorf("%w: JSON inválido: %v", ErrMalformed, err)
+		}
+	} else {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &object); err != nil {
+			return nil, fmt.Errorf("%w: JSON inválido: %v", ErrMalformed, err)
+		}
+		objects = append(objects, object)
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("%w: nenhuma leitura", ErrMalformed)
+	}
+
+	readings := make([]Reading, 0, len(objects))
+	for i, object := range objects {
+		r := Reading{Values: map[string]float64{}}
+		raw, ok := object["timestamp"]
+		if !ok {
+			return nil, fmt.Errorf("%w: leitura %d sem timestamp", ErrMalformed, i)
+		}
+		ts, err := parseTimestamp(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: leitura %d: %v", ErrMalformed, i, err)
+		}
+		r.Timestamp = ts
+		for key, raw := range object {
+			switch key {
+			case "timestamp":
+				continue
+			case "equipment_id":
+				if err := json.Unmarshal(raw, &r.EquipmentID); err != nil {
+					return nil, fmt.Errorf("%w: leitura %d: equipment_id deve ser texto", ErrMalformed, i)
+				}
+				continue
+			}
+			column, ok := m.byJSON[key]
+			if !ok {
+				return nil, fmt.Errorf("%w: leitura %d: campo %q não existe em %s", ErrMalformed, i, key, m.Table)
+			}
+			var v *float64
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return nil, fmt.Errorf("%w: leitura %d: %s deve ser numérico", ErrMalformed, i, key)
+			}
+			if v != nil {
+				r.Values[column] = *v
+			}
+		}
+		if len(r.Values) == 0 {
+			return nil, fmt.Errorf("%w: leitura %d sem valores", ErrMalformed, i)
+		}
+		readings = append(readings, r)
+	}
+	return readings, nil
+}
+
+// parseTimestamp aceita RFC 3339 ou segundos desde a época Unix
+func parseTimestamp(raw json.RawMessage) (time.Time, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		ts, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("timestamp inválido %q", text)
+		}
+		return ts.UTC(), nil
+	}
+	var seconds float64
+	if err := json.Unmarshal(raw, &seconds); err != nil {
+		return time.Time{}, errors.New("timestamp deve ser RFC 3339 ou segundos Unix")
+	}
+	return unixTime(seconds), nil
+}
+
+// unixTime converte segundos Unix fracionários em time.Time (UTC)
+func unixTime(seconds float64) time.Time {
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC()
+}
+
+// senmlRecord é um registro SenML JSON; apenas valores numéricos são aceitos
+type senmlRecord struct {
+	BaseName    *string  `json:"bn"`
+	BaseTime    *float64 `json:"bt"`
+	Name        *string  `json:"n"`
+	Value       *float64 `json:"v"`
+	StringValue *string  `json:"vs"`
+	BoolValue   *bool    `json:"vb"`
+	DataValue   *string  `json:"vd"`
+	Sum         *float64 `json:"s"`
+	Time        *float64 `json:"t"`
+}
+
+// decodeSenML lê um pacote SenML JSON. O nome resolvido (bn + n) termina no campo do modelo, após o
+// último ":" ou "/" (ex.: "urn:dev:mac:0024befffe804ff1:wind_speed"). Registros com o mesmo tempo
+// formam uma única leitura. O tempo resolvido (bt + t) precisa ser absoluto, em segundos Unix.
+func decodeSenML(payload []byte, m *Model) ([]Reading, error) {
+	var records []senmlRecord
+	if err := json.Unmarshal(payload, &records); err != nil {
+		return nil, fmt.Errorf("%w: SenML inválido: %v", ErrMalformed, err)
+	}
+
+	var baseName string
+	var baseTime float64
+	byTime := map[time.Time]*Reading{}
+	for i, rec := range records {
+		if rec.BaseName != nil {
+			baseName = *rec.BaseName
+		}
+		if rec.BaseTime != nil {
+			baseTime = *rec.BaseTime
+		}
+		if rec.Name == nil && rec.Value == nil && rec.StringValue == nil && rec.BoolValue == nil &&
+			rec.DataValue == nil && rec.Sum == nil {
+			continue // Registro apenas com campos base
+		}
+		if rec.StringValue != nil || rec.BoolValue != nil || rec.DataValue != nil {
+			return nil, fmt.Errorf("%w: registro %d: apenas valores numéricos (v) são aceitos", ErrMalformed, i)
+		}
+		if rec.Value == nil {
+			return nil, fmt.Errorf("%w: registro %d sem valor", ErrMalformed, i)
+		}
+		name := baseName
+		if rec.Name != nil {
+			name += *rec.Name
+		}
+		key := name[strings.LastIndexAny(name, ":/")+1:]
+		column, ok := m.byJSON[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: registro %d: campo %q não existe em %s", ErrMalformed, i, key, m.Table)
+		}
+
+		t := baseTime
+		if rec.Time != nil {
+			t += *rec.Time
+		}
+		if math.Abs(t) < senmlRelativeLimit {
+			return nil, fmt.Errorf("%w: registro %d: tempo relativo não é aceito; informe bt em segundos Unix", ErrMalformed, i)
+		}
+		ts := unixTime(t)
+		r, ok := byTime[ts]
+		if !ok {
+			r = &Reading{Timestamp: ts, Values: map[string]float64{}}
+			byTime[ts] = r
+		}
+		r.Values[column] = *rec.Value
+	}
+	if len(byTime) == 0 {
+		return nil, fmt.Errorf("%w: nenhuma leitura", ErrMalformed)
+	}
+
+	readings := make([]Reading, 0, len(byTime))
+	for _, r := range byTime {
+		readings = append(readings, *r)
+	}
+	sort.Slice(readings, func(i, j int) bool { return readings[i].Timestamp.Before(readings[j].Timestamp) })
+	return readings, nil
+}
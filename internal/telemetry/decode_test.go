@@ -0,0 +1,90 @@
+package telemetry
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	m := Models["towermicrometeorologicaldata"]
+	bt := time.Unix(1700000000, 0).UTC()
+
+	tests := []struct {
+		name    string
+		format  string
+		payload string
+		want    []Reading
+		wantErr bool
+	}{
+		{
+			name:    "objeto JSON",
+			format:  FormatJSON,
+			payload: `{"timestamp": "2024-01-01T00:10:00-03:00", "wind_speed": 7.2, "wind_direction": 184, "humidity": null}`,
+			want: []Reading{{Timestamp: time.Date(2024, 1, 1, 3, 10, 0, 0, time.UTC),
+				Values: map[string]float64{"windspeed": 7.2, "winddirection": 184}}},
+		},
+		{
+			name:    "lista JSON com segundos Unix e equipamento",
+			format:  FormatAuto,
+			payload: `[{"timestamp": 1700000000.5, "equipment_id": "e1", "temperature": 21.5}, {"timestamp": 1700000600, "temperature": 22}]`,
+			want: []Reading{
+				{EquipmentID: "e1", Timestamp: bt.Add(500 * time.Millisecond), Values: map[string]float64{"temperature": 21.5}},
+				{Timestamp: bt.Add(10 * time.Minute), Values: map[string]float64{"temperature": 22}},
+			},
+		},
+		{name: "campo desconhecido", format: FormatJSON, payload: `{"timestamp": 0, "gust": 12}`, wantErr: true},
+		{name: "sem timestamp", format: FormatJSON, payload: `{"wind_speed": 7}`, wantErr: true},
+		{name: "valor não numérico", format: FormatJSON, payload: `{"timestamp": 0, "wind_speed": "7"}`, wantErr: true},
+		{name: "sem valores", format: FormatJSON, payload: `{"timestamp": 0, "humidity": null}`, wantErr: true},
+		{name: "JSON inválido", format: FormatJSON, payload: `{"timestamp": `, wantErr: true},
+		{
+			name:   "SenML com bn e bt",
+			format: FormatAuto,
+			payload: `[{"bn": "urn:dev:mac:0024befffe804ff1:", "bt": 1700000000, "n": "wind_speed", "v": 7.2},
+				{"n": "temperature", "v": 21},
+				{"n": "wind_speed", "v": 7.5, "t": 600}]`,
+			want: []Reading{
+				{Timestamp: bt, Values: map[string]float64{"windspeed": 7.2, "temperature": 21}},
+				{Timestamp: bt.Add(10 * time.Minute), Values: map[string]float64{"windspeed": 7.5}},
+			},
+		},
+		{name: "SenML com tempo relativo", format: FormatSenML, payload: `[{"bn": "station/", "n": "humidity", "v": 80, "t": -60}]`, wantErr: true},
+		{name: "SenML sem bt", format: FormatSenML, payload: `[{"n": "humidity", "v": 81}]`, wantErr: true},
+		{
+			name:    "SenML com registro apenas de campos base",
+			format:  FormatSenML,
+			payload: `[{"bn": "urn:dev:1:", "bt": 1700000000}, {"n": "temperature", "v": 20}]`,
+			want:    []Reading{{Timestamp: bt, Values: map[string]float64{"temperature": 20}}},
+		},
+		{name: "SenML com valor texto", format: FormatSenML, payload: `[{"n": "wind_speed", "vs": "7"}]`, wantErr: true},
+		{name: "SenML com valor booleano", format: FormatSenML, payload: `[{"n": "wind_speed", "vb": true}]`, wantErr: true},
+		{name: "SenML sem valor", format: FormatSenML, payload: `[{"n": "wind_speed"}]`, wantErr: true},
+		{name: "SenML com campo desconhecido", format: FormatAuto, payload: `[{"n": "urn:dev:1:gust", "v": 12}]`, wantErr: true},
+		{name: "SenML vazio", format: FormatSenML, payload: `[]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.format, []byte(tt.payload), m)
+			if tt.wantErr {
+				if !errors.Is(err, ErrMalformed) {
+					t.Fatalf("esperado ErrMalformed, obtido %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode = %+v, esperado %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeUnknownFormat(t *testing.T) {
+	if _, err := Decode("xml", []byte(`<r/>`), Models["sodardata"]); err == nil || errors.Is(err, ErrMalformed) {
+		t.Errorf("esperado erro de formato desconhecido, obtido %v", err)
+	}
+}
@@ -0,0 +1,325 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"api/internal/stream"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pending é uma mensagem validada aguardando gravação
+type pending struct {
+	topic    string
+	payload  []byte
+	received time.Time
+	model    *Model
+	readings []Reading
+	ack      func()
+}
+
+// database é o subconjunto de *pgxpool.Pool usado pelo Ingestor
+type database interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// Ingestor valida as mensagens recebidas e grava as leituras em lotes. É independente do cliente MQTT:
+// Handle recebe o tópico, o payload e a função que confirma a mensagem ao broker, chamada só depois
+// que as leituras (ou a mensagem, em MQTTDeadLetters) estão gravadas.
+type Ingestor struct {
+	db  database
+	cfg *Config
+
+	// redeliver é chamado depois de uma gravação bem-sucedida quando mensagens foram descartadas sem
+	// confirmação por excederem MaxBacklog; Subscribe reconecta ao broker para recebê-las de novo
+	redeliver func()
+
+	mu         sync.Mutex
+	batch      []*pending
+	rows       int
+	dropped    int
+	equipments map[string]bool // Existência dos equipamentos já consultados
+	flush      chan struct{}
+}
+
+// NewIngestor cria um Ingestor para a configuração
+func NewIngestor(db *pgxpool.Pool, cfg *Config) *Ingestor {
+	return newIngestor(db, cfg)
+}
+
+func newIngestor(db database, cfg *Config) *Ingestor {
+	return &Ingestor{db: db, cfg: cfg, equipments: map[string]bool{}, flush: make(chan struct{}, 1)}
+}
+
+// Handle valida uma mensagem e a acrescenta ao lote; mensagens inválidas vão para MQTTDeadLetters.
+// Com MaxBacklog leituras pendentes a mensagem é descartada sem confirmação.
+func (in *Ingestor) Handle(ctx context.Context, topic string, payload []byte, ack func()) {
+	received := time.Now().UTC()
+	p := &pending{topic: topic, payload: payload, received: received, ack: ack}
+	route := in.cfg.route(topic)
+	if route == nil {
+		in.deadLetter(ctx, p, "nenhuma rota corresponde ao tópico")
+		return
+	}
+	p.model = route.model
+	readings, err := Decode(route.Format, payload, route.model)
+	if err == nil {
+		err = in.resolveEquipment(ctx, route.equipment(topic), readings)
+	}
+	if err != nil {
+		in.deadLetter(ctx, p, err.Error())
+		return
+	}
+	p.readings = readings
+
+	in.mu.Lock()
+	if in.rows+len(readings) > in.cfg.MaxBacklog && in.rows > 0 {
+		in.dropped++
+		in.mu.Unlock()
+		log.Printf("telemetry: backlog full (%d readings), message from %s not acknowledged", in.cfg.MaxBacklog, topic)
+		return
+	}
+	in.batch = append(in.batch, p)
+	in.rows += len(readings)
+	full := in.rows >= in.cfg.BatchSize
+	in.mu.Unlock()
+	if full {
+		select {
+		case in.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// resolveEquipment define o equipamento de cada leitura (o da rota prevalece e não pode divergir do
+// payload) e confere se ele existe
+func (in *Ingestor) resolveEquipment(ctx context.Context, routeEquipment string, readings []Reading) error {
+	for i := range readings {
+		r := &readings[i]
+		if routeEquipment != "" {
+			if r.EquipmentID != "" && r.EquipmentID != routeEquipment {
+				return fmt.Errorf("%w: equipment_id %s diverge do equipamento da rota %s", ErrMalformed, r.EquipmentID, routeEquipment)
+			}
+			r.EquipmentID = routeEquipment
+		}
+		if r.EquipmentID == "" {
+			return fmt.Errorf("%w: equipamento não informado pela rota nem pelo payload", ErrMalformed)
+		}
+		exists, err := in.equipmentExists(ctx, r.EquipmentID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: equipamento %s inexistente", ErrMalformed, r.EquipmentID)
+		}
+	}
+	return nil
+}
+
+// equipmentExists consulta (uma vez por equipamento) se o UUID corresponde a um equipamento
+func (in *Ingestor) equipmentExists(ctx context.Context, id string) (bool, error) {
+	in.mu.Lock()
+	exists, ok := in.equipments[id]
+	in.mu.Unlock()
+	if ok {
+		return exists, nil
+	}
+	var uuid pgtype.UUID
+	if err := uuid.Scan(id); err != nil {
+		return false, nil
+	}
+	if err := in.db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM equipments WHERE equipmentid = $1)", uuid).Scan(&exists); err != nil {
+		return false, err
+	}
+	in.mu.Lock()
+	in.equipments[id] = exists
+	in.mu.Unlock()
+	return exists, nil
+}
+
+// deadLetter grava a mensagem em MQTTDeadLetters e a confirma. Se a gravação falhar, a mensagem não é
+// confirmada e o broker a entrega de novo na próxima conexão.
+func (in *Ingestor) deadLetter(ctx context.Context, p *pending, reason string) {
+	_, err := in.db.Exec(ctx, `
+		INSERT INTO MQTTDeadLetters (topic, payload, reason, receivedat) VALUES ($1, $2, $3, $4)`,
+		p.topic, p.payload, reason, p.received)
+	if err != nil {
+		log.Printf("telemetry: failed to store dead letter from %s (%s): %v", p.topic, reason, err)
+		return
+	}
+	log.Printf("telemetry: message from %s sent to dead letters: %s", p.topic, reason)
+	p.ack()
+}
+
+// Run grava os lotes a cada FlushInterval, ou antes quando BatchSize é atingido, até ctx ser
+// cancelado; o último lote é gravado antes de retornar
+func (in *Ingestor) Run(ctx context.Context) {
+	ticker := time.NewTicker(in.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			in.Flush(final)
+			cancel()
+			return
+		case <-ticker.C:
+		case <-in.flush:
+		}
+		in.Flush(ctx)
+	}
+}
+
+// Flush grava o lote acumulado. Se o banco rejeitar o lote, cada mensagem é gravada separadamente e
+// as rejeitadas vão para MQTTDeadLetters; se a conexão falhar, o lote volta para a fila, limitada a
+// MaxBacklog leituras.
+func (in *Ingestor) Flush(ctx context.Context) {
+	in.mu.Lock()
+	batch := in.batch
+	in.batch, in.rows = nil, 0
+	in.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	duplicates, err := in.insert(ctx, batch)
+	if err == nil {
+		for _, p := range batch {
+			p.ack()
+		}
+		in.logDuplicates(duplicates)
+		in.recovered()
+		return
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		log.Printf("telemetry: failed to insert %d messages, will retry: %v", len(batch), err)
+		in.requeue(batch)
+		return
+	}
+
+	duplicates = 0
+	for _, p := range batch {
+		n, err := in.insert(ctx, []*pending{p})
+		if err != nil {
+			in.deadLetter(ctx, p, fmt.Sprintf("gravação rejeitada: %v", err))
+			continue
+		}
+		duplicates += n
+		p.ack()
+	}
+	in.logDuplicates(duplicates)
+	in.recovered()
+}
+
+// requeue devolve à fila um lote que não pôde ser gravado, à frente das mensagens recebidas depois
+// dele. As mensagens que excedem MaxBacklog são descartadas sem confirmação, das mais novas para as
+// mais antigas.
+func (in *Ingestor) requeue(batch []*pending) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	queue := append(batch, in.batch...)
+	rows := 0
+	for i, p := range queue {
+		if rows > 0 && rows+len(p.readings) > in.cfg.MaxBacklog {
+			in.dropped += len(queue) - i
+			log.Printf("telemetry: backlog full (%d readings), %d messages not acknowledged", in.cfg.MaxBacklog, len(queue)-i)
+			queue = queue[:i]
+			break
+		}
+		rows += len(p.readings)
+	}
+	in.batch, in.rows = queue, rows
+}
+
+// recovered pede ao broker, depois de uma gravação bem-sucedida, as mensagens descartadas sem confirmação
+func (in *Ingestor) recovered() {
+	in.mu.Lock()
+	dropped := in.dropped
+	in.dropped = 0
+	in.mu.Unlock()
+	if dropped > 0 && in.redeliver != nil {
+		log.Printf("telemetry: requesting redelivery of %d messages", dropped)
+		in.redeliver()
+	}
+}
+
+// logDuplicates registra as leituras ignoradas por já estarem gravadas (mensagens reentregues pelo broker)
+func (in *Ingestor) logDuplicates(n int64) {
+	if n > 0 {
+		log.Printf("telemetry: %d duplicate readings ignored", n)
+	}
+}
+
+// insert grava as leituras das mensagens em uma única transação que também avisa os streams ao vivo
+// sobre cada tabela e equipamento gravados. Cada tabela recebe um COPY em uma tabela temporária, de
+// onde as leituras são inseridas ignorando as que já existem para o equipamento e o timestamp.
+// Retorna a quantidade de leituras ignoradas.
+func (in *Ingestor) insert(ctx context.Context, batch []*pending) (int64, error) {
+	byModel := map[*Model][][]interface{}{}
+	notify := map[stream.Event]bool{}
+	for _, p := range batch {
+		for _, r := range p.readings {
+			notify[stream.Event{Table: p.model.Table, EquipmentID: r.EquipmentID}] = true
+			var equipment pgtype.UUID
+			if err := equipment.Scan(r.EquipmentID); err != nil {
+				return 0, err
+			}
+			row := []interface{}{equipment, r.Timestamp}
+			for _, f := range p.model.fields {
+				if v, ok := r.Values[f.column]; ok {
+					row = append(row, v)
+				} else {
+					row = append(row, nil)
+				}
+			}
+			byModel[p.model] = append(byModel[p.model], row)
+		}
+	}
+
+	tx, err := in.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+	var duplicates int64
+	for m, rows := range byModel {
+		// Os nomes de tabela e coluna vêm de Models
+		staging := m.Table + "_staging"
+		columns := strings.Join(m.columns(), ", ")
+		_, err := tx.Exec(ctx, fmt.Sprintf(`CREATE TEMP TABLE %s ON COMMIT DROP AS
+			SELECT %s FROM %s WITH NO DATA`, staging, columns, m.Table))
+		if err != nil {
+			return 0, err
+		}
+		copied, err := tx.CopyFrom(ctx, pgx.Identifier{staging}, m.columns(), pgx.CopyFromRows(rows))
+		if err != nil {
+			return 0, err
+		}
+		tag, err := tx.Exec(ctx, fmt.Sprintf(`
+			INSERT INTO %[1]s (%[2]s)
+			SELECT %[2]s FROM %[3]s ORDER BY timestamp
+			ON CONFLICT (equipmentid, timestamp) DO NOTHING`, m.Table, columns, staging))
+		if err != nil {
+			return 0, err
+		}
+		duplicates += copied - tag.RowsAffected()
+	}
+	for e := range notify {
+		if err := stream.Notify(ctx, tx, e.Table, e.EquipmentID); err != nil {
+			return 0, err
+		}
+	}
+	return duplicates, tx.Commit(ctx)
+}
@@ -0,0 +1,456 @@
+package telemetry
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const testEquipment = "6f1c2a9e-7a51-4c8e-9d3b-1f0e2d4c5b6a"
+
+// fakeDB simula as tabelas gravadas pelo Ingestor: as leituras, por tabela e por equipamento e
+// timestamp (com o índice único), e MQTTDeadLetters
+type fakeDB struct {
+	mu          sync.Mutex
+	rows        map[string]map[string][]interface{}
+	deadLetters []string // "tópico: motivo"
+	notified    int
+	fail        error   // Devolvido por Begin, simulando o banco indisponível
+	reject      float64 // Valor que o COPY recusa com um erro do PostgreSQL
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{rows: map[string]map[string][]interface{}{}}
+}
+
+func (db *fakeDB) count(table string) int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return len(db.rows[table])
+}
+
+func (db *fakeDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.fail != nil {
+		return nil, db.fail
+	}
+	return &fakeTx{db: db, staged: map[string][][]interface{}{}}, nil
+}
+
+func (db *fakeDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if !strings.Contains(sql, "MQTTDeadLetters") {
+		return pgconn.CommandTag{}, fmt.Errorf("consulta inesperada: %s", sql)
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.deadLetters = append(db.deadLetters, fmt.Sprintf("%s: %s", args[0], args[2]))
+	return pgconn.NewCommandTag("INSERT 0 1"), nil
+}
+
+func (db *fakeDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return existsRow(strings.Contains(sql, "equipments"))
+}
+
+// existsRow responde à consulta de existência do equipamento
+type existsRow bool
+
+func (r existsRow) Scan(dest ...interface{}) error {
+	*dest[0].(*bool) = bool(r)
+	return nil
+}
+
+// fakeTx implementa as operações de pgx.Tx usadas por insert; as linhas só chegam a fakeDB no commit
+type fakeTx struct {
+	pgx.Tx
+	db       *fakeDB
+	staged   map[string][][]interface{}
+	inserted map[string]map[string][]interface{}
+	notified int
+}
+
+func (tx *fakeTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	fields := strings.Fields(sql)
+	switch {
+	case fields[0] == "CREATE":
+		return pgconn.NewCommandTag("SELECT 0"), nil
+	case fields[0] == "INSERT" && strings.Contains(sql, "ON CONFLICT (equipmentid, timestamp) DO NOTHING"):
+		table := fields[2]
+		if tx.inserted == nil {
+			tx.inserted = map[string]map[string][]interface{}{}
+		}
+		if tx.inserted[table] == nil {
+			tx.inserted[table] = map[string][]interface{}{}
+		}
+		tx.db.mu.Lock()
+		defer tx.db.mu.Unlock()
+		n := 0
+		for _, row := range tx.staged[table+"_staging"] {
+			key := fmt.Sprint(row[0], row[1])
+			_, committed := tx.db.rows[table][key]
+			if _, ok := tx.inserted[table][key]; ok || committed {
+				continue
+			}
+			tx.inserted[table][key] = row
+			n++
+		}
+		return pgconn.NewCommandTag(fmt.Sprintf("INSERT 0 %d", n)), nil
+	case strings.Contains(sql, "pg_notify"):
+		tx.notified++
+		return pgconn.NewCommandTag("SELECT 1"), nil
+	}
+	return pgconn.CommandTag{}, fmt.Errorf("consulta inesperada: %s", sql)
+}
+
+func (tx *fakeTx) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error) {
+	var n int64
+	for src.Next() {
+		row, err := src.Values()
+		if err != nil {
+			return 0, err
+		}
+		for _, v := range row[2:] {
+			if v == tx.db.reject {
+				return 0, &pgconn.PgError{Severity: "ERROR", Code: "22003", Message: "numeric field overflow"}
+			}
+		}
+		tx.staged[table[0]] = append(tx.staged[table[0]], row)
+		n++
+	}
+	return n, nil
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	for table, rows := range tx.inserted {
+		if tx.db.rows[table] == nil {
+			tx.db.rows[table] = map[string][]interface{}{}
+		}
+		for key, row := range rows {
+			tx.db.rows[table][key] = row
+		}
+	}
+	tx.db.notified += tx.notified
+	return nil
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error { return nil }
+
+// testBroker é um broker MQTT 3.1.1 mínimo: aceita a conexão e as assinaturas do cliente, publica
+// com QoS 1 e repassa os PUBACK recebidos
+type testBroker struct {
+	ln         net.Listener
+	subscribed chan net.Conn
+	acks       chan uint16
+
+	mu sync.Mutex // Serializa as escritas nas conexões
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	b := &testBroker{ln: ln, subscribed: make(chan net.Conn, 1), acks: make(chan uint16, 100)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *testBroker) url() string {
+	return "tcp://" + b.ln.Addr().String()
+}
+
+func (b *testBroker) write(conn net.Conn, header byte, body []byte) {
+	packet := []byte{header}
+	for n := len(body); ; {
+		digit := byte(n % 128)
+		if n /= 128; n > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if n == 0 {
+			break
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	conn.Write(append(packet, body...))
+}
+
+func (b *testBroker) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		header, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		length, multiplier := 0, 1
+		for {
+			digit, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+			length += int(digit&0x7F) * multiplier
+			multiplier *= 128
+			if digit&0x80 == 0 {
+				break
+			}
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case 1: // CONNECT
+			b.write(conn, 0x20, []byte{0, 0})
+		case 8: // SUBSCRIBE
+			granted := []byte{body[0], body[1]}
+			for rest := body[2:]; len(rest) > 0; {
+				n := int(binary.BigEndian.Uint16(rest))
+				granted = append(granted, rest[2+n])
+				rest = rest[3+n:]
+			}
+			b.write(conn, 0x90, granted)
+			b.subscribed <- conn
+		case 4: // PUBACK
+			b.acks <- binary.BigEndian.Uint16(body)
+		case 12: // PINGREQ
+			b.write(conn, 0xD0, nil)
+		case 14: // DISCONNECT
+			// O paho pode enviar o DISCONNECT antes dos últimos PUBACK; lê até o cliente fechar a conexão
+		}
+	}
+}
+
+// publish envia uma mensagem com QoS 1 ao cliente
+func (b *testBroker) publish(conn net.Conn, id uint16, topic, payload string) {
+	body := binary.BigEndian.AppendUint16(nil, uint16(len(topic)))
+	body = append(body, topic...)
+	body = binary.BigEndian.AppendUint16(body, id)
+	b.write(conn, 0x32, append(body, payload...))
+}
+
+// expectAcks aguarda a confirmação das mensagens informadas, em qualquer ordem
+func (b *testBroker) expectAcks(t *testing.T, ids ...uint16) {
+	t.Helper()
+	want := map[uint16]bool{}
+	for _, id := range ids {
+		want[id] = true
+	}
+	timeout := time.After(5 * time.Second)
+	for len(want) > 0 {
+		select {
+		case id := <-b.acks:
+			if !want[id] {
+				t.Fatalf("confirmação inesperada da mensagem %d", id)
+			}
+			delete(want, id)
+		case <-timeout:
+			t.Fatalf("mensagens não confirmadas: %v", want)
+		}
+	}
+}
+
+// expectNoAck confere que nenhuma mensagem é confirmada por um instante
+func (b *testBroker) expectNoAck(t *testing.T) {
+	t.Helper()
+	select {
+	case id := <-b.acks:
+		t.Fatalf("confirmação inesperada da mensagem %d", id)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func testConfig(broker string, batchSize, maxBacklog int) *Config {
+	level := 1
+	return &Config{
+		Broker: broker, ClientID: "telemetry-test", QoS: 1, BatchSize: batchSize,
+		FlushInterval: time.Hour, MaxBacklog: maxBacklog,
+		Routes: []*Route{{
+			Topic: "stations/+/tower", Table: "towermicrometeorologicaldata", EquipmentLevel: &level,
+			model: Models["towermicrometeorologicaldata"],
+		}},
+	}
+}
+
+func TestIngestorBatchingAndDeadLetters(t *testing.T) {
+	broker := newTestBroker(t)
+	db := newFakeDB()
+	db.reject = 1e9
+	cfg := testConfig(broker.url(), 3, 30)
+	in := newIngestor(db, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- Subscribe(ctx, cfg, in) }()
+
+	var conn net.Conn
+	select {
+	case conn = <-broker.subscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("o cliente não assinou os tópicos")
+	}
+	topic := "stations/" + testEquipment + "/tower"
+	const table = "towermicrometeorologicaldata"
+
+	// Mensagens inválidas vão para as dead letters e são confirmadas na hora
+	broker.publish(conn, 1, topic, `{"timestamp": "2024-01-01T00:00:00Z", "gust": 12}`)
+	broker.publish(conn, 2, "stations/estacao-1/tower", `{"timestamp": "2024-01-01T00:00:00Z", "wind_speed": 7}`)
+	broker.expectAcks(t, 1, 2)
+
+	// As válidas aguardam o lote completar (BatchSize leituras) e só são confirmadas após a gravação
+	broker.publish(conn, 3, topic, `{"timestamp": "2024-01-01T00:00:00Z", "wind_speed": 7.2}`)
+	broker.publish(conn, 4, topic, `{"timestamp": "2024-01-01T00:10:00Z", "wind_speed": 7.4}`)
+	broker.expectNoAck(t)
+	if n := db.count(table); n != 0 {
+		t.Fatalf("%d leituras gravadas antes de o lote completar", n)
+	}
+	broker.publish(conn, 5, topic, `[{"timestamp": "2024-01-01T00:20:00Z", "wind_speed": 7.1},
+		{"timestamp": "2024-01-01T00:30:00Z", "wind_speed": 6.9}]`)
+	broker.expectAcks(t, 3, 4, 5)
+	if n := db.count(table); n != 4 {
+		t.Errorf("%d leituras gravadas, esperado 4", n)
+	}
+
+	// Uma mensagem reentregue não duplica as leituras; uma recusada pelo banco vai para as dead
+	// letters sem impedir a gravação das demais do lote
+	broker.publish(conn, 6, topic, `{"timestamp": "2024-01-01T00:00:00Z", "wind_speed": 7.2}`)
+	broker.publish(conn, 7, topic, `{"timestamp": "2024-01-01T00:40:00Z", "wind_speed": 1e9}`)
+	broker.publish(conn, 8, topic, `{"timestamp": "2024-01-01T00:50:00Z", "wind_speed": 7.0}`)
+	broker.expectAcks(t, 6, 7, 8)
+	if n := db.count(table); n != 5 {
+		t.Errorf("%d leituras gravadas, esperado 5", n)
+	}
+
+	// A última gravação, no encerramento, grava o lote incompleto. O PUBACK pode sair depois do
+	// DISCONNECT e se perder; nesse caso o broker reenvia a mensagem, que é descartada como duplicada.
+	broker.publish(conn, 9, topic, `{"timestamp": "2024-01-01T01:00:00Z", "temperature": 21}`)
+	broker.expectNoAck(t)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if n := db.count(table); n != 6 {
+		t.Errorf("%d leituras gravadas, esperado 6", n)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	// As mensagens são tratadas em paralelo: as duas primeiras dead letters podem vir em qualquer ordem
+	sort.Strings(db.deadLetters[:2])
+	want := []string{
+		"stations/" + testEquipment + "/tower: mensagem inválida: leitura 0: campo \"gust\" não existe em " + table,
+		"stations/estacao-1/tower: mensagem inválida: equipamento estacao-1 inexistente",
+		"stations/" + testEquipment + "/tower: gravação rejeitada: ERROR: numeric field overflow (SQLSTATE 22003)",
+	}
+	if !reflect.DeepEqual(db.deadLetters, want) {
+		t.Errorf("dead letters:\n%q\nesperado:\n%q", db.deadLetters, want)
+	}
+	if db.notified == 0 {
+		t.Error("nenhum aviso aos streams ao vivo")
+	}
+}
+
+func TestIngestorBacklog(t *testing.T) {
+	db := newFakeDB()
+	db.fail = errors.New("connection refused")
+	in := newIngestor(db, testConfig("", 2, 4))
+	redelivered := 0
+	in.redeliver = func() { redelivered++ }
+
+	acked := map[int]bool{}
+	handle := func(i int) {
+		payload := fmt.Sprintf(`{"timestamp": %d, "wind_speed": 7}`, 1700000000+600*i)
+		in.Handle(context.Background(), "stations/"+testEquipment+"/tower", []byte(payload), func() { acked[i] = true })
+	}
+
+	// Com o banco indisponível o lote volta para a fila, limitada a MaxBacklog leituras
+	handle(0)
+	handle(1)
+	in.Flush(context.Background())
+	handle(2)
+	handle(3)
+	handle(4) // Excede MaxBacklog: não é confirmada
+	if in.rows != 4 || len(in.batch) != 4 || in.dropped != 1 {
+		t.Fatalf("fila com %d leituras em %d mensagens, %d descartadas", in.rows, len(in.batch), in.dropped)
+	}
+	in.Flush(context.Background())
+	if len(acked) != 0 || in.rows != 4 {
+		t.Fatalf("confirmadas %v com o banco indisponível, %d leituras na fila", acked, in.rows)
+	}
+
+	// Com o banco de volta o lote é gravado e o broker é chamado a reenviar as descartadas
+	db.mu.Lock()
+	db.fail = nil
+	db.mu.Unlock()
+	in.Flush(context.Background())
+	if len(acked) != 4 || acked[4] || redelivered != 1 {
+		t.Errorf("confirmadas %v, %d pedidos de reenvio", acked, redelivered)
+	}
+	if n := db.count("towermicrometeorologicaldata"); n != 4 {
+		t.Errorf("%d leituras gravadas, esperado 4", n)
+	}
+	in.Flush(context.Background())
+	if redelivered != 1 {
+		t.Errorf("%d pedidos de reenvio, esperado 1", redelivered)
+	}
+}
+
+func TestIngestorRedelivery(t *testing.T) {
+	db := newFakeDB()
+	in := newIngestor(db, testConfig("", 10, 100))
+	topic := "stations/" + testEquipment + "/tower"
+	const table = "towermicrometeorologicaldata"
+	acks := 0
+	ack := func() { acks++ }
+
+	// Um pacote SenML reentregue pelo broker, depois de gravado, resolve para os mesmos timestamps e é
+	// descartado pelo índice único
+	pack := `[{"bn": "urn:dev:tower1:", "bt": 1700000000, "n": "wind_speed", "v": 7.2},
+		{"n": "temperature", "v": 21}, {"n": "wind_speed", "v": 7.5, "t": 600}]`
+	in.Handle(context.Background(), topic, []byte(pack), ack)
+	in.Flush(context.Background())
+	in.Handle(context.Background(), topic, []byte(pack), ack)
+	in.Flush(context.Background())
+	if n := db.count(table); n != 2 || acks != 2 {
+		t.Errorf("%d leituras gravadas e %d confirmações, esperado 2 e 2", n, acks)
+	}
+
+	// Tempos relativos ao recebimento mudariam a cada entrega: o pacote vai para as dead letters
+	in.Handle(context.Background(), topic, []byte(`[{"n": "wind_speed", "v": 7.2, "t": -60}]`), ack)
+	in.Flush(context.Background())
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if len(db.deadLetters) != 1 || !strings.Contains(db.deadLetters[0], "tempo relativo") {
+		t.Errorf("dead letters = %q, esperado o pacote com tempo relativo", db.deadLetters)
+	}
+	if n := len(db.rows[table]); n != 2 || acks != 3 {
+		t.Errorf("%d leituras gravadas e %d confirmações, esperado 2 e 3", n, acks)
+	}
+}
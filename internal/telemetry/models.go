@@ -0,0 +1,72 @@
+// Package telemetry recebe leituras publicadas pelas estações via MQTT. Cada mensagem é associada por
+// tópico a um equipamento e a uma tabela de dados, validada contra o modelo da tabela (JSON ou SenML)
+// e gravada em lotes; mensagens inválidas vão para MQTTDeadLetters.
+package telemetry
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"api/internal/models"
+)
+
+// field é uma grandeza do modelo: o campo no JSON e a coluna na tabela
+type field struct {
+	json   string
+	column string
+}
+
+// Model descreve uma tabela de dados que pode receber leituras
+type Model struct {
+	Table  string
+	fields []field
+	byJSON map[string]string // Campo no JSON → coluna
+}
+
+// modelTypes associa as tabelas aos modelos usados pelos handlers JSON de registro único
+var modelTypes = map[string]interface{}{
+	"towermicrometeorologicaldata": models.TowerMicrometeorologicalData{},
+	"sodardata":                    models.SodarData{},
+	"adcpdata":                     models.ADCPData{},
+	"lidarzephydata":               models.LidarZephyData{},
+	"lidarwindcobedata":            models.LidarWindcobeData{},
+}
+
+// Models reúne as tabelas que podem receber leituras, indexadas pelo nome
+var Models = buildModels()
+
+// buildModels extrai as grandezas numéricas de cada modelo: o campo vem da tag json e a coluna é o nome
+// do campo Go em minúsculas, como nos handlers (WindSpeed → windspeed)
+func buildModels() map[string]*Model {
+	timeType := reflect.TypeOf(time.Time{})
+	result := map[string]*Model{}
+	for table, v := range modelTypes {
+		m := &Model{Table: table, byJSON: map[string]string{}}
+		t := reflect.TypeOf(v)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "" || name == "id" || name == "equipment_id" || f.Type == timeType {
+				continue
+			}
+			if f.Type.Kind() != reflect.Float64 {
+				continue
+			}
+			column := strings.ToLower(f.Name)
+			m.fields = append(m.fields, field{json: name, column: column})
+			m.byJSON[name] = column
+		}
+		result[table] = m
+	}
+	return result
+}
+
+// columns retorna as colunas gravadas pelo COPY: equipamento, timestamp e as grandezas do modelo
+func (m *Model) columns() []string {
+	columns := []string{"equipmentid", "timestamp"}
+	for _, f := range m.fields {
+		columns = append(columns, f.column)
+	}
+	return columns
+}
@@ -0,0 +1,73 @@
+package telemetry
+
+import (
+	"context"
+	"log"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// connectRetry é o intervalo entre tentativas de conexão ao broker
+const connectRetry = 10 * time.Second
+
+// Subscribe conecta ao broker, assina os tópicos das rotas e entrega as mensagens ao Ingestor até ctx
+// ser cancelado. A sessão é persistente (clean session desativada) e as mensagens só são confirmadas
+// depois de gravadas, então nada se perde em reinícios com QoS 1 ou 2.
+func Subscribe(ctx context.Context, cfg *Config, in *Ingestor) error {
+	filters := map[string]byte{}
+	for _, r := range cfg.Routes {
+		filters[r.Topic] = cfg.QoS
+	}
+	handler := func(_ mqtt.Client, msg mqtt.Message) {
+		in.Handle(ctx, msg.Topic(), msg.Payload(), msg.Ack)
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetCleanSession(false).
+		SetAutoAckDisabled(true).
+		SetOrderMatters(false).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(connectRetry).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Println("telemetry: connection to broker lost:", err)
+		}).
+		SetOnConnectHandler(func(c mqtt.Client) {
+			// Assina de novo a cada conexão; com a sessão persistente o broker entrega o que ficou retido
+			token := c.SubscribeMultiple(filters, handler)
+			token.Wait()
+			if err := token.Error(); err != nil {
+				log.Println("telemetry: failed to subscribe:", err)
+				return
+			}
+			log.Printf("telemetry: connected to %s, subscribed to %d topics", cfg.Broker, len(filters))
+		})
+
+	client := mqtt.NewClient(opts)
+	in.redeliver = func() {
+		// O broker só reenvia as mensagens não confirmadas em uma nova conexão da sessão persistente
+		// (com ConnectRetry, Connect tenta de novo até conseguir)
+		client.Disconnect(250)
+		client.Connect()
+	}
+	token := client.Connect()
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return err
+		}
+	case <-ctx.Done():
+	}
+
+	// A última gravação confirma as mensagens pendentes; as que chegarem depois dela não são
+	// confirmadas e o broker as entrega de novo na próxima conexão. O paho pode enviar o DISCONNECT
+	// antes das últimas confirmações: essas mensagens também voltam e são descartadas como duplicadas.
+	in.Run(ctx)
+	client.Disconnect(1000)
+	return nil
+}
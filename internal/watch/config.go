@@ -0,0 +1,138 @@
+// Package watch importa os arquivos que os registradores de campo depositam em diretórios monitorados.
+// Cada arquivo tem o formato identificado pela assinatura, é associado a um equipamento e a uma
+// campanha pelo nome e, após a importação, é arquivado ou movido para a quarentena com um relatório
+// do erro. As impressões digitais (SHA-256) ficam em WatchedFiles para que reinícios não importem o
+// mesmo arquivo duas vezes.
+package watch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"api/internal/parsers"
+
+	"github.com/google/uuid"
+)
+
+// Valores padrão da configuração
+const (
+	DefaultPollInterval = 30 * time.Second
+	DefaultSettleTime   = time.Minute
+)
+
+// Mapping associa os arquivos cujo nome corresponde a Pattern a um equipamento e a uma campanha
+type Mapping struct {
+	Pattern     string `json:"pattern"`            // Padrão de path.Match aplicado ao nome do arquivo (ex.: "WLS7-*.sta")
+	EquipmentID string `json:"equipment_id"`       // UUID do equipamento
+	CampaignID  string `json:"campaign_id"`        // UUID da campanha
+	Format      string `json:"format,omitempty"`   // Força o formato (TOA5, WINDCUBE, SODAR ou PD0) em vez da assinatura
+	Timezone    string `json:"timezone,omitempty"` // Fuso do relógio do registrador (TOA5 e SODAR; padrão UTC)
+
+	location *time.Location
+}
+
+// Directory é um diretório monitorado
+type Directory struct {
+	Path       string    `json:"path"`
+	Archive    string    `json:"archive"`    // Destino dos arquivos importados (subpastas AAAA/MM/DD)
+	Quarantine string    `json:"quarantine"` // Destino dos arquivos com erro, acompanhados de <nome>.error.txt
+	Mappings   []Mapping `json:"mappings"`   // Avaliados em ordem; vale o primeiro que corresponder
+}
+
+// Config reúne os diretórios monitorados
+type Config struct {
+	PollInterval time.Duration
+	SettleTime   time.Duration // Tempo sem alterações para considerar um arquivo completo
+	Directories  []*Directory
+}
+
+// configFile é o formato do arquivo JSON de configuração
+type configFile struct {
+	PollInterval string       `json:"poll_interval"` // Duração Go (ex.: "30s")
+	SettleTime   string       `json:"settle_time"`
+	Directories  []*Directory `json:"directories"`
+}
+
+// LoadConfig lê e valida o arquivo JSON de configuração
+func LoadConfig(file string) (*Config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var raw configFile
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("watch: configuração inválida em %s: %w", file, err)
+	}
+
+	cfg := &Config{PollInterval: DefaultPollInterval, SettleTime: DefaultSettleTime, Directories: raw.Directories}
+	if raw.PollInterval != "" {
+		if cfg.PollInterval, err = time.ParseDuration(raw.PollInterval); err != nil || cfg.PollInterval <= 0 {
+			return nil, fmt.Errorf("watch: poll_interval inválido: %q", raw.PollInterval)
+		}
+	}
+	if raw.SettleTime != "" {
+		if cfg.SettleTime, err = time.ParseDuration(raw.SettleTime); err != nil || cfg.SettleTime < 0 {
+			return nil, fmt.Errorf("watch: settle_time inválido: %q", raw.SettleTime)
+		}
+	}
+	if len(cfg.Directories) == 0 {
+		return nil, fmt.Errorf("watch: nenhum diretório configurado em %s", file)
+	}
+
+	for _, d := range cfg.Directories {
+		if d.Path == "" || d.Archive == "" || d.Quarantine == "" {
+			return nil, fmt.Errorf("watch: path, archive e quarantine são obrigatórios em cada diretório")
+		}
+		for _, p := range []*string{&d.Path, &d.Archive, &d.Quarantine} {
+			if *p, err = filepath.Abs(*p); err != nil {
+				return nil, err
+			}
+		}
+		if d.Archive == d.Path || d.Quarantine == d.Path {
+			return nil, fmt.Errorf("watch: archive e quarantine devem ser diferentes de %s", d.Path)
+		}
+		for i := range d.Mappings {
+			m := &d.Mappings[i]
+			if _, err := path.Match(m.Pattern, ""); err != nil || m.Pattern == "" {
+				return nil, fmt.Errorf("watch: padrão inválido %q em %s", m.Pattern, d.Path)
+			}
+			if m.EquipmentID == "" || m.CampaignID == "" {
+				return nil, fmt.Errorf("watch: equipment_id e campaign_id são obrigatórios no padrão %q", m.Pattern)
+			}
+			for _, id := range []*string{&m.EquipmentID, &m.CampaignID} {
+				parsed, err := uuid.Parse(*id)
+				if err != nil {
+					return nil, fmt.Errorf("watch: identificador inválido %q no padrão %q", *id, m.Pattern)
+				}
+				*id = parsed.String()
+			}
+			if m.Format != "" {
+				m.Format = strings.ToUpper(m.Format)
+				if !knownFormat(m.Format) {
+					return nil, fmt.Errorf("watch: formato inválido %q no padrão %q", m.Format, m.Pattern)
+				}
+			}
+			if m.Timezone != "" {
+				if m.location, err = parsers.ParseTimezone(m.Timezone); err != nil {
+					return nil, fmt.Errorf("watch: %v no padrão %q", err, m.Pattern)
+				}
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// match retorna o primeiro mapeamento que corresponde ao nome do arquivo
+func (d *Directory) match(name string) *Mapping {
+	for i := range d.Mappings {
+		if ok, _ := path.Match(d.Mappings[i].Pattern, name); ok {
+			return &d.Mappings[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package watch
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"api/internal/ingest"
+)
+
+// Formatos reconhecidos, com os mesmos nomes dos parsers dos jobs de importação
+const (
+	FormatTOA5     = ingest.ParserTOA5
+	FormatWindCube = ingest.ParserWindCube
+	FormatSODAR    = ingest.ParserSODAR
+	FormatPD0      = ingest.ParserPD0
+)
+
+// signatureSize é a quantidade de bytes lida do início do arquivo para identificar o formato
+const signatureSize = 512
+
+// knownFormat indica se format é um dos formatos reconhecidos
+func knownFormat(format string) bool {
+	switch format {
+	case FormatTOA5, FormatWindCube, FormatSODAR, FormatPD0:
+		return true
+	}
+	return false
+}
+
+// Detect identifica o formato do arquivo pelo conteúdo inicial e, quando ele não é conclusivo, pela
+// extensão. Retorna "" para arquivos não reconhecidos.
+func Detect(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, signatureSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return detect(head[:n], filepath.Ext(file)), nil
+}
+
+// detect identifica o formato pelos primeiros bytes e pela extensão
+func detect(head []byte, ext string) string {
+	// Ensemble PD0: identificador 0x7F seguido da fonte de dados 0x7F
+	if len(head) >= 2 && head[0] == 0x7F && head[1] == 0x7F {
+		return FormatPD0
+	}
+	text := bytes.TrimPrefix(head, []byte("\ufeff"))
+	first := strings.ToUpper(strings.TrimSpace(string(text[:lineEnd(text)])))
+	switch {
+	case strings.HasPrefix(first, `"TOA5"`), strings.HasPrefix(first, "TOA5,"):
+		return FormatTOA5
+	case strings.HasPrefix(first, "FORMAT-"):
+		return FormatSODAR
+	case strings.HasPrefix(first, "HEADERSIZE="):
+		return FormatWindCube
+	}
+	switch strings.ToLower(ext) {
+	case ".sta", ".rtd":
+		return FormatWindCube
+	case ".mnd":
+		return FormatSODAR
+	case ".pd0", ".000":
+		return FormatPD0
+	}
+	return ""
+}
+
+// lineEnd retorna a posição do fim da primeira linha
+func lineEnd(b []byte) int {
+	if i := bytes.IndexAny(b, "\r\n"); i >= 0 {
+		return i
+	}
+	return len(b)
+}
@@ -0,0 +1,400 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"api/internal/ingest"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Situação de um arquivo em WatchedFiles
+const (
+	StatusProcessing = "processing"
+	StatusIngested   = "ingested"
+	StatusFailed     = "failed"
+)
+
+// errUnmapped indica um arquivo sem mapeamento para equipamento e campanha
+var errUnmapped = errors.New("nenhum mapeamento corresponde ao nome do arquivo")
+
+// errUnknownFormat indica um arquivo cuja assinatura não foi reconhecida
+var errUnknownFormat = errors.New("formato não reconhecido (esperado TOA5, WindCube .sta/.rtd, SODAR .mnd ou PD0)")
+
+// observation é o tamanho e a data de modificação de um arquivo na última varredura
+type observation struct {
+	size    int64
+	modTime time.Time
+}
+
+// Watcher monitora os diretórios configurados
+type Watcher struct {
+	db      *pgxpool.Pool
+	cfg     *Config
+	pending map[string]observation // Arquivos vistos na varredura anterior, por caminho
+	once    bool                   // Varredura única: todos os arquivos são considerados completos
+}
+
+// New cria um Watcher para a configuração
+func New(db *pgxpool.Pool, cfg *Config) *Watcher {
+	return &Watcher{db: db, cfg: cfg, pending: map[string]observation{}}
+}
+
+// prepare cria os diretórios e libera as importações interrompidas por uma parada anterior
+func (w *Watcher) prepare(ctx context.Context) error {
+	for _, d := range w.cfg.Directories {
+		for _, dir := range []string{d.Path, d.Archive, d.Quarantine} {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+		}
+	}
+	// Uma importação em andamento quando o processo parou pode ter gravado linhas: o job dela é
+	// desfeito e o arquivo, que continua no diretório, é importado de novo
+	rows, err := w.db.Query(ctx, `
+		SELECT f.watchedfileid::text, j.ingestionjobid::text FROM WatchedFiles f
+		LEFT JOIN IngestionJobs j ON j.ingestionjobid = f.ingestionjobid AND j.status IN ('queued', 'running')
+		WHERE f.status = 'processing'`)
+	if err != nil {
+		return err
+	}
+	type interrupted struct {
+		file string
+		job  *string
+	}
+	var pending []interrupted
+	for rows.Next() {
+		var i interrupted
+		if err := rows.Scan(&i.file, &i.job); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, i)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, i := range pending {
+		if i.job != nil {
+			rb, err := ingest.DeleteInterruptedJob(ctx, w.db, *i.job)
+			if err != nil {
+				return fmt.Errorf("rollback of interrupted job %s: %w", *i.job, err)
+			}
+			log.Printf("watch: interrupted job %s rolled back (%d rows removed from %s)", *i.job, rb.RowsDeleted, rb.Table)
+		}
+		if _, err := w.db.Exec(ctx, `
+			UPDATE WatchedFiles SET status = 'failed', error = 'importação interrompida', finishedat = now()
+			WHERE watchedfileid = $1::uuid`, i.file); err != nil {
+			return err
+		}
+	}
+	if len(pending) > 0 {
+		log.Printf("watch: %d interrupted imports will be retried", len(pending))
+	}
+	return nil
+}
+
+// RunOnce varre os diretórios uma única vez, sem aguardar a estabilização dos arquivos
+func (w *Watcher) RunOnce(ctx context.Context) error {
+	if err := w.prepare(ctx); err != nil {
+		return err
+	}
+	w.once = true
+	w.Poll(ctx)
+	return ctx.Err()
+}
+
+// Run varre os diretórios a cada PollInterval até ctx ser cancelado
+func (w *Watcher) Run(ctx context.Context) error {
+	if err := w.prepare(ctx); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		w.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll varre uma vez todos os diretórios e importa os arquivos completos
+func (w *Watcher) Poll(ctx context.Context) {
+	seen := map[string]bool{}
+	for _, d := range w.cfg.Directories {
+		entries, err := os.ReadDir(d.Path)
+		if err != nil {
+			log.Printf("watch: failed to read %s: %v", d.Path, err)
+			continue
+		}
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				return
+			}
+			name := entry.Name()
+			if entry.IsDir() || ignored(name) {
+				continue
+			}
+			file := filepath.Join(d.Path, name)
+			seen[file] = true
+			if w.ready(file) {
+				delete(w.pending, file)
+				w.process(ctx, d, file)
+			}
+		}
+	}
+	for file := range w.pending {
+		if !seen[file] {
+			delete(w.pending, file)
+		}
+	}
+}
+
+// ignored indica arquivos ocultos ou temporários de transferências em andamento
+func ignored(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(lower, ".part") ||
+		strings.HasSuffix(lower, ".filepart") || strings.HasSuffix(lower, ".tmp") ||
+		strings.HasSuffix(lower, ".error.txt")
+}
+
+// ready indica se o arquivo está completo: sem alterações desde a varredura anterior e há pelo menos
+// SettleTime
+func (w *Watcher) ready(file string) bool {
+	info, err := os.Stat(file)
+	if err != nil {
+		return false
+	}
+	if w.once {
+		return true
+	}
+	current := observation{size: info.Size(), modTime: info.ModTime()}
+	previous, ok := w.pending[file]
+	w.pending[file] = current
+	return ok && previous == current && time.Since(current.modTime) >= w.cfg.SettleTime
+}
+
+// fingerprint calcula o SHA-256 do arquivo
+func fingerprint(file string) (string, int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	return ingest.Fingerprint(f)
+}
+
+// process importa um arquivo e o move para o arquivo morto ou para a quarentena. Um conteúdo já
+// importado (mesmo SHA-256) é apenas arquivado.
+func (w *Watcher) process(ctx context.Context, d *Directory, file string) {
+	name := filepath.Base(file)
+	sum, size, err := fingerprint(file)
+	if err != nil {
+		log.Printf("watch: failed to read %s: %v", file, err)
+		return
+	}
+
+	var id string
+	err = w.db.QueryRow(ctx, `
+		INSERT INTO WatchedFiles (sha256, filename, sourcepath, filesize, status)
+		VALUES ($1, $2, $3, $4, 'processing')
+		ON CONFLICT (sha256) WHERE status IN ('processing', 'ingested') DO NOTHING
+		RETURNING watchedfileid::text`, sum, name, file, size).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var status string
+		if err := w.db.QueryRow(ctx, `
+			SELECT status FROM WatchedFiles WHERE sha256 = $1 AND status IN ('processing', 'ingested')`,
+			sum).Scan(&status); err != nil {
+			log.Printf("watch: failed to query state of %s: %v", file, err)
+			return
+		}
+		if status == StatusIngested {
+			log.Printf("watch: %s was already ingested (sha256 %s), archiving", name, sum)
+			if _, err := archive(file, d.Archive, sum); err != nil {
+				log.Printf("watch: failed to archive %s: %v", file, err)
+			}
+		}
+		// Em processamento por outra instância: tenta de novo na próxima varredura
+		return
+	}
+	if err != nil {
+		log.Printf("watch: failed to register %s: %v", file, err)
+		return
+	}
+
+	mapping := d.match(name)
+	format := ""
+	var summary *ingest.Summary
+	switch {
+	case mapping == nil:
+		err = errUnmapped
+	default:
+		format = mapping.Format
+		if format == "" {
+			format, err = Detect(file)
+		}
+		if err == nil && format == "" {
+			err = errUnknownFormat
+		}
+		if err == nil {
+			summary, err = w.runJob(ctx, id, file, sum, size, format, mapping)
+		}
+	}
+	if errors.Is(err, ingest.ErrDuplicateFile) {
+		// Conteúdo já importado por outro job (um upload, por exemplo): o arquivo é apenas arquivado
+		log.Printf("watch: %s was already ingested by job %s, archiving", name, summary.JobID)
+		err = nil
+	}
+
+	if err != nil {
+		w.fail(ctx, d, file, id, sum, format, mapping, err)
+		return
+	}
+	_, dbErr := w.db.Exec(ctx, `
+		UPDATE WatchedFiles SET status = 'ingested', format = $2, equipmentid = $3, campaignid = $4,
+		       headerid = NULLIF($5, '')::uuid, ingestionjobid = NULLIF($6, '')::uuid, rowsinserted = $7,
+		       rowsduplicate = $8, rowsskipped = $9, finishedat = now()
+		WHERE watchedfileid = $1::uuid`,
+		id, format, mapping.EquipmentID, mapping.CampaignID, summary.HeaderID, summary.JobID, summary.RowsInserted,
+		summary.RowsDuplicate, summary.RowsSkipped)
+	if dbErr != nil {
+		log.Printf("watch: failed to record ingestion of %s: %v", file, dbErr)
+	}
+	dest, err := archive(file, d.Archive, sum)
+	if err != nil {
+		log.Printf("watch: failed to archive %s: %v", file, err)
+		return
+	}
+	log.Printf("watch: %s ingested as %s (%d rows, %d duplicate, %d skipped), archived to %s",
+		name, format, summary.RowsInserted, summary.RowsDuplicate, summary.RowsSkipped, dest)
+}
+
+// fail registra a falha, move o arquivo para a quarentena e grava o relatório ao lado dele
+func (w *Watcher) fail(ctx context.Context, d *Directory, file, id, sum, format string, mapping *Mapping, cause error) {
+	log.Printf("watch: failed to ingest %s: %v", file, cause)
+	if _, err := w.db.Exec(ctx, `
+		UPDATE WatchedFiles SET status = 'failed', format = NULLIF($2, ''), error = $3, finishedat = now()
+		WHERE watchedfileid = $1::uuid`, id, format, cause.Error()); err != nil {
+		log.Printf("watch: failed to record failure of %s: %v", file, err)
+	}
+	dest, err := moveUnique(file, d.Quarantine, sum)
+	if err != nil {
+		log.Printf("watch: failed to quarantine %s: %v", file, err)
+		return
+	}
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "arquivo: %s\n", file)
+	fmt.Fprintf(&report, "sha256: %s\n", sum)
+	fmt.Fprintf(&report, "data: %s\n", time.Now().UTC().Format(time.RFC3339))
+	if format != "" {
+		fmt.Fprintf(&report, "formato: %s\n", format)
+	}
+	if mapping != nil {
+		fmt.Fprintf(&report, "padrão: %s\nequipamento: %s\ncampanha: %s\n", mapping.Pattern, mapping.EquipmentID, mapping.CampaignID)
+	}
+	fmt.Fprintf(&report, "erro: %v\n", cause)
+	if err := os.WriteFile(dest+".error.txt", []byte(report.String()), 0o644); err != nil {
+		log.Printf("watch: failed to write error report for %s: %v", dest, err)
+	}
+}
+
+// runJob registra o arquivo como um job de importação, associa o job ao registro id de WatchedFiles e
+// o executa com o loader do formato. Com ErrDuplicateFile, o resumo traz apenas o JobID existente.
+func (w *Watcher) runJob(ctx context.Context, id, file, sum string, size int64, format string, m *Mapping) (*ingest.Summary, error) {
+	name := filepath.Base(file)
+	target := ingest.Target{EquipmentID: m.EquipmentID, CampaignID: m.CampaignID}
+	job, err := ingest.CreateJob(ctx, w.db, sum, name, size, format, ingest.SourceIngestd, target, nil)
+	if errors.Is(err, ingest.ErrDuplicateFile) {
+		return &ingest.Summary{JobID: job.ID}, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.db.Exec(ctx, "UPDATE WatchedFiles SET ingestionjobid = $2 WHERE watchedfileid = $1::uuid", id, job.ID); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return job.Run(ctx, w.db, func(target ingest.Target) (*ingest.Summary, error) {
+		switch format {
+		case FormatTOA5:
+			return ingest.LoadTOA5(ctx, w.db, f, name, target, ingest.TOA5Options{Location: m.location})
+		case FormatWindCube:
+			return ingest.LoadWindCube(ctx, w.db, f, name, target)
+		case FormatSODAR:
+			return ingest.LoadSODAR(ctx, w.db, f, name, target, m.location)
+		case FormatPD0:
+			return ingest.LoadPD0(ctx, w.db, f, name, target)
+		}
+		return nil, errUnknownFormat
+	})
+}
+
+// archive move o arquivo para a subpasta AAAA/MM/DD (UTC) de dir
+func archive(file, dir, sum string) (string, error) {
+	day := filepath.Join(dir, time.Now().UTC().Format("2006/01/02"))
+	if err := os.MkdirAll(day, 0o755); err != nil {
+		return "", err
+	}
+	return moveUnique(file, day, sum)
+}
+
+// moveUnique move o arquivo para dir; se já houver um arquivo com o mesmo nome, acrescenta o início do
+// SHA-256 ao nome
+func moveUnique(file, dir, sum string) (string, error) {
+	name := filepath.Base(file)
+	dest := filepath.Join(dir, name)
+	if _, err := os.Stat(dest); err == nil {
+		ext := filepath.Ext(name)
+		dest = filepath.Join(dir, strings.TrimSuffix(name, ext)+"-"+sum[:12]+ext)
+	}
+	if err := os.Rename(file, dest); err == nil {
+		return dest, nil
+	}
+	// Destino em outro sistema de arquivos: copia e remove a origem
+	if err := copyFile(file, dest); err != nil {
+		return "", err
+	}
+	return dest, os.Remove(file)
+}
+
+// copyFile copia src para dst, sincronizando o destino antes de retornar
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
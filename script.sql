@@ -104,7 +104,7 @@ CREATE TABLE IF NOT EXISTS Notificacoes (
     mensagem TEXT NOT NULL,                                           -- Mensagem da notificação
     data_envio TIMESTAMP DEFAULT CURRENT_TIMESTAMP,                   -- Data em que a notificação foi enviada
     id_noticia UUID,                                                  -- ID da notícia relacionada (UUID agora)
-    tipo VARCHAR(50) CHECK (tipo IN ('sistema', 'marketing', 'atualizacao', 'noticia')),  -- Tipo de notificação
+    tipo VARCHAR(50) CHECK (tipo IN ('sistema', 'marketing', 'atualizacao', 'noticia', 'alerta')),  -- Tipo de notificação
     id_usuario UUID,                                                  -- ID do usuário específico (UUID)
     enviado_para_todos BOOLEAN DEFAULT FALSE,                         -- Indica se a notificação é para todos os usuários
     FOREIGN KEY (id_noticia) REFERENCES Noticias(id_noticia) ON DELETE SET NULL,  -- Chave estrangeira para Noticias
@@ -166,6 +166,7 @@ CREATE TABLE IF NOT EXISTS Equipments (
     OperatingStatus VARCHAR(50) CHECK (OperatingStatus IN ('Em Operação', 'Parado', 'Em Uso', 'Em Manutenção')),  -- Status operacional do equipamento
     Location GEOGRAPHY(Point, 4326),                                            -- Local onde o equipamento está instalado ou armazenado
     CampaignIDs UUID[],                                                         -- IDs das campanhas associadas ao equipamento
+    LoggingInterval INTEGER,                                                    -- Intervalo de registro esperado (s); sem valor, é estimado pelos dados
     EquipmentImage VARCHAR(255)                                                 -- Caminho para imagem do equipamento
 );
 
@@ -234,6 +235,16 @@ CREATE TABLE IF NOT EXISTS EstacaoSolarimetricaHeaders (
     SolarRadiationHeader VARCHAR(255),                                 -- Cabeçalho específico para Solar Radiation
     TemperatureHeader VARCHAR(255),                                    -- Cabeçalho específico para Temperatura
     BarometricPressureHeader VARCHAR(255),                             -- Cabeçalho específico para Pressão Barométrica
+    StationName VARCHAR(255),                                          -- Nome da estação declarado no registrador (TOA5)
+    LoggerModel VARCHAR(50),                                           -- Modelo do registrador (e.g., CR1000)
+    LoggerSerial VARCHAR(50),                                          -- Número de série do registrador
+    LoggerOS VARCHAR(100),                                             -- Versão do sistema operacional do registrador
+    ProgramName VARCHAR(255),                                          -- Programa em execução no registrador
+    ProgramSignature VARCHAR(50),                                      -- Assinatura do programa
+    TableName VARCHAR(100),                                            -- Tabela do registrador exportada no arquivo
+    FieldNames TEXT[],                                                 -- Nomes dos campos (linha 2 do TOA5)
+    Units TEXT[],                                                      -- Unidades dos campos (linha 3 do TOA5)
+    Processing TEXT[],                                                 -- Processamento dos campos (linha 4 do TOA5)
     UploadDate TIMESTAMPTZ DEFAULT now()
 );
 
@@ -250,7 +261,7 @@ CREATE TABLE IF NOT EXISTS LIDARWindCubeHeaders (
     GPSLocation VARCHAR(100),
     Comments TEXT,
     FCROption VARCHAR(50),
-    Timezone VARCHAR(10),
+    Timezone VARCHAR(64),  -- Nome IANA ou deslocamento UTC
     LaserDiodeCurrent FLOAT,
     LOS FLOAT,
     InstallationOffsetAGL FLOAT,
@@ -282,6 +293,9 @@ CREATE TABLE IF NOT EXISTS LIDARWindCubeHeaders (
     UploadDate TIMESTAMPTZ DEFAULT now()
 );
 
+-- Bancos criados com Timezone VARCHAR(10) não comportavam nomes IANA ("America/Sao_Paulo")
+ALTER TABLE LIDARWindCubeHeaders ALTER COLUMN Timezone TYPE VARCHAR(64);
+
 
 
 
@@ -304,6 +318,7 @@ CREATE TABLE IF NOT EXISTS EstacaoSolarimetricaDados (
     EstacaoSolarimetricaDadosID SERIAL PRIMARY KEY,
     EquipmentID UUID REFERENCES Equipments(EquipmentID),  -- Referência ao equipamento
     CampaignID UUID REFERENCES Campaigns(CampaignID),     -- Referência à campanha
+    IngestionJobID UUID,                                  -- Job de importação que gravou a linha (IngestionJobs)
     timestamp TIMESTAMPTZ NOT NULL,                       -- Timestamp da leitura
     BattV FLOAT,                                          -- Bateria em volts
     PTemp_C FLOAT,                                        -- Temperatura do painel em graus Celsius
@@ -343,12 +358,17 @@ CREATE TABLE IF NOT EXISTS EstacaoSolarimetricaDados (
 -- Criar uma hypertable no TimescaleDB para particionamento dos dados por mês
 SELECT create_hypertable('EstacaoSolarimetricaDados', 'timestamp', chunk_time_interval => interval '1 month');
 
+-- Impede timestamps duplicados por equipamento, inclusive entre importações simultâneas de arquivos sobrepostos
+CREATE UNIQUE INDEX IF NOT EXISTS idx_estacaosolarimetricadados_equipment_timestamp ON EstacaoSolarimetricaDados (EquipmentID, timestamp);
+
 
 -- Tabela de Dados do LIDAR WindCube
 CREATE TABLE IF NOT EXISTS LIDARWindCubeDados (
     LIDARWindCubeDadosID SERIAL PRIMARY KEY,
     EquipmentID UUID REFERENCES Equipments(EquipmentID),
     CampaignID UUID REFERENCES Campaigns(CampaignID),
+    IngestionJobID UUID,  -- Job de importação que gravou a linha (IngestionJobs)
+    WindCubeHeaderID UUID REFERENCES LIDARWindCubeHeaders(WindCubeHeaderID),  -- Cabeçalho do arquivo de origem (CNRThreshold)
     timestamp TIMESTAMPTZ NOT NULL,
     IntTemp FLOAT,  -- Temperatura interna (°C)
     ExtTemp FLOAT,  -- Temperatura externa (°C)
@@ -626,6 +646,7 @@ CREATE TABLE IF NOT EXISTS SODARDados (
     SODARDadosID SERIAL PRIMARY KEY,
     EquipmentID UUID REFERENCES Equipments(EquipmentID),
     CampaignID UUID REFERENCES Campaigns(CampaignID),
+    IngestionJobID UUID,  -- Job de importação que gravou a linha (IngestionJobs)
     timestamp TIMESTAMPTZ NOT NULL,
     Height FLOAT,
     WindSpeed FLOAT,
@@ -658,3 +679,269 @@ CREATE TABLE IF NOT EXISTS SODARDados (
 
 -- Criação da Hypertable para SODARDados
 SELECT create_hypertable('SODARDados', 'timestamp', chunk_time_interval => interval '1 month');
+
+-- Cabeçalhos dos arquivos PD0 do ADCP (configuração do fixed leader e diagnóstico da decodificação)
+CREATE TABLE IF NOT EXISTS ADCPHeaders (
+    ADCPHeaderID UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    EquipmentID UUID REFERENCES Equipments(EquipmentID),
+    CampaignID UUID REFERENCES Campaigns(CampaignID),
+    FileName VARCHAR(255),
+    SerialNumber VARCHAR(50),
+    FirmwareVersion VARCHAR(50),
+    Frequency INT,                -- Frequência do sistema (kHz)
+    BeamAngle INT,                -- Ângulo dos feixes (°)
+    NumberOfBeams INT,
+    NumberOfCells INT,
+    CellSize FLOAT,               -- Tamanho da célula (m)
+    Blank FLOAT,                  -- Distância em branco (m)
+    Bin1Distance FLOAT,           -- Distância ao centro da primeira célula (m)
+    PingsPerEnsemble INT,
+    CoordinateSystem VARCHAR(20), -- beam, instrument, ship ou earth
+    BeamsFacingUp BOOLEAN,
+    EnsemblesRead INT,            -- Ensembles decodificados
+    ChecksumFailures INT,         -- Ensembles com checksum inválido
+    EnsemblesDropped INT,         -- Ensembles descartados
+    UploadDate TIMESTAMPTZ DEFAULT now()
+);
+
+-- Uma linha por ensemble e célula de profundidade
+CREATE TABLE IF NOT EXISTS ADCPDados (
+    ADCPDadosID SERIAL,
+    EquipmentID UUID REFERENCES Equipments(EquipmentID),
+    CampaignID UUID REFERENCES Campaigns(CampaignID),
+    IngestionJobID UUID,          -- Job de importação que gravou a linha (IngestionJobs)
+    timestamp TIMESTAMPTZ NOT NULL,
+    EnsembleNumber INT,
+    Cell INT,                     -- Número da célula (1 = mais próxima do transdutor)
+    Distance FLOAT,               -- Distância do transdutor ao centro da célula (m)
+    Depth FLOAT,                  -- Profundidade do centro da célula (m)
+    Velocity1 FLOAT,              -- Velocidade do feixe 1 ou leste, conforme o sistema de coordenadas (m/s)
+    Velocity2 FLOAT,              -- Velocidade do feixe 2 ou norte (m/s)
+    Velocity3 FLOAT,              -- Velocidade do feixe 3 ou vertical (m/s)
+    Velocity4 FLOAT,              -- Velocidade do feixe 4 ou erro (m/s)
+    CurrentSpeed FLOAT,           -- Velocidade horizontal da corrente, apenas em coordenadas terrestres (m/s)
+    CurrentDirection FLOAT,       -- Direção para onde a corrente flui (°)
+    Correlation1 INT,
+    Correlation2 INT,
+    Correlation3 INT,
+    Correlation4 INT,
+    EchoIntensity1 INT,
+    EchoIntensity2 INT,
+    EchoIntensity3 INT,
+    EchoIntensity4 INT,
+    PercentGood1 INT,
+    PercentGood2 INT,
+    PercentGood3 INT,
+    PercentGood4 INT,
+    Heading FLOAT,                -- (°)
+    Pitch FLOAT,                  -- (°)
+    Roll FLOAT,                   -- (°)
+    WaterTemperature FLOAT,       -- (°C)
+    Salinity FLOAT,               -- (ppt)
+    Pressure FLOAT,               -- (dbar)
+    TransducerDepth FLOAT,        -- (m)
+    SpeedOfSound FLOAT,           -- (m/s)
+    PRIMARY KEY (ADCPDadosID, timestamp)
+);
+
+-- Criação da Hypertable para ADCPDados
+SELECT create_hypertable('ADCPDados', 'timestamp', chunk_time_interval => interval '1 month');
+
+-- Códigos de qualidade (QARTOD: 1 good, 2 not_evaluated, 3 suspect, 4 bad, 9 missing) por valor
+-- de cada linha das tabelas de dados, gravados pelo pacote qc após cada importação
+CREATE TABLE IF NOT EXISTS QCFlags (
+    DataTable VARCHAR(64) NOT NULL,   -- Tabela de dados avaliada (em minúsculas)
+    RowID BIGINT NOT NULL,            -- Chave da linha avaliada na tabela de dados
+    EquipmentID UUID NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL,   -- Timestamp da linha avaliada
+    Flags JSONB NOT NULL,             -- Código por coluna: {"coluna": código}
+    Tests JSONB,                      -- Testes reprovados por coluna: {"coluna": ["range", "spike"]}
+    WorstFlag SMALLINT NOT NULL,      -- Pior código entre os valores presentes
+    EvaluatedAt TIMESTAMPTZ DEFAULT now(),
+    PRIMARY KEY (DataTable, RowID, timestamp)
+);
+
+-- Criação da Hypertable para QCFlags
+SELECT create_hypertable('QCFlags', 'timestamp', chunk_time_interval => interval '1 month');
+CREATE INDEX IF NOT EXISTS idx_qcflags_equipment ON QCFlags (DataTable, EquipmentID, timestamp);
+
+-- Densidade do ar úmido (kg/m³) pela lei dos gases ideais para as parcelas de ar seco e de vapor.
+-- Pressão em hPa, temperatura em °C e umidade relativa em %; sem umidade, considera ar seco.
+-- A pressão de saturação do vapor segue a fórmula de Tetens.
+CREATE OR REPLACE FUNCTION moist_air_density(pressure_hpa FLOAT, temperature_c FLOAT, rh_percent FLOAT)
+RETURNS FLOAT AS $$
+    SELECT (pressure_hpa - e) * 100 / (287.058 * (temperature_c + 273.15))
+         + e * 100 / (461.495 * (temperature_c + 273.15))
+    FROM (SELECT COALESCE(rh_percent, 0) / 100 * 6.1078 * power(10, 7.5 * temperature_c / (temperature_c + 237.3)) AS e) v
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- Séries de referência de longo prazo (nó de reanálise, estação meteorológica) importadas de arquivo
+-- para a correção de longo prazo (MCP) das campanhas
+CREATE TABLE IF NOT EXISTS ReferenceSeries (
+    ReferenceSeriesID UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    Name VARCHAR(255) NOT NULL,
+    Source VARCHAR(100),              -- Origem dos dados (ERA5, MERRA-2, estação...)
+    FileName VARCHAR(255),
+    FileType VARCHAR(20),             -- CSV ou NetCDF
+    Latitude FLOAT,                   -- Coordenadas do nó ou da estação (°)
+    Longitude FLOAT,
+    Height FLOAT,                     -- Altura do vento (m)
+    CreatedAt TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS ReferenceSeriesData (
+    ReferenceSeriesID UUID NOT NULL REFERENCES ReferenceSeries(ReferenceSeriesID) ON DELETE CASCADE,
+    timestamp TIMESTAMPTZ NOT NULL,
+    WindSpeed FLOAT,                  -- m/s
+    WindDirection FLOAT,              -- Direção de origem (°)
+    PRIMARY KEY (ReferenceSeriesID, timestamp)
+);
+
+-- Criação da Hypertable para ReferenceSeriesData
+SELECT create_hypertable('ReferenceSeriesData', 'timestamp', chunk_time_interval => interval '1 year');
+
+-- Falhas de registro detectadas pelo pacote availability em cada tabela de dados de um equipamento.
+-- Start e End são o último registro antes e o primeiro depois da falha; em falhas abertas (equipamento
+-- em operação sem registros recentes), End é o momento da varredura.
+CREATE TABLE IF NOT EXISTS DataGaps (
+    GapID UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    EquipmentID UUID NOT NULL REFERENCES Equipments(EquipmentID) ON DELETE CASCADE,
+    DataTable VARCHAR(64) NOT NULL,   -- Tabela de dados varrida (em minúsculas)
+    GapStart TIMESTAMPTZ NOT NULL,
+    GapEnd TIMESTAMPTZ NOT NULL,
+    MissingSamples INTEGER NOT NULL,  -- Registros esperados ausentes
+    IsOutage BOOLEAN NOT NULL,        -- Falha com duração a partir do limiar de interrupção
+    IsOpen BOOLEAN NOT NULL DEFAULT FALSE,
+    DetectedAt TIMESTAMPTZ DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_datagaps_equipment ON DataGaps (EquipmentID, DataTable, GapStart);
+
+-- Usuários responsáveis por um equipamento, que recebem os alertas dele
+CREATE TABLE IF NOT EXISTS EquipmentResponsibles (
+    EquipmentID UUID NOT NULL REFERENCES Equipments(EquipmentID) ON DELETE CASCADE,
+    id_usuario UUID NOT NULL REFERENCES Usuarios(id_usuario) ON DELETE CASCADE,
+    PRIMARY KEY (EquipmentID, id_usuario)
+);
+
+-- Regras de alerta avaliadas pelo pacote alerts após cada importação e periodicamente.
+-- threshold: Column Operator Threshold em todos os registros dos últimos Duration segundos;
+-- no_data: nenhum registro na tabela há Duration segundos.
+CREATE TABLE IF NOT EXISTS AlertRules (
+    AlertRuleID UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    Name VARCHAR(255) NOT NULL,
+    EquipmentID UUID REFERENCES Equipments(EquipmentID) ON DELETE CASCADE,  -- Sem equipamento: todos os que têm dados na tabela
+    DataTable VARCHAR(64) NOT NULL,   -- Tabela de dados avaliada (em minúsculas)
+    Kind VARCHAR(20) NOT NULL CHECK (Kind IN ('threshold', 'no_data')),
+    ColumnName VARCHAR(64),           -- Coluna comparada (threshold)
+    Operator VARCHAR(2) CHECK (Operator IN ('<', '<=', '>', '>=')),
+    Threshold FLOAT,
+    Level FLOAT,                      -- Altura ou célula nas tabelas com um registro por nível; sem valor, qualquer nível
+    Duration INTEGER NOT NULL DEFAULT 0 CHECK (Duration >= 0),  -- Segundos
+    Enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    CreatedBy UUID REFERENCES Usuarios(id_usuario) ON DELETE SET NULL,
+    CreatedAt TIMESTAMPTZ DEFAULT now()
+);
+
+-- Histórico de disparos: um disparo fica 'firing' enquanto a condição persiste e passa a 'resolved'
+-- quando ela deixa de valer. O índice único impede disparos abertos duplicados. Revision recebe um novo
+-- valor da sequência a cada mudança e serve de cursor para o /api/stream.
+CREATE SEQUENCE IF NOT EXISTS alertfirings_revision_seq;
+CREATE TABLE IF NOT EXISTS AlertFirings (
+    AlertFiringID UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    AlertRuleID UUID NOT NULL REFERENCES AlertRules(AlertRuleID) ON DELETE CASCADE,
+    EquipmentID UUID NOT NULL REFERENCES Equipments(EquipmentID) ON DELETE CASCADE,
+    Status VARCHAR(10) NOT NULL CHECK (Status IN ('firing', 'resolved')),
+    StartedAt TIMESTAMPTZ NOT NULL,   -- Início da condição (primeiro registro violando ou último registro recebido)
+    FiredAt TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ResolvedAt TIMESTAMPTZ,
+    Value FLOAT,                      -- Último valor avaliado (threshold)
+    Message TEXT NOT NULL,
+    Revision BIGINT NOT NULL DEFAULT nextval('alertfirings_revision_seq')  -- Renovada a cada mudança de situação
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_alertfirings_open ON AlertFirings (AlertRuleID, EquipmentID) WHERE Status = 'firing';
+CREATE INDEX IF NOT EXISTS idx_alertfirings_rule ON AlertFirings (AlertRuleID, FiredAt);
+CREATE INDEX IF NOT EXISTS idx_alertfirings_revision ON AlertFirings (EquipmentID, Revision);
+
+-- Jobs de importação de arquivos (uploads e cmd/ingestd). O índice único sobre o SHA-256 dos jobs que
+-- não falharam torna o reenvio de um arquivo idêntico uma operação sem efeito; as linhas gravadas
+-- levam o IngestionJobID, o que permite desfazer exatamente a importação ao remover o job.
+CREATE TABLE IF NOT EXISTS IngestionJobs (
+    IngestionJobID UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    SHA256 CHAR(64) NOT NULL,
+    FileName VARCHAR(255) NOT NULL,
+    FileSize BIGINT NOT NULL,
+    Parser VARCHAR(16) NOT NULL,      -- TOA5, WINDCUBE, SODAR ou PD0
+    Source VARCHAR(16) NOT NULL DEFAULT 'upload' CHECK (Source IN ('upload', 'ingestd')),
+    EquipmentID UUID REFERENCES Equipments(EquipmentID) ON DELETE SET NULL,
+    CampaignID UUID REFERENCES Campaigns(CampaignID) ON DELETE SET NULL,
+    Status VARCHAR(10) NOT NULL DEFAULT 'queued' CHECK (Status IN ('queued', 'running', 'succeeded', 'failed')),
+    FileType VARCHAR(16),             -- Formato detectado pelo parser (STA, RTD, MND, ...)
+    HeaderID UUID,                    -- Cabeçalho criado pela importação
+    RowsInserted BIGINT,
+    RowsDuplicate BIGINT,
+    RowsSkipped INTEGER,
+    DataStart TIMESTAMPTZ,            -- Primeiro e último timestamps lidos do arquivo
+    DataEnd TIMESTAMPTZ,
+    Warnings JSONB,
+    Error TEXT,
+    SubmittedBy UUID REFERENCES Usuarios(id_usuario) ON DELETE SET NULL,
+    CreatedAt TIMESTAMPTZ NOT NULL DEFAULT now(),
+    StartedAt TIMESTAMPTZ,
+    FinishedAt TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ingestionjobs_sha256 ON IngestionJobs (SHA256) WHERE Status <> 'failed';
+CREATE INDEX IF NOT EXISTS idx_ingestionjobs_created ON IngestionJobs (CreatedAt);
+CREATE INDEX IF NOT EXISTS idx_estacaosolarimetricadados_job ON EstacaoSolarimetricaDados (IngestionJobID) WHERE IngestionJobID IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_lidarwindcubedados_job ON LIDARWindCubeDados (IngestionJobID) WHERE IngestionJobID IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_sodardados_job ON SODARDados (IngestionJobID) WHERE IngestionJobID IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_adcpdados_job ON ADCPDados (IngestionJobID) WHERE IngestionJobID IS NOT NULL;
+
+-- Arquivos importados pelo cmd/ingestd a partir dos diretórios monitorados. O índice único sobre o
+-- SHA-256 dos arquivos em processamento ou importados impede que o mesmo conteúdo seja importado duas
+-- vezes, inclusive após reinícios; tentativas com falha ficam registradas e podem ser repetidas.
+CREATE TABLE IF NOT EXISTS WatchedFiles (
+    WatchedFileID UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    SHA256 CHAR(64) NOT NULL,
+    FileName VARCHAR(255) NOT NULL,
+    SourcePath TEXT NOT NULL,         -- Caminho no diretório monitorado
+    FileSize BIGINT NOT NULL,
+    Format VARCHAR(16),               -- TOA5, WINDCUBE, SODAR ou PD0
+    EquipmentID UUID REFERENCES Equipments(EquipmentID) ON DELETE SET NULL,
+    CampaignID UUID REFERENCES Campaigns(CampaignID) ON DELETE SET NULL,
+    HeaderID UUID,                    -- Cabeçalho criado pela importação
+    IngestionJobID UUID REFERENCES IngestionJobs(IngestionJobID) ON DELETE SET NULL,
+    Status VARCHAR(12) NOT NULL CHECK (Status IN ('processing', 'ingested', 'failed')),
+    RowsInserted BIGINT,
+    RowsDuplicate BIGINT,
+    RowsSkipped INTEGER,
+    Error TEXT,
+    StartedAt TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FinishedAt TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_watchedfiles_sha256 ON WatchedFiles (SHA256) WHERE Status IN ('processing', 'ingested');
+
+-- Mensagens MQTT rejeitadas pelo cmd/mqttd (sem rota, fora do modelo da tabela, equipamento
+-- inexistente ou recusadas pelo banco), guardadas para análise e reprocessamento
+CREATE TABLE IF NOT EXISTS MQTTDeadLetters (
+    DeadLetterID UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    Topic TEXT NOT NULL,
+    Payload BYTEA NOT NULL,
+    Reason TEXT NOT NULL,
+    ReceivedAt TIMESTAMPTZ NOT NULL,
+    StoredAt TIMESTAMPTZ DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_mqttdeadletters_received ON MQTTDeadLetters (ReceivedAt);
+
+-- As tabelas que recebem leituras pelo cmd/mqttd não são criadas por este script. Quando existem,
+-- ganham o índice único que descarta as leituras reentregues pelo broker (ON CONFLICT DO NOTHING).
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['towermicrometeorologicaldata', 'sodardata', 'adcpdata', 'lidarzephydata', 'lidarwindcobedata'] LOOP
+        IF to_regclass(t) IS NOT NULL THEN
+            EXECUTE format('CREATE UNIQUE INDEX IF NOT EXISTS %I ON %I (EquipmentID, timestamp)',
+                           'idx_' || t || '_equipment_timestamp', t);
+        END IF;
+    END LOOP;
+END $$;